@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessRule restricts the API paths starting with Path to the clients in
+// Allow (IP addresses or CIDR ranges; empty allows everyone) and limits each
+// client to RateLimit requests per minute (0 for no limit). The rule with the
+// longest matching Path applies; requests from this machine are never
+// blocked.
+type AccessRule struct {
+	Path      string   `json:"path"`
+	Allow     []string `json:"allow,omitempty"`
+	RateLimit int      `json:"rateLimit,omitempty"`
+}
+
+// rateBucket is the token bucket of one client under one rule.
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+var (
+	rateBucketsMu sync.Mutex
+	rateBuckets   = map[string]*rateBucket{}
+	rateBucketsAt time.Time
+)
+
+// accessMiddleware applies accessRules before any other check, so that
+// blocked and throttled clients do not get to try API keys.
+func accessMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule := accessRule(r.URL.Path)
+		addr, ok := clientAddr(r)
+		if rule == nil || (ok && addr.IsLoopback()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !ok || !addressAllowed(addr, rule.Allow) {
+			rejectRequest(w, r, http.StatusForbidden, "Access from this address is not allowed")
+			return
+		}
+		if rule.RateLimit > 0 {
+			if wait, ok := takeRequest(rule.Path+" "+addr.String(), rule.RateLimit, time.Now()); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				rejectRequest(w, r, http.StatusTooManyRequests, "Too many requests, try again later")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// accessRule returns the rule with the longest path that matches, or nil.
+func accessRule(path string) *AccessRule {
+	var match *AccessRule
+	rules := config().AccessRules
+	for i, rule := range rules {
+		if strings.HasPrefix(path, rule.Path) && (match == nil || len(rule.Path) > len(match.Path)) {
+			match = &rules[i]
+		}
+	}
+	return match
+}
+
+func clientAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// addressAllowed reports whether addr is one of the addresses or ranges in
+// allow. validateAccessRules has already rejected malformed entries.
+func addressAllowed(addr netip.Addr, allow []string) bool {
+	if len(allow) == 0 {
+		return true
+	}
+	for _, entry := range allow {
+		if prefix, err := parseAllowEntry(entry); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAllowEntry reads an IP address or CIDR range as a prefix.
+func parseAllowEntry(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// takeRequest takes a request from the bucket under key, which holds up to
+// perMinute requests and refills at that rate. When the bucket is empty it
+// returns how long until the next request is allowed.
+func takeRequest(key string, perMinute int, now time.Time) (time.Duration, bool) {
+	rateBucketsMu.Lock()
+	defer rateBucketsMu.Unlock()
+
+	// A bucket left alone for a minute is full again and can be dropped.
+	if now.Sub(rateBucketsAt) > time.Minute {
+		for k, b := range rateBuckets {
+			if now.Sub(b.last) > time.Minute {
+				delete(rateBuckets, k)
+			}
+		}
+		rateBucketsAt = now
+	}
+
+	limit := float64(perMinute)
+	b, ok := rateBuckets[key]
+	if !ok {
+		b = &rateBucket{tokens: limit, last: now}
+		rateBuckets[key] = b
+	}
+	b.tokens = min(limit, b.tokens+now.Sub(b.last).Minutes()*limit)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / limit * float64(time.Minute)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// rejectRequest answers in the OpenAI error format on /v1/ and as an API
+// response everywhere else.
+func rejectRequest(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		writeOpenAIError(w, status, message)
+		return
+	}
+	writeJSON(w, status, APIResponse{Success: false, Message: message})
+}
+
+// validateAccessRules checks the paths, addresses and limits of accessRules.
+func validateAccessRules(c *Config) error {
+	for i, rule := range c.AccessRules {
+		field := fmt.Sprintf("accessRules[%d]", i)
+		if !strings.HasPrefix(rule.Path, "/") {
+			return fmt.Errorf("%s.path: %q must start with /, e.g. /v1/ or /api/load", field, rule.Path)
+		}
+		for _, entry := range rule.Allow {
+			if _, err := parseAllowEntry(entry); err != nil {
+				return fmt.Errorf("%s.allow: invalid address %q, expected an IP address or a range such as 192.168.1.0/24", field, entry)
+			}
+		}
+		if rule.RateLimit < 0 {
+			return fmt.Errorf("%s.rateLimit: must be 0 or more requests per minute", field)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// resolveAlias returns the model or configuration name an alias stands for,
+// or name itself when it is not an alias. An alias takes precedence over a
+// model of the same name.
+func resolveAlias(name string) string {
+	for alias, target := range config().Aliases {
+		if strings.EqualFold(alias, name) {
+			return target
+		}
+	}
+	return name
+}
+
+// aliasesOf returns the aliases of a model or configuration name, sorted.
+func aliasesOf(name string) []string {
+	var aliases []string
+	for _, alias := range slices.Sorted(maps.Keys(config().Aliases)) {
+		if strings.EqualFold(config().Aliases[alias], name) {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// validateAliases rejects empty names, aliases of aliases and aliases
+// differing only in case, which would resolve unpredictably.
+func validateAliases(c *Config) error {
+	seen := map[string]string{}
+	for alias, target := range c.Aliases {
+		if strings.TrimSpace(alias) == "" || strings.TrimSpace(target) == "" {
+			return fmt.Errorf("aliases: names and targets must not be empty")
+		}
+		if other, ok := seen[strings.ToLower(alias)]; ok {
+			return fmt.Errorf("aliases: %q and %q differ only in case", other, alias)
+		}
+		seen[strings.ToLower(alias)] = alias
+	}
+	for alias, target := range c.Aliases {
+		if _, ok := seen[strings.ToLower(target)]; ok {
+			return fmt.Errorf("aliases.%s: %q is itself an alias; point it at a model or configuration name", alias, target)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows && embedserver
+
+package main
+
+import "embed"
+
+// Build with -tags embedserver after placing the llama.cpp release archive
+// for the target platform next to main.go.
+//
+//go:embed llama-*
+var serverArchives embed.FS
@@ -0,0 +1,9 @@
+//go:build !windows && !embedserver
+
+package main
+
+import "embed"
+
+// Without the embedserver tag no archive is embedded and llama-server is
+// taken from serverPath, server/ or PATH.
+var serverArchives embed.FS
@@ -0,0 +1,11 @@
+//go:build windows && !noembedserver
+
+package main
+
+import "embed"
+
+// The llama.cpp release archive placed next to main.go is embedded unless
+// lmgo is built with -tags noembedserver.
+//
+//go:embed *.zip
+var serverArchives embed.FS
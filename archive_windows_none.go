@@ -0,0 +1,9 @@
+//go:build windows && noembedserver
+
+package main
+
+import "embed"
+
+// With the noembedserver tag no archive is embedded and llama-server is
+// taken from serverPath, server/ or PATH.
+var serverArchives embed.FS
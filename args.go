@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// flagGroups lists llama-server flags that configure the same setting. Setting
+// any member of a group replaces every other member already present.
+var flagGroups = [][]string{
+	{"-np", "--parallel"},
+	{"-cb", "--cont-batching", "-nocb", "--no-cont-batching"},
+	{"-c", "--ctx-size"},
+	{"-ngl", "--gpu-layers", "--n-gpu-layers"},
+	{"--host"},
+	{"--port"},
+}
+
+// canonicalFlag returns the first name of the group flag belongs to, with any
+// "=value" removed, so "--ctx-size=4096" and "-c" compare equal.
+func canonicalFlag(flag string) string {
+	flag, _, _ = strings.Cut(flag, "=")
+	for _, group := range flagGroups {
+		for _, name := range group {
+			if name == flag {
+				return group[0]
+			}
+		}
+	}
+	return flag
+}
+
+func isFlag(arg string) bool {
+	if !strings.HasPrefix(arg, "-") {
+		return false
+	}
+	_, err := strconv.ParseFloat(arg, 64)
+	return err != nil
+}
+
+// splitArgs groups an argument list into flags with their values, e.g.
+// ["-np", "4", "--mlock"] becomes [["-np", "4"], ["--mlock"]].
+func splitArgs(args []string) [][]string {
+	var groups [][]string
+	for _, arg := range args {
+		if isFlag(arg) || len(groups) == 0 {
+			groups = append(groups, []string{arg})
+			continue
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], arg)
+	}
+	return groups
+}
+
+// mergeArgs returns base with every flag that also appears in overrides
+// (including its aliases and value) replaced by the override. A flag of
+// flagGroups takes one value, so only its last occurrence is kept even when
+// one list repeats it; other flags, such as --lora, may repeat within a list.
+func mergeArgs(base []string, overrides []string) []string {
+	overridden := map[string]bool{}
+	for _, group := range splitArgs(overrides) {
+		overridden[canonicalFlag(group[0])] = true
+	}
+
+	var groups [][]string
+	for _, group := range splitArgs(base) {
+		if !overridden[canonicalFlag(group[0])] {
+			groups = append(groups, group)
+		}
+	}
+	groups = append(groups, splitArgs(overrides)...)
+
+	last := map[string]int{}
+	for i, group := range groups {
+		last[canonicalFlag(group[0])] = i
+	}
+	result := []string{}
+	for i, group := range groups {
+		flag := canonicalFlag(group[0])
+		if isGroupedFlag(flag) && last[flag] != i {
+			continue
+		}
+		result = append(result, group...)
+	}
+	return result
+}
+
+func isGroupedFlag(flag string) bool {
+	for _, group := range flagGroups {
+		if group[0] == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// parallelArgs returns the flags for a parallel-slots preset. Multiple slots
+// need continuous batching to be served concurrently.
+func parallelArgs(slots int) []string {
+	args := []string{"-np", strconv.Itoa(slots)}
+	if slots > 1 {
+		args = append(args, "--cont-batching")
+	}
+	return args
+}
+
+const (
+	maxOverrideArgs      = 64
+	maxOverrideArgLength = 512
+)
+
+// managedFlags are set by lmgo for every launch and cannot be overridden
+// through the API.
+var managedFlags = []string{"-m", "--model", "--port"}
+
+// validateArgsOverride checks the args of a load request before they are
+// passed to llama-server.
+func validateArgsOverride(args []string) error {
+	if len(args) > maxOverrideArgs {
+		return fmt.Errorf("too many args (at most %d)", maxOverrideArgs)
+	}
+	for _, arg := range args {
+		if len(arg) > maxOverrideArgLength {
+			return fmt.Errorf("arg too long (at most %d characters)", maxOverrideArgLength)
+		}
+		if strings.ContainsAny(arg, "\x00\r\n") {
+			return fmt.Errorf("arg %q contains a control character", arg)
+		}
+		name, _, _ := strings.Cut(arg, "=")
+		for _, flag := range managedFlags {
+			if name == flag {
+				return fmt.Errorf("%s is set by lmgo and cannot be overridden", flag)
+			}
+		}
+	}
+	return nil
+}
+
+// deniedRequestFlags cannot be passed in the args of a load request: they
+// would bypass Allow LAN Access, change the API key, serve a directory, read
+// or write files or fetch models from the network. Entries ending in "*"
+// match by prefix.
+var deniedRequestFlags = []string{
+	"--host",
+	"--api-key*",
+	"--path",
+	"--log-file",
+	"--slot-save-path",
+	"-hf*",
+	"--hf-*",
+	"-mu",
+	"--model-url",
+	"-dr",
+	"--docker-repo",
+	"-mm",
+	"--mmproj",
+	"-mmu",
+	"--mmproj-url",
+	"-md",
+	"--model-draft",
+	"--lora*",
+	"--control-vector*",
+	"--chat-template-file",
+	"-f",
+	"--file",
+	"--grammar-file",
+	"-jf",
+	"--json-schema-file",
+	"-lcs",
+	"--lookup-cache-static",
+	"-lcd",
+	"--lookup-cache-dynamic",
+	"--ssl-*",
+}
+
+func isDeniedRequestFlag(name string) bool {
+	for _, denied := range deniedRequestFlags {
+		prefix, wildcard := strings.CutSuffix(denied, "*")
+		if name == denied || wildcard && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRequestArgs checks the args of a load request sent to the API,
+// which are more restricted than those typed in the tray. A denied flag is
+// accepted with the value configured for the model, as in args edited from
+// those of GET /api/args.
+func validateRequestArgs(args, configured []string) error {
+	if err := validateArgsOverride(args); err != nil {
+		return err
+	}
+	known := splitArgs(configured)
+	for _, group := range splitArgs(args) {
+		name, _, _ := strings.Cut(group[0], "=")
+		if !isFlag(name) || !isDeniedRequestFlag(name) {
+			continue
+		}
+		if !slices.ContainsFunc(known, func(k []string) bool { return slices.Equal(k, group) }) {
+			return fmt.Errorf("%s cannot be set through the API", name)
+		}
+	}
+	return nil
+}
+
+// joinArgs renders args as one editable line, quoting those that contain
+// spaces or quotes.
+func joinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"\\") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// parseArgs splits a line edited by the user back into arguments. Double
+// quotes group words, and a backslash escapes the next character inside
+// them.
+func parseArgs(line string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inQuotes, hasArg, escaped := false, false, false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case inQuotes && r == '\\':
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+			hasArg = true
+		case !inQuotes && (r == ' ' || r == '\t'):
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if inQuotes {
+		return nil, errors.New("unterminated quote in args")
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
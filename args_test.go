@@ -0,0 +1,128 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      []string
+		overrides []string
+		want      []string
+	}{
+		{"no overrides", []string{"-c", "4096", "--mlock"}, nil, []string{"-c", "4096", "--mlock"}},
+		{"no base", nil, []string{"-np", "2"}, []string{"-np", "2"}},
+		{"same flag", []string{"-np", "1", "--mlock"}, []string{"-np", "4"}, []string{"--mlock", "-np", "4"}},
+		{"-np replaced by --parallel", []string{"-np", "1", "--mlock"}, []string{"--parallel", "4"}, []string{"--mlock", "--parallel", "4"}},
+		{"--parallel replaced by -np", []string{"--parallel", "4"}, []string{"-np", "2"}, []string{"-np", "2"}},
+		{"-c replaced by --ctx-size", []string{"-c", "4096", "-ngl", "99"}, []string{"--ctx-size", "8192"}, []string{"-ngl", "99", "--ctx-size", "8192"}},
+		{"--ctx-size replaced by -c", []string{"--ctx-size", "8192"}, []string{"-c", "4096"}, []string{"-c", "4096"}},
+		{"--ctx-size=value replaced", []string{"--ctx-size=8192", "--mlock"}, []string{"--ctx-size", "4096"}, []string{"--mlock", "--ctx-size", "4096"}},
+		{"replaced by --ctx-size=value", []string{"-c", "8192"}, []string{"--ctx-size=4096"}, []string{"--ctx-size=4096"}},
+		{"-ngl aliases", []string{"--n-gpu-layers", "10", "--gpu-layers", "20"}, []string{"-ngl", "99"}, []string{"-ngl", "99"}},
+		{"-nocb replaced by -cb", []string{"-nocb"}, []string{"-cb"}, []string{"-cb"}},
+		{"ungrouped flag", []string{"--temp", "0.7", "-c", "4096"}, []string{"--temp", "0.2"}, []string{"-c", "4096", "--temp", "0.2"}},
+		{"negative value", []string{"-ngl", "-1"}, []string{"-c", "2048"}, []string{"-ngl", "-1", "-c", "2048"}},
+		{"base repeats a grouped flag", []string{"-c", "2048", "--mlock", "--ctx-size", "4096"}, nil, []string{"--mlock", "--ctx-size", "4096"}},
+		{"overrides repeat a grouped flag", nil, []string{"-np", "2", "--parallel", "4"}, []string{"--parallel", "4"}},
+		{"repeatable flag kept", []string{"--lora", "a.gguf", "--lora", "b.gguf"}, []string{"-c", "4096"}, []string{"--lora", "a.gguf", "--lora", "b.gguf", "-c", "4096"}},
+		{"repeatable flag replaced", []string{"--lora", "a.gguf", "--lora", "b.gguf"}, []string{"--lora", "c.gguf"}, []string{"--lora", "c.gguf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeArgs(tt.base, tt.overrides)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("mergeArgs(%q, %q) = %q, want %q", tt.base, tt.overrides, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMergeArgsEmitsGroupedFlagsOnce merges every pair of flag group members
+// in both orders and checks that the setting appears exactly once.
+func TestMergeArgsEmitsGroupedFlagsOnce(t *testing.T) {
+	for _, group := range flagGroups {
+		for _, a := range group {
+			for _, b := range group {
+				got := mergeArgs([]string{a, "1", "--mlock", b, "2"}, []string{b, "3"})
+				count := 0
+				for _, arg := range got {
+					if canonicalFlag(arg) == group[0] {
+						count++
+					}
+				}
+				if count != 1 {
+					t.Errorf("merging %s and %s gave %q, want %s once", a, b, got, group[0])
+				}
+			}
+		}
+	}
+}
+
+func TestMergeArgsDoesNotModifyInputs(t *testing.T) {
+	base := []string{"-c", "4096", "--mlock"}
+	overrides := []string{"--ctx-size", "8192"}
+	mergeArgs(base, overrides)
+	if !slices.Equal(base, []string{"-c", "4096", "--mlock"}) || !slices.Equal(overrides, []string{"--ctx-size", "8192"}) {
+		t.Errorf("inputs changed to %q and %q", base, overrides)
+	}
+}
+
+func TestValidateRequestArgs(t *testing.T) {
+	configured := []string{"-ngl", "99", "--mmproj", "/models/mmproj.gguf", "-md", "/models/draft.gguf"}
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"tuning flags", []string{"-c", "8192", "-np", "4", "--temp", "0.7", "--mlock"}, false},
+		{"none", nil, false},
+		{"managed --port", []string{"--port", "9000"}, true},
+		{"managed -m", []string{"-m", "/etc/passwd"}, true},
+		{"--host", []string{"--host", "0.0.0.0"}, true},
+		{"--host=", []string{"--host=0.0.0.0"}, true},
+		{"--api-key", []string{"--api-key", "x"}, true},
+		{"--api-key-file", []string{"--api-key-file", "/tmp/keys"}, true},
+		{"--path", []string{"--path", "/"}, true},
+		{"--path=", []string{"--path=/home"}, true},
+		{"--log-file", []string{"--log-file", "/tmp/x"}, true},
+		{"--slot-save-path", []string{"--slot-save-path", "/tmp"}, true},
+		{"-hf", []string{"-hf", "org/repo"}, true},
+		{"-hfr", []string{"-hfr", "org/repo"}, true},
+		{"--hf-repo", []string{"--hf-repo=org/repo"}, true},
+		{"--hf-token", []string{"--hf-token", "x"}, true},
+		{"-mu", []string{"-mu", "https://example.com/m.gguf"}, true},
+		{"--model-url", []string{"--model-url", "https://example.com/m.gguf"}, true},
+		{"-dr", []string{"-dr", "ai/smollm2"}, true},
+		{"--docker-repo", []string{"--docker-repo", "ai/smollm2"}, true},
+		{"-mmu", []string{"-mmu", "https://example.com/p.gguf"}, true},
+		{"--mmproj-url", []string{"--mmproj-url", "https://example.com/p.gguf"}, true},
+		{"--mmproj elsewhere", []string{"--mmproj", "/etc/shadow"}, true},
+		{"-md elsewhere", []string{"-md", "/home/user/secret"}, true},
+		{"--model-draft", []string{"--model-draft", "/tmp/d.gguf"}, true},
+		{"--chat-template-file", []string{"--chat-template-file", "/etc/passwd"}, true},
+		{"--lora", []string{"--lora", "/tmp/l.gguf"}, true},
+		{"--lora-scaled", []string{"--lora-scaled", "/tmp/l.gguf", "0.5"}, true},
+		{"--control-vector", []string{"--control-vector", "/tmp/v.gguf"}, true},
+		{"-f", []string{"-f", "/etc/passwd"}, true},
+		{"--grammar-file", []string{"--grammar-file", "/etc/passwd"}, true},
+		{"--json-schema-file", []string{"--json-schema-file", "/etc/passwd"}, true},
+		{"-lcs", []string{"-lcs", "/tmp/cache"}, true},
+		{"--lookup-cache-dynamic", []string{"--lookup-cache-dynamic", "/tmp/cache"}, true},
+		{"--ssl-key-file", []string{"--ssl-key-file", "/tmp/key.pem"}, true},
+		{"denied flag after allowed ones", []string{"-c", "4096", "--path", "/"}, true},
+		{"configured --mmproj kept", []string{"-ngl", "50", "--mmproj", "/models/mmproj.gguf"}, false},
+		{"configured -md kept", []string{"-md", "/models/draft.gguf", "-c", "4096"}, false},
+		{"configured flag with another value", []string{"--mmproj", "/models/other.gguf"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRequestArgs(tt.args, configured)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateRequestArgs(%q) = %v, want error %v", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
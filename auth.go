@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// authMiddleware requires one of apiKeys as a Bearer token on the management
+// API and the OpenAI-compatible endpoint once any key is configured. The
+// settings page is opened in a browser that cannot send the token, so it and
+// its /api/config calls are let through when they come from this machine.
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := config().APIKeys
+		if len(keys) == 0 || r.Method == http.MethodOptions || validAPIKey(requestAPIKey(r), keys) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if (r.URL.Path == "/settings" || r.URL.Path == "/api/config") && sameMachineRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="lmgo"`)
+		rejectRequest(w, r, http.StatusUnauthorized, "Invalid or missing API key")
+	})
+}
+
+// requestAPIKey returns the Bearer token of a request.
+func requestAPIKey(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// validAPIKey compares key with every configured key in constant time.
+func validAPIKey(key string, keys []string) bool {
+	valid := 0
+	for _, k := range keys {
+		valid |= subtle.ConstantTimeCompare([]byte(key), []byte(k))
+	}
+	return key != "" && valid == 1
+}
+
+// serverAPIKeyEnv is the environment that makes llama-server require the API
+// keys, when serverApiKeyEnabled is set. The environment keeps the keys out of
+// the process list, where --api-key would show them.
+func serverAPIKeyEnv() []string {
+	c := config()
+	if !c.ServerAPIKey || len(c.APIKeys) == 0 {
+		return nil
+	}
+	return []string{"LLAMA_API_KEY=" + strings.Join(c.APIKeys, ",")}
+}
+
+// authorizeServerRequest adds a key to a request lmgo itself makes to a
+// llama-server started with serverAPIKeyEnv, or to another lmgo.
+func authorizeServerRequest(req *http.Request) {
+	c := config()
+	if len(c.APIKeys) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.APIKeys[0])
+	}
+}
+
+// validateAPIKeys rejects empty keys and keys llama-server could not take
+// from a comma-separated list.
+func validateAPIKeys(c *Config) error {
+	for i, key := range c.APIKeys {
+		if strings.TrimSpace(key) == "" || strings.ContainsAny(key, ", \t\r\n") {
+			return fmt.Errorf("apiKeys[%d]: keys must not be empty or contain spaces or commas", i)
+		}
+	}
+	if c.ServerAPIKey && len(c.APIKeys) == 0 {
+		return fmt.Errorf("serverApiKeyEnabled: needs at least one key in apiKeys")
+	}
+	return nil
+}
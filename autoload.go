@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// AutoLoadEntry is one entry of autoLoadModels. It is written as a plain
+// model, configuration or alias name, or as an object that also starts
+// several instances of the model, on consecutive ports, or applies a preset.
+type AutoLoadEntry struct {
+	Model     string `json:"model"`
+	Instances int    `json:"instances,omitempty"`
+	Preset    string `json:"preset,omitempty"`
+}
+
+func (e *AutoLoadEntry) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*e = AutoLoadEntry{}
+		return json.Unmarshal(data, &e.Model)
+	}
+	type plain AutoLoadEntry
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// MarshalJSON keeps entries that only name a model as plain strings, as
+// autoLoadModels was written before it took objects.
+func (e AutoLoadEntry) MarshalJSON() ([]byte, error) {
+	if e.Instances <= 1 && e.Preset == "" {
+		return json.Marshal(e.Model)
+	}
+	type plain AutoLoadEntry
+	return json.Marshal(plain(e))
+}
+
+// count is the number of instances the entry starts.
+func (e AutoLoadEntry) count() int {
+	return max(e.Instances, 1)
+}
+
+func validateAutoLoadModels(c *Config) error {
+	for i, entry := range c.AutoLoadModels {
+		if strings.TrimSpace(entry.Model) == "" {
+			return fmt.Errorf("autoLoadModels[%d]: model must not be empty", i)
+		}
+		if entry.Instances < 0 {
+			return fmt.Errorf("autoLoadModels[%d]: instances must be at least 1", i)
+		}
+		if _, ok := c.Presets[entry.Preset]; entry.Preset != "" && !ok {
+			return fmt.Errorf("autoLoadModels[%d] uses undefined preset %q", i, entry.Preset)
+		}
+	}
+	return nil
+}
+
+// loadModelsByName loads the models names refer to in order.
+func loadModelsByName(names []string) (failed []string) {
+	entries := make([]AutoLoadEntry, len(names))
+	for i, name := range names {
+		entries[i] = AutoLoadEntry{Model: name}
+	}
+	return loadAutoLoadEntries(entries)
+}
+
+// loadAutoLoadEntries loads every instance of entries in order. Names that
+// match no model are reported together before anything is loaded, rather
+// than one by one between loads.
+func loadAutoLoadEntries(entries []AutoLoadEntry) (failed []string) {
+	var found []AutoLoadEntry
+	for _, entry := range entries {
+		resolved := resolveName(entry.Model)
+		if !resolved.Matched {
+			failed = append(failed, fmt.Sprintf("%s: %s", entry.Model, describeUnmatched(resolved)))
+			continue
+		}
+		found = append(found, entry)
+	}
+	if len(failed) > 0 {
+		for _, f := range failed {
+			log.Printf("Model not found: %s", f)
+		}
+		notify("Model Not Found", strings.Join(failed, "\n")+"\nNames must match a model, configuration or alias exactly. Use Rescan Models to pick up new files.")
+	}
+
+	for _, entry := range found {
+		modelIdx, configIdx, _ := findModelByName(entry.Model)
+		for n := 0; n < entry.count(); n++ {
+			if err := loadModelWithOptions(modelIdx, configIdx, launchOptions{Preset: entry.Preset}); err != nil {
+				log.Printf("Failed to load %s: %v", entry.Model, err)
+				failed = append(failed, fmt.Sprintf("%s: %v", entry.Model, err))
+				break
+			}
+		}
+	}
+	return failed
+}
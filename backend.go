@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// backends are the llama.cpp builds lmgo can choose between, with the names
+// release archives use for them.
+var backends = []struct {
+	name     string
+	keywords []string
+}{
+	{"rocm", []string{"rocm", "hip"}},
+	{"cuda", []string{"cuda"}},
+	{"vulkan", []string{"vulkan"}},
+	{"cpu", []string{"cpu"}},
+}
+
+// gpuInfo is a GPU found at startup. Target is the gfx target of AMD GPUs,
+// e.g. gfx1151, and the compute capability of NVIDIA ones, e.g. 8.6, when
+// they can be read.
+type gpuInfo struct {
+	Vendor string
+	Name   string
+	Target string
+}
+
+func (g gpuInfo) String() string {
+	s := g.Vendor + " " + g.Name
+	if g.Target != "" {
+		s += " (" + g.Target + ")"
+	}
+	return s
+}
+
+var gfxPattern = regexp.MustCompile(`gfx[0-9a-f]+`)
+
+// validateBackend checks the backend setting.
+func validateBackend(backend string) error {
+	if backend == "" || backend == "auto" {
+		return nil
+	}
+	for _, b := range backends {
+		if b.name == backend {
+			return nil
+		}
+	}
+	return fmt.Errorf(`invalid backend %q: expected "auto", "rocm", "cuda", "vulkan" or "cpu"`, backend)
+}
+
+// backendOrder returns the backends to try, best first: the configured one,
+// or those suited to the detected GPUs followed by the CPU.
+func backendOrder(setting string, gpus []gpuInfo) []string {
+	if setting != "" && setting != "auto" {
+		return []string{setting}
+	}
+	var order []string
+	for _, gpu := range gpus {
+		switch gpu.Vendor {
+		case "nvidia":
+			order = append(order, "cuda", "vulkan")
+		case "amd":
+			order = append(order, "rocm", "vulkan")
+		case "intel":
+			order = append(order, "vulkan")
+		}
+	}
+	order = append(order, "cpu")
+
+	var unique []string
+	for _, b := range order {
+		if !slices.Contains(unique, b) {
+			unique = append(unique, b)
+		}
+	}
+	return unique
+}
+
+// buildBackend returns the backend a build is for, judged by its archive or
+// directory name, or "" when the name does not say.
+func buildBackend(name string) string {
+	lower := strings.ToLower(name)
+	for _, b := range backends {
+		for _, keyword := range b.keywords {
+			if strings.Contains(lower, keyword) {
+				return b.name
+			}
+		}
+	}
+	return ""
+}
+
+// pickBuild chooses among builds named by archive or directory. It takes the
+// first backend in order that has a build, skipping ROCm builds compiled for
+// another gfx target than the detected AMD GPUs. With the backend set to
+// auto and no match, a build whose name names no backend is used, then the
+// first one, as before backends were told apart. ok is false when the
+// configured backend has no build.
+func pickBuild(names []string, setting string, gpus []gpuInfo) (string, bool) {
+	if len(names) == 0 {
+		return "", false
+	}
+
+	var targets []string
+	for _, gpu := range gpus {
+		if gpu.Vendor == "amd" && gpu.Target != "" {
+			targets = append(targets, gpu.Target)
+		}
+	}
+	fits := func(name string) bool {
+		target := gfxPattern.FindString(strings.ToLower(name))
+		return target == "" || len(targets) == 0 || slices.Contains(targets, target)
+	}
+
+	for _, backend := range backendOrder(setting, gpus) {
+		for _, name := range names {
+			if buildBackend(name) == backend && fits(name) {
+				return name, true
+			}
+		}
+	}
+	if setting != "" && setting != "auto" {
+		return "", false
+	}
+	for _, name := range names {
+		if buildBackend(name) == "" {
+			return name, true
+		}
+	}
+	return names[0], true
+}
+
+var detectedGPUs []gpuInfo
+
+// findGPUs detects and logs the GPUs once at startup, for choosing a
+// backend.
+func findGPUs() {
+	detectedGPUs = detectGPUs()
+	if len(detectedGPUs) == 0 {
+		log.Printf("No GPU detected")
+		return
+	}
+	for _, gpu := range detectedGPUs {
+		log.Printf("Detected GPU: %s", gpu)
+	}
+}
+
+// addComputeCaps fills in the compute capability of the NVIDIA GPUs from
+// nvidia-smi, which lists them in the same order. Without the driver tools
+// they are left as they are.
+func addComputeCaps(gpus []gpuInfo) {
+	if !slices.ContainsFunc(gpus, func(g gpuInfo) bool { return g.Vendor == "nvidia" }) {
+		return
+	}
+	cmd := exec.Command("nvidia-smi", "--query-gpu=compute_cap", "--format=csv,noheader")
+	hideWindow(cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		return
+	}
+	caps := strings.Fields(string(out))
+	for i := range gpus {
+		if gpus[i].Vendor == "nvidia" && len(caps) > 0 {
+			gpus[i].Target, caps = caps[0], caps[1:]
+		}
+	}
+}
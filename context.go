@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	minAutoContext    = 2048
+	memoryReserveSize = 2 << 30
+
+	// vramReserveSize is left free on the GPU for compute buffers and the
+	// output layer when layers or context are sized automatically.
+	vramReserveSize = 1 << 30
+)
+
+// cacheTypeBytes is the storage cost per KV cache element for each
+// --cache-type-k/--cache-type-v value.
+var cacheTypeBytes = map[string]float64{
+	"f32":    4,
+	"f16":    2,
+	"bf16":   2,
+	"q8_0":   34.0 / 32,
+	"q5_1":   24.0 / 32,
+	"q5_0":   22.0 / 32,
+	"q4_1":   20.0 / 32,
+	"q4_0":   18.0 / 32,
+	"iq4_nl": 18.0 / 32,
+}
+
+// kvBytesPerToken estimates the KV cache size of one token of context.
+func kvBytesPerToken(meta *ggufMetadata, keyBytes, valueBytes float64) float64 {
+	headDimK, headDimV := meta.KeyLength, meta.ValueLength
+	if headDimK == 0 && meta.HeadCount > 0 {
+		headDimK = meta.EmbeddingLength / meta.HeadCount
+	}
+	if headDimV == 0 {
+		headDimV = headDimK
+	}
+	perLayer := float64(meta.HeadCountKV) * (float64(headDimK)*keyBytes + float64(headDimV)*valueBytes)
+	return perLayer * float64(meta.BlockCount)
+}
+
+// cacheTypeSizes returns the bytes per K and V cache element the arguments
+// select, f16 unless set otherwise.
+func cacheTypeSizes(args []string) (keyBytes, valueBytes float64) {
+	keyType, _ := argValue(args, "-ctk", "--cache-type-k")
+	valueType, _ := argValue(args, "-ctv", "--cache-type-v")
+	keyBytes, ok := cacheTypeBytes[strings.ToLower(keyType)]
+	if !ok {
+		keyBytes = 2
+	}
+	valueBytes, ok = cacheTypeBytes[strings.ToLower(valueType)]
+	if !ok {
+		valueBytes = 2
+	}
+	return keyBytes, valueBytes
+}
+
+// chooseContextSize picks the largest context that fits in budget bytes of
+// KV cache: the trained length if it fits, otherwise the largest power of two
+// below it, but never less than minAutoContext or the trained length when
+// that is shorter.
+func chooseContextSize(meta *ggufMetadata, keyBytes, valueBytes float64, budget int64) (ctx int, kvBytes int64) {
+	perToken := kvBytesPerToken(meta, keyBytes, valueBytes)
+	trained := meta.ContextLength
+	if trained <= 0 {
+		trained = minAutoContext
+	}
+	if perToken <= 0 {
+		return trained, 0
+	}
+
+	cost := func(n int) int64 { return int64(perToken * float64(n)) }
+
+	if cost(trained) <= budget {
+		return trained, cost(trained)
+	}
+
+	ctx = 1
+	for ctx*2 < trained {
+		ctx *= 2
+	}
+	for ctx > minAutoContext && cost(ctx) > budget {
+		ctx /= 2
+	}
+	ctx = max(ctx, min(minAutoContext, trained))
+	return ctx, cost(ctx)
+}
+
+func formatGB(bytes int64) string {
+	return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+}
+
+// modelFileSize returns the size of a model, summing every shard of a split
+// GGUF file.
+func modelFileSize(path string) int64 {
+	m := shardPattern.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0
+		}
+		return info.Size()
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), m[1]+"-*-of-"+m[3]+".gguf"))
+	var total int64
+	for _, shard := range matches {
+		if info, err := os.Stat(shard); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+func argValue(args []string, names ...string) (string, bool) {
+	for i := 0; i < len(args)-1; i++ {
+		for _, name := range names {
+			if args[i] == name {
+				return args[i+1], true
+			}
+		}
+	}
+	return "", false
+}
+
+// contextSetting returns the ctxSize that applies to a launch: the
+// model-specific value if set, otherwise the global one.
+func contextSetting(entry modelEntry, configIndex int) string {
+	if cfg := modelSpecificConfig(entry, configIndex); cfg != nil && cfg.CtxSize != "" {
+		return cfg.CtxSize
+	}
+	return config().CtxSize
+}
+
+// applyContextSize adjusts --ctx-size according to the ctxSize setting. An
+// explicit --ctx-size in model-specific args always wins over the global
+// ctxSize and over "auto".
+func applyContextSize(entry modelEntry, configIndex int, args []string) []string {
+	setting := strings.TrimSpace(contextSetting(entry, configIndex))
+	if setting == "" {
+		return args
+	}
+
+	if cfg := modelSpecificConfig(entry, configIndex); cfg != nil && (cfg.CtxSize == "" || strings.EqualFold(setting, "auto")) {
+		if value, ok := argValue(ownArgs(*cfg), "-c", "--ctx-size"); ok {
+			log.Printf("Using explicit --ctx-size %s from model-specific args for %s", value, entry.BaseName)
+			return args
+		}
+	}
+
+	if n, err := strconv.Atoi(setting); err == nil {
+		return mergeArgs(args, []string{"--ctx-size", strconv.Itoa(n)})
+	}
+	if !strings.EqualFold(setting, "auto") {
+		log.Printf("Ignoring invalid ctxSize %q for %s", setting, entry.BaseName)
+		return args
+	}
+
+	meta, err := cachedGGUFMetadata(firstShardPath(entry.Path))
+	if err != nil {
+		log.Printf("Auto context: failed to read metadata for %s: %v", entry.BaseName, err)
+		return args
+	}
+
+	budget, source, err := contextBudget(entry, meta, args)
+	if err != nil {
+		log.Printf("Auto context: failed to query available memory: %v", err)
+		return args
+	}
+
+	keyBytes, valueBytes := cacheTypeSizes(args)
+	ctx, kvBytes := chooseContextSize(meta, keyBytes, valueBytes, budget)
+
+	log.Printf("Auto context for %s: trained %d, fits %d with %s KV (%s)",
+		entry.BaseName, meta.ContextLength, ctx, formatGB(kvBytes), source)
+	return mergeArgs(args, []string{"--ctx-size", strconv.Itoa(ctx)})
+}
+
+// contextBudget returns how many bytes the KV cache may take. When the cache
+// lives on a GPU whose free VRAM can be read, that is what remains after the
+// offloaded weights; the cache of the offloaded layers grows with the context
+// just like the whole cache, so the room is scaled up by their share.
+// Otherwise it is the available system memory after the model.
+func contextBudget(entry modelEntry, meta *ggufMetadata, args []string) (int64, string, error) {
+	if share := offloadShare(meta, args); share > 0 && kvOffloaded(args) {
+		if free, ok := freeVRAM(); ok {
+			room := free - vramReserveSize - mmprojSize(args)
+			budget := int64(float64(room)/share) - modelFileSize(entry.Path)
+			return budget, formatGB(free) + " VRAM free", nil
+		}
+	}
+
+	available, err := availableMemory()
+	if err != nil {
+		return 0, "", err
+	}
+	return int64(available) - modelFileSize(entry.Path) - memoryReserveSize, formatGB(int64(available)) + " available", nil
+}
+
+// gpuLayersSetting returns the gpuLayers that applies to a launch: the
+// model-specific value if set, otherwise the global one.
+func gpuLayersSetting(entry modelEntry, configIndex int) string {
+	if cfg := modelSpecificConfig(entry, configIndex); cfg != nil && cfg.GPULayers != "" {
+		return cfg.GPULayers
+	}
+	return config().GPULayers
+}
+
+// applyAutoArgs applies the gpuLayers and ctxSize settings. The layers come
+// first, because the room left for the context depends on them.
+func applyAutoArgs(entry modelEntry, configIndex int, args []string) []string {
+	args = applyGPULayers(entry, configIndex, args)
+	return applyContextSize(entry, configIndex, args)
+}
+
+// applyGPULayers adjusts -ngl according to the gpuLayers setting. "auto"
+// offloads as many layers as fit in the free VRAM together with their share
+// of the KV cache, planning for the smallest automatic context when ctxSize
+// is also "auto", so the context can then grow into what is left. An explicit
+// -ngl in model-specific args always wins over the global gpuLayers and over
+// "auto".
+func applyGPULayers(entry modelEntry, configIndex int, args []string) []string {
+	setting := strings.TrimSpace(gpuLayersSetting(entry, configIndex))
+	if setting == "" {
+		return args
+	}
+
+	if cfg := modelSpecificConfig(entry, configIndex); cfg != nil && (cfg.GPULayers == "" || strings.EqualFold(setting, "auto")) {
+		if value, ok := argValue(ownArgs(*cfg), "-ngl", "--gpu-layers", "--n-gpu-layers"); ok {
+			log.Printf("Using explicit -ngl %s from model-specific args for %s", value, entry.BaseName)
+			return args
+		}
+	}
+
+	if n, err := strconv.Atoi(setting); err == nil {
+		return mergeArgs(args, []string{"-ngl", strconv.Itoa(n)})
+	}
+	if !strings.EqualFold(setting, "auto") {
+		log.Printf("Ignoring invalid gpuLayers %q for %s", setting, entry.BaseName)
+		return args
+	}
+
+	meta, err := cachedGGUFMetadata(firstShardPath(entry.Path))
+	if err != nil || meta.BlockCount == 0 {
+		log.Printf("Auto GPU layers: failed to read the layer count of %s: %v", entry.BaseName, err)
+		return args
+	}
+	free, ok := freeVRAM()
+	if !ok {
+		log.Printf("Auto GPU layers: free VRAM cannot be read, keeping the configured -ngl for %s", entry.BaseName)
+		return args
+	}
+
+	ctx := argsContext(meta, args)
+	if setting := strings.TrimSpace(contextSetting(entry, configIndex)); strings.EqualFold(setting, "auto") {
+		ctx = minAutoContext
+	} else if n, err := strconv.Atoi(setting); err == nil {
+		ctx = argsContext(meta, []string{"--ctx-size", strconv.Itoa(n)})
+	}
+
+	perLayer := float64(modelFileSize(entry.Path)) / float64(meta.BlockCount)
+	if kvOffloaded(args) {
+		keyBytes, valueBytes := cacheTypeSizes(args)
+		perLayer += kvBytesPerToken(meta, keyBytes, valueBytes) * float64(ctx) / float64(meta.BlockCount)
+	}
+	room := free - vramReserveSize - mmprojSize(args)
+	layers := 0
+	if room > 0 && perLayer > 0 {
+		layers = min(int(float64(room)/perLayer), meta.BlockCount)
+	}
+
+	log.Printf("Auto GPU layers for %s: %d of %d layers fit with a %d-token context (%s VRAM free)",
+		entry.BaseName, layers, meta.BlockCount, ctx, formatGB(free))
+	if layers == meta.BlockCount {
+		// One more than the block count also offloads the output layer.
+		layers++
+	}
+	return mergeArgs(args, []string{"-ngl", strconv.Itoa(layers)})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// llama3 is the KV layout of Llama 3 8B: 32 layers of 8 KV heads of 128
+// dimensions, 128 KiB per token with an f16 cache.
+var llama3 = ggufMetadata{
+	ContextLength:   8192,
+	BlockCount:      32,
+	EmbeddingLength: 4096,
+	HeadCount:       32,
+	HeadCountKV:     8,
+	KeyLength:       128,
+	ValueLength:     128,
+}
+
+const llama3PerToken = 32 * 8 * (128*2 + 128*2)
+
+func TestKVBytesPerToken(t *testing.T) {
+	derived := llama3
+	derived.KeyLength, derived.ValueLength = 0, 0
+	keyOnly := llama3
+	keyOnly.KeyLength, keyOnly.ValueLength = 192, 0
+	noHeads := llama3
+	noHeads.KeyLength, noHeads.ValueLength, noHeads.HeadCount = 0, 0, 0
+
+	tests := []struct {
+		name                 string
+		meta                 ggufMetadata
+		keyBytes, valueBytes float64
+		want                 float64
+	}{
+		{"f16", llama3, 2, 2, llama3PerToken},
+		{"q8_0", llama3, 34.0 / 32, 34.0 / 32, 32 * 8 * (128 * 34.0 / 32 * 2)},
+		{"mixed cache types", llama3, 2, 18.0 / 32, 32 * 8 * (128*2 + 128*18.0/32)},
+		{"head size from embedding", derived, 2, 2, llama3PerToken},
+		{"value length from key length", keyOnly, 2, 2, 32 * 8 * (192*2 + 192*2)},
+		{"no head size", noHeads, 2, 2, 0},
+		{"zero metadata", ggufMetadata{}, 2, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kvBytesPerToken(&tt.meta, tt.keyBytes, tt.valueBytes); got != tt.want {
+				t.Errorf("kvBytesPerToken = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChooseContextSize(t *testing.T) {
+	withContext := func(n int) ggufMetadata {
+		meta := llama3
+		meta.ContextLength = n
+		return meta
+	}
+
+	tests := []struct {
+		name    string
+		meta    ggufMetadata
+		budget  int64
+		wantCtx int
+		wantKV  int64
+	}{
+		{"trained length fits", llama3, 10 << 30, 8192, 8192 * llama3PerToken},
+		{"exactly fits", llama3, 8192 * llama3PerToken, 8192, 8192 * llama3PerToken},
+		{"halved to fit", llama3, 512 << 20, 4096, 4096 * llama3PerToken},
+		{"halved twice", llama3, 300 << 20, 2048, 2048 * llama3PerToken},
+		{"power of two below trained", withContext(40960), 1 << 30, 8192, 8192 * llama3PerToken},
+		{"large trained context clamped", withContext(131072), 100 << 30, 131072, 131072 * llama3PerToken},
+		{"never below the minimum", llama3, 1 << 20, minAutoContext, minAutoContext * llama3PerToken},
+		{"no budget", llama3, 0, minAutoContext, minAutoContext * llama3PerToken},
+		{"short trained context not exceeded", withContext(1024), 0, 1024, 1024 * llama3PerToken},
+		{"unknown KV layout", ggufMetadata{ContextLength: 32768}, 1 << 20, 32768, 0},
+		{"zero metadata", ggufMetadata{}, 1 << 30, minAutoContext, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, kv := chooseContextSize(&tt.meta, 2, 2, tt.budget)
+			if ctx != tt.wantCtx || kv != tt.wantKV {
+				t.Errorf("chooseContextSize = %d, %d bytes; want %d, %d bytes", ctx, kv, tt.wantCtx, tt.wantKV)
+			}
+		})
+	}
+}
+
+func TestApplyContextSizePrecedence(t *testing.T) {
+	saved := *config()
+	t.Cleanup(func() { setConfig(saved) })
+
+	entry := modelEntry{BaseName: "model"}
+	tests := []struct {
+		name   string
+		global string
+		cfg    *ModelConfig
+		want   []string
+	}{
+		{"global size", "8192", nil, []string{"--ctx-size", "8192"}},
+		{"global size over args without one", "8192", &ModelConfig{Args: []string{"-ngl", "99"}}, []string{"-ngl", "99", "--ctx-size", "8192"}},
+		{"explicit -c wins over global size", "8192", &ModelConfig{Args: []string{"-c", "4096"}}, []string{"-c", "4096"}},
+		{"explicit --ctx-size wins over global size", "8192", &ModelConfig{Args: []string{"--ctx-size", "4096"}}, []string{"--ctx-size", "4096"}},
+		{"model ctxSize wins over args", "", &ModelConfig{CtxSize: "16384", Args: []string{"-c", "4096"}}, []string{"--ctx-size", "16384"}},
+		{"explicit -c wins over auto", "auto", &ModelConfig{Args: []string{"-c", "4096"}}, []string{"-c", "4096"}},
+		{"unset", "", &ModelConfig{Args: []string{"-c", "4096"}}, []string{"-c", "4096"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{CtxSize: tt.global}
+			configIndex, args := -1, []string{}
+			if tt.cfg != nil {
+				cfg := *tt.cfg
+				cfg.Name, cfg.Target = "model-config", entry.BaseName
+				c.ModelSpecificArgs = []ModelConfig{cfg}
+				configIndex, args = 0, cfg.Args
+			}
+			setConfig(c)
+			if got := applyContextSize(entry, configIndex, args); !slices.Equal(got, tt.want) {
+				t.Errorf("applyContextSize = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyGPULayersPrecedence(t *testing.T) {
+	saved := *config()
+	t.Cleanup(func() { setConfig(saved) })
+
+	entry := modelEntry{BaseName: "model"}
+	tests := []struct {
+		name   string
+		global string
+		cfg    *ModelConfig
+		want   []string
+	}{
+		{"global layers", "20", nil, []string{"-ngl", "20"}},
+		{"explicit -ngl wins over global layers", "20", &ModelConfig{Args: []string{"-ngl", "99"}}, []string{"-ngl", "99"}},
+		{"explicit --n-gpu-layers wins over global layers", "20", &ModelConfig{Args: []string{"--n-gpu-layers", "99"}}, []string{"--n-gpu-layers", "99"}},
+		{"model gpuLayers wins over args", "", &ModelConfig{GPULayers: "10", Args: []string{"-ngl", "99"}}, []string{"-ngl", "10"}},
+		{"explicit -ngl wins over auto", "auto", &ModelConfig{Args: []string{"-ngl", "99"}}, []string{"-ngl", "99"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{GPULayers: tt.global}
+			configIndex, args := -1, []string{}
+			if tt.cfg != nil {
+				cfg := *tt.cfg
+				cfg.Name, cfg.Target = "model-config", entry.BaseName
+				c.ModelSpecificArgs = []ModelConfig{cfg}
+				configIndex, args = 0, cfg.Args
+			}
+			setConfig(c)
+			if got := applyGPULayers(entry, configIndex, args); !slices.Equal(got, tt.want) {
+				t.Errorf("applyGPULayers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// crashRestartBaseDelay is the wait before the first restart after a
+	// crash; it doubles with every further attempt up to crashRestartMaxDelay.
+	crashRestartBaseDelay = 5 * time.Second
+	crashRestartMaxDelay  = 5 * time.Minute
+
+	// stableRuntime is how long an instance has to run before its crashes
+	// count from zero again.
+	stableRuntime = 10 * time.Minute
+)
+
+// stopAllCount is bumped whenever all instances are stopped, on Unload All,
+// suspend and exit, which also cancels restarts waiting out their backoff.
+var stopAllCount atomic.Int64
+
+// crashRestartLimit is how many times in a row an instance that crashed is
+// restarted: crashRestarts of its modelSpecificArgs entry, where -1 turns
+// restarts off, otherwise the global setting.
+func crashRestartLimit(entry modelEntry, configIndex int) int {
+	if cfg := modelSpecificConfig(entry, configIndex); cfg != nil && cfg.CrashRestarts != 0 {
+		return max(cfg.CrashRestarts, 0)
+	}
+	return config().CrashRestarts
+}
+
+// crashRestartDelay is the backoff before restart attempt n, counted from 1.
+func crashRestartDelay(attempt int) time.Duration {
+	delay := crashRestartBaseDelay
+	for i := 1; i < attempt && delay < crashRestartMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, crashRestartMaxDelay)
+}
+
+// restartAfterCrash schedules restarts of an instance that exited abnormally
+// and reports whether it did. Each attempt waits longer than the previous
+// one, and a restart that fails to load counts as an attempt too. The new
+// instance keeps the launch options and, if it is still free, the port.
+func restartAfterCrash(instance *modelInstance, reason string) bool {
+	restarts := instance.restarts
+	if time.Since(instance.startedAt) > stableRuntime {
+		restarts = 0
+	}
+	limit := crashRestartLimit(instance.entry, instance.configIndex)
+	if restarts >= limit {
+		return false
+	}
+
+	name := displayName(instance)
+	delay := crashRestartDelay(restarts + 1)
+	notify("Model Crashed", fmt.Sprintf("%s on port %d crashed%s. Restarting in %ds (attempt %d of %d).",
+		name, instance.port, reason, int(delay.Seconds()), restarts+1, limit))
+
+	generation := stopAllCount.Load()
+	go func() {
+		for attempt := restarts + 1; attempt <= limit; attempt++ {
+			time.Sleep(crashRestartDelay(attempt))
+			if stopAllCount.Load() != generation {
+				log.Printf("Restart of %s cancelled", name)
+				return
+			}
+
+			idx := findModelIndexByPath(instance.entry.Path)
+			if idx < 0 {
+				notify("Model Stopped", fmt.Sprintf("%s is no longer available and was not restarted", name))
+				return
+			}
+			log.Printf("Restarting %s after a crash (attempt %d of %d)", name, attempt, limit)
+			opts := launchOptions{
+				Parallel:  instance.parallel,
+				Preset:    instance.preset,
+				Args:      instance.customArgs,
+				Port:      instance.port,
+				NoBrowser: true,
+				Restarts:  attempt,
+			}
+			err := loadModelWithOptions(idx, instance.configIndex, opts)
+			if err == nil {
+				return
+			}
+			log.Printf("Restart attempt %d of %s failed: %v", attempt, name, err)
+		}
+		notify("Model Stopped", fmt.Sprintf("%s could not be restarted after %d attempts", name, limit))
+	}()
+	return true
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxFinishedDownloads is how many completed, failed or cancelled downloads
+// are kept for GET /api/downloads.
+const maxFinishedDownloads = 20
+
+// DownloadRequest is the body of POST /api/downloads: either a Hugging Face
+// repo and the path of a file in it, or a direct URL.
+type DownloadRequest struct {
+	Repo string `json:"repo,omitempty"`
+	File string `json:"file,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// DownloadInfo is one entry of GET /api/downloads. Size is 0 while the
+// server does not know it; Speed is in bytes per second.
+type DownloadInfo struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	URL        string  `json:"url"`
+	Size       int64   `json:"size"`
+	Downloaded int64   `json:"downloaded"`
+	Speed      float64 `json:"speed"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type download struct {
+	info   DownloadInfo
+	cancel context.CancelFunc
+
+	lastSample time.Time
+	lastBytes  int64
+}
+
+var (
+	downloads      []*download
+	downloadsMu    sync.Mutex
+	nextDownloadID = 1
+)
+
+var hfRepoPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*/[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// handleDownloads lists downloads on GET and starts one on POST.
+func handleDownloads(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: downloadList()})
+
+	case http.MethodPost:
+		var req DownloadRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid request body"})
+			return
+		}
+		info, err := startDownload(req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, APIResponse{Success: true, Message: fmt.Sprintf("Downloading %s", info.Name), Data: info})
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+	}
+}
+
+// handleCancelDownload stops the download given by the id parameter.
+func handleCancelDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid id"})
+		return
+	}
+
+	// The download goroutine updates info under downloadsMu, so it is copied
+	// before the lock is released.
+	downloadsMu.Lock()
+	var found *download
+	var info DownloadInfo
+	for _, d := range downloads {
+		if d.info.ID == id {
+			found, info = d, d.info
+		}
+	}
+	downloadsMu.Unlock()
+
+	if found == nil || info.Status != "downloading" {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: fmt.Sprintf("No download in progress with id %d", id)})
+		return
+	}
+	found.cancel()
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Message: fmt.Sprintf("Cancelled %s", info.Name)})
+}
+
+func downloadList() []DownloadInfo {
+	downloadsMu.Lock()
+	defer downloadsMu.Unlock()
+
+	list := make([]DownloadInfo, len(downloads))
+	for i, d := range downloads {
+		list[i] = d.info
+	}
+	return list
+}
+
+// downloadSource resolves a request to the URL to fetch and the file name to
+// save it as in the model directory.
+func downloadSource(req DownloadRequest) (string, string, error) {
+	var source string
+	switch {
+	case req.URL != "":
+		u, err := url.Parse(req.URL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return "", "", fmt.Errorf("invalid URL %q", req.URL)
+		}
+		source = u.String()
+		req.File = u.Path
+	case req.Repo != "" && req.File != "":
+		if !hfRepoPattern.MatchString(req.Repo) {
+			return "", "", fmt.Errorf("invalid repo %q, expected owner/name", req.Repo)
+		}
+		file := strings.TrimPrefix(req.File, "/")
+		if strings.Contains(file, "..") || strings.Contains(file, `\`) {
+			return "", "", fmt.Errorf("invalid file %q", req.File)
+		}
+		source = fmt.Sprintf("https://huggingface.co/%s/resolve/main/%s", req.Repo, (&url.URL{Path: file}).EscapedPath())
+	default:
+		return "", "", errors.New("expected a repo and file, or a url")
+	}
+
+	name := path.Base(req.File)
+	if !strings.HasSuffix(strings.ToLower(name), ".gguf") || name == ".gguf" {
+		return "", "", fmt.Errorf("%q is not a .gguf file", name)
+	}
+	return source, name, nil
+}
+
+func startDownload(req DownloadRequest) (DownloadInfo, error) {
+	c := config()
+	source, name, err := downloadSource(req)
+	if err != nil {
+		return DownloadInfo{}, err
+	}
+	if c.ModelDir == "" {
+		return DownloadInfo{}, errors.New("no model directory configured")
+	}
+	dest := filepath.Join(c.ModelDir, name)
+	if _, err := os.Stat(dest); err == nil {
+		return DownloadInfo{}, fmt.Errorf("%s already exists in the model directory", name)
+	}
+
+	downloadsMu.Lock()
+	for _, d := range downloads {
+		if d.info.Name == name && d.info.Status == "downloading" {
+			downloadsMu.Unlock()
+			return DownloadInfo{}, fmt.Errorf("%s is already being downloaded", name)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &download{
+		info:       DownloadInfo{ID: nextDownloadID, Name: name, URL: source, Status: "downloading"},
+		cancel:     cancel,
+		lastSample: time.Now(),
+	}
+	nextDownloadID++
+	downloads = append(downloads, d)
+	info := d.info
+	downloadsMu.Unlock()
+
+	go func() {
+		err := d.run(ctx, dest)
+		cancel()
+
+		downloadsMu.Lock()
+		d.info.Speed = 0
+		switch {
+		case errors.Is(err, context.Canceled):
+			d.info.Status = "cancelled"
+		case err != nil:
+			d.info.Status = "failed"
+			d.info.Error = err.Error()
+		default:
+			d.info.Status = "completed"
+		}
+		pruneDownloads()
+		downloadsMu.Unlock()
+
+		if err == nil {
+			notify("Download Complete", fmt.Sprintf("%s was saved to %s", name, c.ModelDir))
+			refreshConfigAndModels()
+		} else if !errors.Is(err, context.Canceled) {
+			notify("Download Failed", fmt.Sprintf("%s: %v", name, err))
+		}
+	}()
+	return info, nil
+}
+
+// run fetches the file into a .part file next to dest and renames it once it
+// is complete, so a partial download never shows up as a model.
+func (d *download) run(ctx context.Context, dest string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.info.URL, nil)
+	if err != nil {
+		return err
+	}
+	// A token gives access to gated Hugging Face repos.
+	if token := os.Getenv("HF_TOKEN"); token != "" && strings.HasPrefix(d.info.URL, "https://huggingface.co/") {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	downloadsMu.Lock()
+	d.info.Size = max(resp.ContentLength, 0)
+	downloadsMu.Unlock()
+
+	part := dest + ".part"
+	file, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, io.TeeReader(resp.Body, d))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(part)
+		return err
+	}
+	log.Printf("Downloaded %s to %s", d.info.Name, dest)
+	return os.Rename(part, dest)
+}
+
+// Write counts the bytes received and samples the speed about once a
+// second.
+func (d *download) Write(p []byte) (int, error) {
+	downloadsMu.Lock()
+	defer downloadsMu.Unlock()
+
+	d.info.Downloaded += int64(len(p))
+	if elapsed := time.Since(d.lastSample); elapsed >= time.Second {
+		d.info.Speed = float64(d.info.Downloaded-d.lastBytes) / elapsed.Seconds()
+		d.lastSample = time.Now()
+		d.lastBytes = d.info.Downloaded
+	}
+	return len(p), nil
+}
+
+// pruneDownloads drops the oldest finished downloads beyond
+// maxFinishedDownloads. Callers must hold downloadsMu.
+func pruneDownloads() {
+	finished := 0
+	for i := len(downloads) - 1; i >= 0; i-- {
+		if downloads[i].info.Status == "downloading" {
+			continue
+		}
+		finished++
+		if finished > maxFinishedDownloads {
+			downloads = append(downloads[:i], downloads[i+1:]...)
+		}
+	}
+}
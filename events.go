@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The state changes sent on /api/events besides eventModelLoaded and
+// eventModelCrashed.
+const (
+	eventModelUnloaded = "modelUnloaded"
+	eventLoadFailed    = "loadFailed"
+	eventScanComplete  = "scanComplete"
+	eventProgress      = "progress"
+)
+
+// eventKeepAlive is how often an idle event stream gets a comment, so that
+// proxies and clients do not take it for a dead connection.
+const eventKeepAlive = 15 * time.Second
+
+// Event is one state change on /api/events. Progress is the load percentage
+// of progress events, where Message is the label shown in the menu.
+type Event struct {
+	Type     string    `json:"type"`
+	Model    string    `json:"model,omitempty"`
+	Port     int       `json:"port,omitempty"`
+	Progress int       `json:"progress,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+var (
+	eventStreamsMu sync.Mutex
+	eventStreams   = map[chan Event]struct{}{}
+)
+
+// publishEvent sends an event to every open stream. A client too slow to
+// keep up misses events rather than holding up lmgo.
+func publishEvent(event Event) {
+	event.Time = time.Now()
+	eventStreamsMu.Lock()
+	defer eventStreamsMu.Unlock()
+	for stream := range eventStreams {
+		select {
+		case stream <- event:
+		default:
+		}
+	}
+}
+
+// instanceEvent is an event about an instance.
+func instanceEvent(eventType string, instance *modelInstance, message string) Event {
+	return Event{Type: eventType, Model: displayName(instance), Port: instance.port, Message: message}
+}
+
+// closeEventStreams ends every stream, so that shutting down the API server
+// does not wait for them.
+func closeEventStreams() {
+	eventStreamsMu.Lock()
+	defer eventStreamsMu.Unlock()
+	for stream := range eventStreams {
+		close(stream)
+		delete(eventStreams, stream)
+	}
+}
+
+// handleEvents streams events as server-sent events until the client goes
+// away, each with its type as the event name and the Event as JSON data.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: "Streaming not supported"})
+		return
+	}
+
+	stream := make(chan Event, 64)
+	eventStreamsMu.Lock()
+	eventStreams[stream] = struct{}{}
+	eventStreamsMu.Unlock()
+	defer func() {
+		eventStreamsMu.Lock()
+		delete(eventStreams, stream)
+		eventStreamsMu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
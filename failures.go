@@ -0,0 +1,68 @@
+package main
+
+import "strings"
+
+// failureLines is how much of the end of a server's output is searched for
+// the reason it failed.
+const failureLines = 60
+
+// failureSignatures map pieces of llama-server error output, in lower case,
+// to an explanation. The first signature found, searching from the last
+// line, wins. With showLine the matching line is appended, for errors whose
+// details matter.
+var failureSignatures = []struct {
+	patterns []string
+	reason   string
+	showLine bool
+}{
+	{
+		patterns: []string{"out of memory", "failed to allocate", "cudamalloc failed", "erroroutofdevicememory", "hipmalloc failed", "unable to allocate", "insufficient memory"},
+		reason:   "not enough GPU memory; lower -ngl or --ctx-size, or use a smaller quantization",
+	},
+	{
+		patterns: []string{"failed to load multimodal", "failed to load mmproj", "clip_init: failed", "clip_model_load: failed"},
+		reason:   "the multimodal projector (--mmproj) could not be loaded; check its path and that it matches the model",
+	},
+	{
+		patterns: []string{"unknown model architecture", "invalid ggml type", "unknown type", "unsupported model", "wrong number of tensors"},
+		reason:   "the model's architecture or quantization is not supported by this llama-server; a newer llama.cpp build may be needed",
+	},
+	{
+		patterns: []string{"invalid argument", "unknown argument", "error while handling argument", "error: unrecognized"},
+		reason:   "llama-server rejected its arguments",
+		showLine: true,
+	},
+	{
+		patterns: []string{"couldn't bind", "address already in use", "failed to bind"},
+		reason:   "the port is already in use",
+	},
+	{
+		patterns: []string{"failed to open", "no such file", "failed to read magic", "failed to load model"},
+		reason:   "the model file could not be read; it may be missing, incomplete or not a GGUF file",
+	},
+}
+
+// failureReason explains why a server failed from the end of its output. It
+// falls back to the last line of output, and returns "" without any.
+func failureReason(lines []string) string {
+	for i := len(lines) - 1; i >= 0; i-- {
+		lower := strings.ToLower(lines[i])
+		for _, sig := range failureSignatures {
+			for _, pattern := range sig.patterns {
+				if !strings.Contains(lower, pattern) {
+					continue
+				}
+				if sig.showLine {
+					return sig.reason + ": " + strings.TrimSpace(lines[i])
+				}
+				return sig.reason
+			}
+		}
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/getlantern/systray"
+)
+
+// maxRecentModels is how many models the "Recent" submenu lists.
+const maxRecentModels = 10
+
+// namedMenuItem is a "Load Model" entry that stands for a model by name: a
+// favorite pinned at the top or an entry under "Recent", which is reused
+// across refreshes like instanceMenuSlot.
+type namedMenuItem struct {
+	item *systray.MenuItem
+	name string
+}
+
+func isFavorite(name string) bool {
+	return slices.ContainsFunc(config().Favorites, func(f string) bool { return strings.EqualFold(f, name) })
+}
+
+// toggleFavorite pins a model to the top of "Load Model" or unpins it.
+func toggleFavorite(name string) {
+	updateConfig(func(c *Config) {
+		if isFavorite(name) {
+			c.Favorites = slices.DeleteFunc(slices.Clone(c.Favorites), func(f string) bool { return strings.EqualFold(f, name) })
+		} else {
+			c.Favorites = append(slices.Clip(c.Favorites), name)
+		}
+	})
+	if err := saveConfig(); err != nil {
+		log.Printf("Failed to save config: %v", err)
+	}
+	addModelMenuItems()
+	refreshMenuState()
+}
+
+// addFavoriteMenuItems pins the favorites that are in the library at the top
+// of "Load Model", in the order they were added.
+func addFavoriteMenuItems() {
+	menuMu.Lock()
+	defer menuMu.Unlock()
+	for _, favorite := range menuItems.favorites {
+		favorite.item.Hide()
+	}
+	menuItems.favorites = nil
+
+	for _, name := range config().Favorites {
+		modelIdx, configIdx, ok := findModelByName(name)
+		if !ok {
+			continue
+		}
+		item := addLoadMenuItem(menuItems.loadModel, name, modelIdx, configIdx)
+		menuItems.favorites = append(menuItems.favorites, namedMenuItem{item: item, name: name})
+	}
+}
+
+// recordRecent moves a model that finished loading to the front of
+// recentModels.
+func recordRecent(name string) {
+	changed := false
+	updateConfig(func(c *Config) {
+		recent := []string{name}
+		for _, r := range c.RecentModels {
+			if !strings.EqualFold(r, name) && len(recent) < maxRecentModels {
+				recent = append(recent, r)
+			}
+		}
+		changed = !slices.Equal(recent, c.RecentModels)
+		c.RecentModels = recent
+	})
+	if !changed {
+		return
+	}
+	if err := saveConfig(); err != nil {
+		log.Printf("Failed to save config: %v", err)
+	}
+}
+
+// addRecentMenu adds the "Recent" submenu at the top of "Load Model".
+func addRecentMenu() {
+	menuItems.recent = menuItems.loadModel.AddSubMenuItem("Recent", "The models loaded most recently")
+}
+
+// refreshFavoriteAndRecentMenus updates the state shown in the favorites and
+// fills "Recent" with the recent models still in the library. Callers must
+// hold runningModelsMu.
+func refreshFavoriteAndRecentMenus() {
+	menuMu.Lock()
+	defer menuMu.Unlock()
+	for _, favorite := range menuItems.favorites {
+		if modelIdx, configIdx, ok := findModelByName(favorite.name); ok {
+			entry, _ := modelAt(modelIdx)
+			favorite.item.SetTitle("★ " + modelMenuTitle(favorite.name, entry.Path, configIdx))
+		}
+	}
+
+	var recent []string
+	for _, name := range config().RecentModels {
+		if _, _, ok := findModelByName(name); ok {
+			recent = append(recent, name)
+		}
+	}
+	for len(menuItems.recentSlots) < len(recent) {
+		slot := &namedMenuItem{item: menuItems.recent.AddSubMenuItem("", "Load this model")}
+		menuItems.recentSlots = append(menuItems.recentSlots, *slot)
+		idx := len(menuItems.recentSlots) - 1
+		go func() {
+			for range slot.item.ClickedCh {
+				menuMu.Lock()
+				name := menuItems.recentSlots[idx].name
+				menuMu.Unlock()
+				if modelIdx, configIdx, ok := findModelByName(name); ok {
+					loadModelWithOptions(modelIdx, configIdx, launchOptions{FromTray: true})
+				}
+			}
+		}()
+	}
+	for i := range menuItems.recentSlots {
+		slot := &menuItems.recentSlots[i]
+		if i < len(recent) {
+			modelIdx, configIdx, _ := findModelByName(recent[i])
+			entry, _ := modelAt(modelIdx)
+			slot.name = recent[i]
+			slot.item.SetTitle(modelMenuTitle(recent[i], entry.Path, configIdx))
+			slot.item.Show()
+		} else {
+			slot.name = ""
+			slot.item.Hide()
+		}
+	}
+	if len(recent) > 0 {
+		menuItems.recent.Enable()
+	} else {
+		menuItems.recent.Disable()
+	}
+}
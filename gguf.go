@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ggufTypeUint8 = iota
+	ggufTypeInt8
+	ggufTypeUint16
+	ggufTypeInt16
+	ggufTypeUint32
+	ggufTypeInt32
+	ggufTypeFloat32
+	ggufTypeBool
+	ggufTypeString
+	ggufTypeArray
+	ggufTypeUint64
+	ggufTypeInt64
+	ggufTypeFloat64
+)
+
+const (
+	maxGGUFStringLength = 64 << 20
+	maxGGUFIntArray     = 4096
+)
+
+// ggufMetadata holds the header fields lmgo uses from a GGUF file.
+type ggufMetadata struct {
+	Architecture    string
+	Name            string
+	SizeLabel       string
+	FileType        int
+	ParameterCount  int64
+	ChatTemplate    string
+	ContextLength   int
+	BlockCount      int
+	EmbeddingLength int
+	HeadCount       int
+	HeadCountKV     int
+	KeyLength       int
+	ValueLength     int
+}
+
+// readGGUFMetadata parses the key/value header of a GGUF file. Tensor data is
+// never read, so this is cheap even for very large models.
+func readGGUFMetadata(path string) (*ggufMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &ggufReader{r: bufio.NewReaderSize(f, 1<<16)}
+
+	var magic [4]byte
+	if _, err := io.ReadFull(r.r, magic[:]); err != nil {
+		return nil, err
+	}
+	if string(magic[:]) != "GGUF" {
+		return nil, fmt.Errorf("%s is not a GGUF file", path)
+	}
+
+	version := r.uint32()
+	if version < 2 {
+		return nil, fmt.Errorf("unsupported GGUF version %d", version)
+	}
+	tensorCount := r.uint64()
+	kvCount := r.uint64()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	values := map[string]any{}
+	for i := uint64(0); i < kvCount && r.err == nil; i++ {
+		key := r.string()
+		valueType := r.uint32()
+		value := r.value(valueType)
+		if value != nil {
+			values[key] = value
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to read GGUF header: %v", r.err)
+	}
+
+	meta := &ggufMetadata{}
+	// The tensor infos follow the key/values; their shapes add up to the
+	// parameter count. A file cut short just leaves the count at zero.
+	var params int64
+	for i := uint64(0); i < tensorCount && r.err == nil; i++ {
+		r.string() // name
+		dims := r.uint32()
+		elements := int64(1)
+		for d := uint32(0); d < dims && r.err == nil; d++ {
+			elements *= int64(r.uint64())
+		}
+		r.uint32() // type
+		r.uint64() // offset
+		params += elements
+	}
+	if r.err == nil {
+		meta.ParameterCount = params
+	}
+
+	meta.Architecture, _ = values["general.architecture"].(string)
+	meta.Name, _ = values["general.name"].(string)
+	meta.SizeLabel, _ = values["general.size_label"].(string)
+	meta.ChatTemplate, _ = values["tokenizer.chat_template"].(string)
+	meta.FileType = -1
+	if v, ok := values["general.file_type"]; ok {
+		meta.FileType = ggufInt(v)
+	}
+
+	arch := meta.Architecture
+	meta.ContextLength = ggufInt(values[arch+".context_length"])
+	meta.BlockCount = ggufInt(values[arch+".block_count"])
+	meta.EmbeddingLength = ggufInt(values[arch+".embedding_length"])
+	meta.HeadCount = ggufInt(values[arch+".attention.head_count"])
+	meta.HeadCountKV = ggufInt(values[arch+".attention.head_count_kv"])
+	meta.KeyLength = ggufInt(values[arch+".attention.key_length"])
+	meta.ValueLength = ggufInt(values[arch+".attention.value_length"])
+	if meta.HeadCountKV == 0 {
+		meta.HeadCountKV = meta.HeadCount
+	}
+
+	return meta, nil
+}
+
+// ggufFileTypes names the llama_ftype values stored in general.file_type.
+var ggufFileTypes = map[int]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 7: "Q8_0", 8: "Q5_0", 9: "Q5_1",
+	10: "Q2_K", 11: "Q3_K_S", 12: "Q3_K_M", 13: "Q3_K_L", 14: "Q4_K_S", 15: "Q4_K_M",
+	16: "Q5_K_S", 17: "Q5_K_M", 18: "Q6_K", 19: "IQ2_XXS", 20: "IQ2_XS", 21: "Q2_K_S",
+	22: "IQ3_XS", 23: "IQ3_XXS", 24: "IQ1_S", 25: "IQ4_NL", 26: "IQ3_S", 27: "IQ3_M",
+	28: "IQ2_S", 29: "IQ2_M", 30: "IQ4_XS", 31: "IQ1_M", 32: "BF16", 36: "TQ1_0",
+	37: "TQ2_0", 38: "MXFP4_MOE",
+}
+
+// Quantization returns the quantization name, or "" when the file does not
+// record one.
+func (m *ggufMetadata) Quantization() string {
+	if name, ok := ggufFileTypes[m.FileType]; ok {
+		return name
+	}
+	if m.FileType >= 0 {
+		return fmt.Sprintf("type %d", m.FileType)
+	}
+	return ""
+}
+
+// Parameters returns the size label the file records, e.g. "14B", or else
+// the parameter count rounded the same way.
+func (m *ggufMetadata) Parameters() string {
+	switch {
+	case m.SizeLabel != "":
+		return m.SizeLabel
+	case m.ParameterCount >= 1e9:
+		return fmt.Sprintf("%.1fB", float64(m.ParameterCount)/1e9)
+	case m.ParameterCount > 0:
+		return fmt.Sprintf("%.0fM", float64(m.ParameterCount)/1e6)
+	}
+	return ""
+}
+
+// modelSummary is the quantization and size of a model for menus, e.g.
+// "Q4_K_M · 8.5 GB", or just the size when the header cannot be read.
+func modelSummary(path string) string {
+	var parts []string
+	if meta, err := cachedGGUFMetadata(firstShardPath(path)); err == nil && meta.Quantization() != "" {
+		parts = append(parts, meta.Quantization())
+	}
+	if size := modelFileSize(path); size > 0 {
+		parts = append(parts, formatGB(size))
+	}
+	return strings.Join(parts, " · ")
+}
+
+type ggufCacheEntry struct {
+	modTime time.Time
+	size    int64
+	meta    *ggufMetadata
+	err     error
+}
+
+var (
+	ggufCacheMu sync.Mutex
+	ggufCache   = map[string]ggufCacheEntry{}
+)
+
+// cachedGGUFMetadata returns the metadata of path, reading the header again
+// only when the file changed since the last call.
+func cachedGGUFMetadata(path string) (*ggufMetadata, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ggufCacheMu.Lock()
+	entry, ok := ggufCache[path]
+	ggufCacheMu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.meta, entry.err
+	}
+
+	meta, err := readGGUFMetadata(path)
+	ggufCacheMu.Lock()
+	ggufCache[path] = ggufCacheEntry{modTime: info.ModTime(), size: info.Size(), meta: meta, err: err}
+	ggufCacheMu.Unlock()
+	return meta, err
+}
+
+// modelShardCount returns how many files a split GGUF model consists of.
+func modelShardCount(path string) int {
+	m := shardPattern.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return 1
+	}
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), m[1]+"-*-of-"+m[3]+".gguf"))
+	return max(1, len(matches))
+}
+
+// ggufInt converts a decoded integer value to int. Per-layer arrays (used by
+// some architectures for head counts) yield their largest element.
+func ggufInt(v any) int {
+	switch n := v.(type) {
+	case int64:
+		return int(n)
+	case []int64:
+		largest := int64(0)
+		for _, x := range n {
+			largest = max(largest, x)
+		}
+		return int(largest)
+	}
+	return 0
+}
+
+type ggufReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (g *ggufReader) read(n int) []byte {
+	if g.err != nil {
+		return nil
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(g.r, buf); err != nil {
+		g.err = err
+		return nil
+	}
+	return buf
+}
+
+func (g *ggufReader) skip(n uint64) {
+	if g.err != nil {
+		return
+	}
+	if _, err := g.r.Discard(int(n)); err != nil {
+		g.err = err
+	}
+}
+
+func (g *ggufReader) uint32() uint32 {
+	b := g.read(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (g *ggufReader) uint64() uint64 {
+	b := g.read(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (g *ggufReader) string() string {
+	n := g.uint64()
+	if n > maxGGUFStringLength {
+		g.err = fmt.Errorf("string of %d bytes exceeds limit", n)
+		return ""
+	}
+	return string(g.read(int(n)))
+}
+
+func ggufScalarSize(valueType uint32) int {
+	switch valueType {
+	case ggufTypeUint8, ggufTypeInt8, ggufTypeBool:
+		return 1
+	case ggufTypeUint16, ggufTypeInt16:
+		return 2
+	case ggufTypeUint32, ggufTypeInt32, ggufTypeFloat32:
+		return 4
+	case ggufTypeUint64, ggufTypeInt64, ggufTypeFloat64:
+		return 8
+	}
+	return 0
+}
+
+// value decodes one value. Integers are returned as int64, floats as float64;
+// small integer arrays are returned as []int64 and other arrays are skipped.
+func (g *ggufReader) value(valueType uint32) any {
+	switch valueType {
+	case ggufTypeString:
+		return g.string()
+	case ggufTypeArray:
+		elemType := g.uint32()
+		count := g.uint64()
+		if size := ggufScalarSize(elemType); size > 0 {
+			if count > maxGGUFIntArray || elemType == ggufTypeFloat32 || elemType == ggufTypeFloat64 {
+				g.skip(count * uint64(size))
+				return nil
+			}
+			values := make([]int64, 0, count)
+			for i := uint64(0); i < count; i++ {
+				if n, ok := g.value(elemType).(int64); ok {
+					values = append(values, n)
+				}
+			}
+			return values
+		}
+		for i := uint64(0); i < count && g.err == nil; i++ {
+			g.value(elemType)
+		}
+		return nil
+	}
+
+	size := ggufScalarSize(valueType)
+	if size == 0 {
+		g.err = fmt.Errorf("unknown GGUF value type %d", valueType)
+		return nil
+	}
+	b := g.read(size)
+	if b == nil {
+		return nil
+	}
+
+	switch valueType {
+	case ggufTypeUint8, ggufTypeBool:
+		return int64(b[0])
+	case ggufTypeInt8:
+		return int64(int8(b[0]))
+	case ggufTypeUint16:
+		return int64(binary.LittleEndian.Uint16(b))
+	case ggufTypeInt16:
+		return int64(int16(binary.LittleEndian.Uint16(b)))
+	case ggufTypeUint32:
+		return int64(binary.LittleEndian.Uint32(b))
+	case ggufTypeInt32:
+		return int64(int32(binary.LittleEndian.Uint32(b)))
+	case ggufTypeUint64:
+		return int64(binary.LittleEndian.Uint64(b))
+	case ggufTypeInt64:
+		return int64(binary.LittleEndian.Uint64(b))
+	case ggufTypeFloat32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case ggufTypeFloat64:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	}
+	return nil
+}
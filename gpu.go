@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gpuPollInterval is how often the tray tooltip's GPU usage is refreshed.
+const gpuPollInterval = 5 * time.Second
+
+// GPUUsage is the VRAM in bytes and, where the driver reports it, the load in
+// percent of one GPU.
+type GPUUsage struct {
+	Name        string `json:"name,omitempty"`
+	Used        uint64 `json:"used"`
+	Total       uint64 `json:"total"`
+	Utilization *int   `json:"utilization,omitempty"`
+}
+
+var (
+	lastGPUUsageMu sync.Mutex
+	lastGPUUsage   []GPUUsage
+)
+
+// gpuUsage reads every GPU the platform can report on, plus NVIDIA cards
+// through nvidia-smi, which ships with the driver and wraps NVML.
+func gpuUsage() []GPUUsage {
+	gpus := append(platformGPUUsage(), nvidiaGPUUsage()...)
+
+	lastGPUUsageMu.Lock()
+	lastGPUUsage = gpus
+	lastGPUUsageMu.Unlock()
+	return gpus
+}
+
+func nvidiaGPUUsage() []GPUUsage {
+	path, err := exec.LookPath("nvidia-smi")
+	if err != nil {
+		return nil
+	}
+	cmd := exec.Command(path, "--query-gpu=name,memory.used,memory.total,utilization.gpu", "--format=csv,noheader,nounits")
+	hideWindow(cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var gpus []GPUUsage
+	for _, line := range strings.Split(string(bytes.TrimSpace(out)), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			continue
+		}
+		used, err1 := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 64)
+		total, err2 := strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 64)
+		if err1 != nil || err2 != nil || total == 0 {
+			continue
+		}
+		gpu := GPUUsage{Name: strings.TrimSpace(fields[0]), Used: used << 20, Total: total << 20}
+		if percent, err := strconv.Atoi(strings.TrimSpace(fields[3])); err == nil {
+			gpu.Utilization = &percent
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus
+}
+
+func handleGPU(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: gpuUsage()})
+}
+
+// idleTooltip is the tray tooltip while nothing is loading: the VRAM and load
+// of each GPU from the last poll, so the headroom for another model shows on
+// hover.
+func idleTooltip() string {
+	lastGPUUsageMu.Lock()
+	gpus := lastGPUUsage
+	lastGPUUsageMu.Unlock()
+
+	tooltip := "lmgo Model Server"
+	for _, gpu := range gpus {
+		tooltip += fmt.Sprintf("\nVRAM %s / %s", formatGB(int64(gpu.Used)), formatGB(int64(gpu.Total)))
+		if gpu.Utilization != nil {
+			tooltip += fmt.Sprintf(", GPU %d%%", *gpu.Utilization)
+		}
+	}
+	return tooltip
+}
+
+// watchGPUs keeps the tray tooltip's GPU usage current. It leaves the tooltip
+// alone while a model loads, when it shows the progress instead.
+func watchGPUs() {
+	if headless {
+		return
+	}
+	for ; ; time.Sleep(gpuPollInterval) {
+		if len(gpuUsage()) == 0 || modelLoading() {
+			continue
+		}
+		setTooltip(idleTooltip())
+	}
+}
+
+func modelLoading() bool {
+	runningModelsMu.RLock()
+	defer runningModelsMu.RUnlock()
+
+	for _, instance := range runningModels {
+		if !instance.ready {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hookTimeout is how long a hook script may run before it is killed.
+const hookTimeout = 2 * time.Minute
+
+// hooksRunning tracks started llama-servers until their postUnloadHook has
+// run, so that lmgo can wait for the hooks on exit.
+var hooksRunning sync.WaitGroup
+
+// preLoadHook and postUnloadHook return the hook command that applies to an
+// entry: the one of its modelSpecificArgs entry if set, otherwise the global
+// one.
+func preLoadHook(entry modelEntry, configIndex int) string {
+	if cfg := modelSpecificConfig(entry, configIndex); cfg != nil && cfg.PreLoadHook != "" {
+		return cfg.PreLoadHook
+	}
+	return config().PreLoadHook
+}
+
+func postUnloadHook(entry modelEntry, configIndex int) string {
+	if cfg := modelSpecificConfig(entry, configIndex); cfg != nil && cfg.PostUnloadHook != "" {
+		return cfg.PostUnloadHook
+	}
+	return config().PostUnloadHook
+}
+
+// runHook runs a hook command through the shell, telling it about the model
+// in LMGO_HOOK, LMGO_MODEL, LMGO_MODEL_PATH and LMGO_PORT (empty before the
+// port is known). Its output goes to lmgo's log.
+func runHook(kind, command, name string, entry modelEntry, port int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(),
+		"LMGO_HOOK="+kind,
+		"LMGO_MODEL="+name,
+		"LMGO_MODEL_PATH="+entry.Path,
+		"LMGO_PORT="+portString(port),
+	)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	log.Printf("Running %s for %s: %s", kind, name, command)
+	err := cmd.Run()
+	if text := strings.TrimSpace(output.String()); text != "" {
+		log.Printf("%s output:\n%s", kind, text)
+	}
+	switch {
+	case ctx.Err() != nil:
+		return fmt.Errorf("%s did not finish within %v", kind, hookTimeout)
+	case err != nil:
+		return fmt.Errorf("%s failed: %v", kind, err)
+	}
+	return nil
+}
+
+func portString(port int) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(port)
+}
+
+// runPostUnloadHook runs the postUnloadHook of an instance whose llama-server
+// has stopped, for whatever reason.
+func runPostUnloadHook(instance *modelInstance) {
+	command := postUnloadHook(instance.entry, instance.configIndex)
+	if command == "" {
+		return
+	}
+	if err := runHook("postUnloadHook", command, displayName(instance), instance.entry, instance.port); err != nil {
+		log.Printf("Warning: %v", err)
+		notify("Hook Failed", fmt.Sprintf("%s: %v", displayName(instance), err))
+	}
+}
+
+// waitForHooks waits up to timeout for llama-servers to exit and their
+// post-unload hooks to finish.
+func waitForHooks(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		hooksRunning.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Warning: Exiting with hooks still running")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// idleCheckInterval is how often running instances are checked for
+// idleness.
+const idleCheckInterval = 30 * time.Second
+
+// idleTimeout returns the idleTimeoutMinutes that applies to an instance:
+// the model-specific value if set, otherwise the global one. Zero or less
+// means the instance is never unloaded for being idle.
+func idleTimeout(instance *modelInstance) time.Duration {
+	minutes := config().IdleTimeout
+	if cfg := modelSpecificConfig(instance.entry, instance.configIndex); cfg != nil && cfg.IdleTimeout != 0 {
+		minutes = cfg.IdleTimeout
+	}
+	return time.Duration(max(minutes, 0)) * time.Minute
+}
+
+// beginRequest records a request proxied to the instance on port and
+// returns the function to call once it is answered.
+func beginRequest(port int) func() {
+	runningModelsMu.Lock()
+	defer runningModelsMu.Unlock()
+
+	for _, instance := range runningModels {
+		if instance.port == port {
+			instance.activeRequests++
+			instance.lastUsed = time.Now()
+			return func() {
+				runningModelsMu.Lock()
+				instance.activeRequests--
+				instance.lastUsed = time.Now()
+				runningModelsMu.Unlock()
+			}
+		}
+	}
+	return func() {}
+}
+
+// watchIdleInstances unloads instances that have been idle for longer than
+// their idle timeout. Besides requests through the router, a busy slot
+// counts as use, which covers clients talking to the instance directly.
+func watchIdleInstances() {
+	for range time.Tick(idleCheckInterval) {
+		for _, instance := range idleInstances() {
+			if slots, err := slotList(instance.port); err == nil && slices.ContainsFunc(slots, func(s SlotInfo) bool { return s.Processing }) {
+				runningModelsMu.Lock()
+				instance.lastUsed = time.Now()
+				runningModelsMu.Unlock()
+				continue
+			}
+
+			minutes := int(idleTimeout(instance).Minutes())
+			if !unloadInstance(instance.port) {
+				continue
+			}
+			log.Printf("Unloaded %s on port %d after %d idle minutes", displayName(instance), instance.port, minutes)
+			notify("Model Unloaded", fmt.Sprintf("%s was idle for %d minutes and has been unloaded", displayName(instance), minutes))
+		}
+	}
+}
+
+func idleInstances() []*modelInstance {
+	runningModelsMu.RLock()
+	defer runningModelsMu.RUnlock()
+
+	var idle []*modelInstance
+	for _, instance := range runningModels {
+		timeout := idleTimeout(instance)
+		if !instance.ready || timeout == 0 || instance.activeRequests > 0 {
+			continue
+		}
+		if time.Since(instance.lastUsed) >= timeout {
+			idle = append(idle, instance)
+		}
+	}
+	return idle
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/getlantern/systray"
+)
+
+// ipcRequest is a command a second lmgo process sends to the running one
+// over the local control channel, e.g. for `lmgo load qwen2.5`. The channel
+// is a named pipe on Windows and a Unix socket elsewhere, so scripts can
+// drive lmgo without going through the HTTP API.
+type ipcRequest struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// ipcListener accepts connections on the control channel.
+type ipcListener interface {
+	Accept() (io.ReadWriteCloser, error)
+}
+
+var ipcCommands = map[string]func(args []string) APIResponse{
+	"list":   ipcList,
+	"status": ipcStatus,
+	"load":   ipcLoad,
+	"unload": ipcUnload,
+	"logs":   ipcLogs,
+	"test":   ipcTest,
+	"quit":   ipcQuit,
+}
+
+// ipcUsage lists the commands for messages about wrong invocations.
+const ipcUsage = `lmgo list                  the models that can be loaded, as JSON
+  lmgo status                the running instances, as JSON
+  lmgo load <name>...        load models and wait until they are ready
+  lmgo unload [name|port]    unload instances of a model, the one on a port, or all
+  lmgo logs <port> [lines]   the last lines of an instance's llama-server output
+  lmgo test <name|port>      send a short request and report latency and tokens/s
+  lmgo quit                  unload everything and exit`
+
+var (
+	quitOnce sync.Once
+	quitCh   = make(chan struct{})
+)
+
+// requestQuit shuts lmgo down as the Exit menu item does, or, without a
+// tray, as Ctrl+C does.
+func requestQuit() {
+	if headless {
+		quitOnce.Do(func() { close(quitCh) })
+		return
+	}
+	systray.Quit()
+}
+
+// serveIPC answers commands on the control channel until lmgo exits.
+func serveIPC() {
+	listener, err := listenIPC(config().BasePort)
+	if err != nil {
+		log.Printf("Warning: Failed to open control channel: %v", err)
+		return
+	}
+	log.Printf("Control channel listening on %s", ipcAddress(config().BasePort))
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("Control channel stopped: %v", err)
+			return
+		}
+		go serveIPCConn(conn)
+	}
+}
+
+func serveIPCConn(conn io.ReadWriteCloser) {
+	defer conn.Close()
+
+	var req ipcRequest
+	if err := json.NewDecoder(io.LimitReader(conn, 64<<10)).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(APIResponse{Success: false, Message: "Invalid request"})
+		return
+	}
+
+	run, ok := ipcCommands[req.Command]
+	if !ok {
+		json.NewEncoder(conn).Encode(APIResponse{Success: false, Message: fmt.Sprintf("unknown command %q", req.Command)})
+		return
+	}
+	log.Printf("Control channel: %s %s", req.Command, strings.Join(req.Args, " "))
+	json.NewEncoder(conn).Encode(run(req.Args))
+}
+
+func ipcList(args []string) APIResponse {
+	return APIResponse{Success: true, Data: modelList()}
+}
+
+func ipcStatus(args []string) APIResponse {
+	runningModelsMu.RLock()
+	defer runningModelsMu.RUnlock()
+	return APIResponse{Success: true, Data: instanceStatuses()}
+}
+
+// instancesNamed returns the running instances whose configuration or model
+// name is name.
+func instancesNamed(name string) []InstanceStatus {
+	name = resolveAlias(name)
+	runningModelsMu.RLock()
+	defer runningModelsMu.RUnlock()
+
+	var matches []InstanceStatus
+	for _, status := range instanceStatuses() {
+		if strings.EqualFold(status.Name, name) || strings.EqualFold(status.Model, strings.TrimSuffix(name, ".gguf")) {
+			matches = append(matches, status)
+		}
+	}
+	return matches
+}
+
+// ipcLoad loads the named models and answers once they are ready or have
+// failed, so a script can wait for them.
+func ipcLoad(names []string) APIResponse {
+	if len(names) == 0 {
+		return APIResponse{Success: false, Message: "usage: lmgo load <name>..."}
+	}
+
+	var failed []string
+	loaded := []InstanceStatus{}
+	for _, name := range names {
+		modelIdx, configIdx, ok := findModelByName(name)
+		if !ok {
+			failed = append(failed, fmt.Sprintf("%s: no such model", name))
+			continue
+		}
+		if err := loadModel(modelIdx, configIdx); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		loaded = append(loaded, instancesNamed(name)...)
+	}
+	if len(failed) > 0 {
+		return APIResponse{Success: false, Message: strings.Join(failed, "\n")}
+	}
+	return APIResponse{Success: true, Data: loaded}
+}
+
+// ipcUnload unloads the instance on a port, every instance of a model or,
+// without an argument, all instances. It answers with the freed ports.
+func ipcUnload(args []string) APIResponse {
+	switch {
+	case len(args) == 0:
+		all := []int{}
+		runningModelsMu.RLock()
+		for _, instance := range runningModels {
+			all = append(all, instance.port)
+		}
+		runningModelsMu.RUnlock()
+		unloadAllModels()
+		return APIResponse{Success: true, Data: map[string][]int{"unloaded": all}}
+	case len(args) > 1:
+		return APIResponse{Success: false, Message: "usage: lmgo unload [name|port]"}
+	}
+
+	var ports []int
+	if port, err := strconv.Atoi(args[0]); err == nil {
+		ports = []int{port}
+	} else {
+		for _, status := range instancesNamed(args[0]) {
+			ports = append(ports, status.Port)
+		}
+	}
+
+	unloaded := []int{}
+	for _, port := range ports {
+		if unloadInstance(port) {
+			unloaded = append(unloaded, port)
+		}
+	}
+	if len(unloaded) == 0 {
+		return APIResponse{Success: false, Message: fmt.Sprintf("no instance of %s is running", args[0])}
+	}
+	return APIResponse{Success: true, Data: map[string][]int{"unloaded": unloaded}}
+}
+
+// ipcLogs answers with the last lines of the output of the instance on a
+// port, 200 unless a count is given.
+func ipcLogs(args []string) APIResponse {
+	if len(args) == 0 || len(args) > 2 {
+		return APIResponse{Success: false, Message: "usage: lmgo logs <port> [lines]"}
+	}
+	port, err := strconv.Atoi(args[0])
+	if err != nil {
+		return APIResponse{Success: false, Message: fmt.Sprintf("invalid port %q", args[0])}
+	}
+	n := 200
+	if len(args) == 2 {
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+			return APIResponse{Success: false, Message: fmt.Sprintf("invalid line count %q", args[1])}
+		}
+	}
+
+	logSourcesMu.Lock()
+	source := logSources[port]
+	logSourcesMu.Unlock()
+	if source == nil {
+		return APIResponse{Success: false, Message: fmt.Sprintf("no log for port %d", port)}
+	}
+	lines, _ := source.logs.tail(n)
+	return APIResponse{Success: true, Message: strings.Join(lines, "\n")}
+}
+
+// ipcTest smoke tests the instance on a port, or the first instance of a
+// model, and answers with the TestResult.
+func ipcTest(args []string) APIResponse {
+	if len(args) != 1 {
+		return APIResponse{Success: false, Message: "usage: lmgo test <name|port>"}
+	}
+	port, err := strconv.Atoi(args[0])
+	if err != nil {
+		matches := instancesNamed(args[0])
+		if len(matches) == 0 {
+			return APIResponse{Success: false, Message: fmt.Sprintf("no instance of %s is running", args[0])}
+		}
+		port = matches[0].Port
+	}
+	result, err := testInstance(port)
+	if err != nil {
+		return APIResponse{Success: false, Message: fmt.Sprintf("test failed: %v", err)}
+	}
+	return APIResponse{Success: true, Data: result}
+}
+
+func ipcQuit(args []string) APIResponse {
+	go requestQuit()
+	return APIResponse{Success: true, Message: "lmgo is shutting down"}
+}
+
+// runCommand sends a command given on the command line to the running lmgo
+// and prints its answer: the data as indented JSON for scripts, otherwise the
+// message. Errors go to stderr. It returns the exit code.
+func runCommand(args []string) int {
+	if _, ok := ipcCommands[args[0]]; !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\nCommands:\n  %s\n", args[0], ipcUsage)
+		return 2
+	}
+
+	conn, err := dialIPC(config().BasePort)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lmgo is not running on port %d: %v\n", config().BasePort, err)
+		return 1
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(ipcRequest{Command: args[0], Args: args[1:]}); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to send command: %v\n", err)
+		return 1
+	}
+	var resp APIResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read answer: %v\n", err)
+		return 1
+	}
+
+	if !resp.Success {
+		fmt.Fprintln(os.Stderr, resp.Message)
+		return 1
+	}
+	if resp.Data != nil {
+		data, err := json.MarshalIndent(resp.Data, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode answer: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(data))
+		return 0
+	}
+	if resp.Message != "" {
+		fmt.Println(resp.Message)
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// ipcAddress is the path of the control socket for the API port.
+func ipcAddress(port int) string {
+	dir, err := runtimeDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, fmt.Sprintf("lmgo-%d.sock", port))
+}
+
+type socketListener struct {
+	net.Listener
+}
+
+func (l socketListener) Accept() (io.ReadWriteCloser, error) {
+	return l.Listener.Accept()
+}
+
+// listenIPC opens the control socket, readable by the current user only. A
+// socket left behind by a crashed run is replaced; the single-instance lock
+// guarantees no other lmgo on this port is using it.
+func listenIPC(port int) (ipcListener, error) {
+	path := ipcAddress(port)
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return socketListener{listener}, nil
+}
+
+func dialIPC(port int) (io.ReadWriteCloser, error) {
+	return net.Dial("unix", ipcAddress(port))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// ipcAddress is the name of the control pipe for the API port.
+func ipcAddress(port int) string {
+	return fmt.Sprintf(`\\.\pipe\lmgo-%d`, port)
+}
+
+// pipeListener keeps one pipe instance waiting for the next client, so the
+// pipe exists even while a connection is being handed off.
+type pipeListener struct {
+	name *uint16
+	next windows.Handle
+}
+
+// pipeConn is one end of a connection on the control pipe.
+type pipeConn struct {
+	handle windows.Handle
+	server bool
+}
+
+func (c *pipeConn) Read(p []byte) (int, error) {
+	var n uint32
+	err := windows.ReadFile(c.handle, p, &n, nil)
+	if errors.Is(err, windows.ERROR_BROKEN_PIPE) || (err == nil && n == 0 && len(p) > 0) {
+		return 0, io.EOF
+	}
+	return int(n), err
+}
+
+func (c *pipeConn) Write(p []byte) (int, error) {
+	var n uint32
+	err := windows.WriteFile(c.handle, p, &n, nil)
+	return int(n), err
+}
+
+func (c *pipeConn) Close() error {
+	if c.server {
+		windows.FlushFileBuffers(c.handle)
+		windows.DisconnectNamedPipe(c.handle)
+	}
+	return windows.CloseHandle(c.handle)
+}
+
+// listenIPC prepares the control pipe. Its default security only lets the
+// user running lmgo and administrators write to it, and remote clients are
+// rejected.
+func listenIPC(port int) (ipcListener, error) {
+	name, err := windows.UTF16PtrFromString(ipcAddress(port))
+	if err != nil {
+		return nil, err
+	}
+	l := &pipeListener{name: name}
+	if l.next, err = l.createInstance(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *pipeListener) createInstance() (windows.Handle, error) {
+	return windows.CreateNamedPipe(l.name,
+		windows.PIPE_ACCESS_DUPLEX,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+		windows.PIPE_UNLIMITED_INSTANCES, 4096, 4096, 0, nil)
+}
+
+// Accept waits for a client on the waiting instance and creates the next one
+// before handing the connection over.
+func (l *pipeListener) Accept() (io.ReadWriteCloser, error) {
+	handle := l.next
+	if err := windows.ConnectNamedPipe(handle, nil); err != nil && !errors.Is(err, windows.ERROR_PIPE_CONNECTED) {
+		return nil, err
+	}
+	next, err := l.createInstance()
+	if err != nil {
+		windows.DisconnectNamedPipe(handle)
+		windows.CloseHandle(handle)
+		return nil, err
+	}
+	l.next = next
+	return &pipeConn{handle: handle, server: true}, nil
+}
+
+// dialIPC connects to the control pipe, retrying briefly while the waiting
+// instance is taken by another client.
+func dialIPC(port int) (io.ReadWriteCloser, error) {
+	name, err := windows.UTF16PtrFromString(ipcAddress(port))
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		handle, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+		if err == nil {
+			return &pipeConn{handle: handle}, nil
+		}
+		if !errors.Is(err, windows.ERROR_PIPE_BUSY) || time.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
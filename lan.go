@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// apiListen is where the management API listens. The port and TLS stay what
+// lmgo started with; the host follows apiHost.
+var apiListen struct {
+	host string
+	port int
+	tls  bool
+}
+
+// apiAddress is the listen address for host and port; an empty host listens
+// on all interfaces.
+func apiAddress(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// apiBaseURL is the URL this machine reaches the management API at.
+func apiBaseURL() string {
+	host := apiListen.host
+	if ip := net.ParseIP(host); host == "" || ip.IsUnspecified() || isLoopbackHost(host) {
+		host = "localhost"
+	}
+	return apiScheme() + "://" + apiAddress(host, apiListen.port)
+}
+
+// serveAPI runs server until it is shut down.
+func serveAPI(server *http.Server) {
+	log.Printf("API server starting on %s", server.Addr)
+	var err error
+	if server.TLSConfig != nil {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Printf("API server error: %v", err)
+		notify("API Server Failed", fmt.Sprintf("Cannot listen on %s: %v", server.Addr, err))
+	}
+}
+
+// applyAPIHost moves the management API to a changed apiHost, keeping its
+// port.
+func applyAPIHost() {
+	if apiServer == nil || config().APIHost == apiListen.host {
+		return
+	}
+	old := apiServer
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	old.Shutdown(ctx)
+
+	apiListen.host = config().APIHost
+	apiServer = &http.Server{Addr: apiAddress(apiListen.host, apiListen.port), Handler: old.Handler, TLSConfig: old.TLSConfig}
+	apiServer.RegisterOnShutdown(closeEventStreams)
+	go serveAPI(apiServer)
+}
+
+// serverHost is the --host lmgo gives the llama-server of an entry: the host
+// of its modelSpecificArgs entry, otherwise serverHost. Empty leaves the
+// arguments alone.
+func serverHost(entry modelEntry, configIndex int) string {
+	if cfg := modelSpecificConfig(entry, configIndex); cfg != nil && cfg.Host != "" {
+		return cfg.Host
+	}
+	return config().ServerHost
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// lanAccessAllowed reports whether both the management API and the
+// llama-servers are reachable from other machines, as the "Allow LAN Access"
+// menu item shows.
+func lanAccessAllowed() bool {
+	return !isLoopbackHost(config().APIHost) && config().ServerHost == "0.0.0.0"
+}
+
+// setLANAccess opens the management API and llama-servers to the network or
+// restricts them to this machine. Running instances keep their address until
+// they are loaded again.
+func setLANAccess(allow bool) {
+	host, title := "127.0.0.1", "LAN Access Disabled"
+	if allow {
+		host, title = "0.0.0.0", "LAN Access Enabled"
+	}
+	updateConfig(func(c *Config) {
+		c.APIHost = host
+		c.ServerHost = host
+	})
+	if err := saveConfig(); err != nil {
+		log.Printf("Failed to save config: %v", err)
+	}
+	applyAPIHost()
+	refreshMenuState()
+
+	message := fmt.Sprintf("The API now listens on %s.", apiAddress(host, apiListen.port))
+	runningModelsMu.RLock()
+	if len(runningModels) > 0 {
+		message += " Running models keep their address until they are loaded again."
+	}
+	runningModelsMu.RUnlock()
+	notify(title, message)
+}
+
+// validateHosts checks apiHost, serverHost and the host of each
+// modelSpecificArgs entry. lmgo reaches llama-server through 127.0.0.1, so a
+// server host is either that or all interfaces.
+func validateHosts(c *Config) error {
+	if host := c.APIHost; host != "" && host != "localhost" && net.ParseIP(host) == nil {
+		return fmt.Errorf("apiHost: invalid address %q, expected an IP address such as 127.0.0.1 or 0.0.0.0", host)
+	}
+	if err := validateServerHost("serverHost", c.ServerHost); err != nil {
+		return err
+	}
+	for i, cfg := range c.ModelSpecificArgs {
+		if err := validateServerHost(fmt.Sprintf("modelSpecificArgs[%d].host", i), cfg.Host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateServerHost(field, host string) error {
+	switch host {
+	case "", "127.0.0.1", "0.0.0.0":
+		return nil
+	}
+	return fmt.Errorf("%s: invalid address %q, expected 127.0.0.1 or 0.0.0.0", field, host)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type ArgsResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Data    struct {
+		Args []string `json:"args"`
+	} `json:"data"`
+}
+
+type argsMsg struct {
+	index int
+	args  []string
+}
+
+func newArgsInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "Args: "
+	input.CharLimit = 4096
+	return input
+}
+
+// openArgsEditor fetches the launch arguments of the selected model so they
+// can be edited before loading it with "L".
+func openArgsEditor(m Model) (Model, tea.Cmd) {
+	model, ok := m.selectedModel()
+	if !ok {
+		return m, nil
+	}
+	if m, blocked := m.offlineBlocked("load"); blocked {
+		return m, nil
+	}
+	return m, fetchArgs(m.client, model.Index)
+}
+
+func (m Model) handleArgsMsg(msg argsMsg) (Model, tea.Cmd) {
+	model, ok := m.selectedModel()
+	if !ok || model.Index != msg.index || m.state == StateLoadingModel {
+		return m, nil
+	}
+	m.editingArgs = true
+	m.argsInput.SetValue(joinArgs(msg.args))
+	m.argsInput.CursorEnd()
+	return m, m.argsInput.Focus()
+}
+
+// handleArgsKey edits the launch arguments. Enter loads the selected model
+// with them, Esc cancels.
+func handleArgsKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "esc":
+		m.editingArgs = false
+		m.argsInput.Blur()
+		return m, nil
+
+	case "enter":
+		args, err := parseArgs(m.argsInput.Value())
+		if err != nil {
+			m.state = StateError
+			m.message = "✗ " + err.Error()
+			m.messageTime = time.Now()
+			return m, nil
+		}
+		model, ok := m.selectedModel()
+		m.editingArgs = false
+		m.argsInput.Blur()
+		if !ok {
+			return m, nil
+		}
+		// A one-off launch starts another instance if the model runs.
+		return startLoad(m, model, true, args)
+	}
+
+	var cmd tea.Cmd
+	m.argsInput, cmd = m.argsInput.Update(msg)
+	return m, cmd
+}
+
+// joinArgs renders args as one editable line, quoting those that contain
+// spaces or quotes.
+func joinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"\\") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// parseArgs splits a line edited by the user back into arguments. Double
+// quotes group words, and a backslash escapes the next character inside
+// them.
+func parseArgs(line string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inQuotes, hasArg, escaped := false, false, false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case inQuotes && r == '\\':
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+			hasArg = true
+		case !inQuotes && (r == ' ' || r == '\t'):
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if inQuotes {
+		return nil, errors.New("unterminated quote in args")
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultBenchRuns is how many measured runs a bench makes after the
+// warm-up run.
+const defaultBenchRuns = 3
+
+// benchTokens is how many tokens each run generates. EOS is ignored so every
+// run generates exactly this many.
+const benchTokens = 128
+
+// benchPrompt is long enough for a meaningful prompt processing rate.
+var benchPrompt = strings.Repeat("The quick brown fox jumps over the lazy dog. ", 40) +
+	"Continue this text with a long story about the fox."
+
+// benchResult is the throughput of one run in tokens per second.
+type benchResult struct {
+	prompt     float64
+	generation float64
+}
+
+type benchRun struct {
+	name   string
+	cancel context.CancelFunc
+}
+
+type benchDoneMsg struct {
+	name    string
+	results []benchResult
+	err     error
+}
+
+// runBench makes a warm-up run, which loads caches and is not counted, then
+// runs measured runs against the instance at endpoint. progress is called
+// before every run with its number, 0 being the warm-up.
+func runBench(ctx context.Context, endpoint, token string, runs int, progress func(run int)) ([]benchResult, error) {
+	var results []benchResult
+	for run := 0; run <= runs; run++ {
+		if progress != nil {
+			progress(run)
+		}
+		result, err := benchOnce(ctx, endpoint, token)
+		if err != nil {
+			return nil, err
+		}
+		if run > 0 {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// benchOnce sends one fixed-size completion request and reads the timings
+// llama-server adds to its response.
+func benchOnce(ctx context.Context, endpoint, token string) (benchResult, error) {
+	body, err := json.Marshal(map[string]any{
+		"messages":     []chatMessage{{Role: "user", Content: benchPrompt}},
+		"max_tokens":   benchTokens,
+		"temperature":  0,
+		"ignore_eos":   true,
+		"cache_prompt": false,
+	})
+	if err != nil {
+		return benchResult{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return benchResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	authorize(req, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return benchResult{}, context.Canceled
+		}
+		return benchResult{}, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Timings *struct {
+			PromptPerSecond    float64 `json:"prompt_per_second"`
+			PredictedPerSecond float64 `json:"predicted_per_second"`
+		} `json:"timings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil && resp.StatusCode == http.StatusOK {
+		return benchResult{}, fmt.Errorf("failed to parse response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if data.Error.Message == "" {
+			data.Error.Message = resp.Status
+		}
+		return benchResult{}, fmt.Errorf("completion failed: %s", data.Error.Message)
+	}
+	if data.Timings == nil {
+		return benchResult{}, errors.New("the server reported no timings; is it llama-server?")
+	}
+	return benchResult{prompt: data.Timings.PromptPerSecond, generation: data.Timings.PredictedPerSecond}, nil
+}
+
+// benchMedian returns the median prompt and generation rates.
+func benchMedian(results []benchResult) benchResult {
+	median := func(values []float64) float64 {
+		slices.Sort(values)
+		n := len(values)
+		if n == 0 {
+			return 0
+		}
+		if n%2 == 1 {
+			return values[n/2]
+		}
+		return (values[n/2-1] + values[n/2]) / 2
+	}
+
+	var prompt, generation []float64
+	for _, r := range results {
+		prompt = append(prompt, r.prompt)
+		generation = append(generation, r.generation)
+	}
+	return benchResult{prompt: median(prompt), generation: median(generation)}
+}
+
+// startBench benchmarks the instance chat would open against. Only one
+// bench runs at a time; Esc or "x" cancels it.
+func startBench(m Model) (Model, tea.Cmd) {
+	if m.bench != nil {
+		m.state = StateError
+		m.message = fmt.Sprintf("✗ A bench of %s is already running (Esc/x: cancel it)", m.bench.name)
+		m.messageTime = time.Now()
+		return m, nil
+	}
+	target, ok := m.chatTarget()
+	if !ok || !target.Healthy {
+		m.state = StateError
+		m.message = "✗ No model is ready: load one before running a bench"
+		m.messageTime = time.Now()
+		return m, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.bench = &benchRun{name: target.Name, cancel: cancel}
+	endpoint, token := instanceURL(m.client.baseURL, target.Port), m.client.token
+	runs := m.benchRuns
+	return m, func() tea.Msg {
+		results, err := runBench(ctx, endpoint, token, runs, nil)
+		return benchDoneMsg{name: target.Name, results: results, err: err}
+	}
+}
+
+func (m Model) handleBenchDone(msg benchDoneMsg) (Model, tea.Cmd) {
+	if m.bench != nil {
+		m.bench.cancel()
+	}
+	m.bench = nil
+
+	switch {
+	case errors.Is(msg.err, context.Canceled):
+		m.state = StateSuccess
+		m.message = fmt.Sprintf("✓ Bench of %s cancelled", msg.name)
+	case msg.err != nil:
+		m.state = StateError
+		m.message = fmt.Sprintf("✗ Bench of %s failed: %v", msg.name, msg.err)
+	default:
+		median := benchMedian(msg.results)
+		m.state = StateSuccess
+		m.message = fmt.Sprintf("✓ %s: prompt %.1f t/s, generation %.1f t/s (median of %d runs)",
+			msg.name, median.prompt, median.generation, len(msg.results))
+	}
+	m.keepResultOnScreen()
+	return m, nil
+}
+
+// benchCommand is "lmc bench [flags] [model or port]", which prints a table
+// of the runs instead of starting the interface.
+func benchCommand(args []string) int {
+	fs := flag.NewFlagSet("lmc bench", flag.ContinueOnError)
+	runs := fs.Int("n", 0, fmt.Sprintf("number of measured runs after the warm-up (default %d, or benchRuns in the config file)", defaultBenchRuns))
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lmc bench [flags] [model name or port]")
+		fs.PrintDefaults()
+	}
+	opts, err := resolveOptions(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *runs < 0 {
+		fmt.Fprintln(os.Stderr, "lmc bench: -n must be at least 1")
+		return 2
+	}
+	if *runs > 0 {
+		opts.benchRuns = *runs
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p := opts.profiles[opts.active]
+	client := apiClient{baseURL: p.URL, token: p.Token, ctx: ctx}
+	target, err := benchTarget(client, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lmc bench: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Benchmarking %s on port %d: %d runs after a warm-up, %d tokens each\n",
+		target.Name, target.Port, opts.benchRuns, benchTokens)
+	results, err := runBench(ctx, instanceURL(p.URL, target.Port), p.Token, opts.benchRuns, func(run int) {
+		if run == 0 {
+			fmt.Fprintln(os.Stderr, "warm-up…")
+		} else {
+			fmt.Fprintf(os.Stderr, "run %d/%d…\n", run, opts.benchRuns)
+		}
+	})
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "lmc bench: cancelled")
+		return 130
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lmc bench: %v\n", err)
+		return 1
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "run\tprompt t/s\tgeneration t/s\t")
+	for i, r := range results {
+		fmt.Fprintf(w, "%d\t%.1f\t%.1f\t\n", i+1, r.prompt, r.generation)
+	}
+	median := benchMedian(results)
+	fmt.Fprintf(w, "median\t%.1f\t%.1f\t\n", median.prompt, median.generation)
+	w.Flush()
+	return 0
+}
+
+// benchTarget picks the instance to benchmark: the one on the given port, the
+// one whose model name matches, or the only ready instance.
+func benchTarget(c apiClient, arg string) (InstanceInfo, error) {
+	var data InstancesResponse
+	if err := c.request(http.MethodGet, "/api/instances", &data); err != nil {
+		return InstanceInfo{}, fmt.Errorf("failed to fetch instances: %v", err)
+	}
+	var ready []InstanceInfo
+	for _, inst := range data.Data {
+		if inst.Healthy {
+			ready = append(ready, inst)
+		}
+	}
+	if len(ready) == 0 {
+		return InstanceInfo{}, errors.New("no model is ready: load one first")
+	}
+
+	if arg == "" {
+		if len(ready) > 1 {
+			return InstanceInfo{}, fmt.Errorf("%d instances are running: name a model or port", len(ready))
+		}
+		return ready[0], nil
+	}
+
+	if port, err := strconv.Atoi(arg); err == nil {
+		for _, inst := range ready {
+			if inst.Port == port {
+				return inst, nil
+			}
+		}
+		return InstanceInfo{}, fmt.Errorf("no ready instance on port %d", port)
+	}
+	m := Model{instances: ready}
+	idx, err := m.findInstance(arg)
+	if err != nil {
+		return InstanceInfo{}, err
+	}
+	return ready[idx], nil
+}
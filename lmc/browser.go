@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type browserMsg struct {
+	url string
+	err error
+}
+
+// openWebUI opens the llama-server web UI of the instance under the cursor,
+// or of the selected model's instance, in the local browser.
+func openWebUI(m Model) (Model, tea.Cmd) {
+	target, ok := m.chatTarget()
+	if !ok {
+		m.state = StateError
+		m.message = "✗ No model is running: load one before opening its web UI"
+		m.messageTime = time.Now()
+		return m, nil
+	}
+
+	url := instanceURL(m.client.baseURL, target.Port)
+	return m, func() tea.Msg {
+		return browserMsg{url: url, err: openBrowser(url)}
+	}
+}
+
+// openBrowser opens url with the platform's default handler. It fails early
+// on Linux sessions without a display, such as plain SSH logins, where
+// xdg-open would fall back to a text browser inside lmc's terminal.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return errors.New("no graphical session")
+		}
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v", cmd.Args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// SystemPrompt is a named system message the chat view can start the
+// conversation with.
+type SystemPrompt struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+
+	// failed marks an inline error entry, which is never sent back to the
+	// model.
+	failed bool
+}
+
+// chatModel is the conversation view opened with "c". It talks directly to
+// the llama-server of one running instance.
+type chatModel struct {
+	input    textarea.Model
+	viewport viewport.Model
+
+	instance InstanceInfo
+	messages []chatMessage
+	styles   styles
+
+	// prompts are the configured system prompts; system is the index of the
+	// one in use, or -1 for none. It applies from the next message on.
+	prompts []SystemPrompt
+	system  int
+
+	streaming bool
+	streamID  int
+	cancel    context.CancelFunc
+	events    <-chan chatEvent
+}
+
+// chatEvent is one piece of a streamed reply: a text delta, or the end of the
+// stream with an optional error.
+type chatEvent struct {
+	text string
+	done bool
+	err  error
+}
+
+type chatEventMsg struct {
+	id    int
+	event chatEvent
+}
+
+func newChatModel(st styles, prompts []SystemPrompt) chatModel {
+	input := textarea.New()
+	input.Placeholder = "Ask something…"
+	input.ShowLineNumbers = false
+	input.SetHeight(3)
+	input.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
+
+	return chatModel{
+		input:    input,
+		viewport: viewport.New(0, 0),
+		styles:   st,
+		prompts:  prompts,
+		system:   -1,
+	}
+}
+
+// chatTarget picks the instance the chat view opens against: the selected
+// row of the Running pane, else an instance of the selected model, else the
+// first running instance.
+func (m Model) chatTarget() (InstanceInfo, bool) {
+	if len(m.instances) == 0 {
+		return InstanceInfo{}, false
+	}
+	if m.focus == PaneInstances && m.instanceIdx < len(m.instances) {
+		return m.instances[m.instanceIdx], true
+	}
+	if model, ok := m.selectedModel(); ok {
+		for _, inst := range m.instances {
+			if inst.Name == model.Name {
+				return inst, true
+			}
+		}
+	}
+	return m.instances[0], true
+}
+
+func openChat(m Model) (Model, tea.Cmd) {
+	target, ok := m.chatTarget()
+	if !ok {
+		m.state = StateError
+		m.message = "✗ No model is running: load one before chatting"
+		m.messageTime = time.Now()
+		return m, nil
+	}
+
+	if m.chat.instance.Port != target.Port {
+		m.chat.messages = nil
+	}
+	m.chat.instance = target
+	m.chatting = true
+	m.chat.resize(m.windowWidth, m.windowHeight)
+	m.chat.refresh()
+	return m, m.chat.input.Focus()
+}
+
+func handleChatKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.chat.stop()
+		m.chatting = false
+		m.chat.input.Blur()
+		return m, nil
+
+	case "ctrl+c":
+		// Abort the reply in progress; never quit from the chat view.
+		m.chat.stop()
+		return m, nil
+
+	case "tab":
+		if m.chat.streaming || len(m.instances) < 2 {
+			return m, nil
+		}
+		next := 0
+		for i, inst := range m.instances {
+			if inst.Port == m.chat.instance.Port {
+				next = (i + 1) % len(m.instances)
+			}
+		}
+		m.chat.instance = m.instances[next]
+		m.chat.messages = nil
+		m.chat.refresh()
+		return m, nil
+
+	case "ctrl+p":
+		if len(m.chat.prompts) > 0 {
+			m.chat.system++
+			if m.chat.system >= len(m.chat.prompts) {
+				m.chat.system = -1
+			}
+		}
+		return m, nil
+
+	case "ctrl+l":
+		if !m.chat.streaming {
+			m.chat.messages = nil
+			m.chat.refresh()
+		}
+		return m, nil
+
+	case "pgup", "pgdown":
+		var cmd tea.Cmd
+		m.chat.viewport, cmd = m.chat.viewport.Update(msg)
+		return m, cmd
+
+	case "enter":
+		prompt := strings.TrimSpace(m.chat.input.Value())
+		if prompt == "" || m.chat.streaming {
+			return m, nil
+		}
+		m.chat.input.Reset()
+		return m, m.chat.send(m.client, prompt)
+	}
+
+	var cmd tea.Cmd
+	m.chat.input, cmd = m.chat.input.Update(msg)
+	return m, cmd
+}
+
+// send appends the prompt to the conversation and starts streaming the reply.
+func (c *chatModel) send(client apiClient, prompt string) tea.Cmd {
+	c.messages = append(c.messages, chatMessage{Role: "user", Content: prompt})
+
+	var history []chatMessage
+	if c.system >= 0 {
+		history = append(history, chatMessage{Role: "system", Content: c.prompts[c.system].Prompt})
+	}
+	for _, msg := range c.messages {
+		if !msg.failed && msg.Content != "" {
+			history = append(history, msg)
+		}
+	}
+	c.messages = append(c.messages, chatMessage{Role: "assistant"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.streaming = true
+	c.streamID++
+	c.events = streamChat(ctx, instanceURL(client.baseURL, c.instance.Port)+"/v1/chat/completions", client.token, history)
+	c.refresh()
+
+	return waitChatEvent(c.streamID, c.events)
+}
+
+// stop cancels the reply in progress. The partial answer stays in the
+// transcript.
+func (c *chatModel) stop() {
+	if !c.streaming {
+		return
+	}
+	c.cancel()
+	c.streaming = false
+	c.streamID++
+	c.appendError("cancelled")
+}
+
+func (c *chatModel) handleEvent(msg chatEventMsg) tea.Cmd {
+	if msg.id != c.streamID {
+		return nil
+	}
+
+	if msg.event.text != "" {
+		c.messages[len(c.messages)-1].Content += msg.event.text
+	}
+	if msg.event.done {
+		c.streaming = false
+		c.cancel()
+		if msg.event.err != nil {
+			c.appendError(msg.event.err.Error())
+		}
+		c.refresh()
+		return nil
+	}
+
+	c.refresh()
+	return waitChatEvent(c.streamID, c.events)
+}
+
+func (c *chatModel) appendError(text string) {
+	c.messages = append(c.messages, chatMessage{Role: "error", Content: text, failed: true})
+	c.refresh()
+}
+
+func (c *chatModel) resize(width, height int) {
+	c.viewport.Width = max(10, width-6)
+	c.viewport.Height = max(3, height-12)
+	c.input.SetWidth(max(10, width-6))
+	c.refresh()
+}
+
+// refresh re-renders the transcript into the viewport and keeps the newest
+// text in view.
+func (c *chatModel) refresh() {
+	userStyle := c.styles.user
+	botStyle := c.styles.assistant
+	errStyle := c.styles.bad.UnsetBold()
+	wrap := lipgloss.NewStyle().Width(max(10, c.viewport.Width))
+
+	var b strings.Builder
+	for i, msg := range c.messages {
+		switch msg.Role {
+		case "user":
+			b.WriteString(userStyle.Render("You") + "\n")
+			b.WriteString(wrap.Render(msg.Content))
+		case "assistant":
+			b.WriteString(botStyle.Render(c.instance.Name) + "\n")
+			content := msg.Content
+			if content == "" && c.streaming && i == len(c.messages)-1 {
+				content = "…"
+			}
+			b.WriteString(wrap.Render(content))
+		default:
+			b.WriteString(errStyle.Render(wrap.Render("✗ " + msg.Content)))
+		}
+		b.WriteString("\n\n")
+	}
+
+	c.viewport.SetContent(b.String())
+	c.viewport.GotoBottom()
+}
+
+func (m Model) chatView() string {
+	titleStyle := m.styles.title.MarginBottom(1)
+	boxStyle := m.styles.box
+	helpStyle := m.styles.help
+
+	inst := m.chat.instance
+	title := fmt.Sprintf("Chat · %s :%d", inst.Name, inst.Port)
+	if m.chat.system >= 0 {
+		title += " · " + m.chat.prompts[m.chat.system].Name
+	}
+	title = titleStyle.Render(title)
+
+	helpText := "Enter: Send | Alt+Enter: Newline | Ctrl+L: Clear | PgUp/PgDn: Scroll | Esc: Back"
+	if m.chat.streaming {
+		helpText = "Ctrl+C: Stop reply | PgUp/PgDn: Scroll | Esc: Back"
+	} else {
+		if len(m.chat.prompts) > 0 {
+			helpText += " | Ctrl+P: System prompt"
+		}
+		if len(m.instances) > 1 {
+			helpText += " | Tab: Next instance"
+		}
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		title,
+		boxStyle.Render(m.chat.viewport.View()),
+		boxStyle.Render(m.chat.input.View()),
+		helpStyle.Render(helpText),
+	)
+}
+
+// instanceURL is the address of an instance's llama-server, which runs on the
+// same host as lmgo. A wildcard address such as 0.0.0.0 is not reachable as a
+// destination, so it maps to the loopback address.
+func instanceURL(baseURL string, port int) string {
+	host := "127.0.0.1"
+	if u, err := url.Parse(baseURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func waitChatEvent(id int, events <-chan chatEvent) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			event = chatEvent{done: true}
+		}
+		return chatEventMsg{id: id, event: event}
+	}
+}
+
+// streamChat posts a streaming chat completion request and delivers the
+// server-sent events as text deltas. The channel is closed after the final
+// event; cancelling ctx aborts the HTTP stream.
+func streamChat(ctx context.Context, endpoint, token string, messages []chatMessage) <-chan chatEvent {
+	events := make(chan chatEvent, 16)
+
+	go func() {
+		defer close(events)
+
+		send := func(event chatEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		body, err := json.Marshal(map[string]any{
+			"messages": messages,
+			"stream":   true,
+		})
+		if err != nil {
+			send(chatEvent{done: true, err: err})
+			return
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			send(chatEvent{done: true, err: err})
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+		authorize(req, token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			send(chatEvent{done: true, err: fmt.Errorf("request failed: %v", err)})
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			var apiErr struct {
+				Error struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			json.NewDecoder(resp.Body).Decode(&apiErr)
+			if apiErr.Error.Message == "" {
+				apiErr.Error.Message = resp.Status
+			}
+			send(chatEvent{done: true, err: fmt.Errorf("completion failed: %s", apiErr.Error.Message)})
+			return
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			data, ok := strings.CutPrefix(line, "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				send(chatEvent{done: true})
+				return
+			}
+
+			var chunk struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+				} `json:"choices"`
+				Error *struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				continue
+			}
+			if chunk.Error != nil {
+				send(chatEvent{done: true, err: fmt.Errorf("completion failed: %s", chunk.Error.Message)})
+				return
+			}
+			for _, choice := range chunk.Choices {
+				if choice.Delta.Content != "" && !send(chatEvent{text: choice.Delta.Content}) {
+					return
+				}
+			}
+		}
+
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			send(chatEvent{done: true, err: fmt.Errorf("stream interrupted: %v", err)})
+			return
+		}
+		send(chatEvent{done: true})
+	}()
+
+	return events
+}
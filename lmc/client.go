@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// errUnauthorized is returned when the server rejects the API token.
+var errUnauthorized = errors.New("authentication failed — check your token")
+
+// errUntrustedCertificate is returned when the server's HTTPS certificate
+// cannot be verified.
+var errUntrustedCertificate = errors.New("the server's certificate is not trusted — pass --insecure to accept a self-signed one")
+
+// apiClient talks to one lmgo server. Every request is bound to ctx so that
+// switching servers cancels whatever is still in flight against the old one.
+type apiClient struct {
+	baseURL string
+	token   string
+	ctx     context.Context
+	gen     int
+}
+
+// skipCertificateCheck makes every request accept any HTTPS certificate.
+// lmc only talks to lmgo servers chosen by the user, whose certificate is
+// often the self-signed one lmgo generates.
+func skipCertificateCheck() {
+	transport := http.DefaultTransport.(*http.Transport)
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+}
+
+// authorize adds the API token to a request. Requests to instances carry it
+// too, since lmgo can make llama-server require the same keys.
+func authorize(req *http.Request, token string) {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// serverMsg tags a response with the client generation that produced it so
+// that late replies from a previous server can be dropped.
+type serverMsg struct {
+	gen int
+	msg tea.Msg
+}
+
+func (c apiClient) cmd(fn func() tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return serverMsg{gen: c.gen, msg: fn()}
+	}
+}
+
+func (c apiClient) request(method, path string, out any) error {
+	return c.send(method, path, nil, out)
+}
+
+// send is request with a JSON body; a nil in sends none.
+func (c apiClient) send(method, path string, in, out any) error {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	authorize(req, c.token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) {
+			return errUntrustedCertificate
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return errUnauthorized
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+	return nil
+}
+
+func fetchModels(c apiClient) tea.Cmd {
+	return c.cmd(func() tea.Msg {
+		var data ModelsResponse
+		if err := c.request(http.MethodGet, "/api/models", &data); err != nil {
+			return fetchFailedMsg{text: fmt.Sprintf("Failed to fetch models: %v", err)}
+		}
+		return modelsMsg(data)
+	})
+}
+
+// fetchInstances also measures the round trip for the status bar.
+func fetchInstances(c apiClient) tea.Cmd {
+	return c.cmd(func() tea.Msg {
+		start := time.Now()
+		var data InstancesResponse
+		if err := c.request(http.MethodGet, "/api/instances", &data); err != nil {
+			return fetchFailedMsg{text: fmt.Sprintf("Failed to fetch instances: %v", err)}
+		}
+		return instancesMsg{InstancesResponse: data, latency: time.Since(start)}
+	})
+}
+
+func fetchHealth(c apiClient) tea.Cmd {
+	return c.cmd(func() tea.Msg {
+		var data HealthStatus
+		if err := c.request(http.MethodGet, "/api/health", &data); err != nil {
+			// A rejected token is an answer, not an outage.
+			return fetchFailedMsg{text: fmt.Sprintf("Health check failed: %v", err), health: !errors.Is(err, errUnauthorized)}
+		}
+		return healthMsg(data)
+	})
+}
+
+// loadModel loads the model at index. With another set the server starts an
+// additional instance even if the model is already running. Non-nil args
+// replace the server's launch arguments for this load only. The result is
+// tagged with id, see loadDoneMsg.
+func loadModel(c apiClient, id, index int, another bool, args []string) tea.Cmd {
+	return c.cmd(func() tea.Msg {
+		return loadDoneMsg{id: id, result: requestLoad(c, index, another, args)}
+	})
+}
+
+// requestLoad posts the load request and returns a successMsg or errorMsg.
+func requestLoad(c apiClient, index int, another bool, args []string) tea.Msg {
+	start := time.Now()
+
+	path := fmt.Sprintf("/api/load?index=%d", index)
+	if another {
+		path += "&new=1"
+	}
+	var body any
+	if args != nil {
+		body = map[string][]string{"args": args}
+	}
+
+	var data SimpleResponse
+	if err := c.send(http.MethodPost, path, body, &data); err != nil {
+		return errorMsg(fmt.Sprintf("Failed to load model: %v", err))
+	}
+
+	elapsed := time.Since(start)
+
+	if !data.Success {
+		return errorMsg(fmt.Sprintf("Load failed: %s", data.Message))
+	}
+
+	message := data.Message
+	if args != nil {
+		message += " with args: " + joinArgs(args)
+	}
+	return successMsg{message: message, time: elapsed}
+}
+
+// fetchArgs asks the server which arguments a load of the model at index
+// would use.
+func fetchArgs(c apiClient, index int) tea.Cmd {
+	return c.cmd(func() tea.Msg {
+		var data ArgsResponse
+		if err := c.request(http.MethodGet, fmt.Sprintf("/api/args?index=%d", index), &data); err != nil {
+			return errorMsg(fmt.Sprintf("Failed to fetch launch args: %v", err))
+		}
+		if !data.Success {
+			return errorMsg(fmt.Sprintf("Failed to fetch launch args: %s", data.Message))
+		}
+		return argsMsg{index: index, args: data.Data.Args}
+	})
+}
+
+// unloadInstance stops the instance on port, or every instance when port is 0.
+func unloadInstance(c apiClient, port int) tea.Cmd {
+	return c.cmd(func() tea.Msg {
+		return requestUnload(c, port)
+	})
+}
+
+// requestUnload unloads the instance on port, or every instance for port 0,
+// and returns a successMsg or errorMsg.
+func requestUnload(c apiClient, port int) tea.Msg {
+	start := time.Now()
+
+	path := "/api/unload"
+	if port > 0 {
+		path = fmt.Sprintf("/api/unload?port=%d", port)
+	}
+
+	var data SimpleResponse
+	if err := c.request(http.MethodPost, path, &data); err != nil {
+		return errorMsg(fmt.Sprintf("Failed to unload model: %v", err))
+	}
+
+	if !data.Success {
+		return errorMsg(fmt.Sprintf("Unload failed: %s", data.Message))
+	}
+
+	elapsed := time.Since(start)
+	return successMsg{message: data.Message, time: elapsed}
+}
+
+// probeProfile checks whether a profile's server answers within a short
+// timeout. It is not bound to the active client so it never blocks a switch.
+func probeProfile(idx int, p Profile) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		c := apiClient{baseURL: p.URL, token: p.Token, ctx: ctx}
+		var data HealthStatus
+		err := c.request(http.MethodGet, "/api/health", &data)
+		return profileProbeMsg{index: idx, online: err == nil || errors.Is(err, errUnauthorized)}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/termenv"
+)
+
+type clipboardMsg struct {
+	text string
+	name string
+	via  string
+}
+
+// yankEndpoint copies the OpenAI-compatible base URL of the instance under
+// the cursor, or of the selected model's instance, to the clipboard.
+func yankEndpoint(m Model) (Model, tea.Cmd) {
+	target, ok := m.chatTarget()
+	if !ok {
+		m.state = StateError
+		m.message = "✗ Nothing to copy: no model is running"
+		m.messageTime = time.Now()
+		return m, nil
+	}
+
+	url := instanceURL(m.client.baseURL, target.Port) + "/v1"
+	return m, func() tea.Msg {
+		return clipboardMsg{text: url, name: target.Name, via: copyToClipboard(url)}
+	}
+}
+
+// copyToClipboard puts text on the clipboard with the platform's tool and
+// returns what it used. Over SSH, or without a tool, it asks the terminal
+// to do it with an OSC 52 sequence, which reaches the clipboard of the
+// machine the terminal runs on; terminals that do not support it ignore it.
+func copyToClipboard(text string) string {
+	if os.Getenv("SSH_TTY") == "" && os.Getenv("SSH_CONNECTION") == "" {
+		if cmd := clipboardCommand(); cmd != nil {
+			cmd.Stdin = strings.NewReader(text)
+			if err := cmd.Run(); err == nil {
+				return cmd.Args[0]
+			}
+		}
+	}
+	termenv.Copy(text)
+	return "terminal"
+}
+
+func clipboardCommand() *exec.Cmd {
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("clip")
+	case "darwin":
+		return exec.Command("pbcopy")
+	}
+
+	type tool struct {
+		env  string
+		args []string
+	}
+	for _, t := range []tool{
+		{"WAYLAND_DISPLAY", []string{"wl-copy"}},
+		{"DISPLAY", []string{"xclip", "-selection", "clipboard"}},
+		{"DISPLAY", []string{"xsel", "--clipboard", "--input"}},
+	} {
+		if os.Getenv(t.env) == "" {
+			continue
+		}
+		if _, err := exec.LookPath(t.args[0]); err == nil {
+			return exec.Command(t.args[0], t.args[1:]...)
+		}
+	}
+	return nil
+}
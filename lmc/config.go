@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Profile is a named lmgo server that lmc can switch to at runtime.
+type Profile struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Token string `json:"token,omitempty"`
+}
+
+const defaultPollInterval = time.Second
+
+// defaultLoadTimeout is a little longer than the 5 minutes lmgo waits for a
+// model itself, so a load normally fails on the server first.
+const defaultLoadTimeout = 6 * time.Minute
+
+// options are the startup settings resolved from flags, the environment and
+// the user config file.
+type options struct {
+	profiles     []Profile
+	active       int
+	pollInterval time.Duration
+	loadTimeout  time.Duration
+	benchRuns    int
+	notify       bool
+	sortBy       string
+	groupBy      string
+	filter       string
+	themeName    string
+	theme        Theme
+
+	systemPrompts []SystemPrompt
+
+	// configPath is the user config file, or "" when there is none to
+	// save preferences to.
+	configPath string
+}
+
+// flagError is a command line that fs could not parse, which the flag set has
+// already reported.
+type flagError struct{ error }
+
+func (e flagError) Unwrap() error { return e.error }
+
+// resolveOptions defines the common flags on fs and parses args with it.
+// Subcommands pass their own flag set with their extra flags already defined.
+func resolveOptions(fs *flag.FlagSet, args []string) (options, error) {
+	var server, profile, token, poll, loadTimeout string
+	var noColor, insecure bool
+	fs.StringVar(&server, "server", "", "lmgo server URL, e.g. http://127.0.0.1:8080")
+	fs.StringVar(&server, "s", "", "shorthand for --server")
+	fs.StringVar(&server, "host", "", "alias for --server; a bare host:port is taken as http")
+	fs.StringVar(&profile, "profile", "", "name of a server profile from the config file")
+	fs.StringVar(&token, "token", "", "API token for the server lmc starts with (default $LMC_TOKEN)")
+	fs.StringVar(&poll, "poll", "", "how often to refresh status and health, e.g. 5s")
+	fs.StringVar(&loadTimeout, "load-timeout", "", "how long to wait for a model to load, e.g. 10m; 0 waits forever")
+	fs.BoolVar(&noColor, "no-color", false, "disable colors (also set by NO_COLOR)")
+	fs.BoolVar(&insecure, "insecure", false, "accept self-signed HTTPS certificates")
+	if err := fs.Parse(args); err != nil {
+		return options{}, flagError{err}
+	}
+
+	cfg, path, err := loadConfig()
+	if err != nil {
+		return options{}, err
+	}
+
+	var opts options
+	if opts.profiles, opts.active, err = resolveProfiles(cfg, path, server, profile); err != nil {
+		return options{}, err
+	}
+
+	// A token given on the command line or in the environment is meant for
+	// the server lmc starts with, so it never leaks to other profiles.
+	if token == "" {
+		token = os.Getenv("LMC_TOKEN")
+	}
+	if token = strings.TrimSpace(token); token != "" {
+		opts.profiles[opts.active].Token = token
+	}
+
+	opts.pollInterval = defaultPollInterval
+	source := "--poll"
+	if poll == "" {
+		poll = cfg.PollInterval
+		source = "pollInterval in " + path
+	}
+	if poll != "" {
+		d, err := time.ParseDuration(poll)
+		if err != nil || d < 100*time.Millisecond {
+			return options{}, fmt.Errorf("invalid poll interval %q (from %s): expected a duration of at least 100ms, e.g. 5s", poll, source)
+		}
+		opts.pollInterval = d
+	}
+
+	opts.loadTimeout = defaultLoadTimeout
+	source = "--load-timeout"
+	if loadTimeout == "" {
+		loadTimeout = cfg.LoadTimeout
+		source = "loadTimeout in " + path
+	}
+	if loadTimeout != "" {
+		d, err := time.ParseDuration(loadTimeout)
+		if err != nil || d < 0 {
+			return options{}, fmt.Errorf("invalid load timeout %q (from %s): expected a duration such as 10m, or 0 to wait forever", loadTimeout, source)
+		}
+		opts.loadTimeout = d
+	}
+
+	opts.benchRuns = defaultBenchRuns
+	if cfg.BenchRuns != 0 {
+		if cfg.BenchRuns < 1 {
+			return options{}, fmt.Errorf("invalid benchRuns %d in %s: expected at least 1", cfg.BenchRuns, path)
+		}
+		opts.benchRuns = cfg.BenchRuns
+	}
+
+	opts.notify = cfg.NotifyOnComplete
+
+	for i, p := range cfg.SystemPrompts {
+		if strings.TrimSpace(p.Name) == "" || strings.TrimSpace(p.Prompt) == "" {
+			return options{}, fmt.Errorf("invalid systemPrompts[%d] in %s: expected a name and a prompt", i, path)
+		}
+	}
+	opts.systemPrompts = cfg.SystemPrompts
+
+	if insecure || cfg.Insecure {
+		skipCertificateCheck()
+	}
+
+	if opts.themeName, opts.theme, err = resolveTheme(cfg.Theme, cfg.Colors, noColor); err != nil {
+		return options{}, fmt.Errorf("%v in %s", err, path)
+	}
+
+	if slices.Contains(sortModes, cfg.Sort) {
+		opts.sortBy = cfg.Sort
+	}
+	if slices.Contains(groupModes, cfg.Group) {
+		opts.groupBy = cfg.Group
+	}
+	opts.filter = cfg.Filter
+	if filepath.IsAbs(path) {
+		opts.configPath = path
+	}
+	return opts, nil
+}
+
+// resolveProfiles builds the list of servers lmc can switch between and picks
+// the one to start with. The --server flag (also -s and --host) and
+// LMC_SERVER take precedence, then --profile, then the "server" field of the
+// user config file, which falls back to the built-in default.
+func resolveProfiles(cfg Config, path, server, profile string) ([]Profile, int, error) {
+	var err error
+	var profiles []Profile
+	if cfg.Server != "" {
+		profiles = append(profiles, Profile{Name: "default", URL: cfg.Server})
+	}
+	profiles = append(profiles, cfg.Profiles...)
+
+	for i := range profiles {
+		if profiles[i].Name == "" {
+			profiles[i].Name = fmt.Sprintf("profile %d", i+1)
+		}
+		source := fmt.Sprintf("profile %q in %s", profiles[i].Name, path)
+		if profiles[i].URL, err = validateServerURL(profiles[i].URL, source); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if server == "" {
+		server = os.Getenv("LMC_SERVER")
+		if server != "" {
+			if server, err = validateServerURL(server, "LMC_SERVER"); err != nil {
+				return nil, 0, err
+			}
+		}
+	} else if server, err = validateServerURL(server, "--server"); err != nil {
+		return nil, 0, err
+	}
+
+	if server != "" {
+		for i, p := range profiles {
+			if p.URL == server {
+				return profiles, i, nil
+			}
+		}
+		return append([]Profile{{Name: "command line", URL: server}}, profiles...), 0, nil
+	}
+
+	if profile != "" {
+		var names []string
+		for i, p := range profiles {
+			if strings.EqualFold(p.Name, profile) {
+				return profiles, i, nil
+			}
+			names = append(names, p.Name)
+		}
+		return nil, 0, fmt.Errorf("unknown profile %q (available: %s)", profile, strings.Join(names, ", "))
+	}
+
+	if len(profiles) == 0 {
+		profiles = append(profiles, Profile{Name: "default", URL: defaultConfig().Server})
+	}
+	return profiles, 0, nil
+}
+
+// validateServerURL normalizes a server address and rejects anything that is
+// not an http(s) URL with a host. A missing scheme defaults to http.
+func validateServerURL(raw, source string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("no server URL configured (from %s)", source)
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid server URL %q (from %s): expected something like http://127.0.0.1:8080", raw, source)
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+func defaultConfig() Config {
+	var cfg Config
+	if data, err := defaultConfigFS.ReadFile("baseURL.json"); err == nil {
+		json.Unmarshal(data, &cfg)
+	}
+	if cfg.Server == "" {
+		cfg.Server = cfg.BaseURL
+	}
+	if cfg.Server == "" {
+		cfg.Server = "http://127.0.0.1:8080"
+	}
+	cfg.BaseURL = ""
+	return cfg
+}
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lmc", "config.json"), nil
+}
+
+// legacyConfig reads lmc.json or baseURL.json next to the executable, which
+// older versions of lmc used.
+func legacyConfig() (Config, bool) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return Config{}, false
+	}
+	if runtime.GOOS != "windows" {
+		if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+			exePath = resolved
+		}
+	}
+
+	for _, name := range []string{"lmc.json", "baseURL.json"} {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(exePath), name))
+		if err != nil {
+			continue
+		}
+		var cfg Config
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			continue
+		}
+		if cfg.Server == "" {
+			cfg.Server = cfg.BaseURL
+		}
+		cfg.BaseURL = ""
+		if cfg.Server != "" {
+			return cfg, true
+		}
+	}
+	return Config{}, false
+}
+
+// loadConfig reads the user config file, creating it on first run from a
+// legacy config next to the executable or from the built-in default.
+func loadConfig() (Config, string, error) {
+	path, err := configPath()
+	if err != nil {
+		return defaultConfig(), "built-in default", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var cfg Config
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return Config{}, path, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		if cfg.Server == "" {
+			cfg.Server = cfg.BaseURL
+		}
+		return cfg, path, nil
+	}
+	if !os.IsNotExist(err) {
+		return Config{}, path, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	cfg, ok := legacyConfig()
+	if !ok {
+		cfg = defaultConfig()
+	}
+	if err := saveConfig(path, cfg); err != nil {
+		return cfg, "built-in default", nil
+	}
+	return cfg, path, nil
+}
+
+// updateConfig applies change to the config file as it is on disk, so
+// settings edited while lmc runs are kept.
+func updateConfig(path string, change func(*Config)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	change(&cfg)
+	return saveConfig(path, cfg)
+}
+
+func saveConfig(path string, cfg Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
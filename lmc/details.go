@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// wideWindowWidth is the terminal width from which the details pane is shown
+// as a third column without pressing "i".
+const wideWindowWidth = 150
+
+// detailsVisible reports whether the details pane is on screen.
+func (m Model) detailsVisible() bool {
+	return m.showDetails || m.windowWidth >= wideWindowWidth
+}
+
+// detailsView describes the model under the cursor, or the instance under it
+// when the running list has focus. Fields the server did not report are shown
+// as "—", which keeps the pane usable with older servers.
+func (m Model) detailsView(width int, labelStyle lipgloss.Style) string {
+	if m.focus == PaneInstances && m.instanceIdx < len(m.instances) {
+		return instanceDetailsView(m.instances[m.instanceIdx], width, labelStyle)
+	}
+	model, ok := m.selectedModel()
+	if !ok {
+		return "No model selected"
+	}
+
+	unknown := "—"
+	field := func(label, value string) string {
+		if value == "" {
+			value = unknown
+		}
+		return labelStyle.Render(label+": ") + value
+	}
+
+	size := ""
+	if model.Size > 0 {
+		size = formatSize(model.Size)
+		if model.Shards > 1 {
+			size += fmt.Sprintf(" (%d shards)", model.Shards)
+		}
+	}
+	ctx := ""
+	if model.ContextLength > 0 {
+		ctx = fmt.Sprintf("%d", model.ContextLength)
+	}
+	args := "no"
+	if model.HasConfig {
+		args = "yes"
+	}
+	template := ""
+	if model.HasChatTemplate != nil {
+		template = "none"
+		if *model.HasChatTemplate {
+			template = "embedded"
+		}
+	}
+
+	lines := []string{
+		wrapText(model.Name, width),
+		labelStyle.Render("Path:"),
+		wrapText(model.Path, width),
+		field("Size", size),
+		field("Quantization", model.Quantization),
+		field("Parameters", model.Parameters),
+		field("Context length", ctx),
+		field("Architecture", model.Architecture),
+		field("Chat template", template),
+		field("Model-specific args", args),
+	}
+	return strings.Join(lines, "\n")
+}
+
+// instanceDetailsView describes a running instance: when it started and
+// became ready, how often it was restarted and how its last health check went.
+func instanceDetailsView(inst InstanceInfo, width int, labelStyle lipgloss.Style) string {
+	field := func(label, value string) string {
+		if value == "" {
+			value = "—"
+		}
+		return labelStyle.Render(label+": ") + value
+	}
+
+	started, ready, health := "", "", ""
+	if !inst.StartedAt.IsZero() {
+		started = inst.StartedAt.Local().Format("15:04:05")
+	}
+	switch {
+	case inst.loading():
+		ready = "loading"
+	case !inst.ReadyAt.IsZero() && !inst.StartedAt.IsZero():
+		ready = fmt.Sprintf("%s (after %s)", inst.ReadyAt.Local().Format("15:04:05"),
+			formatUptime(int64(inst.ReadyAt.Sub(inst.StartedAt).Seconds())))
+	}
+	if !inst.LastHealthCheck.IsZero() {
+		health = inst.LastHealthCheck.Local().Format("15:04:05") + ", ok"
+		if inst.HealthFailures > 0 {
+			health = fmt.Sprintf("%s, %d failed in a row", inst.LastHealthCheck.Local().Format("15:04:05"), inst.HealthFailures)
+		}
+	}
+
+	name := inst.Name
+	if inst.InstanceNum > 1 {
+		name = fmt.Sprintf("%s #%d", name, inst.InstanceNum)
+	}
+	lines := []string{
+		wrapText(name, width),
+		field("Port", fmt.Sprintf("%d", inst.Port)),
+		field("Started", started),
+		field("Ready", ready),
+		field("Uptime", formatUptime(inst.Uptime)),
+		field("Restarts", fmt.Sprintf("%d", inst.Restarts)),
+		field("Last health check", health),
+	}
+	return strings.Join(lines, "\n")
+}
+
+// minSummaryNameWidth is the room a model name needs before the quantization
+// and size are shown next to it in the list.
+const minSummaryNameWidth = 16
+
+// summary is the quantization and size shown after the name in the model
+// list, e.g. "Q4_K_M 8.5 GB".
+func (model ModelInfo) summary() string {
+	var parts []string
+	if model.Quantization != "" {
+		parts = append(parts, model.Quantization)
+	}
+	if model.Size > 0 {
+		parts = append(parts, formatSize(model.Size))
+	}
+	return strings.Join(parts, " ")
+}
+
+// wrapText breaks s into lines of at most width terminal cells, which also
+// splits long paths that contain no spaces.
+func wrapText(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	return ansi.Hardwrap(s, width, true)
+}
+
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
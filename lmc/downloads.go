@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DownloadInfo is one entry of GET /api/downloads. Size is 0 while the
+// server does not know it; Speed is in bytes per second.
+type DownloadInfo struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	URL        string  `json:"url"`
+	Size       int64   `json:"size"`
+	Downloaded int64   `json:"downloaded"`
+	Speed      float64 `json:"speed"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error"`
+}
+
+type DownloadsResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message"`
+	Data    []DownloadInfo `json:"data"`
+}
+
+// downloadsModel is the downloads view opened with "D".
+type downloadsModel struct {
+	list     []DownloadInfo
+	selected int
+	loaded   bool
+
+	input    textinput.Model
+	entering bool
+	status   string
+}
+
+type (
+	downloadsMsg struct {
+		list []DownloadInfo
+		err  error
+	}
+	downloadStartedMsg struct {
+		message string
+		err     error
+	}
+)
+
+func newDownloadsModel() downloadsModel {
+	input := textinput.New()
+	input.Prompt = "Download: "
+	input.Placeholder = "owner/repo file.gguf, owner/repo/file.gguf or a URL"
+	input.CharLimit = 1024
+	return downloadsModel{input: input}
+}
+
+func openDownloads(m Model) (Model, tea.Cmd) {
+	m.viewingDownloads = true
+	m.downloads.status = ""
+	return m, fetchDownloads(m.client)
+}
+
+func fetchDownloads(c apiClient) tea.Cmd {
+	return c.cmd(func() tea.Msg {
+		var data DownloadsResponse
+		if err := c.request(http.MethodGet, "/api/downloads", &data); err != nil {
+			return downloadsMsg{err: err}
+		}
+		if !data.Success {
+			return downloadsMsg{err: fmt.Errorf("%s", data.Message)}
+		}
+		return downloadsMsg{list: data.Data}
+	})
+}
+
+// requestDownload starts a download on the server. line is a URL, or a repo
+// and the path of a file in it, separated by a space or a slash.
+func requestDownload(c apiClient, line string) tea.Cmd {
+	body := map[string]string{}
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 1 && strings.Contains(fields[0], "://"):
+		body["url"] = fields[0]
+	case len(fields) == 2:
+		body["repo"], body["file"] = fields[0], fields[1]
+	case len(fields) == 1 && strings.Count(fields[0], "/") >= 2:
+		parts := strings.SplitN(fields[0], "/", 3)
+		body["repo"], body["file"] = parts[0]+"/"+parts[1], parts[2]
+	default:
+		return func() tea.Msg {
+			return downloadStartedMsg{err: fmt.Errorf("expected owner/repo file.gguf, owner/repo/file.gguf or a URL")}
+		}
+	}
+
+	return c.cmd(func() tea.Msg {
+		var data SimpleResponse
+		if err := c.send(http.MethodPost, "/api/downloads", body, &data); err != nil {
+			return downloadStartedMsg{err: err}
+		}
+		if !data.Success {
+			return downloadStartedMsg{err: fmt.Errorf("%s", data.Message)}
+		}
+		return downloadStartedMsg{message: data.Message}
+	})
+}
+
+func cancelDownload(c apiClient, id int) tea.Cmd {
+	return c.cmd(func() tea.Msg {
+		var data SimpleResponse
+		if err := c.request(http.MethodPost, fmt.Sprintf("/api/downloads/cancel?id=%d", id), &data); err != nil {
+			return downloadStartedMsg{err: err}
+		}
+		if !data.Success {
+			return downloadStartedMsg{err: fmt.Errorf("%s", data.Message)}
+		}
+		return downloadStartedMsg{message: data.Message}
+	})
+}
+
+// downloading reports whether any download is still in progress, which keeps
+// the list polled even while the view is closed.
+func (d downloadsModel) downloading() bool {
+	for _, dl := range d.list {
+		if dl.Status == "downloading" {
+			return true
+		}
+	}
+	return false
+}
+
+// handleDownloadsMsg updates the list. A download that completed since the
+// last update refreshes the model list so the new file can be loaded.
+func (m Model) handleDownloadsMsg(msg tea.Msg) (Model, tea.Cmd) {
+	d := &m.downloads
+	switch msg := msg.(type) {
+	case downloadsMsg:
+		if msg.err != nil {
+			d.status = "✗ " + msg.err.Error()
+			return m, nil
+		}
+		wasDownloading := map[int]bool{}
+		for _, dl := range d.list {
+			wasDownloading[dl.ID] = dl.Status == "downloading"
+		}
+		d.list = msg.list
+		d.loaded = true
+		d.selected = min(d.selected, max(0, len(d.list)-1))
+
+		for _, dl := range d.list {
+			if dl.Status == "completed" && wasDownloading[dl.ID] {
+				d.status = fmt.Sprintf("✓ %s downloaded", dl.Name)
+				if !m.viewingDownloads && (m.state == StateReady || m.state == StateModelSelected) {
+					m.state = StateSuccess
+					m.message = d.status
+					m.messageTime = time.Now()
+				}
+				return m, fetchModels(m.client)
+			}
+		}
+		return m, nil
+
+	case downloadStartedMsg:
+		if msg.err != nil {
+			d.status = "✗ " + msg.err.Error()
+			return m, nil
+		}
+		d.status = "✓ " + msg.message
+		return m, fetchDownloads(m.client)
+	}
+	return m, nil
+}
+
+func handleDownloadsKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	d := &m.downloads
+
+	if d.entering {
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "esc":
+			d.entering = false
+			d.input.Blur()
+			return m, nil
+		case "enter":
+			line := strings.TrimSpace(d.input.Value())
+			d.entering = false
+			d.input.Blur()
+			d.input.SetValue("")
+			if line == "" {
+				return m, nil
+			}
+			d.status = "Starting download…"
+			return m, requestDownload(m.client, line)
+		}
+		var cmd tea.Cmd
+		d.input, cmd = d.input.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "esc", "q", "D":
+		m.viewingDownloads = false
+		return m, nil
+
+	case "up", "k":
+		if len(d.list) > 0 {
+			d.selected = (d.selected - 1 + len(d.list)) % len(d.list)
+		}
+
+	case "down", "j":
+		if len(d.list) > 0 {
+			d.selected = (d.selected + 1) % len(d.list)
+		}
+
+	case "n":
+		if m, blocked := m.offlineBlocked("download"); blocked {
+			m.downloads.status = "✗ " + m.offlineBanner()
+			return m, nil
+		}
+		d.entering = true
+		return m, d.input.Focus()
+
+	case "x":
+		if d.selected < len(d.list) && d.list[d.selected].Status == "downloading" {
+			return m, cancelDownload(m.client, d.list[d.selected].ID)
+		}
+		d.status = "✗ Select a download in progress to cancel it"
+
+	case "r":
+		return m, fetchDownloads(m.client)
+	}
+	return m, nil
+}
+
+func (m Model) downloadsView() string {
+	st := m.styles
+	d := m.downloads
+	width := max(40, m.windowWidth-8)
+
+	var rows []string
+	switch {
+	case !d.loaded:
+		rows = append(rows, "Loading…")
+	case len(d.list) == 0:
+		rows = append(rows, st.help.Render("No downloads yet. Press N to start one."))
+	}
+	for i, dl := range d.list {
+		row := fmt.Sprintf("%s  %s", truncateString(dl.Name, width/2), downloadProgress(dl))
+		switch dl.Status {
+		case "completed":
+			row += "  " + st.good.Render("done")
+		case "failed":
+			row += "  " + st.bad.Render("failed: "+dl.Error)
+		case "cancelled":
+			row += "  " + st.help.Render("cancelled")
+		}
+		row = truncateString(row, width)
+		if i == d.selected {
+			row = st.selected.Render("➤ " + row)
+		} else {
+			row = st.item.Render("  " + row)
+		}
+		rows = append(rows, row)
+	}
+
+	footer := d.status
+	if d.entering {
+		d.input.Width = max(10, width-14)
+		footer = d.input.View()
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		st.title.Render("Downloads"),
+		st.box.Width(width).Render(strings.Join(rows, "\n")),
+		footer,
+		st.help.Render("↑↓: Select | N: New download | X: Cancel selected | R: Refresh | Esc: Back"),
+	)
+}
+
+// downloadProgress renders size, percentage and speed, e.g.
+// "1.2 GB / 4.5 GB  27%  35.0 MB/s".
+func downloadProgress(dl DownloadInfo) string {
+	if dl.Size <= 0 {
+		return formatSize(dl.Downloaded)
+	}
+	text := fmt.Sprintf("%s / %s  %d%%", formatSize(dl.Downloaded), formatSize(dl.Size), dl.Downloaded*100/dl.Size)
+	if dl.Status == "downloading" && dl.Speed > 0 {
+		text += fmt.Sprintf("  %s/s", formatSize(int64(dl.Speed)))
+	}
+	return text
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// eventsPollInterval is how often instances, health and resources are
+	// still polled while the event stream reports changes as they happen.
+	eventsPollInterval = 5 * time.Second
+	// eventsRetryDelay is how long lmc waits before reconnecting a stream
+	// that ended.
+	eventsRetryDelay = 5 * time.Second
+)
+
+// serverEvent is one state change from lmgo's /api/events.
+type serverEvent struct {
+	Type     string `json:"type"`
+	Model    string `json:"model"`
+	Port     int    `json:"port"`
+	Progress int    `json:"progress"`
+	Message  string `json:"message"`
+}
+
+// eventMsg is an event from the stream, or a change of its state: connected
+// or not, or done for good because the server has no event stream.
+type eventMsg struct {
+	event     serverEvent
+	status    bool
+	connected bool
+	done      bool
+}
+
+// streamEvents follows the event stream of c's server until c is cancelled,
+// reconnecting whenever it ends.
+func streamEvents(c apiClient) <-chan eventMsg {
+	events := make(chan eventMsg)
+	send := func(msg eventMsg) bool {
+		select {
+		case events <- msg:
+			return true
+		case <-c.ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(events)
+		for {
+			supported := readEvents(c, send)
+			if !supported {
+				send(eventMsg{done: true})
+				return
+			}
+			select {
+			case <-time.After(eventsRetryDelay):
+			case <-c.ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// readEvents reads one connection of the event stream. It returns false when
+// the server does not offer the stream, as older versions do not.
+func readEvents(c apiClient, send func(eventMsg) bool) bool {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.baseURL+"/api/events", nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	authorize(req, c.token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false
+	}
+	if resp.StatusCode != http.StatusOK || !send(eventMsg{status: true, connected: true}) {
+		return true
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if rest, ok := strings.CutPrefix(line, "data:"); ok {
+			data += strings.TrimPrefix(rest, " ")
+			continue
+		}
+		if line != "" || data == "" {
+			continue
+		}
+		var event serverEvent
+		if json.Unmarshal([]byte(data), &event) == nil && !send(eventMsg{event: event}) {
+			return true
+		}
+		data = ""
+	}
+	send(eventMsg{status: true, connected: false})
+	return true
+}
+
+// waitEvent delivers the next message of the stream.
+func waitEvent(c apiClient, events <-chan eventMsg) tea.Cmd {
+	if events == nil {
+		return nil
+	}
+	return c.cmd(func() tea.Msg {
+		msg, ok := <-events
+		if !ok {
+			return nil
+		}
+		return msg
+	})
+}
+
+// handleEvent refreshes what an event changed right away instead of waiting
+// for the next poll.
+func (m Model) handleEvent(msg eventMsg) (Model, tea.Cmd) {
+	if msg.done {
+		m.eventsLive = false
+		m.events = nil
+		return m, nil
+	}
+	next := waitEvent(m.client, m.events)
+	if msg.status {
+		m.eventsLive = msg.connected
+		if msg.connected {
+			// Catch up on what happened while the stream was down.
+			return m, tea.Batch(next, fetchInstances(m.client))
+		}
+		return m, next
+	}
+
+	if msg.event.Type == "scanComplete" {
+		return m, tea.Batch(next, fetchModels(m.client))
+	}
+	return m, tea.Batch(next, fetchInstances(m.client))
+}
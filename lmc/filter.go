@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// fuzzyMatch reports whether every rune of query appears in text in order,
+// ignoring case, and returns the rune positions in text that matched.
+func fuzzyMatch(query, text string) ([]int, bool) {
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return nil, true
+	}
+
+	var positions []int
+	qi := 0
+	for i, r := range []rune(text) {
+		if unicode.ToLower(r) == q[qi] {
+			positions = append(positions, i)
+			qi++
+			if qi == len(q) {
+				return positions, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// modelFilter is a parsed filter query. Terms such as quant:q4, size:<8g and
+// dir:qwen narrow the list by metadata; the rest of the query is matched
+// fuzzily against the model name.
+type modelFilter struct {
+	text    string
+	quant   []string
+	dirs    []string
+	minSize int64
+	maxSize int64
+}
+
+// parseFilter splits query into its filter terms and the fuzzy text. A
+// malformed term is reported and left out.
+func parseFilter(query string) (modelFilter, error) {
+	var f modelFilter
+	var text []string
+	var err error
+	for _, term := range strings.Fields(query) {
+		key, value, ok := strings.Cut(term, ":")
+		value = strings.ToLower(value)
+		switch {
+		case !ok || value == "":
+			text = append(text, term)
+		case strings.EqualFold(key, "quant") || strings.EqualFold(key, "q"):
+			f.quant = append(f.quant, value)
+		case strings.EqualFold(key, "dir"):
+			f.dirs = append(f.dirs, value)
+		case strings.EqualFold(key, "size"):
+			if termErr := f.parseSize(value); termErr != nil && err == nil {
+				err = termErr
+			}
+		default:
+			text = append(text, term)
+		}
+	}
+	f.text = strings.Join(text, " ")
+	return f, err
+}
+
+// parseSize reads a size term such as <8g, >500m or 4g-8g.
+func (f *modelFilter) parseSize(value string) error {
+	lower, upper := "", ""
+	switch {
+	case strings.HasPrefix(value, "<"):
+		upper = value[1:]
+	case strings.HasPrefix(value, ">"):
+		lower = value[1:]
+	case strings.Contains(value, "-"):
+		lower, upper, _ = strings.Cut(value, "-")
+	default:
+		return fmt.Errorf("size:%s: expected <8g, >500m or 4g-8g", value)
+	}
+	for _, bound := range []struct {
+		text string
+		into *int64
+	}{{lower, &f.minSize}, {upper, &f.maxSize}} {
+		if bound.text == "" {
+			continue
+		}
+		bytes, ok := parseByteSize(bound.text)
+		if !ok {
+			return fmt.Errorf("size:%s: expected <8g, >500m or 4g-8g", value)
+		}
+		*bound.into = bytes
+	}
+	return nil
+}
+
+// parseByteSize reads a size such as 8g, 500m or 1.5gb.
+func parseByteSize(text string) (int64, bool) {
+	text = strings.TrimSuffix(strings.ToLower(text), "b")
+	unit := int64(1)
+	switch {
+	case strings.HasSuffix(text, "k"):
+		unit = 1 << 10
+	case strings.HasSuffix(text, "m"):
+		unit = 1 << 20
+	case strings.HasSuffix(text, "g"):
+		unit = 1 << 30
+	case strings.HasSuffix(text, "t"):
+		unit = 1 << 40
+	}
+	if unit > 1 {
+		text = text[:len(text)-1]
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return int64(n * float64(unit)), true
+}
+
+// match reports whether model passes the filter and returns the name runes
+// the fuzzy text matched.
+func (f modelFilter) match(model ModelInfo) ([]int, bool) {
+	if len(f.quant) > 0 && !containsAny(model.Quantization, f.quant) {
+		return nil, false
+	}
+	if len(f.dirs) > 0 && !containsAny(modelDir(model.Path), f.dirs) {
+		return nil, false
+	}
+	// An unknown size never passes a size term.
+	if (f.minSize > 0 || f.maxSize > 0) && model.Size == 0 {
+		return nil, false
+	}
+	if (f.minSize > 0 && model.Size < f.minSize) || (f.maxSize > 0 && model.Size > f.maxSize) {
+		return nil, false
+	}
+
+	if positions, ok := fuzzyMatch(f.text, model.Name); ok {
+		return positions, true
+	}
+	_, ok := fuzzyMatch(f.text, model.Filename)
+	return nil, ok
+}
+
+func containsAny(text string, values []string) bool {
+	text = strings.ToLower(text)
+	for _, v := range values {
+		if strings.Contains(text, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// modelDir returns the directory part of a model path.
+func modelDir(path string) string {
+	if i := strings.LastIndexAny(path, `/\`); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// setModels replaces the model list, keeping the filter and the cursor on
+// the same model across refreshes.
+func (m *Model) setModels(models []ModelInfo) {
+	selected := -1
+	if model, ok := m.selectedModel(); ok {
+		selected = model.Index
+	}
+	m.models = models
+	m.filterModels(selected)
+}
+
+// applyFilter rebuilds the visible rows from the current query. The cursor
+// stays on the same model when it is still visible.
+func (m *Model) applyFilter() {
+	selected := -1
+	if model, ok := m.selectedModel(); ok {
+		selected = model.Index
+	}
+	m.filterModels(selected)
+}
+
+func (m *Model) filterModels(selected int) {
+	f, err := parseFilter(m.filter.Value())
+	m.filterErr = ""
+	if err != nil {
+		m.filterErr = err.Error()
+	}
+	m.visible = nil
+	m.matches = make(map[int][]int)
+	for i, model := range m.models {
+		if positions, ok := f.match(model); ok {
+			m.visible = append(m.visible, i)
+			if len(positions) > 0 {
+				m.matches[i] = positions
+			}
+		}
+	}
+	m.sortVisible()
+
+	m.selectedIdx = 0
+	for row, i := range m.visible {
+		if m.models[i].Index == selected {
+			m.selectedIdx = row
+			break
+		}
+	}
+	m.scrollToCursor()
+}
+
+// selectedModel returns the model under the cursor in the filtered view.
+func (m Model) selectedModel() (ModelInfo, bool) {
+	if m.selectedIdx < 0 || m.selectedIdx >= len(m.visible) {
+		return ModelInfo{}, false
+	}
+	return m.models[m.visible[m.selectedIdx]], true
+}
+
+// highlightMatches renders the runes of text at positions with style.
+func highlightMatches(text string, positions []int, style lipgloss.Style) string {
+	if len(positions) == 0 {
+		return text
+	}
+
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+
+	var b strings.Builder
+	for i, r := range []rune(text) {
+		if matched[i] {
+			b.WriteString(style.Render(string(r)))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
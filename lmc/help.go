@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+type keyHelp struct {
+	keys string
+	desc string
+}
+
+type helpGroup struct {
+	title string
+	keys  []keyHelp
+}
+
+// fullHelp lists every binding of the main screen for the "?" overlay.
+var fullHelp = []helpGroup{
+	{"Navigation", []keyHelp{
+		{"↑↓ / k j", "Move the cursor"},
+		{"Tab", "Switch between Models and Running"},
+		{"/", "Filter models"},
+		{"Esc", "Clear the filter"},
+		{"Shift+S", "Cycle the sort order"},
+		{"Shift+G", "Cycle the grouping"},
+	}},
+	{"Models", []keyHelp{
+		{"Enter", "Load the selected model"},
+		{"L", "Load with edited launch args"},
+		{"u", "Unload the selected instance"},
+		{"Shift+U", "Unload all instances"},
+		{"Esc / x", "Cancel a load in progress"},
+	}},
+	{"Views", []keyHelp{
+		{"i", "Toggle details of the selected model or instance"},
+		{"c", "Chat with a running instance"},
+		{"g", "Show instance logs"},
+		{"D", "Manage model downloads"},
+		{"t", "Show slot activity"},
+		{"a", "Show requests and tokens per model"},
+		{"o", "Open the instance's web UI"},
+		{"y", "Copy the instance's /v1 URL"},
+		{"b", "Measure tokens/sec (Esc/x: cancel)"},
+		{"Shift+T", "Test that the instance answers"},
+		{"h", "Toggle the help line"},
+		{"?", "Toggle this help"},
+	}},
+	{"Server", []keyHelp{
+		{"s", "Switch server profile"},
+		{"w", "Show what runs on every server"},
+		{"r", "Refresh data"},
+		{"p", "Pause or resume polling"},
+		{":", "Open the command palette"},
+		{"q / Ctrl+C", "Exit"},
+	}},
+}
+
+// handleFullHelpKey closes the help overlay; every other key is ignored
+// while it is open.
+func handleFullHelpKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "?", "esc", "q":
+		m.showFullHelp = false
+	}
+	return m, nil
+}
+
+// fullHelpView renders the help groups side by side, in as many columns as
+// fit in width.
+func (m Model) fullHelpView(width int) string {
+	st := m.styles
+
+	var blocks []string
+	blockWidth := 0
+	for _, group := range fullHelp {
+		keyWidth := 0
+		for _, k := range group.keys {
+			keyWidth = max(keyWidth, ansi.StringWidth(k.keys))
+		}
+		lines := []string{st.group.Render(group.title)}
+		for _, k := range group.keys {
+			pad := strings.Repeat(" ", keyWidth-ansi.StringWidth(k.keys))
+			lines = append(lines, fmt.Sprintf("%s%s  %s", k.keys, pad, st.help.Render(k.desc)))
+		}
+		block := strings.Join(lines, "\n")
+		blocks = append(blocks, block)
+		blockWidth = max(blockWidth, lipgloss.Width(block))
+	}
+
+	columns := max(1, min(len(blocks), (width-6)/(blockWidth+3)))
+	var rows []string
+	for i := 0; i < len(blocks); i += columns {
+		var row []string
+		for _, block := range blocks[i:min(i+columns, len(blocks))] {
+			row = append(row, lipgloss.NewStyle().Width(blockWidth+3).Render(block))
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+	}
+
+	body := "Keys\n\n" + strings.Join(rows, "\n\n") + "\n\n" + st.help.Render("? or Esc: Close")
+	return st.box.Render(body)
+}
+
+// overlay draws fg centered on top of bg, which is dimmed so the overlay
+// stands out. Both are full renders of at most width x height cells.
+func overlay(bg, fg string, width, height int, dim lipgloss.Style) string {
+	bgLines := strings.Split(ansi.Strip(bg), "\n")
+	for len(bgLines) < height {
+		bgLines = append(bgLines, "")
+	}
+
+	fgLines := strings.Split(fg, "\n")
+	fgWidth := lipgloss.Width(fg)
+	x := max(0, (width-fgWidth)/2)
+	y := max(0, (height-len(fgLines))/2)
+
+	for row, under := range bgLines {
+		if row < y || row >= y+len(fgLines) {
+			bgLines[row] = dim.Render(under)
+			continue
+		}
+		line := fgLines[row-y]
+		left := ansi.Truncate(under, x, "")
+		left += strings.Repeat(" ", x-ansi.StringWidth(left))
+		right := ansi.TruncateLeft(under, x+fgWidth, "")
+		line += strings.Repeat(" ", fgWidth-ansi.StringWidth(line))
+		bgLines[row] = dim.Render(left) + line + dim.Render(right)
+	}
+	return strings.Join(bgLines, "\n")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// narrowWindowWidth is the terminal width below which the panes no longer
+// fit side by side; the focused one is shown alone instead.
+const narrowWindowWidth = 70
+
+// panelFrameHeight is the height of a boxed panel beyond its content: the
+// border, and the bottom margin that separates stacked panels.
+const panelFrameHeight = 3
+
+const (
+	fullHelpLine  = "↑↓/kj: Select | Tab: Models/Running | Enter: Load selected model | L: Load with edited args | u: Unload selected instance \n /: Filter | I: Details | C: Chat | g: Logs | D: Downloads | T: Slots | O: Open web UI | Y: Copy API URL | B: Bench | Shift+T: Test | Shift+U: Unload all | R: Refresh data | P: Pause polling | s: Switch server | Shift+S: Sort | Shift+G: Group | ?: All keys | :: Commands | Q/Ctrl+C: Exit"
+	shortHelpLine = "↑↓: Select | Tab: Models/Running | Enter: Load | u: Unload | ?: All keys | Q: Exit"
+)
+
+// layout is the geometry of the main screen. Widths and heights are outer
+// sizes, borders and margins included.
+type layout struct {
+	// columns is 1 when only the focused pane fits, 2 for the models beside
+	// the running and status panels (or the details), and 3 for all of them.
+	columns  int
+	colWidth int
+
+	// topHeight is the height of the row of panes between the title and the
+	// action panel. statusHeight is 0 when the status panel is hidden to
+	// leave the running panel room for at least one row.
+	topHeight     int
+	runningHeight int
+	statusHeight  int
+}
+
+// computeLayout derives the layout from the terminal size, the height taken
+// by everything outside the panes, whether the details pane is open and how
+// many lines the status panel has.
+func computeLayout(width, height, chrome int, details bool, statusLines int) layout {
+	l := layout{topHeight: max(panelFrameHeight+1, height-chrome)}
+	switch {
+	case width < narrowWindowWidth:
+		l.columns, l.colWidth = 1, width
+	case details && width >= wideWindowWidth:
+		l.columns, l.colWidth = 3, width/3
+	default:
+		l.columns, l.colWidth = 2, width/2
+	}
+
+	// The running panel needs its title, a blank line and one instance.
+	l.statusHeight = statusLines + panelFrameHeight
+	l.runningHeight = l.topHeight - l.statusHeight
+	if l.runningHeight < 3+panelFrameHeight {
+		l.statusHeight = 0
+		l.runningHeight = l.topHeight
+	}
+	return l
+}
+
+// panelWidth and panelHeight are the sizes to give a section style so that
+// the rendered panel fills an outer size. The border and right margin take
+// three columns, and one is left free between the panels and the edge.
+func panelWidth(outer int) int {
+	return max(1, outer-4)
+}
+
+func panelHeight(outer int) int {
+	return max(1, outer-panelFrameHeight)
+}
+
+// clipLines cuts text to its first n lines, since a panel's height only pads
+// its content and never cuts it.
+func clipLines(text string, n int) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) <= n {
+		return text
+	}
+	return strings.Join(lines[:max(0, n)], "\n")
+}
+
+// layout returns the layout for the current window and state.
+func (m Model) layout() layout {
+	chrome := 2 + // title and its margin
+		1 + panelFrameHeight + // action panel
+		1 // status bar
+	if help := m.helpPanel(); help != "" {
+		chrome += lipgloss.Height(help)
+	}
+	return computeLayout(m.windowWidth, m.windowHeight, chrome, m.detailsVisible(), 3+len(m.resourceLines(m.styles.warn, m.styles.bad)))
+}
+
+// helpPanel is the key help under the action panel, wrapped to the window.
+// When the full key list would take more than a few lines, a short one
+// pointing to the "?" overlay is shown instead.
+func (m Model) helpPanel() string {
+	if !m.showHelp {
+		return ""
+	}
+	return fitHelp(m.helpText(), m.styles.help, m.windowWidth, m.windowHeight)
+}
+
+// fitHelp wraps text with style to a window of the given size, replacing the
+// full key list with shortHelpLine when it would take more than an eighth of
+// the height (and at least two lines).
+func fitHelp(text string, style lipgloss.Style, width, height int) string {
+	style = style.Width(max(1, width-2))
+	if text == fullHelpLine && lipgloss.Height(style.Render(text)) > max(2, height/8) {
+		text = shortHelpLine
+	}
+	return style.Render(text)
+}
+
+func (m Model) helpText() string {
+	switch {
+	case m.picking:
+		return "↑↓/kj: Select server | Enter: Switch | Esc: Cancel"
+	case m.filtering:
+		return "Type to filter | ↑↓: Select | Enter: Keep filter | Esc: Clear filter"
+	case m.state == StateLoadingModel:
+		return "Esc/x: Cancel the load and unload its instance | Tab: Models/Running | C: Chat | g: Logs | Q/Ctrl+C: Exit"
+	case m.editingArgs:
+		return "Edit the launch arguments for this load only | Enter: Load | Esc: Cancel"
+	case m.commanding:
+		return paletteCommands + " | Enter: Run | Esc: Cancel"
+	}
+	return fullHelpLine
+}
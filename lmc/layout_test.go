@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestComputeLayout(t *testing.T) {
+	const chrome, statusLines = 10, 3
+	tests := []struct {
+		name          string
+		width, height int
+		details       bool
+		want          layout
+	}{
+		{"very narrow", 40, 24, false, layout{columns: 1, colWidth: 40, topHeight: 14, runningHeight: 8, statusHeight: 6}},
+		{"just below two columns", 69, 24, false, layout{columns: 1, colWidth: 69, topHeight: 14, runningHeight: 8, statusHeight: 6}},
+		{"two columns", 70, 24, false, layout{columns: 2, colWidth: 35, topHeight: 14, runningHeight: 8, statusHeight: 6}},
+		{"standard", 100, 30, false, layout{columns: 2, colWidth: 50, topHeight: 20, runningHeight: 14, statusHeight: 6}},
+		{"details in a standard window", 100, 30, true, layout{columns: 2, colWidth: 50, topHeight: 20, runningHeight: 14, statusHeight: 6}},
+		{"details just below three columns", 149, 30, true, layout{columns: 2, colWidth: 74, topHeight: 20, runningHeight: 14, statusHeight: 6}},
+		{"details in three columns", 150, 30, true, layout{columns: 3, colWidth: 50, topHeight: 20, runningHeight: 14, statusHeight: 6}},
+		{"wide without details", 150, 30, false, layout{columns: 2, colWidth: 75, topHeight: 20, runningHeight: 14, statusHeight: 6}},
+		{"very wide", 200, 50, true, layout{columns: 3, colWidth: 66, topHeight: 40, runningHeight: 34, statusHeight: 6}},
+		{"status panel just fits", 100, 22, false, layout{columns: 2, colWidth: 50, topHeight: 12, runningHeight: 6, statusHeight: 6}},
+		{"status panel hidden", 100, 21, false, layout{columns: 2, colWidth: 50, topHeight: 11, runningHeight: 11}},
+		{"very short", 100, 5, false, layout{columns: 2, colWidth: 50, topHeight: 4, runningHeight: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := computeLayout(tt.width, tt.height, chrome, tt.details, statusLines); got != tt.want {
+				t.Errorf("computeLayout(%d×%d, details=%v) = %+v, want %+v", tt.width, tt.height, tt.details, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFitHelp(t *testing.T) {
+	tests := []struct {
+		width, height int
+		wantShort     bool
+		wantLines     int
+	}{
+		{40, 50, true, 3},
+		{70, 24, true, 2},
+		{100, 24, true, 1},
+		{100, 50, false, 5},
+		{150, 12, true, 1},
+		{150, 24, false, 3},
+		{200, 50, false, 3},
+	}
+	for _, tt := range tests {
+		got := fitHelp(fullHelpLine, lipgloss.NewStyle(), tt.width, tt.height)
+		short := lipgloss.NewStyle().Width(tt.width - 2).Render(shortHelpLine)
+		if (got == short) != tt.wantShort || lipgloss.Height(got) != tt.wantLines {
+			t.Errorf("fitHelp at %d×%d: short=%v in %d lines, want short=%v in %d lines",
+				tt.width, tt.height, got == short, lipgloss.Height(got), tt.wantShort, tt.wantLines)
+		}
+		for _, line := range strings.Split(got, "\n") {
+			if w := lipgloss.Width(line); w > tt.width-2 {
+				t.Errorf("fitHelp at %d×%d: line %q is %d cells wide", tt.width, tt.height, line, w)
+			}
+		}
+	}
+}
+
+func TestFitHelpKeepsOtherText(t *testing.T) {
+	const text = "Type to filter | ↑↓: Select | Enter: Keep filter | Esc: Clear filter"
+	got := fitHelp(text, lipgloss.NewStyle(), 30, 8)
+	if got != lipgloss.NewStyle().Width(28).Render(text) {
+		t.Errorf("fitHelp replaced a short help text: %q", got)
+	}
+}
+
+func TestPanelSize(t *testing.T) {
+	tests := []struct{ outer, width, height int }{
+		{50, 46, 47},
+		{5, 1, 2},
+		{2, 1, 1},
+		{0, 1, 1},
+	}
+	for _, tt := range tests {
+		if w, h := panelWidth(tt.outer), panelHeight(tt.outer); w != tt.width || h != tt.height {
+			t.Errorf("panel size for %d = %d, %d; want %d, %d", tt.outer, w, h, tt.width, tt.height)
+		}
+	}
+}
+
+func TestClipLines(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"a\nb\nc", 2, "a\nb"},
+		{"a\nb\nc", 3, "a\nb\nc"},
+		{"a\nb\nc", 10, "a\nb\nc"},
+		{"a\nb", 0, ""},
+		{"a\nb", -1, ""},
+		{"", 1, ""},
+	}
+	for _, tt := range tests {
+		if got := clipLines(tt.text, tt.n); got != tt.want {
+			t.Errorf("clipLines(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
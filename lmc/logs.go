@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	logTailLines    = 200
+	maxLogViewLines = 5000
+	logRetryDelay   = 2 * time.Second
+)
+
+// LogSource is one entry of GET /api/logs.
+type LogSource struct {
+	Port    int    `json:"port"`
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+}
+
+type LogSourcesResponse struct {
+	Success bool        `json:"success"`
+	Data    []LogSource `json:"data"`
+}
+
+type LogLinesResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Data    struct {
+		Lines []string `json:"lines"`
+		Next  int64    `json:"next"`
+	} `json:"data"`
+}
+
+// logModel is the log view opened with "g". It shows the last lines of one
+// instance's llama-server output and then follows it by long polling.
+type logModel struct {
+	viewport viewport.Model
+
+	sources []LogSource
+	source  LogSource
+	lines   []string
+	next    int64
+
+	// follow keeps the view pinned to the newest line. Scrolling up pauses
+	// it; scrolling back to the bottom resumes it.
+	follow bool
+
+	streamID int
+	ctx      context.Context
+	cancel   context.CancelFunc
+	status   string
+}
+
+type (
+	logSourcesMsg struct {
+		id      int
+		sources []LogSource
+		err     error
+	}
+	logLinesMsg struct {
+		id    int
+		lines []string
+		next  int64
+		err   error
+	}
+	logRetryMsg struct{ id int }
+)
+
+func newLogModel() logModel {
+	return logModel{viewport: viewport.New(0, 0), follow: true}
+}
+
+func openLogs(m Model) (Model, tea.Cmd) {
+	m.viewingLogs = true
+	m.logs.resize(m.windowWidth, m.windowHeight)
+	m.logs.status = "Loading log sources…"
+	return m, m.logs.start(m.client, LogSource{})
+}
+
+// start (re)connects to the log of source. An empty source first fetches the
+// list of log sources and picks a running instance.
+func (l *logModel) start(client apiClient, source LogSource) tea.Cmd {
+	l.stop()
+
+	l.ctx, l.cancel = context.WithCancel(client.ctx)
+	l.streamID++
+	c := client
+	c.ctx = l.ctx
+	id := l.streamID
+
+	if source.Port == 0 {
+		l.source = LogSource{}
+		return func() tea.Msg {
+			var data LogSourcesResponse
+			err := c.request(http.MethodGet, "/api/logs", &data)
+			return logSourcesMsg{id: id, sources: data.Data, err: err}
+		}
+	}
+
+	l.source = source
+	l.lines = nil
+	l.next = 0
+	l.follow = true
+	l.refresh()
+	return fetchLogLines(c, id, source.Port, -1)
+}
+
+func (l *logModel) stop() {
+	if l.cancel != nil {
+		l.cancel()
+		l.cancel = nil
+	}
+	l.streamID++
+}
+
+// fetchLogLines requests the last lines of the log when since is negative,
+// or waits for lines numbered since and later.
+func fetchLogLines(c apiClient, id int, port int, since int64) tea.Cmd {
+	return func() tea.Msg {
+		path := fmt.Sprintf("/api/logs?port=%d&lines=%d", port, logTailLines)
+		if since >= 0 {
+			path = fmt.Sprintf("/api/logs?port=%d&since=%d", port, since)
+		}
+
+		var data LogLinesResponse
+		if err := c.request(http.MethodGet, path, &data); err != nil {
+			return logLinesMsg{id: id, err: err}
+		}
+		if !data.Success {
+			return logLinesMsg{id: id, err: fmt.Errorf("%s", data.Message)}
+		}
+		return logLinesMsg{id: id, lines: data.Data.Lines, next: data.Data.Next}
+	}
+}
+
+func (m Model) handleLogMsg(msg tea.Msg) (Model, tea.Cmd) {
+	l := &m.logs
+
+	switch msg := msg.(type) {
+	case logSourcesMsg:
+		if msg.id != l.streamID {
+			return m, nil
+		}
+		if msg.err != nil {
+			l.status = fmt.Sprintf("Failed to list logs: %v (retrying)", msg.err)
+			return m, logRetry(msg.id)
+		}
+		l.sources = msg.sources
+		if len(l.sources) == 0 {
+			l.status = "No logs yet: load a model first"
+			l.refresh()
+			return m, nil
+		}
+		return m, l.start(m.client, l.pickSource(m))
+
+	case logLinesMsg:
+		if msg.id != l.streamID {
+			return m, nil
+		}
+		if msg.err != nil {
+			l.status = fmt.Sprintf("Disconnected: %v (reconnecting)", msg.err)
+			return m, logRetry(msg.id)
+		}
+		l.status = ""
+		l.append(msg.lines)
+		l.next = msg.next
+		return m, l.followCmd(m.client)
+
+	case logRetryMsg:
+		if msg.id != l.streamID {
+			return m, nil
+		}
+		if l.source.Port == 0 {
+			return m, l.start(m.client, LogSource{})
+		}
+		return m, l.followCmd(m.client)
+	}
+	return m, nil
+}
+
+// followCmd waits for the next lines of the current source.
+func (l *logModel) followCmd(client apiClient) tea.Cmd {
+	c := client
+	c.ctx = l.ctx
+	return fetchLogLines(c, l.streamID, l.source.Port, l.next)
+}
+
+func logRetry(id int) tea.Cmd {
+	return tea.Tick(logRetryDelay, func(time.Time) tea.Msg {
+		return logRetryMsg{id: id}
+	})
+}
+
+// pickSource prefers the instance the cursor is on, then any running
+// instance, then the most recent log.
+func (l *logModel) pickSource(m Model) LogSource {
+	if target, ok := m.chatTarget(); ok {
+		for _, s := range l.sources {
+			if s.Port == target.Port {
+				return s
+			}
+		}
+	}
+	for _, s := range l.sources {
+		if s.Running {
+			return s
+		}
+	}
+	return l.sources[len(l.sources)-1]
+}
+
+// append adds lines, dropping the oldest ones beyond maxLogViewLines.
+func (l *logModel) append(lines []string) {
+	l.lines = append(l.lines, lines...)
+	if len(l.lines) > maxLogViewLines {
+		l.lines = append([]string(nil), l.lines[len(l.lines)-maxLogViewLines:]...)
+	}
+	l.refresh()
+}
+
+func (l *logModel) resize(width, height int) {
+	l.viewport.Width = max(10, width-6)
+	l.viewport.Height = max(3, height-7)
+	l.refresh()
+}
+
+func (l *logModel) refresh() {
+	wrap := lipgloss.NewStyle().Width(max(10, l.viewport.Width))
+	l.viewport.SetContent(wrap.Render(strings.Join(l.lines, "\n")))
+	if l.follow {
+		l.viewport.GotoBottom()
+	}
+}
+
+func handleLogKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	l := &m.logs
+
+	switch msg.String() {
+	case "esc", "g", "q":
+		l.stop()
+		m.viewingLogs = false
+		return m, nil
+
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "tab":
+		if len(l.sources) < 2 {
+			return m, nil
+		}
+		next := 0
+		for i, s := range l.sources {
+			if s.Port == l.source.Port {
+				next = (i + 1) % len(l.sources)
+			}
+		}
+		return m, l.start(m.client, l.sources[next])
+
+	case "r":
+		return m, l.start(m.client, LogSource{})
+
+	case "end", "G":
+		l.follow = true
+		l.viewport.GotoBottom()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	l.viewport, cmd = l.viewport.Update(msg)
+	l.follow = l.viewport.AtBottom()
+	return m, cmd
+}
+
+func (m Model) logView() string {
+	titleStyle := m.styles.title
+	boxStyle := m.styles.box
+	helpStyle := m.styles.help
+
+	l := m.logs
+	name := "Logs"
+	if l.source.Port != 0 {
+		name = fmt.Sprintf("Logs · %s :%d", l.source.Name, l.source.Port)
+		if !l.source.Running {
+			name += " (stopped)"
+		}
+	}
+
+	state := "following"
+	if !l.follow {
+		state = "paused, End to follow"
+	}
+	if l.status != "" {
+		state = l.status
+	}
+
+	helpText := "↑↓/PgUp/PgDn: Scroll | End: Follow | R: Reload sources | Esc: Back"
+	if len(l.sources) > 1 {
+		helpText += " | Tab: Next log"
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render(name)+helpStyle.Render("  "+state),
+		boxStyle.Render(l.viewport.View()),
+		helpStyle.Render(helpText),
+	)
+}
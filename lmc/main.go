@@ -1,31 +1,74 @@
 package main
 
 import (
+	"context"
 	"embed"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"path/filepath"
-	"runtime"
+	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
 )
 
 //go:embed baseURL.json
 var defaultConfigFS embed.FS
 
 type Config struct {
-	BaseURL string `json:"baseURL"`
+	Server       string    `json:"server"`
+	Profiles     []Profile `json:"profiles,omitempty"`
+	PollInterval string    `json:"pollInterval,omitempty"`
+	LoadTimeout  string    `json:"loadTimeout,omitempty"`
+	BenchRuns    int       `json:"benchRuns,omitempty"`
+	Sort         string    `json:"sort,omitempty"`
+	Group        string    `json:"group,omitempty"`
+	Filter       string    `json:"filter,omitempty"`
+
+	// NotifyOnComplete rings the bell and shows a desktop notification when
+	// a load or unload that took a while finishes.
+	NotifyOnComplete bool `json:"notifyOnComplete,omitempty"`
+
+	// Theme is "auto", "dark", "light" or "none"; Colors overrides single
+	// colors of it by their Theme JSON name.
+	Theme  string            `json:"theme,omitempty"`
+	Colors map[string]string `json:"colors,omitempty"`
+
+	// SystemPrompts are offered in the chat view, where Ctrl+P cycles through
+	// them.
+	SystemPrompts []SystemPrompt `json:"systemPrompts,omitempty"`
+
+	// Insecure accepts any HTTPS certificate, such as the self-signed one
+	// lmgo generates.
+	Insecure bool `json:"insecure,omitempty"`
+
+	// BaseURL is the field used by older lmc.json/baseURL.json files.
+	BaseURL string `json:"baseURL,omitempty"`
 }
 
 type ModelInfo struct {
-	Index int    `json:"index"`
-	Name  string `json:"name"`
-	Path  string `json:"path"`
+	Index     int    `json:"index"`
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Filename  string `json:"filename"`
+	HasConfig bool   `json:"hasConfig"`
+
+	// Extended fields; older servers leave them empty.
+	Size          int64  `json:"size"`
+	Shards        int    `json:"shards"`
+	Quantization  string `json:"quantization"`
+	Parameters    string `json:"parameters"`
+	ContextLength int    `json:"contextLength"`
+	Architecture  string `json:"architecture"`
+	Modified      int64  `json:"modified"`
+	LastUsed      int64  `json:"lastUsed"`
+	// HasChatTemplate is nil when the server did not read the header.
+	HasChatTemplate *bool `json:"hasChatTemplate"`
 }
 
 type ModelsResponse struct {
@@ -33,18 +76,35 @@ type ModelsResponse struct {
 	Data    []ModelInfo `json:"data"`
 }
 
-type StatusData struct {
-	Loaded     bool   `json:"loaded"`
-	ConfigName string `json:"configName,omitempty"`
-	Model      struct {
-		BaseName string `json:"baseName"`
-		Path     string `json:"path"`
-	} `json:"model"`
+// InstanceInfo is one running llama-server as reported by /api/instances.
+type InstanceInfo struct {
+	Name        string `json:"name"`
+	Model       string `json:"model"`
+	ConfigName  string `json:"configName,omitempty"`
+	Port        int    `json:"port"`
+	InstanceNum int    `json:"instanceNum"`
+	Parallel    int    `json:"parallel,omitempty"`
+	Uptime      int64  `json:"uptime"`
+	Healthy     bool   `json:"healthy"`
+	Progress    int    `json:"progress"`
+	Phase       string `json:"phase,omitempty"`
+
+	StartedAt       time.Time `json:"startedAt"`
+	ReadyAt         time.Time `json:"readyAt"`
+	Restarts        int       `json:"restarts"`
+	LastHealthCheck time.Time `json:"lastHealthCheck"`
+	HealthFailures  int       `json:"healthFailures"`
 }
 
-type StatusResponse struct {
-	Success bool       `json:"success"`
-	Data    StatusData `json:"data"`
+// loading reports whether the instance is still loading its model, as
+// opposed to a ready instance that stopped answering health checks.
+func (inst InstanceInfo) loading() bool {
+	return !inst.Healthy && inst.Progress != 100
+}
+
+type InstancesResponse struct {
+	Success bool           `json:"success"`
+	Data    []InstanceInfo `json:"data"`
 }
 
 type HealthStatus struct {
@@ -68,34 +128,160 @@ const (
 	StateError
 )
 
+// The layout needs room for the title, a panel with at least one model row,
+// the action panel and the help text.
+const (
+	minWindowWidth  = 50
+	minWindowHeight = 20
+)
+
+// Pane is the part of the screen that has the cursor.
+type Pane int
+
+const (
+	PaneModels Pane = iota
+	PaneInstances
+)
+
 type Model struct {
-	state   AppState
-	baseURL string
+	state  AppState
+	client apiClient
+	cancel context.CancelFunc
+
+	profiles      []Profile
+	profileIdx    int
+	picking       bool
+	pickerIdx     int
+	profileOnline map[int]bool
 
 	models      []ModelInfo
 	selectedIdx int
-
-	health           string
-	loadedModel      string
-	loadedModelName  string
-	loadedConfigName string
-	lastStatus       time.Time
-	statusError      bool
+	listOffset  int
+
+	// visible holds the indices into models that pass the filter, in
+	// display order; selectedIdx indexes into it. matches maps a model index
+	// to the name runes matched by the filter. filterErr describes a
+	// malformed term of the query.
+	filter    textinput.Model
+	filtering bool
+	filterErr string
+	visible   []int
+	matches   map[int][]int
+
+	// sortBy and groupBy order the visible rows; see sortModes and
+	// groupModes. They and the filter are saved to configPath when changed.
+	sortBy     string
+	groupBy    string
+	configPath string
+
+	instances   []InstanceInfo
+	instanceIdx int
+	focus       Pane
+	resources   ResourceUsage
+
+	health      string
+	lastStatus  time.Time
+	statusError bool
+
+	// pollInterval is how often instances and health are refreshed in the
+	// background; paused stops that until "p" is pressed again.
+	pollInterval time.Duration
+	paused       bool
+
+	// events is the server's event stream, which triggers refreshes as
+	// soon as something changes; eventsLive is set while it is connected.
+	events     <-chan eventMsg
+	eventsLive bool
+
+	// failures counts failed health checks in a row. While offline only the
+	// health check is retried, at retryAt, backing off after each failure.
+	failures       int
+	offline        bool
+	retryAt        time.Time
+	lastFetchError string
+
+	// latency and lastUpdate describe the last successful instances fetch,
+	// shown in the status bar.
+	latency    time.Duration
+	lastUpdate time.Time
 
 	message       string
 	messageTime   time.Time
 	operationTime time.Duration
 
+	// loadingName and loadStarted describe the load in progress, whose
+	// instance reports its progress through /api/instances.
+	loadingName string
+	loadStarted time.Time
+	loadBar     progress.Model
+
+	// loadID numbers the loads so a result that arrives after its load timed
+	// out or was cancelled is recognized. cancelName is the model of a
+	// cancelled load whose instance the server had not reported yet; it is
+	// unloaded as soon as it shows up.
+	loadID        int
+	loadTimeout   time.Duration
+	cancelledLoad int
+	cancelName    string
+
+	// opName, opUnload and opStarted describe the load or unload whose end
+	// is announced when notifyOnComplete is set.
+	opName           string
+	opUnload         bool
+	opStarted        time.Time
+	notifyOnComplete bool
+
+	// confirmingLoad asks whether to start another instance of the selected
+	// model, which is already running.
+	confirmingLoad bool
+
+	// argsInput edits the launch arguments for a one-off load with "L".
+	argsInput   textinput.Model
+	editingArgs bool
+
+	// bench is the throughput test in progress, started with "b".
+	bench     *benchRun
+	benchRuns int
+
+	// palette is the ":" command line; showFullHelp is the "?" overlay.
+	palette      textinput.Model
+	commanding   bool
+	showFullHelp bool
+
+	styles styles
+
 	loadingDots  int
 	windowWidth  int
 	windowHeight int
 	showHelp     bool
+	showDetails  bool
+
+	chat     chatModel
+	chatting bool
+
+	logs        logModel
+	viewingLogs bool
+
+	downloads        downloadsModel
+	viewingDownloads bool
+
+	slots        slotsModel
+	viewingSlots bool
+
+	usage        usageModel
+	viewingUsage bool
+
+	servers        serversModel
+	viewingServers bool
 }
 
 type (
-	tickMsg    time.Time
-	modelsMsg  ModelsResponse
-	statusMsg  StatusResponse
+	tickMsg      time.Time
+	modelsMsg    ModelsResponse
+	instancesMsg struct {
+		InstancesResponse
+		latency time.Duration
+	}
 	healthMsg  HealthStatus
 	loadMsg    SimpleResponse
 	unloadMsg  SimpleResponse
@@ -104,243 +290,175 @@ type (
 		message string
 		time    time.Duration
 	}
+	profileProbeMsg struct {
+		index  int
+		online bool
+	}
 )
 
-func fetchModels(baseURL string) tea.Cmd {
-	return func() tea.Msg {
-		resp, err := http.Get(baseURL + "/api/models")
-		if err != nil {
-			return errorMsg(fmt.Sprintf("Failed to fetch models: %v", err))
-		}
-		defer resp.Body.Close()
+func NewModel(opts options) Model {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := opts.profiles[opts.active]
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return errorMsg(fmt.Sprintf("Failed to read response: %v", err))
-		}
+	filter := textinput.New()
+	filter.Prompt = "/"
+	filter.Placeholder = "filter models, e.g. qwen quant:q4 size:<8g dir:coding"
+	filter.SetValue(opts.filter)
 
-		var data ModelsResponse
-		if err := json.Unmarshal(body, &data); err != nil {
-			return errorMsg(fmt.Sprintf("Failed to parse models list: %v", err))
-		}
+	st := newStyles(opts.theme)
+	client := apiClient{baseURL: p.URL, token: p.Token, ctx: ctx}
 
-		return modelsMsg(data)
+	return Model{
+		styles:       st,
+		filter:       filter,
+		argsInput:    newArgsInput(),
+		palette:      newPalette(),
+		chat:         newChatModel(st, opts.systemPrompts),
+		logs:         newLogModel(),
+		downloads:    newDownloadsModel(),
+		loadBar:      newLoadBar(),
+		client:       client,
+		events:       streamEvents(client),
+		cancel:       cancel,
+		profiles:     opts.profiles,
+		profileIdx:   opts.active,
+		pollInterval: opts.pollInterval,
+		loadTimeout:  opts.loadTimeout,
+		benchRuns:    opts.benchRuns,
+		sortBy:       opts.sortBy,
+		groupBy:      opts.groupBy,
+		configPath:   opts.configPath,
+		state:        StateLoading,
+		selectedIdx:  0,
+		health:       "Checking...",
+		showHelp:     true,
+		loadingDots:  0,
+
+		notifyOnComplete: opts.notify,
 	}
 }
 
-func fetchStatus(baseURL string) tea.Cmd {
-	return func() tea.Msg {
-		resp, err := http.Get(baseURL + "/api/status")
-		if err != nil {
-			return errorMsg(fmt.Sprintf("Failed to fetch status: %v", err))
-		}
-		defer resp.Body.Close()
+func (m Model) Init() tea.Cmd {
+	return tea.Batch(
+		fetchModels(m.client),
+		fetchInstances(m.client),
+		fetchHealth(m.client),
+		fetchResources(m.client),
+		waitEvent(m.client, m.events),
+		tickCmd(),
+	)
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return errorMsg(fmt.Sprintf("Failed to read status: %v", err))
+	switch msg := msg.(type) {
+	case serverMsg:
+		if msg.gen != m.client.gen {
+			return m, nil
 		}
+		return m.Update(msg.msg)
 
-		var data StatusResponse
-		if err := json.Unmarshal(body, &data); err != nil {
-			return errorMsg(fmt.Sprintf("Failed to parse status: %v", err))
+	case profileProbeMsg:
+		if m.profileOnline != nil {
+			m.profileOnline[msg.index] = msg.online
 		}
+		return m, nil
 
-		return statusMsg(data)
-	}
-}
+	case chatEventMsg:
+		return m, m.chat.handleEvent(msg)
 
-func fetchHealth(baseURL string) tea.Cmd {
-	return func() tea.Msg {
-		resp, err := http.Get(baseURL + "/api/health")
-		if err != nil {
-			return errorMsg(fmt.Sprintf("Health check failed: %v", err))
-		}
-		defer resp.Body.Close()
+	case logSourcesMsg, logLinesMsg, logRetryMsg:
+		return m.handleLogMsg(msg)
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return errorMsg(fmt.Sprintf("Failed to read health status: %v", err))
-		}
+	case downloadsMsg, downloadStartedMsg:
+		return m.handleDownloadsMsg(msg)
 
-		var data HealthStatus
-		if err := json.Unmarshal(body, &data); err != nil {
-			return errorMsg(fmt.Sprintf("Failed to parse health status: %v", err))
-		}
+	case slotsMsg:
+		return m.handleSlotsMsg(msg)
 
-		return healthMsg(data)
-	}
-}
+	case usageMsg:
+		return m.handleUsageMsg(msg)
 
-func loadModel(baseURL string, index int) tea.Cmd {
-	return func() tea.Msg {
-		start := time.Now()
-		url := fmt.Sprintf("%s/api/load?index=%d", baseURL, index)
+	case serverInstancesMsg:
+		return m.handleServerInstancesMsg(msg)
 
-		resp, err := http.Post(url, "application/json", nil)
-		if err != nil {
-			return errorMsg(fmt.Sprintf("Failed to load model: %v", err))
-		}
-		defer resp.Body.Close()
+	case eventMsg:
+		return m.handleEvent(msg)
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return errorMsg(fmt.Sprintf("Failed to read response: %v", err))
+	case tea.KeyMsg:
+		if m.showFullHelp {
+			return handleFullHelpKey(m, msg)
 		}
-
-		var data SimpleResponse
-		if err := json.Unmarshal(body, &data); err != nil {
-			return errorMsg(fmt.Sprintf("Failed to parse response: %v", err))
+		if m.chatting {
+			return handleChatKey(m, msg)
 		}
-
-		elapsed := time.Since(start)
-
-		if !data.Success {
-			return errorMsg(fmt.Sprintf("Load failed: %s", data.Message))
+		if m.viewingLogs {
+			return handleLogKey(m, msg)
 		}
-
-		return successMsg{message: data.Message, time: elapsed}
-	}
-}
-
-func unloadModel(baseURL string) tea.Cmd {
-	return func() tea.Msg {
-		start := time.Now()
-		url := baseURL + "/api/unload"
-		resp, err := http.Post(url, "application/json", nil)
-		if err != nil {
-			return errorMsg(fmt.Sprintf("Failed to unload model: %v", err))
+		if m.viewingDownloads {
+			return handleDownloadsKey(m, msg)
 		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return errorMsg(fmt.Sprintf("Failed to read response: %v", err))
+		if m.viewingSlots {
+			return handleSlotsKey(m, msg)
 		}
-
-		var data SimpleResponse
-		if err := json.Unmarshal(body, &data); err != nil {
-			return errorMsg(fmt.Sprintf("Failed to parse response: %v", err))
+		if m.viewingUsage {
+			return handleUsageKey(m, msg)
 		}
-
-		if !data.Success {
-			return errorMsg(fmt.Sprintf("Unload failed: %s", data.Message))
+		if m.viewingServers {
+			return handleServersKey(m, msg)
 		}
-
-		elapsed := time.Since(start)
-		return successMsg{message: data.Message, time: elapsed}
-	}
-}
-
-func getExecutableDir() (string, error) {
-	exePath, err := os.Executable()
-	if err != nil {
-		return "", err
-	}
-
-	if runtime.GOOS != "windows" {
-		exePath, err = filepath.EvalSymlinks(exePath)
-		if err != nil {
-			return "", err
+		if m.picking {
+			return handlePickerKey(m, msg)
 		}
-	}
-
-	return filepath.Dir(exePath), nil
-}
-
-func loadConfig() (string, error) {
-	exeDir, err := getExecutableDir()
-	if err != nil {
-		exeDir = "."
-	}
-
-	configFile := filepath.Join(exeDir, "lmc.json")
-	fallbackFile := filepath.Join(exeDir, "baseURL.json")
-
-	if _, err := os.Stat(configFile); err == nil {
-		data, err := os.ReadFile(configFile)
-		if err != nil {
-			return "", err
+		if m.filtering {
+			return handleFilterKey(m, msg)
 		}
-		var config Config
-		if err := json.Unmarshal(data, &config); err != nil {
-			return "", err
+		if m.confirmingLoad {
+			return handleConfirmLoadKey(m, msg)
 		}
-		return config.BaseURL, nil
-	}
-
-	if _, err := os.Stat(fallbackFile); err == nil {
-		data, err := os.ReadFile(fallbackFile)
-		if err != nil {
-			return "", err
+		if m.editingArgs {
+			return handleArgsKey(m, msg)
 		}
-		var config Config
-		if err := json.Unmarshal(data, &config); err != nil {
-			return "", err
+		if m.commanding {
+			return handlePaletteKey(m, msg)
 		}
-		return config.BaseURL, nil
-	}
-
-	defaultConfig := Config{
-		BaseURL: "http://127.0.0.1:8080",
-	}
-	data, err := json.MarshalIndent(defaultConfig, "", "  ")
-	if err != nil {
-		return "", err
-	}
-	if err := os.WriteFile(fallbackFile, data, 0644); err != nil {
-		return "", err
-	}
-	return defaultConfig.BaseURL, nil
-}
-
-func NewModel() Model {
-	baseURL, err := loadConfig()
-	if err != nil {
-		baseURL = "http://127.0.0.1:8080"
-	}
-
-	return Model{
-		baseURL:          baseURL,
-		state:            StateLoading,
-		selectedIdx:      0,
-		health:           "Checking...",
-		loadedModel:      "None",
-		loadedConfigName: "",
-		showHelp:         true,
-		loadingDots:      0,
-	}
-}
-
-func (m Model) Init() tea.Cmd {
-	return tea.Batch(
-		fetchModels(m.baseURL),
-		fetchStatus(m.baseURL),
-		fetchHealth(m.baseURL),
-		tickCmd(),
-	)
-}
-
-func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var cmds []tea.Cmd
-
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
 		return handleKeyMsg(m, msg)
 
 	case tea.WindowSizeMsg:
 		m.windowWidth = msg.Width
 		m.windowHeight = msg.Height
+		m.scrollToCursor()
+		m.chat.resize(msg.Width, msg.Height)
+		m.logs.resize(msg.Width, msg.Height)
 		return m, nil
 
 	case tickMsg:
 		m.loadingDots = (m.loadingDots + 1) % 4
 
-		if time.Since(m.lastStatus) > 1*time.Second {
+		if m.offline {
+			if !m.paused && time.Now().After(m.retryAt) {
+				m.retryAt = time.Now().Add(m.retryDelay())
+				cmds = append(cmds, fetchHealth(m.client))
+			}
+		} else if !m.paused && time.Since(m.lastStatus) > m.statusInterval() {
 			m.lastStatus = time.Now()
-			cmds = append(cmds, fetchStatus(m.baseURL), fetchHealth(m.baseURL))
+			cmds = append(cmds, fetchInstances(m.client), fetchHealth(m.client), fetchResources(m.client))
+			if m.viewingDownloads || m.downloads.downloading() {
+				cmds = append(cmds, fetchDownloads(m.client))
+			}
+			if m.viewingSlots {
+				cmds = append(cmds, fetchSlots(m.client, m.instances))
+			}
+			if m.viewingUsage {
+				cmds = append(cmds, fetchUsage(m.client))
+			}
+			if m.viewingServers {
+				cmds = append(cmds, fetchAllServers(m.profiles))
+			}
 		}
 
+		m.checkLoadTimeout()
 		if m.state == StateSuccess || m.state == StateError {
 			if time.Since(m.messageTime) > 3*time.Second {
 				m.state = StateReady
@@ -349,31 +467,44 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tea.Batch(append(cmds, tickCmd())...)
 
 	case modelsMsg:
-		m.models = msg.Data
+		m.lastFetchError = ""
+		m.setModels(msg.Data)
 		if len(m.models) > 0 {
 			m.state = StateReady
 		}
 		return m, nil
 
-	case statusMsg:
+	case instancesMsg:
 		if msg.Success {
-			m.statusError = false
-			if msg.Data.Loaded {
-				m.loadedModel = msg.Data.Model.BaseName
-				m.loadedModelName = msg.Data.Model.BaseName
-				m.loadedConfigName = msg.Data.ConfigName
-			} else {
-				m.loadedModel = "None"
-				m.loadedModelName = ""
-				m.loadedConfigName = ""
+			m.latency = msg.latency
+			m.lastUpdate = time.Now()
+			m.lastFetchError = ""
+			m.instances = msg.Data
+			if m.instanceIdx >= len(m.instances) {
+				m.instanceIdx = max(0, len(m.instances)-1)
+			}
+			if len(m.instances) == 0 {
+				m.focus = PaneModels
 			}
 		}
-		return m, nil
+		return m.unloadCancelled()
 
 	case healthMsg:
 		m.health = msg.Status
+		m.statusError = false
+		m.failures = 0
+		if m.offline {
+			return m.reconnected()
+		}
+		return m, nil
+
+	case resourcesMsg:
+		m.resources = ResourceUsage(msg)
 		return m, nil
 
+	case fetchFailedMsg:
+		return m.fetchFailed(msg), nil
+
 	case loadMsg:
 		if msg.Success {
 			m.state = StateSuccess
@@ -383,7 +514,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.message = fmt.Sprintf("✗ Load failed: %s", msg.Message)
 		}
 		m.messageTime = time.Now()
-		return m, fetchStatus(m.baseURL)
+		return m, fetchInstances(m.client)
 
 	case unloadMsg:
 		if msg.Success {
@@ -394,27 +525,70 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.message = fmt.Sprintf("✗ Unload failed: %s", msg.Message)
 		}
 		m.messageTime = time.Now()
-		return m, fetchStatus(m.baseURL)
+		return m, fetchInstances(m.client)
 
 	case successMsg:
-
+		notify := m.finishOperation(true, msg.message)
 		m.state = StateSuccess
 		m.message = fmt.Sprintf("✓ %s (Load time: %v)", msg.message, msg.time)
 		m.operationTime = msg.time
 		m.messageTime = time.Now()
 
-		return m, fetchStatus(m.baseURL)
+		// Refresh right away rather than waiting for the next poll, which
+		// may be paused or far off.
+		m.lastStatus = time.Now()
+		return m, tea.Batch(fetchInstances(m.client), fetchHealth(m.client), notify)
+
+	case clipboardMsg:
+		m.state = StateSuccess
+		m.message = fmt.Sprintf("✓ Copied %s of %s (via %s)", msg.text, msg.name, msg.via)
+		m.messageTime = time.Now()
+		return m, nil
+
+	case browserMsg:
+		if msg.err != nil {
+			m.state = StateError
+			m.message = fmt.Sprintf("✗ Could not open a browser (%v). Open %s manually", msg.err, msg.url)
+			// Keep the URL on screen long enough to copy it.
+			m.messageTime = time.Now().Add(27 * time.Second)
+			return m, nil
+		}
+		m.state = StateSuccess
+		m.message = fmt.Sprintf("✓ Opened %s", msg.url)
+		m.messageTime = time.Now()
+		return m, nil
+
+	case argsMsg:
+		return m.handleArgsMsg(msg)
+
+	case loadDoneMsg:
+		return m.handleLoadDone(msg)
+
+	case benchDoneMsg:
+		return m.handleBenchDone(msg)
+
+	case testDoneMsg:
+		return m.handleTestDone(msg)
 
 	case errorMsg:
+		notify := m.finishOperation(false, string(msg))
 		m.state = StateError
 		m.message = fmt.Sprintf("✗ %s", string(msg))
 		m.messageTime = time.Now()
-		return m, nil
+		return m, notify
 	}
 	return m, nil
 }
 
 func handleKeyMsg(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.state == StateLoadingModel && (msg.String() == "esc" || msg.String() == "x") {
+		return cancelLoad(m)
+	}
+	if m.bench != nil && (msg.String() == "esc" || msg.String() == "x") {
+		m.bench.cancel()
+		return m, nil
+	}
+
 	switch msg.String() {
 	case "ctrl+c", "q":
 		return m, tea.Quit
@@ -423,10 +597,96 @@ func handleKeyMsg(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
 		m.showHelp = !m.showHelp
 		return m, nil
 
+	case "?":
+		m.showFullHelp = true
+		return m, nil
+
+	case "b":
+		return startBench(m)
+
+	case "T":
+		return startTest(m)
+
+	case ":":
+		m.commanding = true
+		return m, m.palette.Focus()
+
+	case "i":
+		m.showDetails = !m.showDetails
+		return m, nil
+
+	case "c":
+		return openChat(m)
+
+	case "g":
+		return openLogs(m)
+
+	case "D":
+		return openDownloads(m)
+
+	case "t":
+		return openSlots(m)
+
+	case "a":
+		return openUsage(m)
+
+	case "w":
+		return openServers(m)
+
+	case "o":
+		return openWebUI(m)
+
+	case "y":
+		return yankEndpoint(m)
+
+	case "s":
+		if len(m.profiles) > 1 {
+			return openPicker(m)
+		}
+		return m, nil
+
+	case "S":
+		m.sortBy = nextMode(sortModes, m.sortBy)
+		m.applyFilter()
+		return m, m.saveListPrefs()
+
+	case "G":
+		m.groupBy = nextMode(groupModes, m.groupBy)
+		m.applyFilter()
+		return m, m.saveListPrefs()
+
+	case "/":
+		m.focus = PaneModels
+		m.filtering = true
+		return m, m.filter.Focus()
+
+	case "esc":
+		if m.filter.Value() != "" {
+			m.filter.SetValue("")
+			m.applyFilter()
+			return m, m.saveListPrefs()
+		}
+		return m, nil
+
+	case "tab":
+		if m.focus == PaneModels && len(m.instances) > 0 {
+			m.focus = PaneInstances
+		} else {
+			m.focus = PaneModels
+		}
+		return m, nil
+
 	case "up", "k":
+		if m.focus == PaneInstances {
+			if len(m.instances) > 0 {
+				m.instanceIdx = (m.instanceIdx - 1 + len(m.instances)) % len(m.instances)
+			}
+			return m, nil
+		}
 		if m.state == StateReady || m.state == StateModelSelected {
-			if len(m.models) > 0 {
-				m.selectedIdx = (m.selectedIdx - 1 + len(m.models)) % len(m.models)
+			if len(m.visible) > 0 {
+				m.selectedIdx = (m.selectedIdx - 1 + len(m.visible)) % len(m.visible)
+				m.scrollToCursor()
 			}
 			if m.state == StateReady {
 				m.state = StateModelSelected
@@ -435,9 +695,16 @@ func handleKeyMsg(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
 		return m, nil
 
 	case "down", "j":
+		if m.focus == PaneInstances {
+			if len(m.instances) > 0 {
+				m.instanceIdx = (m.instanceIdx + 1) % len(m.instances)
+			}
+			return m, nil
+		}
 		if m.state == StateReady || m.state == StateModelSelected {
-			if len(m.models) > 0 {
-				m.selectedIdx = (m.selectedIdx + 1) % len(m.models)
+			if len(m.visible) > 0 {
+				m.selectedIdx = (m.selectedIdx + 1) % len(m.visible)
+				m.scrollToCursor()
 			}
 			if m.state == StateReady {
 				m.state = StateModelSelected
@@ -446,84 +713,459 @@ func handleKeyMsg(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
 		return m, nil
 
 	case "enter":
-		if m.state == StateReady || m.state == StateModelSelected {
-			if m.selectedIdx >= 0 && m.selectedIdx < len(m.models) {
-				m.state = StateLoadingModel
-				return m, loadModel(m.baseURL, m.selectedIdx)
+		if m.focus == PaneModels && (m.state == StateReady || m.state == StateModelSelected) {
+			if m, blocked := m.offlineBlocked("load"); blocked {
+				return m, nil
+			}
+			if model, ok := m.selectedModel(); ok {
+				if m.runningCount(model.Name) > 0 {
+					m.confirmingLoad = true
+					return m, nil
+				}
+				return startLoad(m, model, false, nil)
 			}
 		}
 		return m, nil
 
+	case "L":
+		if m.focus == PaneModels && (m.state == StateReady || m.state == StateModelSelected) {
+			return openArgsEditor(m)
+		}
+		return m, nil
+
 	case "u":
 		if m.state == StateReady || m.state == StateModelSelected {
+			if m, blocked := m.offlineBlocked("unload"); blocked {
+				return m, nil
+			}
+			port := m.unloadTarget()
+			if port == 0 {
+				m.state = StateError
+				m.message = "✗ Nothing to unload: select a running instance (Tab)"
+				m.messageTime = time.Now()
+				return m, nil
+			}
+			m.state = StateUnloadingModel
+			m.beginOperation(m.instanceName(port), true)
+			return m, unloadInstance(m.client, port)
+		}
+		return m, nil
+
+	case "U":
+		if (m.state == StateReady || m.state == StateModelSelected) && len(m.instances) > 0 {
+			if m, blocked := m.offlineBlocked("unload"); blocked {
+				return m, nil
+			}
 			m.state = StateUnloadingModel
-			return m, unloadModel(m.baseURL)
+			m.beginOperation("all models", true)
+			return m, unloadInstance(m.client, 0)
+		}
+		return m, nil
+
+	case "p":
+		m.paused = !m.paused
+		if !m.paused {
+			// Catch up immediately instead of waiting a full interval.
+			m.lastStatus = time.Time{}
 		}
 		return m, nil
 
 	case "r":
 		m.state = StateLoading
+		m.lastStatus = time.Now()
 		return m, tea.Batch(
-			fetchModels(m.baseURL),
-			fetchStatus(m.baseURL),
-			fetchHealth(m.baseURL),
+			fetchModels(m.client),
+			fetchInstances(m.client),
+			fetchHealth(m.client),
+			fetchResources(m.client),
 		)
 	}
 
 	return m, nil
 }
 
-func (m Model) View() string {
-	if m.windowWidth == 0 || m.windowHeight == 0 {
-		return "Initializing..."
+// handleFilterKey edits the filter query. The list narrows as the query
+// changes; Enter keeps the filter and Esc clears it.
+func handleFilterKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "enter":
+		m.filtering = false
+		m.filter.Blur()
+		return m, m.saveListPrefs()
+
+	case "esc":
+		m.filtering = false
+		m.filter.Blur()
+		m.filter.SetValue("")
+		m.applyFilter()
+		return m, m.saveListPrefs()
+
+	case "up", "down":
+		if len(m.visible) > 0 {
+			if msg.String() == "up" {
+				m.selectedIdx = (m.selectedIdx - 1 + len(m.visible)) % len(m.visible)
+			} else {
+				m.selectedIdx = (m.selectedIdx + 1) % len(m.visible)
+			}
+			m.scrollToCursor()
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	before := m.filter.Value()
+	m.filter, cmd = m.filter.Update(msg)
+	if m.filter.Value() != before {
+		m.applyFilter()
+	}
+	return m, cmd
+}
+
+// listHeight is the number of model rows that fit in the model panel below
+// its header, filter line and scroll marker.
+func (m Model) listHeight() int {
+	return max(0, panelHeight(m.layout().topHeight)-3)
+}
+
+// scrollToCursor adjusts listOffset so the cursor row is inside the visible
+// window of the model list.
+// keepResultOnScreen delays clearing the message of a bench or smoke test
+// long enough to read the numbers in it.
+func (m *Model) keepResultOnScreen() {
+	m.messageTime = time.Now().Add(12 * time.Second)
+}
+
+func (m *Model) scrollToCursor() {
+	height := m.listHeight()
+	m.listOffset = max(0, min(m.listOffset, m.selectedIdx, len(m.visible)-1))
+	for height > 0 && m.listOffset < m.selectedIdx && m.listLines(m.listOffset, m.selectedIdx) > height {
+		m.listOffset++
+	}
+	// Scroll back up while the rest of the list still fits.
+	for m.listOffset > 0 && m.listLines(m.listOffset-1, len(m.visible)-1) <= height {
+		m.listOffset--
+	}
+}
+
+// modelCount describes the list size, e.g. "12" or "3/80" when filtered.
+func (m Model) modelCount() string {
+	if len(m.visible) == len(m.models) {
+		return fmt.Sprintf("%d", len(m.models))
+	}
+	return fmt.Sprintf("%d/%d", len(m.visible), len(m.models))
+}
+
+// filterLine shows the filter input while editing, or the applied query,
+// sort order and grouping.
+func (m Model) filterLine(style lipgloss.Style) string {
+	if m.filtering {
+		if m.filterErr != "" {
+			return m.filter.View() + "  " + m.styles.bad.Render(m.filterErr)
+		}
+		return m.filter.View()
+	}
+	var parts []string
+	if m.filter.Value() != "" {
+		parts = append(parts, "/"+m.filter.Value()+"  (Esc to clear)")
+	}
+	if m.filterErr != "" {
+		parts = append(parts, m.filterErr)
+	}
+	if m.sortBy != "" {
+		parts = append(parts, "sorted by "+sortLabel(m.sortBy))
+	}
+	if m.groupBy != "" {
+		parts = append(parts, "grouped by "+m.groupBy)
+	}
+	return style.Render(strings.Join(parts, " · "))
+}
+
+// unloadTarget returns the port of the instance the unload key acts on: the
+// selected row of the Running pane, or the latest instance of the selected
+// model. It returns 0 when there is nothing to unload.
+func (m Model) unloadTarget() int {
+	if m.focus == PaneInstances {
+		if m.instanceIdx >= 0 && m.instanceIdx < len(m.instances) {
+			return m.instances[m.instanceIdx].Port
+		}
+		return 0
+	}
+
+	model, ok := m.selectedModel()
+	if !ok {
+		return 0
+	}
+	port := 0
+	for _, inst := range m.instances {
+		if inst.Name == model.Name {
+			port = inst.Port
+		}
+	}
+	return port
+}
+
+// instanceName returns the model running on port, or the port itself when
+// no instance is known there.
+func (m Model) instanceName(port int) string {
+	for _, inst := range m.instances {
+		if inst.Port == port {
+			return inst.Name
+		}
+	}
+	return fmt.Sprintf("port %d", port)
+}
+
+// runningCount returns how many instances of the named model are running.
+func (m Model) runningCount(name string) int {
+	count := 0
+	for _, inst := range m.instances {
+		if inst.Name == name {
+			count++
+		}
+	}
+	return count
+}
+
+// loadedSummary counts the loaded models, e.g. "2 models (3 instances)".
+func (m Model) loadedSummary() string {
+	models := map[string]bool{}
+	for _, inst := range m.instances {
+		models[inst.Name] = true
+	}
+	switch {
+	case len(models) == 0:
+		return "none"
+	case len(models) == len(m.instances):
+		return fmt.Sprintf("%d model%s", len(models), plural(len(models)))
+	}
+	return fmt.Sprintf("%d model%s (%d instances)", len(models), plural(len(models)), len(m.instances))
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
 	}
+	return "s"
+}
 
-	titleStyle := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("#FFFFFF")).
-		Background(lipgloss.Color("#7C3AED")).
-		Padding(0, 2).
-		MarginBottom(1)
+// startLoad loads model, or starts another instance of it when another is
+// set. Non-nil args replace the launch arguments for this load only. The
+// model is remembered so that its progress and completion are tracked apart
+// from instances that are already running.
+func startLoad(m Model, model ModelInfo, another bool, args []string) (Model, tea.Cmd) {
+	m.confirmingLoad = false
+	m.state = StateLoadingModel
+	m.loadingName = model.Name
+	m.loadStarted = time.Now()
+	m.beginOperation(model.Name, false)
+	m.loadID++
+	return m, loadModel(m.client, m.loadID, model.Index, another, args)
+}
 
-	sectionStyle := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("240")).
-		Padding(0, 1).
-		Margin(0, 1, 1, 0)
+// handleConfirmLoadKey answers the prompt shown when Enter is pressed on a
+// model that is already running.
+func handleConfirmLoadKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	model, ok := m.selectedModel()
+	if !ok {
+		m.confirmingLoad = false
+		return m, nil
+	}
 
-	statusGood := lipgloss.NewStyle().Foreground(lipgloss.Color("46")).Bold(true)
-	statusBad := lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
-	statusNeutral := lipgloss.NewStyle().Foreground(lipgloss.Color("220"))
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
 
-	selectedStyle := lipgloss.NewStyle().
-		Background(lipgloss.Color("63")).
-		Foreground(lipgloss.Color("255")).
-		Bold(true).
-		Padding(0, 1)
+	case "enter", "y":
+		if m, blocked := m.offlineBlocked("load"); blocked {
+			m.confirmingLoad = false
+			return m, nil
+		}
+		return startLoad(m, model, true, nil)
+
+	case "f":
+		m.confirmingLoad = false
+		for i, inst := range m.instances {
+			if inst.Name == model.Name {
+				m.focus = PaneInstances
+				m.instanceIdx = i
+				break
+			}
+		}
+		return m, nil
+
+	case "esc", "n":
+		m.confirmingLoad = false
+	}
+	return m, nil
+}
+
+// openPicker shows the server picker and probes every profile in the
+// background so unreachable servers can be marked offline.
+func openPicker(m Model) (Model, tea.Cmd) {
+	m.picking = true
+	m.pickerIdx = m.profileIdx
+	m.profileOnline = make(map[int]bool)
+
+	var cmds []tea.Cmd
+	for i, p := range m.profiles {
+		cmds = append(cmds, probeProfile(i, p))
+	}
+	return m, tea.Batch(cmds...)
+}
+
+func handlePickerKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "esc", "s", "q":
+		m.picking = false
+		return m, nil
+
+	case "up", "k":
+		m.pickerIdx = (m.pickerIdx - 1 + len(m.profiles)) % len(m.profiles)
+		return m, nil
+
+	case "down", "j":
+		m.pickerIdx = (m.pickerIdx + 1) % len(m.profiles)
+		return m, nil
 
-	loadedStyle := lipgloss.NewStyle().
-		Background(lipgloss.Color("#F48FB1")).
-		Foreground(lipgloss.Color("255")).
-		Bold(true).
-		Padding(0, 1)
+	case "enter":
+		m.picking = false
+		if m.pickerIdx == m.profileIdx {
+			return m, nil
+		}
+		return switchProfile(m, m.pickerIdx)
+	}
+	return m, nil
+}
 
-	modelItemStyle := lipgloss.NewStyle().
-		Padding(0, 1).
-		Margin(0, 0, 0, 0)
+// switchProfile points lmc at another server. Requests still running against
+// the old server are cancelled and their replies ignored.
+func switchProfile(m Model, idx int) (Model, tea.Cmd) {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := m.profiles[idx]
 
-	messageSuccess := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("46")).
-		Bold(true)
+	m.client = apiClient{baseURL: p.URL, token: p.Token, ctx: ctx, gen: m.client.gen + 1}
+	m.cancel = cancel
+	m.profileIdx = idx
 
-	messageError := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("196")).
-		Bold(true)
+	m.chat.stop()
+	if m.bench != nil {
+		m.bench.cancel()
+	}
+	m.chat.messages = nil
+	m.chatting = false
+	m.logs.stop()
+	m.viewingLogs = false
+	m.downloads = newDownloadsModel()
+	m.viewingDownloads = false
+	m.slots = slotsModel{}
+	m.viewingSlots = false
+	m.usage = usageModel{}
+	m.viewingUsage = false
+	m.viewingServers = false
+
+	m.state = StateLoading
+	m.confirmingLoad = false
+	m.editingArgs = false
+	m.argsInput.Blur()
+	m.cancelName = ""
+	m.models = nil
+	m.visible = nil
+	m.selectedIdx = 0
+	m.listOffset = 0
+	m.health = "Checking..."
+	m.instances = nil
+	m.instanceIdx = 0
+	m.resources = ResourceUsage{}
+	m.focus = PaneModels
+	m.statusError = false
+	m.failures = 0
+	m.offline = false
+	m.lastFetchError = ""
+	m.lastStatus = time.Now()
+	m.events = streamEvents(m.client)
+	m.eventsLive = false
+
+	return m, tea.Batch(
+		fetchModels(m.client),
+		fetchInstances(m.client),
+		fetchHealth(m.client),
+		fetchResources(m.client),
+		waitEvent(m.client, m.events),
+	)
+}
 
-	helpStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("240")).
-		Italic(true)
+func (m Model) View() string {
+	if m.windowWidth == 0 || m.windowHeight == 0 {
+		return "Initializing..."
+	}
+	if m.windowHeight < minWindowHeight || m.windowWidth < minWindowWidth {
+		return lipgloss.Place(m.windowWidth, m.windowHeight,
+			lipgloss.Center, lipgloss.Center,
+			fmt.Sprintf("Terminal too small (%dx%d)\nResize to at least %dx%d",
+				m.windowWidth, m.windowHeight, minWindowWidth, minWindowHeight))
+	}
+	if m.chatting {
+		return lipgloss.Place(m.windowWidth, m.windowHeight,
+			lipgloss.Center, lipgloss.Center,
+			m.chatView())
+	}
+	if m.viewingLogs {
+		return lipgloss.Place(m.windowWidth, m.windowHeight,
+			lipgloss.Center, lipgloss.Center,
+			m.logView())
+	}
+	if m.viewingDownloads {
+		return lipgloss.Place(m.windowWidth, m.windowHeight,
+			lipgloss.Center, lipgloss.Center,
+			m.downloadsView())
+	}
+	if m.viewingSlots {
+		return lipgloss.Place(m.windowWidth, m.windowHeight,
+			lipgloss.Center, lipgloss.Center,
+			m.slotsView())
+	}
+	if m.viewingUsage {
+		return lipgloss.Place(m.windowWidth, m.windowHeight,
+			lipgloss.Center, lipgloss.Center,
+			m.usageView())
+	}
+	if m.viewingServers {
+		return lipgloss.Place(m.windowWidth, m.windowHeight,
+			lipgloss.Center, lipgloss.Center,
+			m.serversView())
+	}
 
-	title := titleStyle.Render("lmgo Control")
+	st := m.styles
+	titleStyle := st.title.MarginBottom(1)
+	sectionStyle := st.section
+	statusGood := st.good
+	statusBad := st.bad
+	statusNeutral := st.warn
+	selectedStyle := st.selected
+	loadedStyle := st.loaded
+	modelItemStyle := st.item
+	messageSuccess := st.success
+	messageError := st.error
+	helpStyle := st.help
+	matchStyle := st.match
+	groupStyle := st.group
+
+	l := m.layout()
+	colWidth := l.colWidth
+
+	profile := m.profiles[m.profileIdx]
+	title := lipgloss.JoinHorizontal(lipgloss.Top,
+		titleStyle.Render("lmgo Control"),
+		helpStyle.Render(truncateString(fmt.Sprintf("  %s · %s", profile.Name, profile.URL), m.windowWidth-16)))
 
 	var modelList string
 	if m.state == StateLoading && len(m.models) == 0 {
@@ -535,27 +1177,74 @@ func (m Model) View() string {
 		modelList = fmt.Sprintf("%s%s", loadingText, dots)
 	} else if len(m.models) == 0 {
 		modelList = "No available models found"
+	} else if len(m.visible) == 0 {
+		modelList = statusNeutral.Render("No models match the filter")
 	} else {
-		maxModelNameWidth := max(10, (m.windowWidth/2 - 12))
+		maxModelNameWidth := max(10, (colWidth - 12))
+		start := min(m.listOffset, len(m.visible))
+		end := start
+
+		lines := 0
+		for row := start; row < len(m.visible); row++ {
+			header, hasHeader := m.groupHeader(row, start)
+			need := 1
+			if hasHeader {
+				need++
+			}
+			if lines+need > m.listHeight() {
+				break
+			}
+			lines += need
+			end = row + 1
+			if hasHeader {
+				modelList += groupStyle.Render(truncateString(header, maxModelNameWidth)) + "\n"
+			}
 
-		for i, model := range m.models {
-			displayName := truncateString(model.Name, maxModelNameWidth-4)
+			i := m.visible[row]
+			model := m.models[i]
+			nameWidth := maxModelNameWidth - 4
+			summary := model.summary()
+			if summary != "" && nameWidth-len(summary)-2 >= minSummaryNameWidth {
+				nameWidth -= len(summary) + 2
+			} else {
+				summary = ""
+			}
+			displayName := truncateString(model.Name, nameWidth)
+			displayName = highlightMatches(displayName, m.matches[i], matchStyle)
 			item := fmt.Sprintf("%d. %s", i+1, displayName)
 
-			if i == m.selectedIdx {
+			if count := m.runningCount(model.Name); count > 1 {
+				item += fmt.Sprintf(" (%d)", count)
+			}
+			if summary != "" {
+				item += "  " + summary
+			}
+
+			if row == m.selectedIdx && m.focus == PaneModels {
 				item = selectedStyle.Render(fmt.Sprintf("➤  %s", item))
-			} else if model.Name == m.loadedConfigName || (m.loadedConfigName == "" && model.Name == m.loadedModelName) {
+			} else if m.runningCount(model.Name) > 0 {
 				item = loadedStyle.Render(fmt.Sprintf("  %s", item))
 			} else {
 				item = modelItemStyle.Render(fmt.Sprintf("  %s", item))
 			}
 			modelList += item + "\n"
 		}
+		if end < len(m.visible) {
+			modelList += helpStyle.Render(fmt.Sprintf("  ↓ %d more", len(m.visible)-end))
+		}
 	}
 
-	modelPanel := sectionStyle.Width(m.windowWidth/2 - 4).
-		Height(m.windowHeight/2 - 2).
-		Render(fmt.Sprintf("Available Models (%d)\n\n%s", len(m.models), modelList))
+	header := fmt.Sprintf("Available Models (%s)", m.modelCount())
+	if m.listOffset > 0 || m.listLines(0, len(m.visible)-1) > m.listHeight() {
+		header += helpStyle.Render(fmt.Sprintf("  %d/%d", m.selectedIdx+1, len(m.visible)))
+		if m.listOffset > 0 {
+			header += helpStyle.Render(fmt.Sprintf("  ↑ %d more", m.listOffset))
+		}
+	}
+
+	modelPanel := sectionStyle.Width(panelWidth(colWidth)).
+		Height(panelHeight(l.topHeight)).
+		Render(fmt.Sprintf("%s\n%s\n%s", header, m.filterLine(helpStyle), modelList))
 
 	healthStatus := statusNeutral.Render(m.health)
 	if m.health == "ok" {
@@ -564,34 +1253,82 @@ func (m Model) View() string {
 		healthStatus = statusBad.Render("✗ Error")
 	}
 
-	modelStatus := statusNeutral.Render(m.loadedModel)
-	if m.loadedModel != "None" && m.loadedModel != "" {
-		maxModelStatusWidth := max(10, (m.windowWidth/2 - 20))
-		displayName := truncateString(m.loadedModel, maxModelStatusWidth-4)
-		modelStatus = statusGood.Render("✓ " + displayName)
+	var instanceList string
+	if len(m.instances) == 0 {
+		instanceList = statusNeutral.Render("No model loaded")
+	} else {
+		maxInstanceNameWidth := max(10, (colWidth - 30))
+
+		for i, inst := range m.instances {
+			name := inst.Name
+			if inst.InstanceNum > 1 {
+				name = fmt.Sprintf("%s #%d", name, inst.InstanceNum)
+			}
+			state := statusGood.Render("✓")
+			if inst.loading() {
+				state = statusNeutral.Render("…")
+			} else if !inst.Healthy {
+				state = statusBad.Render("✗")
+			}
+			if inst.Restarts > 0 {
+				state += statusNeutral.Render(fmt.Sprintf(" ↻%d", inst.Restarts))
+			}
+			item := fmt.Sprintf("%s :%d  %s  %s",
+				truncateString(name, maxInstanceNameWidth), inst.Port,
+				formatUptime(inst.Uptime), state)
+			if slots := m.slotsText(inst.Port, statusNeutral, statusBad); slots != "" {
+				item += "  " + slots
+			}
+
+			if i == m.instanceIdx && m.focus == PaneInstances {
+				item = selectedStyle.Render(fmt.Sprintf("➤  %s", item))
+			} else {
+				item = modelItemStyle.Render(fmt.Sprintf("  %s", item))
+			}
+			instanceList += item + "\n"
+		}
+	}
+
+	resourceLines := m.resourceLines(statusNeutral, statusBad)
+
+	runningPanel := sectionStyle.Width(panelWidth(colWidth)).
+		Height(panelHeight(l.runningHeight)).
+		Render(clipLines(fmt.Sprintf("Running (%d)\n\n%s", len(m.instances), instanceList), panelHeight(l.runningHeight)))
+
+	lastUpdated := m.lastStatus.Format("15:04:05")
+	if m.paused {
+		lastUpdated += statusBad.Render("  ⏸ paused")
+	} else {
+		lastUpdated += helpStyle.Render(fmt.Sprintf("  every %v", m.statusInterval()))
+		if m.eventsLive {
+			lastUpdated += helpStyle.Render(", live")
+		}
 	}
 
-	statusPanel := sectionStyle.Width(m.windowWidth/2 - 4).
-		Height(m.windowHeight/2 - 2).
-		Render(fmt.Sprintf(
-			"Health Status: %s\n\n"+
-				"Current Model: %s\n\n"+
-				"Last Updated: %s",
-			healthStatus,
-			modelStatus,
-			m.lastStatus.Format("15:04:05")))
+	statusText := fmt.Sprintf(
+		"Health Status: %s\n"+
+			"Loaded: %s\n"+
+			"Last Updated: %s",
+		healthStatus,
+		m.loadedSummary(),
+		lastUpdated)
+	for _, line := range resourceLines {
+		statusText += "\n" + line
+	}
+
+	var statusPanel string
+	if l.statusHeight > 0 {
+		statusPanel = sectionStyle.Width(panelWidth(colWidth)).
+			Height(panelHeight(l.statusHeight)).
+			Render(statusText)
+	}
 
 	var actionPanel string
 	switch m.state {
 	case StateLoading:
 		actionPanel = "Initializing..."
 	case StateLoadingModel:
-		loadingText := "Loading model"
-		dots := ""
-		for i := 0; i < m.loadingDots; i++ {
-			dots += "."
-		}
-		actionPanel = fmt.Sprintf("%s%s", loadingText, dots)
+		actionPanel = m.loadProgressView(m.windowWidth - 8)
 	case StateUnloadingModel:
 		loadingText := "Unloading model"
 		dots := ""
@@ -604,56 +1341,135 @@ func (m Model) View() string {
 	case StateError:
 		actionPanel = messageError.Render(m.message)
 	default:
-		if len(m.models) > 0 && m.selectedIdx >= 0 && m.selectedIdx < len(m.models) {
-			selectedModel := m.models[m.selectedIdx]
+		if m.focus == PaneInstances && m.instanceIdx < len(m.instances) {
+			inst := m.instances[m.instanceIdx]
+			actionPanel = fmt.Sprintf("Selected instance: %s on port %d", truncateString(inst.Name, m.windowWidth-40), inst.Port)
+		} else if selectedModel, ok := m.selectedModel(); ok {
 			maxActionWidth := m.windowWidth - 10
 			displayName := truncateString(selectedModel.Name, maxActionWidth-10)
 			actionPanel = fmt.Sprintf("Selected: %s", displayName)
 		} else {
-			actionPanel = "Use ↑↓ to select model | Enter to load | U to unload | R to refresh | Q to exit"
+			actionPanel = "Use ↑↓ to select model | Enter to load | Tab to switch pane | u to unload | R to refresh | Q to exit"
 		}
 	}
 
+	if m.bench != nil && (m.state == StateReady || m.state == StateModelSelected) {
+		actionPanel = fmt.Sprintf("Benchmarking %s%s  (Esc/x: Cancel)",
+			truncateString(m.bench.name, m.windowWidth/3), strings.Repeat(".", m.loadingDots))
+	}
+	if m.offline && m.state != StateSuccess && m.state != StateError {
+		actionPanel = statusBad.Render(m.offlineBanner())
+	}
+	if m.confirmingLoad {
+		if model, ok := m.selectedModel(); ok {
+			actionPanel = statusNeutral.Render(fmt.Sprintf("%s is already running (%d). Enter: Start another instance | F: Go to it | Esc: Cancel",
+				truncateString(model.Name, m.windowWidth/3), m.runningCount(model.Name)))
+		}
+	}
+	if m.editingArgs && m.state != StateError {
+		m.argsInput.Width = max(10, m.windowWidth-16)
+		actionPanel = m.argsInput.View()
+	}
+	if m.commanding {
+		m.palette.Width = max(10, m.windowWidth-12)
+		actionPanel = m.palette.View()
+	}
+
 	actionPanel = sectionStyle.Width(m.windowWidth - 4).
 		Height(1).
 		Render(actionPanel)
 
-	var helpPanel string
-	if m.showHelp {
-		helpText := "↑↓/kj: Select | Enter: Load selected model | U: Unload current model \n R: Refresh data | Q/Ctrl+C: Exit"
-		helpPanel = helpStyle.Render(helpText)
+	if m.picking {
+		var list string
+		for i, p := range m.profiles {
+			state := statusNeutral.Render("checking…")
+			if online, ok := m.profileOnline[i]; ok {
+				if online {
+					state = statusGood.Render("online")
+				} else {
+					state = statusBad.Render("offline")
+				}
+			}
+			item := fmt.Sprintf("%s  %s  %s", p.Name, helpStyle.Render(p.URL), state)
+			if i == m.pickerIdx {
+				item = selectedStyle.Render("➤  " + item)
+			} else {
+				item = modelItemStyle.Render("  " + item)
+			}
+			list += item + "\n"
+		}
+		modelPanel = sectionStyle.Width(panelWidth(colWidth)).
+			Height(panelHeight(l.topHeight)).
+			Render(clipLines(fmt.Sprintf("Switch Server (%d)\n\n%s", len(m.profiles), list), panelHeight(l.topHeight)))
 	}
 
-	topRow := lipgloss.JoinHorizontal(lipgloss.Top, modelPanel, statusPanel)
+	rightColumn := lipgloss.JoinVertical(lipgloss.Left, runningPanel, statusPanel)
+	var detailsPanel string
+	if m.detailsVisible() {
+		detailsPanel = sectionStyle.Width(panelWidth(colWidth)).
+			Height(panelHeight(l.topHeight)).
+			Render(clipLines("Details\n\n"+m.detailsView(panelWidth(colWidth)-4, helpStyle), panelHeight(l.topHeight)))
+	}
+
+	var topRow string
+	switch {
+	case l.columns == 1 && m.picking:
+		topRow = modelPanel
+	case l.columns == 1 && m.focus == PaneInstances:
+		topRow = rightColumn
+	case l.columns == 1 && detailsPanel != "":
+		topRow = detailsPanel
+	case l.columns == 1:
+		topRow = modelPanel
+	case l.columns == 3:
+		topRow = lipgloss.JoinHorizontal(lipgloss.Top, modelPanel, detailsPanel, rightColumn)
+	case detailsPanel != "":
+		topRow = lipgloss.JoinHorizontal(lipgloss.Top, modelPanel, detailsPanel)
+	default:
+		topRow = lipgloss.JoinHorizontal(lipgloss.Top, modelPanel, rightColumn)
+	}
 
 	fullScreen := lipgloss.JoinVertical(lipgloss.Left,
 		title,
 		topRow,
 		actionPanel,
-		helpPanel,
+		m.statusBar(m.windowWidth-2),
+		m.helpPanel(),
 	)
 
-	return lipgloss.Place(m.windowWidth, m.windowHeight,
+	screen := lipgloss.Place(m.windowWidth, m.windowHeight,
 		lipgloss.Center, lipgloss.Center,
 		fullScreen,
 		lipgloss.WithWhitespaceChars(""),
-		lipgloss.WithWhitespaceForeground(lipgloss.Color("238")),
+		lipgloss.WithWhitespaceForeground(st.whitespace),
 	)
+	if m.showFullHelp {
+		screen = overlay(screen, m.fullHelpView(m.windowWidth), m.windowWidth, m.windowHeight, st.dim)
+	}
+	return screen
 }
 
-func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+// truncateString shortens s to at most maxWidth terminal cells. It measures
+// display width rather than bytes, never splits a character or grapheme
+// cluster, and ends with "…" only when something was cut.
+func truncateString(s string, maxWidth int) string {
+	if maxWidth <= 0 {
+		return ""
 	}
+	return ansi.Truncate(s, maxWidth, "…")
+}
 
-	if maxLen <= 3 {
-		if maxLen <= 0 {
-			return ""
-		}
-		return s[:maxLen]
+// formatUptime renders seconds as a compact duration such as "2h05m" or "42s".
+func formatUptime(seconds int64) string {
+	d := time.Duration(seconds) * time.Second
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
 	}
-
-	return s[:maxLen-3] + "..."
 }
 
 func tickCmd() tea.Cmd {
@@ -663,8 +1479,26 @@ func tickCmd() tea.Cmd {
 }
 
 func main() {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if command, ok := subcommands[args[0]]; ok {
+			os.Exit(command(args[1:]))
+		}
+	}
+
+	opts, err := resolveOptions(flag.CommandLine, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lmc: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.themeName == "none" {
+		// Also strips the colors of bubbles components such as the
+		// progress bar, which do not use the theme.
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+
 	p := tea.NewProgram(
-		NewModel(),
+		NewModel(opts),
 		tea.WithAltScreen(),
 	)
 
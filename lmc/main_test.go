@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestTruncateString(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		maxWidth int
+		want     string
+	}{
+		{"ascii fits", "llama-3-8b", 10, "llama-3-8b"},
+		{"ascii cut", "llama-3-8b-instruct", 10, "llama-3-8…"},
+		{"ascii one cell", "llama", 1, "…"},
+		{"zero width", "llama", 0, ""},
+		{"negative width", "llama", -3, ""},
+		{"empty", "", 5, ""},
+		{"cjk fits", "通义千问", 8, "通义千问"},
+		{"cjk cut", "通义千问模型", 8, "通义千…"},
+		{"cjk never split", "通义千问模型", 7, "通义千…"},
+		{"cjk odd width", "通义千问模型", 4, "通…"},
+		{"combining marks fit", "cafe\u0301-e\u0301", 6, "cafe\u0301-e\u0301"},
+		{"combining mark kept with its letter", "e\u0301e\u0301e\u0301e\u0301", 3, "e\u0301e\u0301…"},
+		{"emoji fits", "🦙 llama", 8, "🦙 llama"},
+		{"emoji cut", "llama 🦙🦙", 9, "llama 🦙…"},
+		{"emoji dropped when the ellipsis needs its cell", "llama 🦙🦙", 8, "llama …"},
+		{"emoji never split", "ab🦙cd", 3, "ab…"},
+		{"zwj sequence kept whole", "👩‍💻👩‍💻 coder", 5, "👩‍💻👩‍💻…"},
+		{"styled", "\x1b[1mllama-3-8b-instruct\x1b[0m", 6, "\x1b[1mllama…\x1b[0m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateString(tt.s, tt.maxWidth)
+			if got != tt.want {
+				t.Errorf("truncateString(%q, %d) = %q, want %q", tt.s, tt.maxWidth, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateString(%q, %d) split a character: %q", tt.s, tt.maxWidth, got)
+			}
+			if w := ansi.StringWidth(got); w > max(tt.maxWidth, 0) {
+				t.Errorf("truncateString(%q, %d) is %d cells wide", tt.s, tt.maxWidth, w)
+			}
+		})
+	}
+}
+
+// TestTruncateStringAlignsMixedWidths pads truncated names of different
+// scripts the way the model list does and checks the columns line up.
+func TestTruncateStringAlignsMixedWidths(t *testing.T) {
+	names := []string{
+		"llama-3-8b-instruct-q4_k_m",
+		"通义千问-7B-Chat-量化版",
+		"cafe\u0301-cre\u0300me-e\u0301-model",
+		"🦙🦙 llama party 🦙🦙",
+		"short",
+	}
+	for _, width := range []int{4, 9, 12, 20, 40} {
+		for _, name := range names {
+			cell := truncateString(name, width)
+			padded := cell + strings.Repeat(" ", width-ansi.StringWidth(cell)) + "|"
+			if got := ansi.StringWidth(padded); got != width+1 {
+				t.Errorf("width %d: %q padded to %d cells, want %d", width, name, got, width+1)
+			}
+			// A cut name fills the column up to at most one cell, which is
+			// left when the next character is two cells wide.
+			if cell != name && ansi.StringWidth(cell) < width-1 {
+				t.Errorf("width %d: %q cut to %q, which wastes space", width, name, cell)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// notifyMinDuration is how long a load or unload must take before its end
+// is announced; quicker ones finish while the user is still watching.
+const notifyMinDuration = 3 * time.Second
+
+// beginOperation records the load or unload that is starting, so its end can
+// be announced.
+func (m *Model) beginOperation(name string, unload bool) {
+	m.opName = name
+	m.opUnload = unload
+	m.opStarted = time.Now()
+}
+
+// finishOperation announces the end of the recorded load or unload with the
+// terminal bell and a desktop notification, if notifyOnComplete is set. A
+// load that timed out is still announced when its result arrives.
+func (m *Model) finishOperation(ok bool, text string) tea.Cmd {
+	if m.opName == "" {
+		return nil
+	}
+	name := m.opName
+	m.opName = ""
+	if !m.notifyOnComplete || time.Since(m.opStarted) < notifyMinDuration {
+		return nil
+	}
+
+	title := "lmc: " + name
+	switch {
+	case !m.opUnload && ok:
+		title += " loaded"
+	case !m.opUnload:
+		title += " failed to load"
+	case ok:
+		title += " unloaded"
+	default:
+		title += " failed to unload"
+	}
+	return func() tea.Msg {
+		os.Stdout.WriteString("\a")
+		desktopNotify(title, text)
+		return nil
+	}
+}
+
+// desktopNotify shows a desktop notification with the platform's tool. A
+// missing tool or a failure is ignored: the bell has already rung.
+func desktopNotify(title, body string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", windowsToastScript)
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			title, body)
+	default:
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=lmc", title, body)
+	}
+	// Passed through the environment so no quoting is needed in the script.
+	cmd.Env = append(os.Environ(), "LMC_NOTIFY_TITLE="+title, "LMC_NOTIFY_BODY="+body)
+	cmd.Run()
+}
+
+const windowsToastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:LMC_NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:LMC_NOTIFY_BODY)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('lmc').Show([Windows.UI.Notifications.ToastNotification]::new($xml))
+`
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// offlineThreshold is the number of failed health checks in a row after
+	// which the server is treated as unreachable.
+	offlineThreshold = 2
+	maxRetryDelay    = 30 * time.Second
+)
+
+// fetchFailedMsg reports a background request that could not reach the
+// server, as opposed to one the server answered with an error.
+type fetchFailedMsg struct {
+	text   string
+	health bool
+}
+
+// fetchFailed counts failed health checks and switches to the offline state
+// once there are offlineThreshold of them in a row. An error is shown only
+// when it differs from the previous one, and not at all while offline, where
+// the banner already says what is going on.
+func (m Model) fetchFailed(msg fetchFailedMsg) Model {
+	if msg.health {
+		m.statusError = true
+		m.failures++
+		if m.failures >= offlineThreshold {
+			m.offline = true
+			m.retryAt = time.Now().Add(m.retryDelay())
+		}
+	}
+
+	if m.offline || msg.text == m.lastFetchError {
+		return m
+	}
+	m.lastFetchError = msg.text
+	m.state = StateError
+	m.message = "✗ " + msg.text
+	m.messageTime = time.Now()
+	return m
+}
+
+// retryDelay doubles the poll interval for every failure past the threshold,
+// up to maxRetryDelay.
+func (m Model) retryDelay() time.Duration {
+	delay := m.pollInterval
+	for i := offlineThreshold; i < m.failures && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// reconnected leaves the offline state after a successful health check and
+// reloads everything that may have changed while the server was away.
+func (m Model) reconnected() (Model, tea.Cmd) {
+	m.offline = false
+	m.lastFetchError = ""
+	m.state = StateSuccess
+	m.message = fmt.Sprintf("✓ Reconnected to %s", m.client.baseURL)
+	m.messageTime = time.Now()
+	m.lastStatus = time.Now()
+	return m, tea.Batch(fetchModels(m.client), fetchInstances(m.client))
+}
+
+// offlineBlocked refuses an action that needs the server while it is
+// unreachable.
+func (m Model) offlineBlocked(action string) (Model, bool) {
+	if !m.offline {
+		return m, false
+	}
+	m.state = StateError
+	m.message = fmt.Sprintf("✗ Cannot %s: server unreachable at %s", action, m.client.baseURL)
+	m.messageTime = time.Now()
+	return m, true
+}
+
+func (m Model) offlineBanner() string {
+	if m.paused {
+		return fmt.Sprintf("⚠ Server unreachable at %s — polling paused (P to resume)", m.client.baseURL)
+	}
+	wait := max(0, time.Until(m.retryAt).Round(time.Second))
+	return fmt.Sprintf("⚠ Server unreachable at %s — retrying in %v", m.client.baseURL, wait)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// paletteCommands are the commands understood by the ":" prompt.
+const paletteCommands = "load <model> | unload [<model>|all] | server <profile> | refresh | help | quit"
+
+func newPalette() textinput.Model {
+	input := textinput.New()
+	input.Prompt = ":"
+	input.Placeholder = paletteCommands
+	input.CharLimit = 256
+	return input
+}
+
+// handlePaletteKey edits the command line. Enter runs the command, Esc
+// closes the palette.
+func handlePaletteKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "esc":
+		m.commanding = false
+		m.palette.Blur()
+		return m, nil
+
+	case "enter":
+		line := m.palette.Value()
+		m.commanding = false
+		m.palette.Blur()
+		m.palette.SetValue("")
+		return runCommand(m, line)
+	}
+
+	var cmd tea.Cmd
+	m.palette, cmd = m.palette.Update(msg)
+	return m, cmd
+}
+
+// runCommand executes a palette command by replaying the keys that do the
+// same thing, so both paths behave identically.
+func runCommand(m Model, line string) (Model, tea.Cmd) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+
+	// A message still on screen must not swallow the command.
+	if (m.state == StateSuccess || m.state == StateError) && len(m.models) > 0 {
+		m.state = StateReady
+	}
+
+	switch strings.ToLower(name) {
+	case "":
+		return m, nil
+
+	case "load":
+		if arg == "" {
+			return commandError(m, "usage: load <model>")
+		}
+		row, err := m.findModelRow(arg)
+		if err != nil {
+			return commandError(m, err.Error())
+		}
+		m.focus = PaneModels
+		m.selectedIdx = row
+		m.scrollToCursor()
+		if m.state == StateReady {
+			m.state = StateModelSelected
+		}
+		return handleKeyMsg(m, tea.KeyMsg{Type: tea.KeyEnter})
+
+	case "unload":
+		switch {
+		case arg == "":
+			return handleKeyMsg(m, keyRunes("u"))
+		case strings.EqualFold(arg, "all"):
+			return handleKeyMsg(m, keyRunes("U"))
+		}
+		idx, err := m.findInstance(arg)
+		if err != nil {
+			return commandError(m, err.Error())
+		}
+		m.focus = PaneInstances
+		m.instanceIdx = idx
+		return handleKeyMsg(m, keyRunes("u"))
+
+	case "server":
+		if arg == "" {
+			return openPicker(m)
+		}
+		for i, p := range m.profiles {
+			if strings.EqualFold(p.Name, arg) {
+				if i == m.profileIdx {
+					return m, nil
+				}
+				return switchProfile(m, i)
+			}
+		}
+		names := make([]string, len(m.profiles))
+		for i, p := range m.profiles {
+			names[i] = p.Name
+		}
+		return commandError(m, fmt.Sprintf("unknown profile %q (available: %s)", arg, strings.Join(names, ", ")))
+
+	case "refresh":
+		return handleKeyMsg(m, keyRunes("r"))
+
+	case "help":
+		m.showFullHelp = true
+		return m, nil
+
+	case "quit", "q":
+		return m, tea.Quit
+	}
+	return commandError(m, fmt.Sprintf("unknown command %q (commands: %s)", name, paletteCommands))
+}
+
+func keyRunes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func commandError(m Model, text string) (Model, tea.Cmd) {
+	m.state = StateError
+	m.message = "✗ " + text
+	m.messageTime = time.Now()
+	return m, nil
+}
+
+// findModelRow returns the visible row of the model called name: an exact
+// match, ignoring case, or else the only model whose name contains it. The
+// filter is cleared when the model is hidden by it.
+func (m *Model) findModelRow(name string) (int, error) {
+	find := func() (int, error) {
+		var matches []int
+		for row, i := range m.visible {
+			model := m.models[i]
+			if strings.EqualFold(model.Name, name) {
+				return row, nil
+			}
+			if strings.Contains(strings.ToLower(model.Name), strings.ToLower(name)) {
+				matches = append(matches, row)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return 0, fmt.Errorf("no model matches %q", name)
+		case 1:
+			return matches[0], nil
+		}
+		return 0, fmt.Errorf("%d models match %q, be more specific", len(matches), name)
+	}
+
+	row, err := find()
+	if err != nil && m.filter.Value() != "" {
+		m.filter.SetValue("")
+		m.applyFilter()
+		row, err = find()
+	}
+	return row, err
+}
+
+// findInstance returns the index of the latest running instance of the
+// model called name, matched like findModelRow.
+func (m Model) findInstance(name string) (int, error) {
+	idx := -1
+	for i, inst := range m.instances {
+		if strings.EqualFold(inst.Name, name) {
+			idx = i
+		}
+	}
+	if idx >= 0 {
+		return idx, nil
+	}
+
+	matched := map[string]bool{}
+	for i, inst := range m.instances {
+		if strings.Contains(strings.ToLower(inst.Name), strings.ToLower(name)) {
+			matched[inst.Name] = true
+			idx = i
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return 0, fmt.Errorf("no running instance matches %q", name)
+	case 1:
+		return idx, nil
+	}
+	return 0, fmt.Errorf("%d running models match %q, be more specific", len(matched), name)
+}
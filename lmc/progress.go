@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// loadPollInterval is how often instances are polled while a load is in
+// progress, so the progress bar moves even with a long poll interval.
+const loadPollInterval = 500 * time.Millisecond
+
+// statusInterval is the poll interval for instances and health, shortened
+// while a model is loading and lengthened while the event stream reports
+// changes, including the load progress.
+func (m Model) statusInterval() time.Duration {
+	switch {
+	case m.eventsLive:
+		return max(m.pollInterval, eventsPollInterval)
+	case m.state == StateLoadingModel:
+		return min(m.pollInterval, loadPollInterval)
+	}
+	return m.pollInterval
+}
+
+func newLoadBar() progress.Model {
+	return progress.New(progress.WithDefaultGradient())
+}
+
+// loadingInstance finds the instance that is still starting for the model
+// being loaded.
+func (m Model) loadingInstance() (InstanceInfo, bool) {
+	if inst, ok := m.startingInstance(m.loadingName); ok {
+		return inst, true
+	}
+	for _, inst := range m.instances {
+		if inst.loading() {
+			return inst, true
+		}
+	}
+	return InstanceInfo{}, false
+}
+
+// startingInstance finds an instance of the named model that is still
+// starting.
+func (m Model) startingInstance(name string) (InstanceInfo, bool) {
+	for _, inst := range m.instances {
+		if inst.loading() && inst.Name == name {
+			return inst, true
+		}
+	}
+	return InstanceInfo{}, false
+}
+
+// loadProgressView renders the load in progress as a progress bar with the
+// elapsed time, or as animated dots while the server reports no percentage.
+// Once the tensors are read, the phase the server is in is shown instead of
+// an estimate.
+func (m Model) loadProgressView(width int) string {
+	elapsed := time.Since(m.loadStarted).Round(time.Second)
+	label := "Loading " + truncateString(m.loadingName, width/3)
+
+	if inst, ok := m.loadingInstance(); ok && inst.Progress >= 0 {
+		timing := elapsed.String()
+		if eta, ok := loadETA(time.Since(m.loadStarted), inst.Progress); ok {
+			timing += fmt.Sprintf(", ~%v left", eta)
+		} else if inst.Progress >= 100 && inst.Phase != "" {
+			timing = inst.Phase + ", " + timing
+		}
+		m.loadBar.Width = max(10, width-ansi.StringWidth(label)-ansi.StringWidth(timing)-6)
+		return fmt.Sprintf("%s  %s  %s", label, m.loadBar.ViewAs(float64(inst.Progress)/100), timing)
+	}
+	return fmt.Sprintf("%s%s  %v", label, strings.Repeat(".", m.loadingDots), elapsed)
+}
+
+// loadETA extrapolates the remaining load time from the progress so far. It
+// waits for a few percent first, since the early estimate swings wildly.
+func loadETA(elapsed time.Duration, percent int) (time.Duration, bool) {
+	if percent < 5 || percent >= 100 {
+		return 0, false
+	}
+	remaining := elapsed * time.Duration(100-percent) / time.Duration(percent)
+	return remaining.Round(time.Second), true
+}
+
+// loadDoneMsg is the result of the load numbered id: a successMsg or an
+// errorMsg.
+type loadDoneMsg struct {
+	id     int
+	result tea.Msg
+}
+
+// handleLoadDone reports the result of a load. Results of a cancelled load
+// are dropped, except that a load which finished before the cancel reached
+// the server is reported. A load that timed out is still reported when it
+// finishes, unless another operation is running by then.
+func (m Model) handleLoadDone(msg loadDoneMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.id == m.cancelledLoad:
+		if _, loaded := msg.result.(successMsg); loaded && m.cancelName != "" {
+			m.state = StateError
+			m.message = fmt.Sprintf("✗ %s finished loading before it could be cancelled", m.cancelName)
+			m.messageTime = time.Now()
+			m.cancelName = ""
+			return m, fetchInstances(m.client)
+		}
+		return m, nil
+
+	case msg.id != m.loadID:
+		return m, nil
+
+	case m.state == StateLoading || m.state == StateUnloadingModel:
+		return m, fetchInstances(m.client)
+	}
+	return m.Update(msg.result)
+}
+
+// checkLoadTimeout gives up waiting for a load that takes longer than the
+// load timeout. Polling goes on, so the model still shows up in the Running
+// pane if it finishes after all, and its result is reported when it arrives.
+func (m *Model) checkLoadTimeout() {
+	if m.state != StateLoadingModel || m.loadTimeout <= 0 || time.Since(m.loadStarted) < m.loadTimeout {
+		return
+	}
+	m.state = StateError
+	m.message = fmt.Sprintf("✗ %s did not finish loading within %v; still watching for it", m.loadingName, m.loadTimeout)
+	m.messageTime = time.Now()
+}
+
+// cancelLoad abandons the load in progress and unloads its instance. If the
+// server has not reported the instance yet, it is unloaded once it appears.
+func cancelLoad(m Model) (Model, tea.Cmd) {
+	m.cancelledLoad = m.loadID
+	if inst, ok := m.startingInstance(m.loadingName); ok {
+		m.state = StateUnloadingModel
+		m.beginOperation(m.loadingName, true)
+		return m, unloadInstance(m.client, inst.Port)
+	}
+	m.opName = ""
+	m.cancelName = m.loadingName
+	m.state = StateSuccess
+	m.message = fmt.Sprintf("✓ Cancelled loading %s", m.loadingName)
+	m.messageTime = time.Now()
+	return m, nil
+}
+
+// unloadCancelled unloads the instance of a cancelled load once the server
+// reports it.
+func (m Model) unloadCancelled() (Model, tea.Cmd) {
+	if m.cancelName == "" {
+		return m, nil
+	}
+	inst, ok := m.startingInstance(m.cancelName)
+	if !ok {
+		return m, nil
+	}
+	m.cancelName = ""
+	return m, unloadInstance(m.client, inst.Port)
+}
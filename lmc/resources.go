@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Usage above these fractions is shown in the warning and error colors.
+const (
+	usageWarn  = 0.75
+	usageAlert = 0.90
+)
+
+// MemoryUsage is used and total memory in bytes.
+type MemoryUsage struct {
+	Name  string `json:"name"`
+	Used  uint64 `json:"used"`
+	Total uint64 `json:"total"`
+}
+
+// GPUUsage is the VRAM of one GPU and its load in percent, which servers
+// leave out where the driver does not report it.
+type GPUUsage struct {
+	Name        string `json:"name"`
+	Used        uint64 `json:"used"`
+	Total       uint64 `json:"total"`
+	Utilization *int   `json:"utilization"`
+}
+
+type InstanceSlots struct {
+	Port  int `json:"port"`
+	Busy  int `json:"busy"`
+	Total int `json:"total"`
+}
+
+// ResourceUsage is GET /api/resources. Servers leave out what they cannot
+// measure, and older servers do not have the endpoint at all.
+type ResourceUsage struct {
+	Memory    *MemoryUsage    `json:"memory"`
+	GPUs      []GPUUsage      `json:"gpus"`
+	Instances []InstanceSlots `json:"instances"`
+}
+
+type ResourcesResponse struct {
+	Success bool          `json:"success"`
+	Data    ResourceUsage `json:"data"`
+}
+
+type resourcesMsg ResourceUsage
+
+// fetchResources never reports an error: a server without the endpoint just
+// has no resource lines.
+func fetchResources(c apiClient) tea.Cmd {
+	return c.cmd(func() tea.Msg {
+		var data ResourcesResponse
+		if err := c.request(http.MethodGet, "/api/resources", &data); err != nil || !data.Success {
+			return resourcesMsg{}
+		}
+		return resourcesMsg(data.Data)
+	})
+}
+
+// resourceLines renders memory, VRAM, GPU load and slot occupancy for the status
+// panel, one line per value the server reported.
+func (m Model) resourceLines(warn, alert lipgloss.Style) []string {
+	var lines []string
+	if mem := m.resources.Memory; mem != nil && mem.Total > 0 {
+		lines = append(lines, "RAM: "+usageText(mem.Used, mem.Total, warn, alert))
+	}
+	for _, gpu := range m.resources.GPUs {
+		if gpu.Total == 0 {
+			continue
+		}
+		line := fmt.Sprintf("VRAM %s: %s", gpu.Name, usageText(gpu.Used, gpu.Total, warn, alert))
+		if gpu.Utilization != nil {
+			line += fmt.Sprintf(", %d%% busy", *gpu.Utilization)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func usageText(used, total uint64, warn, alert lipgloss.Style) string {
+	fraction := float64(used) / float64(total)
+	text := fmt.Sprintf("%s / %s (%.0f%%)", formatSize(int64(used)), formatSize(int64(total)), fraction*100)
+	switch {
+	case fraction >= usageAlert:
+		return alert.Render(text)
+	case fraction >= usageWarn:
+		return warn.Render(text)
+	}
+	return text
+}
+
+// slotsText renders the busy slots of the instance on port, e.g. "1/4 busy",
+// or "" when the server did not report them.
+func (m Model) slotsText(port int, warn, alert lipgloss.Style) string {
+	for _, s := range m.resources.Instances {
+		if s.Port != port || s.Total == 0 {
+			continue
+		}
+		text := fmt.Sprintf("%d/%d busy", s.Busy, s.Total)
+		switch {
+		case s.Busy == s.Total:
+			return alert.Render(text)
+		case float64(s.Busy)/float64(s.Total) >= usageWarn:
+			return warn.Render(text)
+		}
+		return text
+	}
+	return ""
+}
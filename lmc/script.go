@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// subcommands run instead of the interface when named as the first argument.
+// Except for bench, they are meant for scripts: results go to stdout, errors
+// to stderr, and the exit code is 0 on success, 1 on failure and 2 for
+// invalid arguments.
+var subcommands = map[string]func(args []string) int{
+	"bench":  benchCommand,
+	"list":   listCommand,
+	"status": statusCommand,
+	"load":   loadCommand,
+	"unload": unloadCommand,
+}
+
+// rawResponse keeps the data of an API response as the server sent it, so
+// that --json passes on fields this version of lmc does not know.
+type rawResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// scriptContext parses the flags of a subcommand and returns a client for the
+// selected server, cancelled by Ctrl+C. A non-zero code means the command
+// must exit with it.
+func scriptContext(fs *flag.FlagSet, args []string) (apiClient, options, context.CancelFunc, int) {
+	opts, err := resolveOptions(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return apiClient{}, options{}, nil, 0
+		}
+		// Parse errors are already printed by the flag set.
+		var parseErr flagError
+		if !errors.As(err, &parseErr) {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Name(), err)
+		}
+		return apiClient{}, options{}, nil, 2
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	p := opts.profiles[opts.active]
+	return apiClient{baseURL: p.URL, token: p.Token, ctx: ctx}, opts, stop, 0
+}
+
+func newScriptFlags(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: "+usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// fetchRaw gets an API list and fails on an unsuccessful response.
+func fetchRaw(c apiClient, path string) (json.RawMessage, error) {
+	var data rawResponse
+	if err := c.request(http.MethodGet, path, &data); err != nil {
+		return nil, err
+	}
+	if !data.Success {
+		return nil, errors.New(data.Message)
+	}
+	return data.Data, nil
+}
+
+// printJSON writes data indented, followed by a newline.
+func printJSON(data json.RawMessage) {
+	var out bytes.Buffer
+	if json.Indent(&out, data, "", "  ") != nil {
+		out.Reset()
+		out.Write(data)
+	}
+	fmt.Println(out.String())
+}
+
+func scriptFailed(name string, err error) int {
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "%s: cancelled\n", name)
+		return 130
+	}
+	fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+	return 1
+}
+
+// listCommand is "lmc list [--json]": the models the server can load.
+func listCommand(args []string) int {
+	fs := newScriptFlags("lmc list", "lmc list [flags]")
+	asJSON := fs.Bool("json", false, "print the models as JSON, as GET /api/models returns them")
+	c, _, stop, code := scriptContext(fs, args)
+	if stop == nil {
+		return code
+	}
+	defer stop()
+
+	data, err := fetchRaw(c, "/api/models")
+	if err != nil {
+		return scriptFailed(fs.Name(), err)
+	}
+	if *asJSON {
+		printJSON(data)
+		return 0
+	}
+
+	var models []ModelInfo
+	if err := json.Unmarshal(data, &models); err != nil {
+		return scriptFailed(fs.Name(), err)
+	}
+	running := map[string]int{}
+	if instances, err := fetchScriptInstances(c); err == nil {
+		for _, inst := range instances {
+			running[inst.Name]++
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZE\tQUANT\tPARAMS\tRUNNING")
+	for _, model := range models {
+		size := "-"
+		if model.Size > 0 {
+			size = formatSize(model.Size)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", model.Name, size, orDash(model.Quantization), orDash(model.Parameters), running[model.Name])
+	}
+	w.Flush()
+	return 0
+}
+
+// statusCommand is "lmc status [--json]": the running instances.
+func statusCommand(args []string) int {
+	fs := newScriptFlags("lmc status", "lmc status [flags]")
+	asJSON := fs.Bool("json", false, "print the instances as JSON, as GET /api/instances returns them")
+	c, _, stop, code := scriptContext(fs, args)
+	if stop == nil {
+		return code
+	}
+	defer stop()
+
+	data, err := fetchRaw(c, "/api/instances")
+	if err != nil {
+		return scriptFailed(fs.Name(), err)
+	}
+	if *asJSON {
+		printJSON(data)
+		return 0
+	}
+
+	var instances []InstanceInfo
+	if err := json.Unmarshal(data, &instances); err != nil {
+		return scriptFailed(fs.Name(), err)
+	}
+	if len(instances) == 0 {
+		fmt.Println("No model is running")
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPORT\tSTATE\tUPTIME")
+	for _, inst := range instances {
+		state := "ready"
+		switch {
+		case inst.loading():
+			state = fmt.Sprintf("loading %d%%", max(0, inst.Progress))
+		case !inst.Healthy:
+			state = "not responding"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", inst.Name, inst.Port, state, formatUptime(inst.Uptime))
+	}
+	w.Flush()
+	return 0
+}
+
+// loadCommand is "lmc load [--new] <model>", which returns once the model is
+// ready or has failed to load.
+func loadCommand(args []string) int {
+	fs := newScriptFlags("lmc load", "lmc load [flags] <model>")
+	another := fs.Bool("new", false, "start another instance if the model is already running")
+	c, opts, stop, code := scriptContext(fs, args)
+	if stop == nil {
+		return code
+	}
+	defer stop()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := fetchRaw(c, "/api/models")
+	if err != nil {
+		return scriptFailed(fs.Name(), err)
+	}
+	var models []ModelInfo
+	if err := json.Unmarshal(data, &models); err != nil {
+		return scriptFailed(fs.Name(), err)
+	}
+	names := make([]string, len(models))
+	for i, model := range models {
+		names[i] = model.Name
+	}
+	idx, err := matchName(names, fs.Arg(0), "model")
+	if err != nil {
+		return scriptFailed(fs.Name(), err)
+	}
+
+	if opts.loadTimeout > 0 {
+		var cancel context.CancelFunc
+		c.ctx, cancel = context.WithTimeout(c.ctx, opts.loadTimeout)
+		defer cancel()
+	}
+	fmt.Fprintf(os.Stderr, "Loading %s…\n", models[idx].Name)
+	switch msg := requestLoad(c, models[idx].Index, *another, nil).(type) {
+	case successMsg:
+		fmt.Println(msg.message)
+		return 0
+	case errorMsg:
+		if errors.Is(c.ctx.Err(), context.Canceled) {
+			return scriptFailed(fs.Name(), context.Canceled)
+		}
+		if errors.Is(c.ctx.Err(), context.DeadlineExceeded) {
+			return scriptFailed(fs.Name(), fmt.Errorf("%s did not load within %v", models[idx].Name, opts.loadTimeout))
+		}
+		return scriptFailed(fs.Name(), errors.New(string(msg)))
+	}
+	return 1
+}
+
+// unloadCommand is "lmc unload <port|model|all>". A model name unloads
+// every instance of that model.
+func unloadCommand(args []string) int {
+	fs := newScriptFlags("lmc unload", "lmc unload [flags] <port|model|all>")
+	c, _, stop, code := scriptContext(fs, args)
+	if stop == nil {
+		return code
+	}
+	defer stop()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	target := fs.Arg(0)
+
+	var ports []int
+	if strings.EqualFold(target, "all") {
+		ports = []int{0}
+	} else if port, err := strconv.Atoi(target); err == nil && port > 0 {
+		ports = []int{port}
+	} else {
+		instances, err := fetchScriptInstances(c)
+		if err != nil {
+			return scriptFailed(fs.Name(), err)
+		}
+		names := make([]string, len(instances))
+		for i, inst := range instances {
+			names[i] = inst.Name
+		}
+		idx, err := matchName(names, target, "running model")
+		if err != nil {
+			return scriptFailed(fs.Name(), err)
+		}
+		for _, inst := range instances {
+			if inst.Name == instances[idx].Name {
+				ports = append(ports, inst.Port)
+			}
+		}
+	}
+
+	failed := false
+	for _, port := range ports {
+		switch msg := requestUnload(c, port).(type) {
+		case successMsg:
+			fmt.Println(msg.message)
+		case errorMsg:
+			fmt.Fprintf(os.Stderr, "%s: %s\n", fs.Name(), string(msg))
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func fetchScriptInstances(c apiClient) ([]InstanceInfo, error) {
+	var data InstancesResponse
+	if err := c.request(http.MethodGet, "/api/instances", &data); err != nil {
+		return nil, err
+	}
+	return data.Data, nil
+}
+
+// matchName finds name in names: an exact match ignoring case, or else the
+// only name that contains it, like the ":" palette.
+func matchName(names []string, name, what string) (int, error) {
+	match := -1
+	matched := map[string]bool{}
+	for i, candidate := range names {
+		if strings.EqualFold(candidate, name) {
+			return i, nil
+		}
+		if strings.Contains(strings.ToLower(candidate), strings.ToLower(name)) {
+			matched[candidate] = true
+			match = i
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return 0, fmt.Errorf("no %s matches %q", what, name)
+	case 1:
+		return match, nil
+	}
+	return 0, fmt.Errorf("%d %ss match %q, be more specific", len(matched), what, name)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
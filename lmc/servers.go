@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// serversModel is the view opened with "w": the running instances of every
+// server profile, so it shows at a glance which models run where.
+type serversModel struct {
+	servers []serverState
+	cursor  int
+}
+
+type serverState struct {
+	loaded    bool
+	instances []InstanceInfo
+	err       string
+}
+
+type serverInstancesMsg struct {
+	index     int
+	instances []InstanceInfo
+	err       error
+}
+
+func openServers(m Model) (Model, tea.Cmd) {
+	m.viewingServers = true
+	m.servers = serversModel{servers: make([]serverState, len(m.profiles)), cursor: m.profileIdx}
+	return m, fetchAllServers(m.profiles)
+}
+
+func fetchAllServers(profiles []Profile) tea.Cmd {
+	var cmds []tea.Cmd
+	for i, p := range profiles {
+		cmds = append(cmds, fetchServerInstances(i, p))
+	}
+	return tea.Batch(cmds...)
+}
+
+// fetchServerInstances asks one profile's server for its instances. Like
+// probeProfile it is not bound to the active client, so a slow server never
+// holds up the others or a switch.
+func fetchServerInstances(idx int, p Profile) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		c := apiClient{baseURL: p.URL, token: p.Token, ctx: ctx}
+		var data InstancesResponse
+		err := c.request(http.MethodGet, "/api/instances", &data)
+		switch {
+		case errors.Is(err, errUnauthorized), errors.Is(err, errUntrustedCertificate):
+		case err != nil:
+			err = errors.New("offline")
+		case !data.Success:
+			err = errors.New("error")
+		}
+		return serverInstancesMsg{index: idx, instances: data.Data, err: err}
+	}
+}
+
+func (m Model) handleServerInstancesMsg(msg serverInstancesMsg) (Model, tea.Cmd) {
+	if msg.index >= len(m.servers.servers) {
+		return m, nil
+	}
+	state := serverState{loaded: true, instances: msg.instances}
+	if msg.err != nil {
+		state.err = msg.err.Error()
+	}
+	m.servers.servers[msg.index] = state
+	return m, nil
+}
+
+func handleServersKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q", "w":
+		m.viewingServers = false
+	case "up", "k":
+		m.servers.cursor = (m.servers.cursor - 1 + len(m.profiles)) % len(m.profiles)
+	case "down", "j":
+		m.servers.cursor = (m.servers.cursor + 1) % len(m.profiles)
+	case "r":
+		return m, fetchAllServers(m.profiles)
+	case "enter":
+		m.viewingServers = false
+		if m.servers.cursor != m.profileIdx {
+			return switchProfile(m, m.servers.cursor)
+		}
+	}
+	return m, nil
+}
+
+func (m Model) serversView() string {
+	st := m.styles
+	width := max(40, m.windowWidth-8)
+	inner := width - 2
+
+	var rows []string
+	running := 0
+	for i, p := range m.profiles {
+		var state serverState
+		if i < len(m.servers.servers) {
+			state = m.servers.servers[i]
+		}
+
+		status := st.dim.Render("checking…")
+		switch {
+		case state.err != "":
+			status = st.bad.Render(state.err)
+		case state.loaded && len(state.instances) == 0:
+			status = st.help.Render("idle")
+		case state.loaded:
+			status = st.good.Render(fmt.Sprintf("%d running", len(state.instances)))
+		}
+		name := p.Name
+		if i == m.profileIdx {
+			name += " (current)"
+		}
+		header := fmt.Sprintf("%s  %s  %s", name, st.help.Render(p.URL), status)
+		if i == m.servers.cursor {
+			header = st.selected.Render("➤ " + header)
+		} else {
+			header = st.item.Render("  " + header)
+		}
+		rows = append(rows, header)
+
+		for _, inst := range state.instances {
+			running++
+			mark := st.good.Render("●")
+			switch {
+			case inst.loading():
+				mark = st.warn.Render("…")
+			case !inst.Healthy:
+				mark = st.bad.Render("✗")
+			}
+			row := fmt.Sprintf("    %s :%-5d ", mark, inst.Port)
+			rows = append(rows, row+truncateString(inst.Name, max(0, inner-lipgloss.Width(row))))
+		}
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		st.title.Render(fmt.Sprintf("Servers (%d instances)", running)),
+		st.box.Width(width).Render(clipLines(strings.Join(rows, "\n"), max(1, m.windowHeight-7))),
+		st.help.Render("Running instances of every profile | ↑↓: Select | Enter: Switch | R: Refresh | Esc: Back"),
+	)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxSlotPrompt is how many characters of a prompt the slots view keeps, so
+// that huge prompts neither pile up in memory nor break the layout.
+const maxSlotPrompt = 200
+
+// slotsDisabled is the error of an instance started without the /slots
+// endpoint, as reported by the server.
+const slotsDisabled = "the /slots endpoint is disabled"
+
+// SlotInfo is one slot of an instance. Decoded is the number of tokens
+// generated so far for the current or last request.
+type SlotInfo struct {
+	ID         int    `json:"id"`
+	Processing bool   `json:"processing"`
+	Decoded    int    `json:"decoded"`
+	Prompt     string `json:"prompt"`
+}
+
+// InstanceSlotList is one entry of GET /api/slots. Available is false when
+// the instance does not expose /slots; Error says why.
+type InstanceSlotList struct {
+	Name      string     `json:"name"`
+	Port      int        `json:"port"`
+	Available bool       `json:"available"`
+	Error     string     `json:"error"`
+	Slots     []SlotInfo `json:"slots"`
+}
+
+type SlotsResponse struct {
+	Success bool               `json:"success"`
+	Message string             `json:"message"`
+	Data    []InstanceSlotList `json:"data"`
+}
+
+// slotsModel is the slots view opened with "t".
+type slotsModel struct {
+	list   []InstanceSlotList
+	loaded bool
+	err    string
+}
+
+type slotsMsg struct {
+	list []InstanceSlotList
+	err  error
+}
+
+func openSlots(m Model) (Model, tea.Cmd) {
+	if len(m.instances) == 0 {
+		m.state = StateError
+		m.message = "✗ No model is running"
+		m.messageTime = time.Now()
+		return m, nil
+	}
+	m.viewingSlots = true
+	return m, fetchSlots(m.client, m.instances)
+}
+
+// fetchSlots asks the server for the slots of every instance. Servers
+// without /api/slots are bypassed by asking the instances directly, which
+// works when their ports are reachable from here.
+func fetchSlots(c apiClient, instances []InstanceInfo) tea.Cmd {
+	return c.cmd(func() tea.Msg {
+		var data SlotsResponse
+		err := c.request(http.MethodGet, "/api/slots", &data)
+		switch {
+		case errors.Is(err, errUnauthorized):
+			return slotsMsg{err: err}
+		case err != nil:
+			return slotsMsg{list: querySlotsDirectly(c, instances)}
+		case !data.Success:
+			return slotsMsg{err: fmt.Errorf("%s", data.Message)}
+		}
+		for i := range data.Data {
+			for j := range data.Data[i].Slots {
+				data.Data[i].Slots[j].Prompt = shortPrompt(data.Data[i].Slots[j].Prompt)
+			}
+		}
+		return slotsMsg{list: data.Data}
+	})
+}
+
+func querySlotsDirectly(c apiClient, instances []InstanceInfo) []InstanceSlotList {
+	var list []InstanceSlotList
+	for _, inst := range instances {
+		if !inst.Healthy {
+			continue
+		}
+		entry := InstanceSlotList{Name: inst.Name, Port: inst.Port}
+		if slots, err := querySlots(c, inst.Port); err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.Available = true
+			entry.Slots = slots
+		}
+		list = append(list, entry)
+	}
+	return list
+}
+
+// querySlots reads llama-server's /slots endpoint on port.
+func querySlots(c apiClient, port int) ([]SlotInfo, error) {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, instanceURL(c.baseURL, port)+"/slots", nil)
+	if err != nil {
+		return nil, err
+	}
+	authorize(req, c.token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotImplemented || resp.StatusCode == http.StatusNotFound:
+		return nil, errors.New(slotsDisabled)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("/slots returned %s", resp.Status)
+	}
+
+	var raw []struct {
+		ID           int             `json:"id"`
+		IsProcessing bool            `json:"is_processing"`
+		Prompt       json.RawMessage `json:"prompt"`
+		NextToken    json.RawMessage `json:"next_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid /slots response: %v", err)
+	}
+
+	slots := make([]SlotInfo, len(raw))
+	for i, r := range raw {
+		slots[i] = SlotInfo{ID: r.ID, Processing: r.IsProcessing}
+		var prompt string
+		if json.Unmarshal(r.Prompt, &prompt) == nil {
+			slots[i].Prompt = shortPrompt(prompt)
+		}
+		// next_token is an object in older llama-server versions and an
+		// array of them in newer ones.
+		var next struct {
+			NDecoded int `json:"n_decoded"`
+		}
+		var nexts []struct {
+			NDecoded int `json:"n_decoded"`
+		}
+		if json.Unmarshal(r.NextToken, &next) == nil {
+			slots[i].Decoded = next.NDecoded
+		} else if json.Unmarshal(r.NextToken, &nexts) == nil && len(nexts) > 0 {
+			slots[i].Decoded = nexts[0].NDecoded
+		}
+	}
+	return slots, nil
+}
+
+// shortPrompt keeps the start of a prompt on one line, at most
+// maxSlotPrompt characters long. The result is a copy, so the full prompt
+// can be freed.
+func shortPrompt(prompt string) string {
+	prompt = strings.Join(strings.Fields(prompt), " ")
+	if runes := []rune(prompt); len(runes) > maxSlotPrompt {
+		prompt = string(runes[:maxSlotPrompt])
+	}
+	return strings.Clone(prompt)
+}
+
+func (m Model) handleSlotsMsg(msg slotsMsg) (Model, tea.Cmd) {
+	if msg.err != nil {
+		m.slots.err = msg.err.Error()
+		return m, nil
+	}
+	m.slots = slotsModel{list: msg.list, loaded: true}
+	return m, nil
+}
+
+func handleSlotsKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q", "t":
+		m.viewingSlots = false
+	case "r":
+		return m, fetchSlots(m.client, m.instances)
+	}
+	return m, nil
+}
+
+func (m Model) slotsView() string {
+	st := m.styles
+	width := max(40, m.windowWidth-8)
+	inner := width - 2
+
+	var rows []string
+	switch {
+	case !m.slots.loaded && m.slots.err == "":
+		rows = append(rows, "Loading…")
+	case m.slots.loaded && len(m.slots.list) == 0:
+		rows = append(rows, st.help.Render("No instance is ready yet."))
+	}
+	for i, inst := range m.slots.list {
+		if i > 0 {
+			rows = append(rows, "")
+		}
+		rows = append(rows, st.group.Render(truncateString(fmt.Sprintf("%s :%d", inst.Name, inst.Port), inner)))
+		if !inst.Available {
+			rows = append(rows, st.bad.Render(truncateString("  "+inst.Error, inner)))
+			if inst.Error == slotsDisabled {
+				rows = append(rows, st.help.Render(truncateString("  Enable it by dropping --no-slots, or with --slots on older builds", inner)))
+			}
+			continue
+		}
+		for _, slot := range inst.Slots {
+			state := st.help.Render("idle      ")
+			if slot.Processing {
+				state = st.warn.Render("processing")
+			}
+			row := fmt.Sprintf("  #%-2d %s %6d tok  ", slot.ID, state, slot.Decoded)
+			rows = append(rows, row+truncateString(slot.Prompt, max(0, inner-lipgloss.Width(row))))
+		}
+	}
+
+	footer := ""
+	if m.slots.err != "" {
+		footer = st.error.Render("✗ " + m.slots.err)
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		st.title.Render("Slots"),
+		st.box.Width(width).Render(clipLines(strings.Join(rows, "\n"), max(1, m.windowHeight-8))),
+		footer,
+		st.help.Render("R: Refresh | Esc: Back"),
+	)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestResponse is the answer of lmgo's POST /api/test. The message sums up
+// the first-token latency, tokens per second and GPU offload.
+type TestResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+type testDoneMsg struct {
+	message string
+	err     error
+}
+
+// startTest asks lmgo to send a short request to the instance chat would
+// open against, confirming the model answers.
+func startTest(m Model) (Model, tea.Cmd) {
+	target, ok := m.chatTarget()
+	if !ok || !target.Healthy {
+		m.state = StateError
+		m.message = "✗ No model is ready: load one before testing it"
+		m.messageTime = time.Now()
+		return m, nil
+	}
+
+	m.state = StateSuccess
+	m.message = fmt.Sprintf("Testing %s on port %d…", target.Name, target.Port)
+	// Kept until the result replaces it.
+	m.messageTime = time.Now().Add(2 * time.Minute)
+	c := m.client
+	return m, c.cmd(func() tea.Msg {
+		var data TestResponse
+		if err := c.request(http.MethodPost, fmt.Sprintf("/api/test?port=%d", target.Port), &data); err != nil {
+			return testDoneMsg{err: fmt.Errorf("Test of %s failed: %v", target.Name, err)}
+		}
+		if !data.Success {
+			return testDoneMsg{err: fmt.Errorf("%s", data.Message)}
+		}
+		return testDoneMsg{message: data.Message}
+	})
+}
+
+func (m Model) handleTestDone(msg testDoneMsg) (Model, tea.Cmd) {
+	if msg.err != nil {
+		m.state = StateError
+		m.message = fmt.Sprintf("✗ %v", msg.err)
+	} else {
+		m.state = StateSuccess
+		m.message = "✓ " + msg.message
+	}
+	m.keepResultOnScreen()
+	return m, nil
+}
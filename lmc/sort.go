@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Sort orders of the model list, cycled with Shift+S. The empty order keeps
+// the order the server returns.
+var sortModes = []string{"", "name", "size", "recent", "used"}
+
+// Groupings of the model list, cycled with Shift+G.
+var groupModes = []string{"", "folder", "family"}
+
+func nextMode(modes []string, current string) string {
+	for i, mode := range modes {
+		if mode == current {
+			return modes[(i+1)%len(modes)]
+		}
+	}
+	return modes[0]
+}
+
+func sortLabel(mode string) string {
+	switch mode {
+	case "name":
+		return "name"
+	case "size":
+		return "size"
+	case "recent":
+		return "recently added"
+	case "used":
+		return "last used"
+	}
+	return "server order"
+}
+
+// groupOf returns the section a model belongs to under the current grouping.
+func (m Model) groupOf(model ModelInfo) string {
+	switch m.groupBy {
+	case "folder":
+		dir := model.Path
+		if i := strings.LastIndexAny(dir, `/\`); i >= 0 {
+			dir = dir[:i]
+		}
+		if i := strings.LastIndexAny(dir, `/\`); i >= 0 {
+			dir = dir[i+1:]
+		}
+		if dir == "" {
+			return "(no folder)"
+		}
+		return dir
+	case "family":
+		if model.Architecture == "" {
+			return "(unknown)"
+		}
+		return model.Architecture
+	}
+	return ""
+}
+
+// sortVisible orders the visible rows by group, then by the sort order.
+// Ties keep the server order.
+func (m *Model) sortVisible() {
+	less := func(a, b ModelInfo) bool {
+		switch m.sortBy {
+		case "name":
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		case "size":
+			return a.Size > b.Size
+		case "recent":
+			return a.Modified > b.Modified
+		case "used":
+			return a.LastUsed > b.LastUsed
+		}
+		return false
+	}
+
+	sort.SliceStable(m.visible, func(i, j int) bool {
+		a, b := m.models[m.visible[i]], m.models[m.visible[j]]
+		if ga, gb := m.groupOf(a), m.groupOf(b); ga != gb {
+			return strings.ToLower(ga) < strings.ToLower(gb)
+		}
+		return less(a, b)
+	})
+}
+
+// groupHeader returns the section header drawn above a visible row: at the
+// start of each group, and at the top of the window so the current group is
+// always named. Headers are not rows, so the cursor never lands on them.
+func (m Model) groupHeader(row, top int) (string, bool) {
+	if m.groupBy == "" {
+		return "", false
+	}
+	group := m.groupOf(m.models[m.visible[row]])
+	if row == top || group != m.groupOf(m.models[m.visible[row-1]]) {
+		return group, true
+	}
+	return "", false
+}
+
+// listLines is the number of screen lines rows from..to take, headers
+// included, when from is the top row.
+func (m Model) listLines(from, to int) int {
+	lines := 0
+	for row := from; row <= to && row < len(m.visible); row++ {
+		lines++
+		if _, ok := m.groupHeader(row, from); ok {
+			lines++
+		}
+	}
+	return lines
+}
+
+// saveListPrefs stores the sort order, grouping and filter in the config file
+// so they survive restarts. A failure only costs the preference, so it is reported
+// but not fatal.
+func (m Model) saveListPrefs() tea.Cmd {
+	if m.configPath == "" {
+		return nil
+	}
+	path, sortBy, groupBy, filter := m.configPath, m.sortBy, m.groupBy, m.filter.Value()
+	return func() tea.Msg {
+		err := updateConfig(path, func(cfg *Config) {
+			cfg.Sort = sortBy
+			cfg.Group = groupBy
+			cfg.Filter = filter
+		})
+		if err != nil {
+			return errorMsg(fmt.Sprintf("Failed to save list settings: %v", err))
+		}
+		return nil
+	}
+}
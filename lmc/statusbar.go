@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// statusBar is the line above the help: polling state, server, latency of
+// the last instances fetch and the age of the data. The age is computed at
+// render time, so it keeps counting between polls. It is cut to width rather
+// than wrapped.
+func (m Model) statusBar(width int) string {
+	st := m.styles
+
+	var state string
+	switch {
+	case m.offline:
+		state = st.bad.Render("● offline")
+	case m.paused:
+		state = st.warn.Render("⏸ paused")
+	default:
+		state = st.good.Render("● live")
+	}
+
+	profile := m.profiles[m.profileIdx]
+	parts := []string{state, profile.Name + " " + profile.URL}
+	if !m.lastUpdate.IsZero() {
+		parts = append(parts,
+			formatLatency(m.latency),
+			"updated "+formatAge(time.Since(m.lastUpdate))+" ago")
+	} else {
+		parts = append(parts, "no data yet")
+	}
+
+	return truncateString(st.help.Render(strings.Join(parts, " · ")), width)
+}
+
+func formatLatency(d time.Duration) string {
+	if d < time.Millisecond {
+		return "<1ms"
+	}
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+// formatAge renders a short age such as "3s", "2m" or "1h".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh", int(d.Hours()))
+}
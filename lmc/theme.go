@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme holds every color lmc draws with. Values are lipgloss colors: ANSI
+// numbers such as "63" or hex values such as "#7C3AED". An empty value keeps
+// the terminal's default color. The JSON names are the keys accepted by
+// "colors" in the config file.
+type Theme struct {
+	Title      string `json:"title"`
+	TitleBg    string `json:"titleBackground"`
+	Border     string `json:"border"`
+	Muted      string `json:"muted"`
+	Good       string `json:"good"`
+	Warn       string `json:"warn"`
+	Bad        string `json:"bad"`
+	Selected   string `json:"selected"`
+	SelectedBg string `json:"selectedBackground"`
+	Loaded     string `json:"loaded"`
+	LoadedBg   string `json:"loadedBackground"`
+	Match      string `json:"match"`
+	Accent     string `json:"accent"`
+	Assistant  string `json:"assistant"`
+}
+
+var themes = map[string]Theme{
+	"dark": {
+		Title:      "#FFFFFF",
+		TitleBg:    "#7C3AED",
+		Border:     "240",
+		Muted:      "240",
+		Good:       "46",
+		Warn:       "220",
+		Bad:        "196",
+		Selected:   "255",
+		SelectedBg: "63",
+		Loaded:     "255",
+		LoadedBg:   "#F48FB1",
+		Match:      "214",
+		Accent:     "63",
+		Assistant:  "#F48FB1",
+	},
+	"light": {
+		Title:      "#FFFFFF",
+		TitleBg:    "#5B21B6",
+		Border:     "245",
+		Muted:      "238",
+		Good:       "28",
+		Warn:       "130",
+		Bad:        "160",
+		Selected:   "255",
+		SelectedBg: "25",
+		Loaded:     "255",
+		LoadedBg:   "#AD1457",
+		Match:      "166",
+		Accent:     "25",
+		Assistant:  "#AD1457",
+	},
+	"none": {},
+}
+
+// resolveTheme picks the theme by name and applies per-color overrides.
+// NO_COLOR or --no-color force the colorless theme; "auto" and an empty name
+// choose dark or light from the terminal background.
+func resolveTheme(name string, overrides map[string]string, noColor bool) (string, Theme, error) {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return "none", themes["none"], nil
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "auto" {
+		name = "dark"
+		if !lipgloss.HasDarkBackground() {
+			name = "light"
+		}
+	}
+	theme, ok := themes[name]
+	if !ok {
+		return "", Theme{}, fmt.Errorf("unknown theme %q (available: auto, dark, light, none)", name)
+	}
+	if len(overrides) == 0 {
+		return name, theme, nil
+	}
+
+	colors := map[string]string{}
+	data, _ := json.Marshal(theme)
+	json.Unmarshal(data, &colors)
+	for key, value := range overrides {
+		if _, ok := colors[key]; !ok {
+			var keys []string
+			for k := range colors {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return "", Theme{}, fmt.Errorf("unknown color %q in colors (available: %s)", key, strings.Join(keys, ", "))
+		}
+		colors[key] = value
+	}
+	data, _ = json.Marshal(colors)
+	json.Unmarshal(data, &theme)
+	return name, theme, nil
+}
+
+// styles are the lipgloss styles built from a Theme. Everything that must
+// stay recognizable without color, like the cursor row, also uses a marker or
+// an attribute.
+type styles struct {
+	title      lipgloss.Style
+	section    lipgloss.Style
+	box        lipgloss.Style
+	help       lipgloss.Style
+	dim        lipgloss.Style
+	good       lipgloss.Style
+	warn       lipgloss.Style
+	bad        lipgloss.Style
+	selected   lipgloss.Style
+	loaded     lipgloss.Style
+	item       lipgloss.Style
+	success    lipgloss.Style
+	error      lipgloss.Style
+	match      lipgloss.Style
+	group      lipgloss.Style
+	user       lipgloss.Style
+	assistant  lipgloss.Style
+	whitespace lipgloss.TerminalColor
+}
+
+func newStyles(t Theme) styles {
+	color := func(c string) lipgloss.TerminalColor {
+		if c == "" {
+			return lipgloss.NoColor{}
+		}
+		return lipgloss.Color(c)
+	}
+
+	s := styles{
+		title: lipgloss.NewStyle().
+			Bold(true).
+			Foreground(color(t.Title)).
+			Background(color(t.TitleBg)).
+			Padding(0, 2),
+		box: lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(color(t.Border)).
+			Padding(0, 1),
+		help: lipgloss.NewStyle().
+			Foreground(color(t.Muted)).
+			Italic(true),
+		dim:  lipgloss.NewStyle().Foreground(color(t.Muted)).Faint(true),
+		good: lipgloss.NewStyle().Foreground(color(t.Good)).Bold(true),
+		warn: lipgloss.NewStyle().Foreground(color(t.Warn)),
+		bad:  lipgloss.NewStyle().Foreground(color(t.Bad)).Bold(true),
+		selected: lipgloss.NewStyle().
+			Background(color(t.SelectedBg)).
+			Foreground(color(t.Selected)).
+			Bold(true).
+			Padding(0, 1),
+		loaded: lipgloss.NewStyle().
+			Background(color(t.LoadedBg)).
+			Foreground(color(t.Loaded)).
+			Bold(true).
+			Padding(0, 1),
+		item: lipgloss.NewStyle().
+			Padding(0, 1).
+			Margin(0, 0, 0, 0),
+		success:    lipgloss.NewStyle().Foreground(color(t.Good)).Bold(true),
+		error:      lipgloss.NewStyle().Foreground(color(t.Bad)).Bold(true),
+		match:      lipgloss.NewStyle().Foreground(color(t.Match)).Bold(true),
+		group:      lipgloss.NewStyle().Foreground(color(t.Accent)).Bold(true),
+		user:       lipgloss.NewStyle().Foreground(color(t.Accent)).Bold(true),
+		assistant:  lipgloss.NewStyle().Foreground(color(t.Assistant)).Bold(true),
+		whitespace: color(t.Border),
+	}
+	s.section = s.box.Margin(0, 1, 1, 0)
+
+	// Loaded rows are told apart by their background; without one, underline
+	// them. Matches get the same treatment so the filter stays readable.
+	if t.LoadedBg == "" {
+		s.loaded = s.loaded.Underline(true)
+	}
+	if t.Match == "" {
+		s.match = s.match.Underline(true)
+	}
+	return s
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ModelUsage is one entry of GET /api/stats: the requests a model answered
+// through lmgo's OpenAI-compatible endpoint.
+type ModelUsage struct {
+	Name             string    `json:"name"`
+	Requests         int64     `json:"requests"`
+	Errors           int64     `json:"errors"`
+	PromptTokens     int64     `json:"promptTokens"`
+	CompletionTokens int64     `json:"completionTokens"`
+	AvgLatencyMs     int64     `json:"avgLatencyMs"`
+	LastUsed         time.Time `json:"lastUsed"`
+}
+
+type UsageResponse struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Data    []ModelUsage `json:"data"`
+}
+
+// usageModel is the usage view opened with "a".
+type usageModel struct {
+	list   []ModelUsage
+	loaded bool
+	err    string
+}
+
+type usageMsg struct {
+	list []ModelUsage
+	err  error
+}
+
+func openUsage(m Model) (Model, tea.Cmd) {
+	m.viewingUsage = true
+	return m, fetchUsage(m.client)
+}
+
+func fetchUsage(c apiClient) tea.Cmd {
+	return c.cmd(func() tea.Msg {
+		var data UsageResponse
+		err := c.request(http.MethodGet, "/api/stats", &data)
+		switch {
+		case errors.Is(err, errUnauthorized):
+			return usageMsg{err: err}
+		case err != nil:
+			return usageMsg{err: fmt.Errorf("the server does not report usage: %v", err)}
+		case !data.Success:
+			return usageMsg{err: fmt.Errorf("%s", data.Message)}
+		}
+		// Models that were loaded but never asked anything have no usage
+		// to show.
+		list := []ModelUsage{}
+		for _, usage := range data.Data {
+			if usage.Requests > 0 {
+				list = append(list, usage)
+			}
+		}
+		return usageMsg{list: list}
+	})
+}
+
+func (m Model) handleUsageMsg(msg usageMsg) (Model, tea.Cmd) {
+	if msg.err != nil {
+		m.usage.err = msg.err.Error()
+		return m, nil
+	}
+	m.usage = usageModel{list: msg.list, loaded: true}
+	return m, nil
+}
+
+func handleUsageKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q", "a":
+		m.viewingUsage = false
+	case "r":
+		return m, fetchUsage(m.client)
+	}
+	return m, nil
+}
+
+func (m Model) usageView() string {
+	st := m.styles
+	width := max(40, m.windowWidth-8)
+	inner := width - 2
+
+	var rows []string
+	switch {
+	case !m.usage.loaded && m.usage.err == "":
+		rows = append(rows, "Loading…")
+	case m.usage.loaded && len(m.usage.list) == 0:
+		rows = append(rows, st.help.Render("No requests through lmgo's /v1 endpoint yet."))
+	default:
+		rows = append(rows, st.group.Render(fmt.Sprintf("%-8s %6s %10s %10s %9s  %s", "Requests", "Errors", "Prompt", "Generated", "Latency", "Model")))
+	}
+	for _, usage := range m.usage.list {
+		errs := fmt.Sprintf("%6d", usage.Errors)
+		if usage.Errors > 0 {
+			errs = st.bad.Render(errs)
+		}
+		row := fmt.Sprintf("%8d %s %10d %10d %9s  ", usage.Requests, errs,
+			usage.PromptTokens, usage.CompletionTokens, formatLatency(time.Duration(usage.AvgLatencyMs)*time.Millisecond))
+		rows = append(rows, row+truncateString(usage.Name, max(0, inner-lipgloss.Width(row))))
+	}
+
+	footer := ""
+	if m.usage.err != "" {
+		footer = st.error.Render("✗ " + m.usage.err)
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		st.title.Render("Usage"),
+		st.box.Width(width).Render(clipLines(strings.Join(rows, "\n"), max(1, m.windowHeight-8))),
+		footer,
+		st.help.Render("Tokens and average latency per model | R: Refresh | Esc: Back"),
+	)
+}
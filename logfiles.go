@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logsDir holds the output of every llama-server, one file per model and
+// port.
+const logsDir = "logs"
+
+const (
+	defaultLogMaxSizeMB = 10
+	defaultLogMaxFiles  = 3
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// logFile appends server output to a file, moving it to .1, .2 and so on
+// once it grows past logMaxSizeMB and keeping logMaxFiles of those. It is
+// shared by stdout and stderr.
+type logFile struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	maxSize  int64
+	maxFiles int
+}
+
+// instanceLogPath returns the log file of an instance, e.g.
+// logs/Qwen2.5-14B-8081.log.
+func instanceLogPath(instance *modelInstance) string {
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(displayName(instance), "_"), "_")
+	path := filepath.Join(logsDir, fmt.Sprintf("%s-%d.log", name, instance.port))
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
+}
+
+// openLogFile opens the log of an instance for appending and marks the start
+// of the launch in it.
+func openLogFile(instance *modelInstance, args []string) (*logFile, error) {
+	maxSize := config().LogMaxSizeMB
+	if maxSize <= 0 {
+		maxSize = defaultLogMaxSizeMB
+	}
+	maxFiles := config().LogMaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultLogMaxFiles
+	}
+
+	f := &logFile{path: instanceLogPath(instance), maxSize: int64(maxSize) << 20, maxFiles: maxFiles}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(f, "=== %s: %s %s\n", time.Now().Format(time.DateTime), serverPath, strings.Join(args, " "))
+	return f, nil
+}
+
+func (f *logFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *logFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return len(p), nil
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		f.rotate()
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	// Output must keep flowing to the other writers even when the disk is
+	// full, so write errors are not passed on.
+	if err != nil {
+		return len(p), nil
+	}
+	return n, nil
+}
+
+// rotate shifts the older files up by one, dropping the oldest, and starts
+// a new file. Callers must hold f.mu.
+func (f *logFile) rotate() {
+	f.file.Close()
+	f.file = nil
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxFiles))
+	for i := f.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	os.Rename(f.path, f.path+".1")
+	if err := f.open(); err != nil {
+		f.file = nil
+	}
+}
+
+func (f *logFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// openLogsFolder shows the logs directory in the file manager.
+func openLogsFolder() {
+	dir, err := filepath.Abs(logsDir)
+	if err == nil {
+		err = os.MkdirAll(dir, 0755)
+	}
+	if err == nil {
+		err = openBrowser(dir)
+	}
+	if err != nil {
+		log.Printf("Failed to open %s: %v", logsDir, err)
+	}
+}
+
+// refreshLogMenu lists the captured logs under "View Logs", including those
+// of instances that have stopped, so a failed load can be looked into.
+func refreshLogMenu() {
+	sources := logSourceList()
+
+	menuMu.Lock()
+	defer menuMu.Unlock()
+	for len(menuItems.logs) < len(sources) {
+		item := menuItems.viewLogs.AddSubMenuItem("", "Open the log file of this instance")
+		menuItems.logs = append(menuItems.logs, logMenuSlot{item: item})
+		idx := len(menuItems.logs) - 1
+		go func() {
+			for range item.ClickedCh {
+				menuMu.Lock()
+				path := menuItems.logs[idx].path
+				menuMu.Unlock()
+				// The system's default handler opens the file in a text
+				// viewer.
+				if err := openBrowser(path); err != nil {
+					log.Printf("Failed to open %s: %v", path, err)
+				}
+			}
+		}()
+	}
+
+	for i := range menuItems.logs {
+		slot := &menuItems.logs[i]
+		if i < len(sources) {
+			title := fmt.Sprintf("%s, Port:%d", sources[i].Name, sources[i].Port)
+			if !sources[i].Running {
+				title += " (stopped)"
+			}
+			slot.path = sources[i].File
+			slot.item.SetTitle(title)
+			slot.item.Show()
+		} else {
+			slot.path = ""
+			slot.item.Hide()
+		}
+	}
+}
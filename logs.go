@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxLogLines      = 2000
+	maxLogLineLength = 4096
+	logWaitTimeout   = 20 * time.Second
+)
+
+// logBuffer keeps the most recent output lines of one llama-server. Lines are
+// numbered from 0 so that clients can ask for everything after the last line
+// they saw.
+type logBuffer struct {
+	mu      sync.Mutex
+	lines   []string
+	next    int64
+	changed chan struct{}
+}
+
+func newLogBuffer() *logBuffer {
+	return &logBuffer{changed: make(chan struct{})}
+}
+
+// writer returns an io.Writer for one output stream. Each stream gets its own
+// line buffer so stdout and stderr lines are not mixed.
+func (b *logBuffer) writer() *logWriter {
+	return &logWriter{buf: b}
+}
+
+func (b *logBuffer) append(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lines = append(b.lines, line)
+	if len(b.lines) > maxLogLines {
+		b.lines = append([]string(nil), b.lines[len(b.lines)-maxLogLines:]...)
+	}
+	b.next++
+
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// tail returns the last n lines and the number of the line after them.
+func (b *logBuffer) tail(n int) ([]string, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n = min(max(n, 0), len(b.lines))
+	return append([]string(nil), b.lines[len(b.lines)-n:]...), b.next
+}
+
+// since returns the retained lines numbered seq and later, waiting up to
+// logWaitTimeout for new output when there is none yet.
+func (b *logBuffer) since(ctx context.Context, seq int64) ([]string, int64) {
+	b.mu.Lock()
+	if seq >= b.next {
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+		case <-time.After(logWaitTimeout):
+		}
+		b.mu.Lock()
+	}
+	defer b.mu.Unlock()
+
+	first := b.next - int64(len(b.lines))
+	seq = max(seq, first)
+	if seq >= b.next {
+		return []string{}, b.next
+	}
+	return append([]string(nil), b.lines[seq-first:]...), b.next
+}
+
+type logWriter struct {
+	buf  *logBuffer
+	line []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		if c == '\n' {
+			w.buf.append(string(w.line))
+			w.line = w.line[:0]
+			continue
+		}
+		if c != '\r' && len(w.line) < maxLogLineLength {
+			w.line = append(w.line, c)
+		}
+	}
+	return len(p), nil
+}
+
+// logSource is the output of the latest instance started on a port. It stays
+// available after the instance exits so failed loads can be inspected.
+type logSource struct {
+	instance *modelInstance
+	logs     *logBuffer
+}
+
+var (
+	logSourcesMu sync.Mutex
+	logSources   = map[int]*logSource{}
+)
+
+func registerLogSource(instance *modelInstance, logs *logBuffer) {
+	logSourcesMu.Lock()
+	defer logSourcesMu.Unlock()
+	logSources[instance.port] = &logSource{instance: instance, logs: logs}
+}
+
+// LogSourceInfo describes one entry of GET /api/logs.
+type LogSourceInfo struct {
+	Port    int    `json:"port"`
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+	File    string `json:"file"`
+}
+
+// LogLines is the response of GET /api/logs?port=N.
+type LogLines struct {
+	Port  int      `json:"port"`
+	Lines []string `json:"lines"`
+	Next  int64    `json:"next"`
+}
+
+// handleLogs lists the available logs, or returns the output of the instance
+// on port. With since=N it returns the lines from number N on, holding the
+// request open until new output arrives so clients can follow the log.
+func handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+
+	portStr := r.URL.Query().Get("port")
+	if portStr == "" {
+		writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: logSourceList()})
+		return
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid port"})
+		return
+	}
+
+	logSourcesMu.Lock()
+	source := logSources[port]
+	logSourcesMu.Unlock()
+	if source == nil {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "No log for this port"})
+		return
+	}
+
+	result := LogLines{Port: port}
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		seq, err := strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid since"})
+			return
+		}
+		result.Lines, result.Next = source.logs.since(r.Context(), seq)
+	} else {
+		n := 200
+		if linesStr := r.URL.Query().Get("lines"); linesStr != "" {
+			if n, err = strconv.Atoi(linesStr); err != nil {
+				writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid lines"})
+				return
+			}
+		}
+		result.Lines, result.Next = source.logs.tail(n)
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: result})
+}
+
+func logSourceList() []LogSourceInfo {
+	runningModelsMu.RLock()
+	running := map[*modelInstance]bool{}
+	for _, instance := range runningModels {
+		running[instance] = true
+	}
+	runningModelsMu.RUnlock()
+
+	logSourcesMu.Lock()
+	defer logSourcesMu.Unlock()
+
+	list := []LogSourceInfo{}
+	for port, source := range logSources {
+		list = append(list, LogSourceInfo{
+			Port:    port,
+			Name:    displayName(source.instance),
+			Running: running[source.instance],
+			File:    instanceLogPath(source.instance),
+		})
+	}
+	for i := 1; i < len(list); i++ {
+		for j := i; j > 0 && list[j].Port < list[j-1].Port; j-- {
+			list[j], list[j-1] = list[j-1], list[j]
+		}
+	}
+	return list
+}
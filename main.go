@@ -1,74 +1,195 @@
 package main
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"context"
-	"embed"
+	"crypto/tls"
+	_ "embed"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/getlantern/systray"
-	"golang.org/x/sys/windows/registry"
 )
 
 //go:embed favicon.ico
 var iconData []byte
 
-//go:embed *.zip
-var serverArchives embed.FS
-
 //go:embed default_config.json
 var defaultConfigData []byte
 
 type ModelConfig struct {
-	Name   string   `json:"name"`
-	Target string   `json:"target"`
-	Args   []string `json:"args"`
+	Name      string   `json:"name"`
+	Target    string   `json:"target"`
+	Args      []string `json:"args"`
+	Preset    string   `json:"preset,omitempty"`
+	CtxSize   string   `json:"ctxSize,omitempty"`
+	GPULayers string   `json:"gpuLayers,omitempty"`
+
+	IdleTimeout   int `json:"idleTimeoutMinutes,omitempty"`
+	CrashRestarts int `json:"crashRestarts,omitempty"`
+
+	PreLoadHook    string `json:"preLoadHook,omitempty"`
+	PostUnloadHook string `json:"postUnloadHook,omitempty"`
+
+	Host string `json:"host,omitempty"`
 }
 
 type Config struct {
-	ModelDir          string        `json:"modelDir"`
-	AutoOpenWeb       bool          `json:"autoOpenWebEnabled"`
-	AutoStartEnabled  bool          `json:"autoStartEnabled"`
-	BasePort          int           `json:"basePort"`
-	LlamaServerPort   int           `json:"llamaServerPort"`
-	DefaultArgs       []string      `json:"defaultArgs"`
-	ModelSpecificArgs []ModelConfig `json:"modelSpecificArgs"`
-	ExcludePatterns   []string      `json:"excludePatterns,omitempty"`
+	ModelDir          string              `json:"modelDir"`
+	AutoOpenWeb       bool                `json:"autoOpenWebEnabled"`
+	BrowserCommand    string              `json:"browserCommand,omitempty"`
+	AutoStartEnabled  bool                `json:"autoStartEnabled"`
+	AutoStartMethod   string              `json:"autoStartMethod,omitempty"`
+	BasePort          int                 `json:"basePort"`
+	APIHost           string              `json:"apiHost,omitempty"`
+	ServerHost        string              `json:"serverHost,omitempty"`
+	APIKeys           []string            `json:"apiKeys,omitempty"`
+	ServerAPIKey      bool                `json:"serverApiKeyEnabled,omitempty"`
+	TLS               bool                `json:"tlsEnabled,omitempty"`
+	TLSCert           string              `json:"tlsCert,omitempty"`
+	TLSKey            string              `json:"tlsKey,omitempty"`
+	AccessRules       []AccessRule        `json:"accessRules,omitempty"`
+	LlamaServerPort   int                 `json:"llamaServerPort"`
+	MaxServerPort     int                 `json:"llamaServerPortMax,omitempty"`
+	ModelPorts        map[string]int      `json:"modelPorts,omitempty"`
+	DefaultArgs       []string            `json:"defaultArgs"`
+	Presets           map[string][]string `json:"presets,omitempty"`
+	ModelSpecificArgs []ModelConfig       `json:"modelSpecificArgs"`
+	ExcludePatterns   []string            `json:"excludePatterns,omitempty"`
+	UnloadOnSuspend   bool                `json:"unloadOnSuspendEnabled"`
+	AutoLoadModels    []AutoLoadEntry     `json:"autoLoadModels,omitempty"`
+	Notifications     *bool               `json:"notificationsEnabled,omitempty"`
+	RestoreSession    bool                `json:"restoreSessionEnabled,omitempty"`
+	StartupDelay      int                 `json:"startupDelaySeconds,omitempty"`
+	WaitForGPU        int                 `json:"waitForGPUSeconds,omitempty"`
+	ParallelPresets   []int               `json:"parallelPresets,omitempty"`
+	CtxSize           string              `json:"ctxSize,omitempty"`
+	GPULayers         string              `json:"gpuLayers,omitempty"`
+	ServerPath        string              `json:"serverPath,omitempty"`
+	Backend           string              `json:"backend,omitempty"`
+	ServerRelease     string              `json:"serverRelease,omitempty"`
+	LoadOnDemand      bool                `json:"loadOnDemandEnabled,omitempty"`
+	IdleTimeout       int                 `json:"idleTimeoutMinutes,omitempty"`
+	CrashRestarts     int                 `json:"crashRestarts,omitempty"`
+	RestartUnhealthy  bool                `json:"restartUnhealthyEnabled,omitempty"`
+	Webhooks          []Webhook           `json:"webhooks,omitempty"`
+	PreLoadHook       string              `json:"preLoadHook,omitempty"`
+	PostUnloadHook    string              `json:"postUnloadHook,omitempty"`
+	VRAMCheck         string              `json:"vramCheck,omitempty"`
+	MenuSort          string              `json:"menuSort,omitempty"`
+	MenuGroup         string              `json:"menuGroup,omitempty"`
+	ScanSubfolders    bool                `json:"scanSubfoldersEnabled,omitempty"`
+	Favorites         []string            `json:"favorites,omitempty"`
+	Aliases           map[string]string   `json:"aliases,omitempty"`
+	RecentModels      []string            `json:"recentModels,omitempty"`
+	LogMaxSizeMB      int                 `json:"logMaxSizeMB,omitempty"`
+	LogMaxFiles       int                 `json:"logMaxFiles,omitempty"`
 }
 
-var config Config
+// currentConfig holds the settings in effect. A stored Config is never
+// modified: writers hold configMu and store a changed copy, so readers load
+// one consistent snapshot through config. configMu also guards fileSettings.
+var (
+	currentConfig atomic.Pointer[Config]
+	configMu      sync.Mutex
+)
+
+func init() {
+	currentConfig.Store(&Config{})
+}
+
+// config returns the settings in effect. An operation that reads several
+// settings loads it once; the result must not be modified.
+func config() *Config {
+	return currentConfig.Load()
+}
+
+// setConfig makes c the settings in effect. Callers hold configMu, except
+// before other goroutines start.
+func setConfig(c Config) {
+	currentConfig.Store(&c)
+}
+
+// updateConfig applies change to a copy of the settings in effect and stores
+// the copy. The copy shares its slices and maps with the previous settings,
+// so change replaces them rather than modifying them in place.
+func updateConfig(change func(c *Config)) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	c := *config()
+	change(&c)
+	setConfig(c)
+}
 
 var (
-	runningModel    *modelInstance
+	runningModels   []*modelInstance
 	runningModelsMu sync.RWMutex
 
+	// currentModels is replaced as a whole by a rescan and never modified
+	// in place. Read it through modelSnapshot or modelAt.
 	currentModels []modelEntry
+	modelsMu      sync.RWMutex
+	rescanMu      sync.Mutex
+
+	serverPath     string
+	apiServer      *http.Server
+	headless       bool
+	autoStartStale bool
 
-	serverPath string
-	apiServer  *http.Server
+	// menuMu guards the reused menu slots below: refreshes from load, crash,
+	// watchdog and reload goroutines add and reassign them while the click
+	// goroutines of the slots read what they stand for.
+	menuMu sync.Mutex
 
 	menuItems struct {
-		loadModel    *systray.MenuItem
-		unloadModel  *systray.MenuItem
-		webInterface *systray.MenuItem
-		autoStart    *systray.MenuItem
-		refresh      *systray.MenuItem
-		quit         *systray.MenuItem
-		models       []*systray.MenuItem
-		modelConfigs [][]*systray.MenuItem
+		loadModel      *systray.MenuItem
+		unloadModel    *systray.MenuItem
+		unloadAll      *systray.MenuItem
+		webInterface   *systray.MenuItem
+		test           *systray.MenuItem
+		instances      []instanceMenuSlot
+		autoStart      *systray.MenuItem
+		lanAccess      *systray.MenuItem
+		openFolder     *systray.MenuItem
+		viewLogs       *systray.MenuItem
+		openLogs       *systray.MenuItem
+		logs           []logMenuSlot
+		rescan         *systray.MenuItem
+		settings       *systray.MenuItem
+		restoreSession *systray.MenuItem
+		quit           *systray.MenuItem
+		models         []*systray.MenuItem
+		modelConfigs   [][]*systray.MenuItem
+		modelOrder     []int
+		groups         []*systray.MenuItem
+		favorites      []namedMenuItem
+		recent         *systray.MenuItem
+		recentSlots    []namedMenuItem
+		sortModes      []*systray.MenuItem
+		folders        []*systray.MenuItem
 	}
 )
 
@@ -83,8 +204,89 @@ type modelInstance struct {
 	entry       modelEntry
 	cmd         *exec.Cmd
 	port        int
+	instanceNum int
 	configIndex int
 	configName  string
+	progress    *loadProgress
+	ready       bool
+	parallel    int
+	preset      string
+	customArgs  []string
+	startedAt   time.Time
+	readyAt     time.Time
+
+	// restarts counts the crashes and hangs this instance was restarted
+	// after.
+	restarts int
+
+	// healthFailures counts the failed health checks in a row; the instance
+	// is unhealthy once there are unhealthyAfter of them.
+	healthFailures  int
+	unhealthy       bool
+	lastHealthCheck time.Time
+
+	// lastUsed and activeRequests track requests through the router, for
+	// unloading idle instances.
+	lastUsed       time.Time
+	activeRequests int
+}
+
+// logMenuSlot is an entry under "View Logs", reused across refreshes like
+// instanceMenuSlot. path is the log file it opens.
+type logMenuSlot struct {
+	item *systray.MenuItem
+	path string
+}
+
+// instanceMenuSlot holds the per-instance entries under "Unload Model",
+// "Web Interface" and "Test". Slots are reused across refreshes since tray items cannot
+// be removed, and port records which instance a slot currently stands for.
+type instanceMenuSlot struct {
+	unload *systray.MenuItem
+	web    *systray.MenuItem
+	test   *systray.MenuItem
+	port   int
+}
+
+// launchOptions are one-off adjustments applied to a single launch on top of
+// the configured arguments.
+type launchOptions struct {
+	Parallel int
+
+	// Preset names an entry of presets applied on top of the configured
+	// arguments.
+	Preset string
+
+	// Args, when not nil, replaces the default or model-specific arguments
+	// for this launch only.
+	Args []string
+
+	// NoBrowser skips autoOpenWebEnabled, for loads no one asked to see.
+	NoBrowser bool
+
+	// Force loads the model even if it is estimated not to fit in VRAM.
+	Force bool
+
+	// FromTray marks loads chosen in the tray menu. Only those may ask the
+	// user to confirm a load that does not fit in VRAM; the API, the router
+	// and auto-load must not wait on a dialog.
+	FromTray bool
+
+	// Port is used for the instance if it is free, as when restoring a
+	// session; otherwise a port is allocated as usual.
+	Port int
+
+	// Restarts is set when the launch restarts a crashed instance.
+	Restarts int
+}
+
+// LoadRequest is the optional JSON body of POST /api/load.
+type LoadRequest struct {
+	Args []string `json:"args,omitempty"`
+
+	// ExtraArgs are merged on top of the arguments the model would be
+	// loaded with, e.g. to try another --ctx-size once.
+	ExtraArgs []string `json:"extraArgs,omitempty"`
 }
 
 type APIResponse struct {
@@ -93,16 +295,114 @@ type APIResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// ActivateRequest is sent by a second lmgo process to the running one before
+// it exits.
+type ActivateRequest struct {
+	Load []string `json:"load,omitempty"`
+}
+
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
 type ModelStatus struct {
-	Loaded     bool       `json:"loaded"`
-	Model      modelEntry `json:"model,omitempty"`
-	Port       int        `json:"port,omitempty"`
-	ServerPort int        `json:"serverPort,omitempty"`
-	ConfigName string     `json:"configName,omitempty"`
+	Loaded     bool             `json:"loaded"`
+	Model      modelEntry       `json:"model,omitempty"`
+	Port       int              `json:"port,omitempty"`
+	ServerPort int              `json:"serverPort,omitempty"`
+	ConfigName string           `json:"configName,omitempty"`
+	Instances  []InstanceStatus `json:"instances"`
+}
+
+// InstanceStatus describes one running llama-server for /api/instances.
+type InstanceStatus struct {
+	Name        string `json:"name"`
+	Model       string `json:"model"`
+	ConfigName  string `json:"configName,omitempty"`
+	Path        string `json:"path"`
+	Port        int    `json:"port"`
+	InstanceNum int    `json:"instanceNum"`
+	Parallel    int    `json:"parallel,omitempty"`
+	Preset      string `json:"preset,omitempty"`
+	Uptime      int64  `json:"uptime"`
+	Healthy     bool   `json:"healthy"`
+	// Progress is the loading percentage: -1 while loading without a known
+	// percentage, 100 once the instance is ready.
+	Progress int `json:"progress"`
+	// Phase is what a loading instance is doing: "loading tensors",
+	// "creating context" or "warming up".
+	Phase string `json:"phase,omitempty"`
+
+	StartedAt time.Time `json:"startedAt"`
+	ReadyAt   time.Time `json:"readyAt,omitzero"`
+	// Restarts counts the times the instance was restarted after crashing
+	// or hanging.
+	Restarts int `json:"restarts"`
+	// LastHealthCheck is when the watchdog last checked the instance, and
+	// HealthFailures how many checks in a row it has failed since.
+	LastHealthCheck time.Time `json:"lastHealthCheck,omitzero"`
+	HealthFailures  int       `json:"healthFailures"`
 }
 
 func main() {
-	hideConsole()
+	var loadNames stringList
+	flag.Var(&loadNames, "load", "Load the named model on startup (can be repeated)")
+	bootLaunch := flag.Bool("boot", false, "Launched by auto-start; apply startupDelaySeconds")
+	headlessFlag := flag.Bool("headless", false, "Run without the tray icon, controlled through the API only")
+	noTray := flag.Bool("no-tray", false, "Same as --headless")
+	configFlag := flag.String("config", "", "Use this config file instead of lmgo.json next to the executable")
+	modelDirFlag := flag.String("model-dir", "", "Scan this directory for models instead of modelDir (overrides LMGO_MODEL_DIR)")
+	installSvc := flag.Bool("install-service", false, "Register lmgo as a Windows service and exit")
+	uninstallSvc := flag.Bool("uninstall-service", false, "Remove the lmgo Windows service and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: lmgo [flags]\n       lmgo [flags] <command> [args]\n\nCommands, sent to the running lmgo:\n  %s\n\nFlags:\n", ipcUsage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Commands print their answer, so a windowsgui build started from a
+	// terminal has to reattach to it.
+	command := flag.Args()
+	if len(command) > 0 {
+		attachConsole()
+	}
+
+	if *installSvc || *uninstallSvc {
+		var err error
+		if *installSvc {
+			err = installService()
+		} else {
+			err = uninstallService()
+		}
+		if err != nil {
+			log.Fatalf("Service operation failed: %v", err)
+		}
+		return
+	}
+
+	// Paths on the command line and in the environment are relative to the
+	// directory lmgo was started from, so resolve them before changing to the
+	// executable's directory.
+	if *configFlag != "" {
+		if err := setConfigPath(*configFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := parseOverrides(*modelDirFlag); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(command) > 0 {
+		log.SetOutput(io.Discard)
+	}
+
+	isService := len(command) == 0 && runningAsService()
+	headless = *headlessFlag || *noTray || isService
+
+	if !headless && len(command) == 0 {
+		hideConsole()
+	}
 
 	if exePath, err := os.Executable(); err == nil {
 		exeDir := filepath.Dir(exePath)
@@ -115,59 +415,421 @@ func main() {
 		log.Printf("Warning: Failed to get executable path: %v", err)
 	}
 
+	if isService {
+		if logFile, err := os.OpenFile("lmgo.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+			log.SetOutput(logFile)
+		}
+	}
+
 	if err := loadConfig(); err != nil {
+		if len(command) > 0 {
+			fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+		notify("Invalid Config", err.Error())
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	if isAutoStartEnabled() != config.AutoStartEnabled {
-		config.AutoStartEnabled = isAutoStartEnabled()
+	if len(command) > 0 {
+		os.Exit(runCommand(command))
 	}
 
+	run, message := arbitrateInstance(acquireSingleInstance, func() error {
+		return handOffToRunningInstance(config().BasePort, loadNames)
+	})
+	if message != "" {
+		notify("lmgo", message)
+	}
+	if !run {
+		os.Exit(0)
+	}
+
+	syncAutoStart()
+	target, enabled, stale := autoStartStatus()
+	updateConfig(func(c *Config) { c.AutoStartEnabled = enabled })
+	if enabled {
+		autoStartStale = stale
+		if stale {
+			log.Printf("Auto-start entry points to %s instead of the current executable", target)
+			notify("Auto Startup Needs Repair", fmt.Sprintf("The auto-start entry points to %s. Click Auto Startup in the menu to update it.", target))
+		}
+	}
+
+	if isService {
+		if err := runService(*bootLaunch, loadNames); err != nil {
+			log.Fatalf("Service failed: %v", err)
+		}
+		return
+	}
+
+	if err := startBackend(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if headless {
+		stop := make(chan struct{})
+		go func() {
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+			<-sig
+			close(stop)
+		}()
+		runHeadless(stop, *bootLaunch, loadNames)
+		return
+	}
+
+	systray.Run(func() {
+		onReady()
+		go startupAutoLoad(*bootLaunch, loadNames)
+	}, onExit)
+}
+
+// startBackend extracts the server, scans models and starts the management
+// API. It is shared by the tray, headless and service modes.
+func startBackend() error {
+	findGPUs()
 	if err := extractServer(); err != nil {
-		log.Fatalf("Failed to extract server: %v", err)
+		return fmt.Errorf("failed to set up llama-server: %v", err)
 	}
+	stopOrphanedServers()
 
-	var err error
-	currentModels, err = findGGUFFiles(config.ModelDir)
+	models, err := findGGUFFiles(config().ModelDir)
 	if err != nil {
-		log.Fatalf("Error scanning model files: %v", err)
+		return fmt.Errorf("error scanning model files: %v", err)
 	}
-	if len(currentModels) == 0 {
-		log.Fatalf("No .gguf files found in directory: %s", config.ModelDir)
+	if len(models) == 0 {
+		return fmt.Errorf("no .gguf files found in directory: %s", config().ModelDir)
 	}
+	setModels(models)
+	readPreviousSession()
+	loadStats()
 
 	startAPIServer()
+	go watchIdleInstances()
+	go watchInstanceHealth()
+	go watchGPUs()
+	go watchConfig()
+	go serveIPC()
+	go persistStats()
+
+	if err := registerPowerNotifications(); err != nil {
+		log.Printf("Warning: Failed to register for suspend/resume notifications: %v", err)
+	}
+	return nil
+}
+
+// runHeadless runs the load/unload engine without a tray icon until stop is
+// closed or `lmgo quit` is received, then shuts everything down.
+func runHeadless(stop <-chan struct{}, boot bool, loadNames []string) {
+	log.Printf("Running headless. Found %d models. API available at %s/api", len(modelSnapshot()), apiBaseURL())
+	go startupAutoLoad(boot, loadNames)
+	select {
+	case <-stop:
+	case <-quitCh:
+	}
+	log.Printf("Shutting down")
+	onExit()
+}
+
+// autoStartStatus reports whether an auto-start entry exists for the
+// configured backend and whether it still launches this executable.
+func autoStartStatus() (target string, enabled bool, stale bool) {
+	target, found := readAutoStartTarget()
+	if !found {
+		return "", false, false
+	}
+	return target, true, isStaleAutoStartTarget(target)
+}
+
+// syncAutoStart rewrites an auto-start entry that launches this executable
+// but no longer matches the config, such as a scheduled task created before
+// startupDelaySeconds or autoStartMethod changed.
+func syncAutoStart() {
+	target, found := readAutoStartTarget()
+	if !found || isStaleAutoStartTarget(target) || !autoStartOutdated() {
+		return
+	}
+	if err := setAutoStart(true); err != nil {
+		log.Printf("Failed to update auto-start: %v", err)
+		return
+	}
+	log.Printf("Auto-start entry updated to match the config")
+}
+
+// isStaleAutoStartTarget reports whether target is missing or is not the
+// executable currently running.
+func isStaleAutoStartTarget(target string) bool {
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		return true
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	exeInfo, err := os.Stat(exePath)
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(targetInfo, exeInfo)
+}
+
+func setTooltip(text string) {
+	if headless {
+		return
+	}
+	systray.SetTooltip(text)
+}
+
+// arbitrateInstance decides whether this launch runs. It does when it
+// acquires the single-instance guard, or when the guard cannot be created at
+// all. Otherwise another copy is running: the launch is handed to it, and the
+// message to show is returned when that fails.
+func arbitrateInstance(acquire func() (bool, error), handOff func() error) (run bool, message string) {
+	acquired, err := acquire()
+	if err != nil {
+		log.Printf("Warning: Failed to create single-instance mutex: %v", err)
+		return true, ""
+	}
+	if acquired {
+		return true, ""
+	}
+
+	log.Printf("Another lmgo instance is already running")
+	if err := handOff(); err != nil {
+		log.Printf("Failed to contact running instance: %v", err)
+		return false, "lmgo is already running."
+	}
+	return false, ""
+}
+
+// handOffToRunningInstance forwards the --load arguments of a second launch to
+// the running instance through the management API.
+func handOffToRunningInstance(port int, loadNames []string) error {
+	body, err := json.Marshal(ActivateRequest{Load: loadNames})
+	if err != nil {
+		return err
+	}
+
+	// The running instance reads the same config, so it serves HTTPS exactly
+	// when this one would, with a certificate that need not name 127.0.0.1.
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	scheme := "http"
+	if config().TLS {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://127.0.0.1:%d/api/activate", scheme, port)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	authorizeServerRequest(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("running instance returned %s", resp.Status)
+	}
+	return nil
+}
+
+// findModelByName resolves a model name to a (model, config) index pair. The
+// name is matched against config names first and then against file base
+// names, case-insensitively.
+func findModelByName(name string) (int, int, bool) {
+	name = resolveAlias(name)
+	models := modelSnapshot()
+	for i, m := range models {
+		configIdx := 0
+		for _, cfg := range config().ModelSpecificArgs {
+			if cfg.Target != m.BaseName {
+				continue
+			}
+			if strings.EqualFold(cfg.Name, name) {
+				return i, configIdx, true
+			}
+			configIdx++
+		}
+	}
+
+	for i, m := range models {
+		if strings.EqualFold(m.BaseName, name) {
+			return i, -1, true
+		}
+	}
+
+	return -1, -1, false
+}
+
+// modelSnapshot returns the models found by the last scan. The slice is never
+// modified, so it may be kept and indexed without holding modelsMu.
+func modelSnapshot() []modelEntry {
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	return currentModels
+}
+
+// modelAt returns the model at idx of the current scan, or false when a
+// rescan has left fewer models.
+func modelAt(idx int) (modelEntry, bool) {
+	models := modelSnapshot()
+	if idx < 0 || idx >= len(models) {
+		return modelEntry{}, false
+	}
+	return models[idx], true
+}
+
+func setModels(models []modelEntry) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	currentModels = models
+}
+
+// isAutoLoaded reports whether autoLoadModels names a model or
+// configuration, directly or through an alias.
+func isAutoLoaded(name string) bool {
+	return slices.ContainsFunc(config().AutoLoadModels, func(e AutoLoadEntry) bool { return strings.EqualFold(resolveAlias(e.Model), name) })
+}
+
+// toggleAutoLoad adds a model to autoLoadModels or removes every entry that
+// names it.
+func toggleAutoLoad(name string) {
+	updateConfig(func(c *Config) {
+		if isAutoLoaded(name) {
+			c.AutoLoadModels = slices.DeleteFunc(slices.Clone(c.AutoLoadModels), func(e AutoLoadEntry) bool { return strings.EqualFold(resolveAlias(e.Model), name) })
+		} else {
+			c.AutoLoadModels = append(slices.Clip(c.AutoLoadModels), AutoLoadEntry{Model: name})
+		}
+	})
+	if err := saveConfig(); err != nil {
+		log.Printf("Failed to save config: %v", err)
+	}
+	addModelMenuItems()
+	refreshMenuState()
+}
+
+// startupAutoLoad loads autoLoadModels, or the previous session when
+// restoreSessionEnabled is set and there is one, and any --load arguments.
+// Boot launches wait startupDelaySeconds first, and when waitForGPUSeconds is
+// set the backend is probed until it reports a device.
+func startupAutoLoad(boot bool, extra []string) {
+	c := config()
+	restore := c.RestoreSession && hasPreviousSession()
+	var entries []AutoLoadEntry
+	if !restore {
+		entries = append(entries, c.AutoLoadModels...)
+	}
+	for _, name := range extra {
+		entries = append(entries, AutoLoadEntry{Model: name})
+	}
+	if len(entries) == 0 && !restore {
+		return
+	}
+
+	if boot && c.StartupDelay > 0 {
+		log.Printf("Boot launch, waiting %ds before loading models", c.StartupDelay)
+		for remaining := c.StartupDelay; remaining > 0; remaining-- {
+			setTooltip(fmt.Sprintf("lmgo Model Server - starting in %ds", remaining))
+			time.Sleep(time.Second)
+		}
+	}
+
+	if c.WaitForGPU > 0 {
+		if err := waitForGPU(time.Duration(c.WaitForGPU) * time.Second); err != nil {
+			log.Printf("Warning: %v", err)
+			notify("GPU Not Ready", fmt.Sprintf("%v. Trying to load models anyway.", err))
+		}
+	}
+
+	setTooltip(idleTooltip())
+	if restore {
+		restoreSession()
+	}
+	if failed := loadAutoLoadEntries(entries); len(failed) > 0 {
+		fireWebhooks(eventAutoLoadFailed, "Auto-Load Failed", strings.Join(failed, "\n"))
+	}
+}
+
+// waitForGPU runs "llama-server --list-devices" until it lists at least one
+// device or the timeout expires.
+func waitForGPU(timeout time.Duration) error {
+	start := time.Now()
+	for {
+		elapsed := time.Since(start)
+		setTooltip(fmt.Sprintf("lmgo Model Server - waiting for GPU… %ds", int(elapsed.Seconds())))
+
+		cmd := exec.Command(serverPath, "--list-devices")
+		hideWindow(cmd)
+		out, err := cmd.CombinedOutput()
+		if err == nil && hasListedDevice(string(out)) {
+			log.Printf("GPU ready after %ds", int(elapsed.Seconds()))
+			return nil
+		}
 
-	systray.Run(onReady, onExit)
+		if elapsed >= timeout {
+			return fmt.Errorf("no GPU device reported after %ds", int(timeout.Seconds()))
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// hasListedDevice reports whether --list-devices output contains a device
+// line after the "Available devices:" header.
+func hasListedDevice(output string) bool {
+	inList := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Available devices") {
+			inList = true
+			continue
+		}
+		if inList && line != "" {
+			return true
+		}
+	}
+	return false
 }
 
 func loadConfig() error {
-	configFile := "lmgo.json"
+	configFile := configPath
 
 	if _, err := os.Stat(configFile); os.IsNotExist(err) {
 		log.Printf("Config file %s does not exist, creating default config...", configFile)
 
-		if err := json.Unmarshal(defaultConfigData, &config); err != nil {
+		loaded := *config()
+		if err := json.Unmarshal(defaultConfigData, &loaded); err != nil {
 			return fmt.Errorf("failed to parse embedded default config: %v", err)
 		}
 
-		if config.BasePort == 0 {
-			config.BasePort = 8080
+		if loaded.BasePort == 0 {
+			loaded.BasePort = 8080
 		}
-		if config.LlamaServerPort == 0 {
-			config.LlamaServerPort = 8081
+		if loaded.LlamaServerPort == 0 {
+			loaded.LlamaServerPort = 8081
 		}
-
-		if config.ModelSpecificArgs == nil {
-			config.ModelSpecificArgs = []ModelConfig{}
+		if loaded.ModelSpecificArgs == nil {
+			loaded.ModelSpecificArgs = []ModelConfig{}
 		}
-		if config.ExcludePatterns == nil {
-			config.ExcludePatterns = []string{}
+		if loaded.ExcludePatterns == nil {
+			loaded.ExcludePatterns = []string{}
 		}
 
-		if config.BasePort == config.LlamaServerPort {
-			return fmt.Errorf("API port (%d) and llama-server port (%d) cannot be the same", config.BasePort, config.LlamaServerPort)
+		configMu.Lock()
+		applyOverrides(&loaded)
+		if loaded.BasePort == loaded.LlamaServerPort {
+			configMu.Unlock()
+			return fmt.Errorf("API port (%d) and llama-server port (%d) cannot be the same", loaded.BasePort, loaded.LlamaServerPort)
 		}
+		setConfig(loaded)
+		configMu.Unlock()
 
 		if err := saveConfig(); err != nil {
 			return fmt.Errorf("failed to save default config: %v", err)
@@ -177,40 +839,135 @@ func loadConfig() error {
 		return nil
 	}
 
-	data, err := os.ReadFile(configFile)
+	// Parse into a fresh value so keys removed from the file do not linger
+	// on a reload.
+	loaded, err := readConfigFile(configFile)
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %v", err)
+		return err
+	}
+	if err := applyConfig(loaded); err != nil {
+		return err
 	}
 
-	if err := json.Unmarshal(data, &config); err != nil {
-		return fmt.Errorf("failed to parse config file: %v", err)
+	cfg := config()
+	log.Printf("Config loaded: modelDir=%s, basePort=%d, llamaServerPort=%d, excludePatterns=%v", cfg.ModelDir, cfg.BasePort, cfg.LlamaServerPort, cfg.ExcludePatterns)
+	return nil
+}
+
+// applyConfig makes loaded the current config if it is valid and otherwise
+// keeps the current one. The auto-start state comes from the system, not the
+// file.
+func applyConfig(loaded Config) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	loaded.AutoStartEnabled = config().AutoStartEnabled
+
+	previousFile := fileSettings
+	applyOverrides(&loaded)
+	if err := validateConfig(&loaded); err != nil {
+		fileSettings = previousFile
+		return err
 	}
+	setConfig(loaded)
+	return nil
+}
 
-	if config.BasePort == 0 {
-		config.BasePort = 8080
+// validateConfig fills in the default ports and checks a loaded config.
+func validateConfig(c *Config) error {
+	if c.BasePort == 0 {
+		c.BasePort = 8080
 	}
-	if config.LlamaServerPort == 0 {
-		config.LlamaServerPort = 8081
+	if c.LlamaServerPort == 0 {
+		c.LlamaServerPort = 8081
 	}
 
-	if config.BasePort == config.LlamaServerPort {
-		return fmt.Errorf("API port (%d) and llama-server port (%d) cannot be the same", config.BasePort, config.LlamaServerPort)
+	if err := validatePort("basePort", c.BasePort); err != nil {
+		return err
 	}
-
-	if config.ModelSpecificArgs == nil {
-		config.ModelSpecificArgs = []ModelConfig{}
+	if err := validatePort("llamaServerPort", c.LlamaServerPort); err != nil {
+		return err
+	}
+	if c.MaxServerPort != 0 {
+		if err := validatePort("llamaServerPortMax", c.MaxServerPort); err != nil {
+			return err
+		}
 	}
-	if config.ExcludePatterns == nil {
-		config.ExcludePatterns = []string{}
+	if c.BasePort == c.LlamaServerPort {
+		return fmt.Errorf("API port (%d) and llama-server port (%d) cannot be the same", c.BasePort, c.LlamaServerPort)
+	}
+	if err := validatePortRange(c); err != nil {
+		return err
+	}
+	if err := validateModelDir(c); err != nil {
+		return err
+	}
+	if err := validateModelPorts(c); err != nil {
+		return err
+	}
+	if err := validateBackend(c.Backend); err != nil {
+		return err
+	}
+	if err := validateServerRelease(c.ServerRelease); err != nil {
+		return err
+	}
+	if err := validateVRAMCheck(c.VRAMCheck); err != nil {
+		return err
+	}
+	if err := validateMenuSort(c.MenuSort); err != nil {
+		return err
+	}
+	if err := validateMenuGroup(c.MenuGroup); err != nil {
+		return err
+	}
+	if err := validateAliases(c); err != nil {
+		return err
+	}
+	if err := validatePresets(c); err != nil {
+		return err
+	}
+	if err := validateAutoLoadModels(c); err != nil {
+		return err
+	}
+	if err := validateModelSpecificArgs(c); err != nil {
+		return err
+	}
+	if err := validateAutoSetting("ctxSize", c.CtxSize); err != nil {
+		return err
+	}
+	if err := validateAutoSetting("gpuLayers", c.GPULayers); err != nil {
+		return err
+	}
+	if err := validateCounts(c); err != nil {
+		return err
+	}
+	if err := validateWebhooks(c); err != nil {
+		return err
+	}
+	if err := validateHosts(c); err != nil {
+		return err
+	}
+	if err := validateAPIKeys(c); err != nil {
+		return err
+	}
+	if err := validateTLS(c); err != nil {
+		return err
+	}
+	if err := validateAccessRules(c); err != nil {
+		return err
 	}
 
-	log.Printf("Config loaded: modelDir=%s, basePort=%d, llamaServerPort=%d, excludePatterns=%v", config.ModelDir, config.BasePort, config.LlamaServerPort, config.ExcludePatterns)
+	if c.ModelSpecificArgs == nil {
+		c.ModelSpecificArgs = []ModelConfig{}
+	}
+	if c.ExcludePatterns == nil {
+		c.ExcludePatterns = []string{}
+	}
 	return nil
 }
 
 func saveConfig() error {
-	configFile := "lmgo.json"
-	data, err := json.MarshalIndent(config, "", "  ")
+	configFile := configPath
+	data, err := json.MarshalIndent(fileConfig(), "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to encode config: %v", err)
 	}
@@ -218,73 +975,277 @@ func saveConfig() error {
 	if err := os.WriteFile(configFile, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
+	recordConfigModTime()
 
 	log.Printf("Config saved to: %s", configFile)
 	return nil
 }
 
 func extractServer() error {
+	c := config()
+	if c.ServerPath != "" {
+		path, err := configuredServer(c.ServerPath)
+		if err != nil {
+			return err
+		}
+		serverPath = path
+		log.Printf("Using llama-server from serverPath: %s", serverPath)
+		return nil
+	}
+	if c.ServerRelease != "" {
+		path, err := releaseServer(c.ServerRelease)
+		if err != nil {
+			return err
+		}
+		serverPath = path
+		return nil
+	}
+
 	serverDir := "server"
-	serverPath = filepath.Join(serverDir, "llama-server.exe")
+	serverPath = filepath.Join(serverDir, serverBinaryName)
+
+	name, err := selectServerArchive()
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		if paths := findServerBinaries(serverDir); len(paths) > 0 {
+			serverPath = paths[0]
+			log.Printf("Server already exists at: %s", serverPath)
+			return nil
+		}
+		path, err := exec.LookPath(serverBinaryName)
+		if err != nil {
+			return fmt.Errorf("no embedded server archive for %s and %s was not found in PATH", runtime.GOOS, serverBinaryName)
+		}
+		serverPath = path
+		log.Printf("Using llama-server from PATH: %s", serverPath)
+		return nil
+	}
 
-	if _, err := os.Stat(serverPath); err == nil {
+	// The archive extracted last is recorded, so that choosing another
+	// backend replaces it. A server directory without the record is kept.
+	marker := filepath.Join(serverDir, ".archive")
+	previous, _ := os.ReadFile(marker)
+	if paths := findServerBinaries(serverDir); len(paths) > 0 && (len(previous) == 0 || string(previous) == name) {
+		serverPath = paths[0]
 		log.Printf("Server already exists at: %s", serverPath)
 		return nil
 	}
+	if len(previous) > 0 {
+		log.Printf("Replacing the server extracted from %s with %s", previous, name)
+		if err := os.RemoveAll(serverDir); err != nil {
+			return fmt.Errorf("failed to remove the previous server: %v", err)
+		}
+	}
 
+	data, err := serverArchives.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("failed to read embedded archive: %v", err)
+	}
 	if err := os.MkdirAll(serverDir, 0755); err != nil {
 		return fmt.Errorf("failed to create server directory: %v", err)
 	}
 
-	entries, err := serverArchives.ReadDir(".")
+	if strings.HasSuffix(name, ".zip") {
+		err = extractZip(bytes.NewReader(data), int64(len(data)), serverDir)
+	} else {
+		err = extractTarGz(bytes.NewReader(data), serverDir)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to read embedded archives: %v", err)
+		return fmt.Errorf("failed to extract server: %v", err)
+	}
+
+	paths := findServerBinaries(serverDir)
+	if len(paths) == 0 {
+		return fmt.Errorf("%s not found in embedded archive %s", serverBinaryName, name)
 	}
-	if len(entries) != 1 {
-		return fmt.Errorf("expected exactly one embedded zip file, found %d", len(entries))
+	serverPath = paths[0]
+	if err := os.WriteFile(marker, []byte(name), 0644); err != nil {
+		log.Printf("Warning: failed to record the extracted archive: %v", err)
 	}
 
-	zipData, err := serverArchives.ReadFile(entries[0].Name())
+	log.Printf("Server extracted to: %s", serverPath)
+	return nil
+}
+
+// archKeywords are the names release archives use for each CPU
+// architecture.
+var archKeywords = map[string][]string{
+	"amd64": {"x64", "x86_64", "amd64"},
+	"arm64": {"arm64", "aarch64"},
+}
+
+// namesMentioning returns the names that contain one of keywords.
+func namesMentioning(names, keywords []string) []string {
+	var matching []string
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		if slices.ContainsFunc(keywords, func(k string) bool { return strings.Contains(lower, k) }) {
+			matching = append(matching, name)
+		}
+	}
+	return matching
+}
+
+// configuredServer resolves the serverPath setting, which names either the
+// llama-server executable or a directory to search for it. A directory with
+// several llama.cpp builds is searched for the one for the backend, judged
+// by the names of the directories the executables are in.
+func configuredServer(path string) (string, error) {
+	info, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("failed to read embedded zip: %v", err)
+		return "", fmt.Errorf("serverPath %q: %v", path, err)
+	}
+	if !info.IsDir() {
+		return path, nil
 	}
 
-	if err := extractZip(zipData, serverDir); err != nil {
-		return fmt.Errorf("failed to extract server: %v", err)
+	paths := findServerBinaries(path)
+	if len(paths) == 0 {
+		return "", fmt.Errorf("serverPath %q does not contain %s", path, serverBinaryName)
+	}
+	builds := make([]string, len(paths))
+	for i, p := range paths {
+		builds[i], _ = filepath.Rel(path, p)
+	}
+	build, ok := pickBuild(builds, config().Backend, detectedGPUs)
+	if !ok {
+		return "", fmt.Errorf("serverPath %q has no build for the %s backend", path, config().Backend)
 	}
+	return filepath.Join(path, build), nil
+}
 
-	log.Printf("Server extracted to: %s", serverPath)
+// selectServerArchive picks the embedded archive for the current platform.
+// When several archives are embedded, those whose name mentions the current
+// OS are kept, then those that also mention the CPU, so that for example
+// macos-arm64 (Metal) wins over macos-x64 on Apple Silicon. Among them the
+// backend decides, see pickBuild. An empty name means nothing is embedded.
+func selectServerArchive() (string, error) {
+	entries, err := serverArchives.ReadDir(".")
+	if err != nil || len(entries) == 0 {
+		return "", nil
+	}
+
+	var candidates []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tar.gz") {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+
+	if len(candidates) > 1 {
+		found := len(candidates)
+		if candidates = namesMentioning(candidates, archiveKeywords); len(candidates) == 0 {
+			return "", fmt.Errorf("none of the %d embedded server archives is for %s", found, runtime.GOOS)
+		}
+		if forCPU := namesMentioning(candidates, archKeywords[runtime.GOARCH]); len(forCPU) > 0 {
+			candidates = forCPU
+		}
+	}
+
+	name, ok := pickBuild(candidates, config().Backend, detectedGPUs)
+	if !ok {
+		return "", fmt.Errorf("no embedded server archive for the %s backend", config().Backend)
+	}
+	return name, nil
+}
+
+// findServerBinaries looks for llama-server executables anywhere under dir,
+// since release archives place them at different depths per platform.
+func findServerBinaries(dir string) []string {
+	var found []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && d.Name() == serverBinaryName {
+			found = append(found, path)
+		}
+		return nil
+	})
+	return found
+}
+
+// archiveEntryPath returns the path of an archive entry relative to dest.
+// Names such as "../x" that would land outside dest are rejected, since
+// release archives are downloaded rather than embedded.
+func archiveEntryPath(dest, name string) (string, error) {
+	rel, err := filepath.Rel(dest, filepath.Clean(filepath.Join(dest, name)))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q is outside the destination", name)
+	}
+	return rel, nil
+}
+
+// checkSymlink rejects a symbolic link at the relative path rel whose target
+// is absolute or leads outside the destination.
+func checkSymlink(rel, linkname string) error {
+	target := filepath.Join(filepath.Dir(rel), linkname)
+	if filepath.IsAbs(linkname) || target == ".." || strings.HasPrefix(target, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("archive link %q points outside the destination: %q", rel, linkname)
+	}
 	return nil
 }
 
-func extractZip(data []byte, dest string) error {
-	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+// extractZip extracts an archive into dest. Writes go through os.Root, so
+// that a link inside the archive cannot redirect them outside dest either.
+func extractZip(r io.ReaderAt, size int64, dest string) error {
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	root, err := os.OpenRoot(dest)
 	if err != nil {
 		return err
 	}
+	defer root.Close()
 
 	for _, file := range zipReader.File {
-		target := filepath.Join(dest, file.Name)
+		rel, err := archiveEntryPath(dest, file.Name)
+		if err != nil {
+			return err
+		}
 
 		if file.FileInfo().IsDir() {
-			if err := os.MkdirAll(target, 0755); err != nil {
+			if err := root.MkdirAll(rel, 0755); err != nil {
 				return err
 			}
 			continue
 		}
 
-		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		if err := root.MkdirAll(filepath.Dir(rel), 0755); err != nil {
 			return err
 		}
 
-		dstFile, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, file.Mode())
+		srcFile, err := file.Open()
 		if err != nil {
 			return err
 		}
 
-		srcFile, err := file.Open()
+		if file.Mode()&fs.ModeSymlink != 0 {
+			linkname, err := io.ReadAll(io.LimitReader(srcFile, 4096))
+			srcFile.Close()
+			if err != nil {
+				return err
+			}
+			if err := checkSymlink(rel, string(linkname)); err != nil {
+				return err
+			}
+			root.Remove(rel)
+			if err := root.Symlink(string(linkname), rel); err != nil {
+				return err
+			}
+			continue
+		}
+
+		dstFile, err := root.OpenFile(rel, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, file.Mode().Perm())
 		if err != nil {
-			dstFile.Close()
+			srcFile.Close()
 			return err
 		}
 
@@ -301,33 +1262,139 @@ func extractZip(data []byte, dest string) error {
 	return nil
 }
 
+// extractTarGz extracts an archive into dest, with the same checks as
+// extractZip. Hard links must point at an entry inside dest.
+func extractTarGz(r io.Reader, dest string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rel, err := archiveEntryPath(dest, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := root.MkdirAll(rel, 0755); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := checkSymlink(rel, header.Linkname); err != nil {
+				return err
+			}
+			if err := root.MkdirAll(filepath.Dir(rel), 0755); err != nil {
+				return err
+			}
+			root.Remove(rel)
+			if err := root.Symlink(header.Linkname, rel); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			oldRel, err := archiveEntryPath(dest, header.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := root.MkdirAll(filepath.Dir(rel), 0755); err != nil {
+				return err
+			}
+			root.Remove(rel)
+			if err := root.Link(oldRel, rel); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := root.MkdirAll(filepath.Dir(rel), 0755); err != nil {
+				return err
+			}
+			dstFile, err := root.OpenFile(rel, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(dstFile, tr); err != nil {
+				dstFile.Close()
+				return err
+			}
+			dstFile.Close()
+		}
+	}
+}
+
 func startAPIServer() {
+	c := config()
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/api/models", handleModels)
 	mux.HandleFunc("/api/status", handleStatus)
 	mux.HandleFunc("/api/load", handleLoad)
+	mux.HandleFunc("/api/args", handleArgs)
 	mux.HandleFunc("/api/unload", handleUnload)
+	mux.HandleFunc("/api/test", handleTest)
+	mux.HandleFunc("/api/resolve", handleResolve)
+	mux.HandleFunc("/api/instances", handleInstances)
+	mux.HandleFunc("/api/logs", handleLogs)
+	mux.HandleFunc("/api/resources", handleResources)
+	mux.HandleFunc("/api/gpu", handleGPU)
+	mux.HandleFunc("/api/slots", handleSlots)
+	mux.HandleFunc("/api/downloads", handleDownloads)
+	mux.HandleFunc("/api/downloads/cancel", handleCancelDownload)
 	mux.HandleFunc("/api/health", handleHealth)
-
-	apiServer = &http.Server{
-		Addr:    fmt.Sprintf(":%d", config.BasePort),
-		Handler: corsMiddleware(mux),
-	}
-
-	go func() {
-		log.Printf("API server starting on port %d", config.BasePort)
-		if err := apiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	mux.HandleFunc("/api/activate", handleActivate)
+	mux.HandleFunc("/api/rescan", handleRescan)
+	mux.HandleFunc("/api/config", handleConfig)
+	mux.HandleFunc("/settings", handleSettingsPage)
+	mux.HandleFunc("/api/session", handleSession)
+	mux.HandleFunc("/api/session/restore", handleRestoreSession)
+	mux.HandleFunc("/api/stats", handleStats)
+	mux.HandleFunc("/api/events", handleEvents)
+	mux.HandleFunc("/v1/", handleOpenAI)
+
+	apiListen.host, apiListen.port = c.APIHost, c.BasePort
+	server := &http.Server{
+		Addr:    apiAddress(apiListen.host, apiListen.port),
+		Handler: corsMiddleware(accessMiddleware(authMiddleware(mux))),
+	}
+	if c.TLS {
+		tlsConfig, err := apiTLSConfig()
+		if err != nil {
+			// Falling back to plain HTTP would send keys and prompts in the
+			// clear, so the API stays off instead.
 			log.Printf("API server error: %v", err)
+			notify("API Server Failed", fmt.Sprintf("HTTPS is enabled but the certificate cannot be loaded: %v", err))
+			return
 		}
-	}()
+		server.TLSConfig = tlsConfig
+		apiListen.tls = true
+	}
+	server.RegisterOnShutdown(closeEventStreams)
+	apiServer = server
+	go serveAPI(apiServer)
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -353,12 +1420,21 @@ func handleModels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var models []map[string]interface{}
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    modelList(),
+	})
+}
+
+// modelList returns the /api/models items: one per modelSpecificArgs entry,
+// or one per file without entries.
+func modelList() []map[string]interface{} {
+	models := []map[string]interface{}{}
 	modelIndex := 0
 
-	for i, m := range currentModels {
+	for i, m := range modelSnapshot() {
 		modelConfigs := []ModelConfig{}
-		for _, cfg := range config.ModelSpecificArgs {
+		for _, cfg := range config().ModelSpecificArgs {
 			if cfg.Target == m.BaseName {
 				modelConfigs = append(modelConfigs, cfg)
 			}
@@ -376,6 +1452,13 @@ func handleModels(w http.ResponseWriter, r *http.Request) {
 					"hasConfig":   true,
 					"configName":  cfg.Name,
 				})
+				addModelDetails(models[len(models)-1], m)
+				if aliases := aliasesOf(cfg.Name); len(aliases) > 0 {
+					models[len(models)-1]["aliases"] = aliases
+				}
+				if used := lastUsed(cfg.Name); !used.IsZero() {
+					models[len(models)-1]["lastUsed"] = used.Unix()
+				}
 				modelIndex++
 			}
 		} else {
@@ -388,14 +1471,45 @@ func handleModels(w http.ResponseWriter, r *http.Request) {
 				"filename":    filepath.Base(m.Path),
 				"hasConfig":   false,
 			})
+			addModelDetails(models[len(models)-1], m)
+			if aliases := aliasesOf(m.BaseName); len(aliases) > 0 {
+				models[len(models)-1]["aliases"] = aliases
+			}
+			if used := lastUsed(m.BaseName); !used.IsZero() {
+				models[len(models)-1]["lastUsed"] = used.Unix()
+			}
 			modelIndex++
 		}
 	}
+	return models
+}
 
-	writeJSON(w, http.StatusOK, APIResponse{
-		Success: true,
-		Data:    models,
-	})
+// addModelDetails adds file size, shard count and GGUF header fields to a
+// /api/models item. Header fields are omitted when the file cannot be parsed.
+func addModelDetails(item map[string]interface{}, entry modelEntry) {
+	item["size"] = modelFileSize(entry.Path)
+	item["shards"] = modelShardCount(entry.Path)
+	if info, err := os.Stat(firstShardPath(entry.Path)); err == nil {
+		item["modified"] = info.ModTime().Unix()
+	}
+
+	meta, err := cachedGGUFMetadata(firstShardPath(entry.Path))
+	if err != nil {
+		return
+	}
+	if meta.Architecture != "" {
+		item["architecture"] = meta.Architecture
+	}
+	if quant := meta.Quantization(); quant != "" {
+		item["quantization"] = quant
+	}
+	if params := meta.Parameters(); params != "" {
+		item["parameters"] = params
+	}
+	if meta.ContextLength > 0 {
+		item["contextLength"] = meta.ContextLength
+	}
+	item["hasChatTemplate"] = meta.ChatTemplate != ""
 }
 
 func handleStatus(w http.ResponseWriter, r *http.Request) {
@@ -407,50 +1521,175 @@ func handleStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	runningModelsMu.RLock()
-	defer runningModelsMu.RUnlock()
+	runningModelsMu.RLock()
+	defer runningModelsMu.RUnlock()
+
+	status := ModelStatus{
+		Loaded:     len(runningModels) > 0,
+		ServerPort: config().BasePort,
+		Port:       0,
+		Instances:  instanceStatuses(),
+	}
+
+	if len(runningModels) > 0 {
+		latest := runningModels[len(runningModels)-1]
+		status.Model = latest.entry
+		status.Port = latest.port
+		status.ConfigName = latest.configName
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+func handleInstances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+
+	runningModelsMu.RLock()
+	instances := instanceStatuses()
+	runningModelsMu.RUnlock()
+
+	writeJSON(w, http.StatusOK, APIResponse{
+		Success: true,
+		Data:    instances,
+	})
+}
+
+// instanceStatuses reports the running instances in start order. Callers must
+// hold runningModelsMu.
+func instanceStatuses() []InstanceStatus {
+	statuses := []InstanceStatus{}
+	for _, instance := range runningModels {
+		progress, phase := 100, ""
+		if !instance.ready && instance.progress != nil {
+			progress, _, _ = instance.progress.snapshot()
+			phase = instance.progress.currentPhase()
+		}
+		statuses = append(statuses, InstanceStatus{
+			Name:        displayName(instance),
+			Model:       instance.entry.BaseName,
+			ConfigName:  instance.configName,
+			Path:        instance.entry.Path,
+			Port:        instance.port,
+			InstanceNum: instance.instanceNum,
+			Parallel:    instance.parallel,
+			Preset:      instance.preset,
+			Uptime:      int64(time.Since(instance.startedAt).Seconds()),
+			Healthy:     instance.ready && !instance.unhealthy,
+			Progress:    progress,
+			Phase:       phase,
+
+			StartedAt:       instance.startedAt,
+			ReadyAt:         instance.readyAt,
+			Restarts:        instance.restarts,
+			LastHealthCheck: instance.lastHealthCheck,
+			HealthFailures:  instance.healthFailures,
+		})
+	}
+	return statuses
+}
+
+func handleLoad(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+
+	entry, modelIndex, configIndex, ok := apiModelIndex(w, r)
+	if !ok {
+		return
+	}
+
+	var req LoadRequest
+	if r.Body != nil {
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil && err != io.EOF {
+			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid request body"})
+			return
+		}
+	}
+	// Without API keys anyone who can reach the API, including any web page
+	// open in a browser, could launch llama-server with their arguments.
+	if (req.Args != nil || req.ExtraArgs != nil) && len(config().APIKeys) == 0 && !sameMachineRequest(r) {
+		writeJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "args and extraArgs can only be sent from this machine unless apiKeys are set"})
+		return
+	}
+	configured := applyAutoArgs(entry, configIndex, getModelArgs(entry, configIndex))
+	if err := validateRequestArgs(req.Args, configured); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	if req.ExtraArgs != nil {
+		if req.Args != nil {
+			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "args and extraArgs cannot be combined"})
+			return
+		}
+		if err := validateRequestArgs(req.ExtraArgs, configured); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "extraArgs: " + err.Error()})
+			return
+		}
+		req.Args = mergeArgs(configured, req.ExtraArgs)
+	}
+
+	// new=1 starts another instance of a model that is already running.
+	another, _ := strconv.ParseBool(r.URL.Query().Get("new"))
+	// force=1 loads a model that is estimated not to fit in VRAM.
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
 
-	status := ModelStatus{
-		Loaded:     runningModel != nil,
-		ServerPort: config.BasePort,
-		Port:       0,
+	runningModelsMu.RLock()
+	alreadyLoaded := findInstance(entry.Path, configIndex) != nil
+	runningModelsMu.RUnlock()
+	if alreadyLoaded && !another {
+		if req.Args != nil {
+			writeJSON(w, http.StatusConflict, APIResponse{Success: false, Message: "Model already loaded. Add new=1 to start another instance with these args"})
+			return
+		}
+		writeJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Model already loaded", Data: entry})
+		return
 	}
 
-	if runningModel != nil {
-		status.Model = runningModel.entry
-		status.Port = runningModel.port
-		status.ConfigName = runningModel.configName
+	if err := loadModelWithOptions(modelIndex, configIndex, launchOptions{Args: req.Args, Force: force}); errors.Is(err, errNotEnoughVRAM) {
+		writeJSON(w, http.StatusConflict, APIResponse{Success: false, Message: fmt.Sprintf("Failed to load model: %v. Add force=1 to load it anyway", err)})
+		return
+	} else if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: fmt.Sprintf("Failed to load model: %v", err)})
+		return
 	}
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
-		Data:    status,
+		Message: "Model loaded successfully",
+		Data:    entry,
 	})
 }
 
-func handleLoad(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
-		return
-	}
-
+// apiModelIndex resolves the index query parameter, which counts every
+// configuration of a model as its own entry, to a model and config index and
+// the model they refer to. It writes the error response itself when the index
+// is missing or invalid.
+func apiModelIndex(w http.ResponseWriter, r *http.Request) (modelEntry, int, int, bool) {
 	idxStr := r.URL.Query().Get("index")
 	if idxStr == "" {
 		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Missing index parameter"})
-		return
+		return modelEntry{}, 0, 0, false
 	}
 
 	apiIndex, err := strconv.Atoi(idxStr)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid index"})
-		return
+		return modelEntry{}, 0, 0, false
 	}
 
 	modelIndex, configIndex := -1, -1
 	currentIndex := 0
-	for i, m := range currentModels {
+	models := modelSnapshot()
+	for i, m := range models {
 		modelConfigs := []ModelConfig{}
-		for _, cfg := range config.ModelSpecificArgs {
+		for _, cfg := range config().ModelSpecificArgs {
 			if cfg.Target == m.BaseName {
 				modelConfigs = append(modelConfigs, cfg)
 			}
@@ -476,32 +1715,28 @@ func handleLoad(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 	}
-
-	if modelIndex == -1 || modelIndex >= len(currentModels) {
+	if modelIndex == -1 || modelIndex >= len(models) {
 		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid index"})
-		return
+		return modelEntry{}, 0, 0, false
 	}
+	return models[modelIndex], modelIndex, configIndex, true
+}
 
-	runningModelsMu.RLock()
-	alreadyLoaded := runningModel != nil && 
-		runningModel.entry.Path == currentModels[modelIndex].Path && 
-		runningModel.configIndex == configIndex
-	runningModelsMu.RUnlock()
-	if alreadyLoaded {
-		writeJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Model already loaded", Data: currentModels[modelIndex]})
+// handleArgs returns the llama-server arguments a load of the model at index
+// would use, for clients that let the user edit them before loading.
+func handleArgs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
 		return
 	}
 
-	if err := loadModel(modelIndex, configIndex); err != nil {
-		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: fmt.Sprintf("Failed to load model: %v", err)})
+	entry, _, configIndex, ok := apiModelIndex(w, r)
+	if !ok {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, APIResponse{
-		Success: true,
-		Message: "Model loaded successfully",
-		Data:    currentModels[modelIndex],
-	})
+	args := applyAutoArgs(entry, configIndex, getModelArgs(entry, configIndex))
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: map[string]interface{}{"args": args}})
 }
 
 func handleUnload(w http.ResponseWriter, r *http.Request) {
@@ -513,8 +1748,25 @@ func handleUnload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if portStr := r.URL.Query().Get("port"); portStr != "" {
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid port"})
+			return
+		}
+		if !unloadInstance(port) {
+			writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: fmt.Sprintf("No instance running on port %d", port)})
+			return
+		}
+		writeJSON(w, http.StatusOK, APIResponse{
+			Success: true,
+			Message: fmt.Sprintf("Instance on port %d unloaded", port),
+		})
+		return
+	}
+
 	runningModelsMu.RLock()
-	isLoaded := runningModel != nil
+	isLoaded := len(runningModels) > 0
 	runningModelsMu.RUnlock()
 
 	if !isLoaded {
@@ -525,11 +1777,11 @@ func handleUnload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	unloadModel()
+	unloadAllModels()
 
 	writeJSON(w, http.StatusOK, APIResponse{
 		Success: true,
-		Message: "Model unloaded",
+		Message: "All models unloaded",
 	})
 }
 
@@ -539,9 +1791,63 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func handleRescan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+
+	if err := refreshConfigAndModels(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: fmt.Sprintf("Rescan failed: %v", err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Message: fmt.Sprintf("Found %d models", len(modelSnapshot()))})
+}
+
+func handleActivate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+
+	var req ActivateRequest
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid request body"})
+			return
+		}
+	}
+
+	if len(req.Load) > 0 {
+		go loadModelsByName(req.Load)
+	} else {
+		notify("lmgo", "lmgo is already running. Use the tray icon to manage models.")
+	}
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Message: "Activated"})
+}
+
+// modelSpecificConfig returns the modelSpecificArgs entry used for a launch,
+// or nil when the default arguments apply.
+func modelSpecificConfig(entry modelEntry, configIndex int) *ModelConfig {
+	var matchingConfigs []ModelConfig
+	for _, cfg := range config().ModelSpecificArgs {
+		if cfg.Target == entry.BaseName {
+			matchingConfigs = append(matchingConfigs, cfg)
+		}
+	}
+	if len(matchingConfigs) == 0 {
+		return nil
+	}
+	if configIndex >= 0 && configIndex < len(matchingConfigs) {
+		return &matchingConfigs[configIndex]
+	}
+	return &matchingConfigs[0]
+}
+
 func getModelArgs(entry modelEntry, configIndex int) []string {
 	var matchingConfigs []ModelConfig
-	for _, cfg := range config.ModelSpecificArgs {
+	for _, cfg := range config().ModelSpecificArgs {
 		if cfg.Target == entry.BaseName {
 			matchingConfigs = append(matchingConfigs, cfg)
 		}
@@ -550,36 +1856,188 @@ func getModelArgs(entry modelEntry, configIndex int) []string {
 	if len(matchingConfigs) > 0 {
 		if configIndex >= 0 && configIndex < len(matchingConfigs) {
 			log.Printf("Using config '%s' for %s", matchingConfigs[configIndex].Name, entry.BaseName)
-			return matchingConfigs[configIndex].Args
+			return entryArgs(matchingConfigs[configIndex])
 		} else if len(matchingConfigs) > 0 {
 			log.Printf("Using first config '%s' for %s", matchingConfigs[0].Name, entry.BaseName)
-			return matchingConfigs[0].Args
+			return entryArgs(matchingConfigs[0])
 		}
 	}
 
 	log.Printf("Using default config for %s", entry.BaseName)
-	return config.DefaultArgs
+	return config().DefaultArgs
+}
+
+var shardPattern = regexp.MustCompile(`(?i)^(.*)-(\d{5})-of-(\d{5})\.gguf$`)
+
+// firstShardPath returns the path of the first shard when path points at a
+// split GGUF file ("name-00003-of-00005.gguf"), and path unchanged otherwise.
+func firstShardPath(path string) string {
+	m := shardPattern.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return path
+	}
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf("%s-00001-of-%s.gguf", m[1], m[3]))
+}
+
+// notify logs the message and shows a desktop notification. In headless mode
+// notifications are only logged.
+func notify(title, message string) {
+	log.Printf("Notification: %s - %s", title, message)
+	if headless || !notificationsEnabled() {
+		return
+	}
+
+	if err := showNotification(title, message); err != nil {
+		log.Printf("Failed to show notification: %v", err)
+	}
 }
 
-func openBrowser(url string) error {
-	return exec.Command("cmd", "/c", "start", url).Start()
+func openModelFolder(entry modelEntry) {
+	path := firstShardPath(entry.Path)
+	if _, err := os.Stat(path); err != nil {
+		log.Printf("Model file not found: %s: %v", path, err)
+		notify("Model Not Found", fmt.Sprintf("%s no longer exists. Use Rescan Models to pick up new files.", filepath.Base(path)))
+		return
+	}
+
+	if err := revealInFileManager(path); err != nil {
+		log.Printf("Failed to open folder for %s: %v", path, err)
+		notify("Open Folder Failed", fmt.Sprintf("Could not open the file manager for %s: %v", filepath.Base(path), err))
+	}
 }
 
-func getConsoleWindow() syscall.Handle {
-	kernel32 := syscall.NewLazyDLL("kernel32.dll")
-	proc := kernel32.NewProc("GetConsoleWindow")
-	ret, _, _ := proc.Call()
-	return syscall.Handle(ret)
+var (
+	suspendMu       sync.Mutex
+	suspended       bool
+	suspendedModels []*modelInstance
+)
+
+func handleSuspend() {
+	suspendMu.Lock()
+	defer suspendMu.Unlock()
+
+	if suspended {
+		return
+	}
+	suspended = true
+	// Restarts waiting out a crash backoff would relaunch a model while the
+	// machine sleeps.
+	stopAllCount.Add(1)
+
+	// The instances are taken off the list before they are stopped, as in
+	// stopAllModels, so the API and the tray are not blocked meanwhile.
+	// suspendMu stays held so a resume waits for the stops to finish.
+	unload := config().UnloadOnSuspend
+	runningModelsMu.Lock()
+	count := len(runningModels)
+	if count > 0 && unload {
+		suspendedModels = runningModels
+		runningModels = nil
+	}
+	runningModelsMu.Unlock()
+
+	if count == 0 {
+		log.Printf("System suspending, no model running")
+		return
+	}
+
+	if !unload {
+		log.Printf("System suspending, %d instance(s) will be health-checked on resume", count)
+		return
+	}
+
+	for _, instance := range suspendedModels {
+		log.Printf("System suspending, unloading %s (port %d)", instance.entry.BaseName, instance.port)
+		stopModelInstance(instance)
+	}
 }
 
-func hideConsole() {
-	hwnd := getConsoleWindow()
-	if hwnd == 0 {
+func handleResume() {
+	suspendMu.Lock()
+	if !suspended {
+		suspendMu.Unlock()
 		return
 	}
-	user32 := syscall.NewLazyDLL("user32.dll")
-	showWindow := user32.NewProc("ShowWindow")
-	showWindow.Call(uintptr(hwnd), uintptr(0))
+	suspended = false
+	restore := suspendedModels
+	suspendedModels = nil
+	suspendMu.Unlock()
+
+	log.Printf("System resumed")
+
+	runningModelsMu.RLock()
+	instances := append([]*modelInstance(nil), runningModels...)
+	runningModelsMu.RUnlock()
+
+	var summaries []string
+	for _, instance := range restore {
+		summaries = append(summaries, restartInstance(instance, "Restored"))
+	}
+	for _, instance := range instances {
+		if checkInstanceHealth(instance.port) {
+			log.Printf("Model %s (port %d) is healthy after resume", instance.entry.BaseName, instance.port)
+			summaries = append(summaries, fmt.Sprintf("%s is still running", instance.entry.BaseName))
+			continue
+		}
+		log.Printf("Model %s (port %d) failed health check after resume, restarting", instance.entry.BaseName, instance.port)
+		unloadInstance(instance.port)
+		summaries = append(summaries, restartInstance(instance, "Restarted"))
+	}
+
+	refreshMenuState()
+	if len(summaries) > 0 {
+		notify("System Resumed", strings.Join(summaries, "\n"))
+	}
+}
+
+// restartInstance loads the model of a previous instance again with the same
+// configuration and returns a one-line summary for the resume notification.
+func restartInstance(instance *modelInstance, verb string) string {
+	idx := findModelIndexByPath(instance.entry.Path)
+	if idx < 0 {
+		log.Printf("Model %s is no longer available", instance.entry.BaseName)
+		return fmt.Sprintf("%s is no longer available", instance.entry.BaseName)
+	}
+
+	opts := launchOptions{Parallel: instance.parallel, Preset: instance.preset, Args: instance.customArgs}
+	if err := loadModelWithOptions(idx, instance.configIndex, opts); err != nil {
+		log.Printf("Failed to restart %s after resume: %v", instance.entry.BaseName, err)
+		return fmt.Sprintf("Failed to restart %s: %v", instance.entry.BaseName, err)
+	}
+
+	log.Printf("%s %s after resume", verb, instance.entry.BaseName)
+	return fmt.Sprintf("%s %s", verb, instance.entry.BaseName)
+}
+
+func findModelIndexByPath(path string) int {
+	for i, m := range modelSnapshot() {
+		if m.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkInstanceHealth polls llama-server's /health endpoint a few times,
+// giving the GPU driver a moment to come back after wake.
+func checkInstanceHealth(port int) bool {
+	client := &http.Client{Timeout: 5 * time.Second}
+	url := fmt.Sprintf("http://127.0.0.1:%d/health", port)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			time.Sleep(2 * time.Second)
+		}
+		resp, err := client.Get(url)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return true
+		}
+	}
+	return false
 }
 
 func onReady() {
@@ -590,73 +2048,69 @@ func onReady() {
 	buildMenuOnce()
 	refreshMenuState()
 
-	log.Printf("Started. Found %d models. API available at http://localhost:%d/api", len(currentModels), config.BasePort)
+	log.Printf("Started. Found %d models. API available at %s/api", len(modelSnapshot()), apiBaseURL())
 }
 
 func buildMenuOnce() {
 	menuItems.loadModel = systray.AddMenuItem("Load Model", "Select a model to load")
 
-	menuItems.models = []*systray.MenuItem{}
-	menuItems.modelConfigs = [][]*systray.MenuItem{}
-
-	for i := 0; i < len(currentModels); i++ {
-		m := currentModels[i]
-
-		modelConfigs := []ModelConfig{}
-		for _, cfg := range config.ModelSpecificArgs {
-			if cfg.Target == m.BaseName {
-				modelConfigs = append(modelConfigs, cfg)
-			}
-		}
-
-		if len(modelConfigs) > 0 {
-			for configIdx, cfg := range modelConfigs {
-				item := menuItems.loadModel.AddSubMenuItem(cfg.Name, "")
-				menuItems.models = append(menuItems.models, item)
-
-				go func(modelIdx int, cfgIdx int, menuItem *systray.MenuItem) {
-					for range menuItem.ClickedCh {
-						loadModel(modelIdx, cfgIdx)
-					}
-				}(i, configIdx, item)
-			}
-		} else {
-			item := menuItems.loadModel.AddSubMenuItem(m.BaseName, "")
-			menuItems.models = append(menuItems.models, item)
+	addSortMenu()
+	addRecentMenu()
+	addModelMenuItems()
 
-			go func(modelIdx int, menuItem *systray.MenuItem) {
-				for range menuItem.ClickedCh {
-					loadModel(modelIdx, -1)
-				}
-			}(i, item)
+	menuItems.restoreSession = systray.AddMenuItem("Restore Previous Session", "Load the models that were running when lmgo last exited")
+	menuItems.restoreSession.Hide()
+	go func() {
+		for range menuItems.restoreSession.ClickedCh {
+			go restoreSessionFromMenu()
 		}
-	}
+	}()
 
-	menuItems.unloadModel = systray.AddMenuItem("Unload Model", "Unload the model")
+	menuItems.unloadModel = systray.AddMenuItem("Unload Model", "Unload a running instance")
 	menuItems.unloadModel.Disable()
+	menuItems.unloadAll = menuItems.unloadModel.AddSubMenuItem("Unload All", "Unload every running instance")
 	go func() {
-		for range menuItems.unloadModel.ClickedCh {
-			unloadModel()
+		for range menuItems.unloadAll.ClickedCh {
+			unloadAllModels()
 		}
 	}()
 
 	menuItems.webInterface = systray.AddMenuItem("Web Interface", "Open web interface")
 	menuItems.webInterface.Disable()
+
+	menuItems.test = systray.AddMenuItem("Test", "Check that a model answers and how fast")
+	menuItems.test.Disable()
+
+	menuItems.openFolder = systray.AddMenuItem("Open Model Folder", "Show a model file in Explorer")
+	addOpenFolderItems()
+
+	menuItems.viewLogs = systray.AddMenuItem("View Logs", "Open the output of an instance")
+	menuItems.openLogs = menuItems.viewLogs.AddSubMenuItem("Open Logs Folder", "Show the llama-server log files")
 	go func() {
-		for range menuItems.webInterface.ClickedCh {
-			openCurrentModelWebInterface()
+		for range menuItems.openLogs.ClickedCh {
+			openLogsFolder()
 		}
 	}()
 
 	menuItems.autoStart = systray.AddMenuItem("Auto Startup", "Toggle auto-start on boot")
 	go func() {
 		for range menuItems.autoStart.ClickedCh {
-			config.AutoStartEnabled = !config.AutoStartEnabled
+			if autoStartStale {
+				if err := setAutoStart(true); err != nil {
+					log.Printf("Failed to repair auto-start: %v", err)
+				} else {
+					log.Printf("Auto-start entry repaired")
+					autoStartStale = false
+					refreshMenuState()
+				}
+				continue
+			}
 
-			if err := setAutoStart(config.AutoStartEnabled); err != nil {
+			enable := !config().AutoStartEnabled
+			if err := setAutoStart(enable); err != nil {
 				log.Printf("Failed to update auto-start: %v", err)
-				config.AutoStartEnabled = !config.AutoStartEnabled
 			} else {
+				updateConfig(func(c *Config) { c.AutoStartEnabled = enable })
 				if err := saveConfig(); err != nil {
 					log.Printf("Failed to save config: %v", err)
 				}
@@ -665,10 +2119,26 @@ func buildMenuOnce() {
 		}
 	}()
 
-	menuItems.refresh = systray.AddMenuItem("Refresh", "Reload config and rescan models")
+	menuItems.lanAccess = systray.AddMenuItem("Allow LAN Access", "Let other machines reach the API and the web interfaces")
+	go func() {
+		for range menuItems.lanAccess.ClickedCh {
+			setLANAccess(!lanAccessAllowed())
+		}
+	}()
+
+	menuItems.settings = systray.AddMenuItem("Edit Settings", "Edit lmgo.json in the browser")
+	go func() {
+		for range menuItems.settings.ClickedCh {
+			openSettings()
+		}
+	}()
+
+	menuItems.rescan = systray.AddMenuItem("Rescan Models", "Reload config and rescan the model directory")
 	go func() {
-		for range menuItems.refresh.ClickedCh {
-			refreshConfigAndModels()
+		for range menuItems.rescan.ClickedCh {
+			if err := refreshConfigAndModels(); err != nil {
+				notify("Rescan Failed", err.Error())
+			}
 		}
 	}()
 
@@ -682,23 +2152,175 @@ func buildMenuOnce() {
 	}()
 }
 
-func refreshMenuState() {
-	runningModelsMu.RLock()
-	hasRunningModel := runningModel != nil
-	runningModelsMu.RUnlock()
+// addModelMenuItems adds the "Load Model" entries, after the favorites, in
+// the order of menuSort and in the submenus of menuGroup. refreshMenuState
+// keeps to that order when it updates their titles. The entries of the
+// previous build are hidden since tray items cannot be removed.
+func addModelMenuItems() {
+	for _, item := range menuItems.models {
+		item.Hide()
+	}
+	addFavoriteMenuItems()
 
-	if hasRunningModel {
-		menuItems.unloadModel.Enable()
-		menuItems.webInterface.Enable()
-	} else {
-		menuItems.unloadModel.Disable()
-		menuItems.webInterface.Disable()
+	menuItems.models = []*systray.MenuItem{}
+	menuItems.modelConfigs = [][]*systray.MenuItem{}
+	menuItems.modelOrder = modelOrder()
+	groups := addGroupMenus(menuItems.modelOrder)
+
+	models := modelSnapshot()
+	for _, i := range menuItems.modelOrder {
+		m := models[i]
+		parent := menuParent(m, groups)
+
+		modelConfigs := []ModelConfig{}
+		for _, cfg := range config().ModelSpecificArgs {
+			if cfg.Target == m.BaseName {
+				modelConfigs = append(modelConfigs, cfg)
+			}
+		}
+
+		if len(modelConfigs) > 0 {
+			for configIdx, cfg := range modelConfigs {
+				menuItems.models = append(menuItems.models, addLoadMenuItem(parent, cfg.Name, i, configIdx))
+			}
+		} else {
+			menuItems.models = append(menuItems.models, addLoadMenuItem(parent, m.BaseName, i, -1))
+		}
+	}
+}
+
+// addLoadMenuItem adds one entry under "Load Model" or one of its groups: a
+// submenu offering the default launch, one item per preset and slot count,
+// custom arguments, pinning the model to the favorites and loading it on
+// startup.
+func addLoadMenuItem(parent *systray.MenuItem, title string, modelIdx int, cfgIdx int) *systray.MenuItem {
+	c := config()
+	item := parent.AddSubMenuItem(title, "")
+
+	children := []*systray.MenuItem{}
+	addChoice := func(title, tooltip string, opts launchOptions) {
+		child := item.AddSubMenuItem(title, tooltip)
+		children = append(children, child)
+		go func() {
+			for range child.ClickedCh {
+				loadModelWithOptions(modelIdx, cfgIdx, opts)
+			}
+		}()
+	}
+
+	addChoice("Default", "Load with the configured arguments", launchOptions{FromTray: true})
+	for _, name := range slices.Sorted(maps.Keys(c.Presets)) {
+		addChoice(name, "Load with "+joinArgs(c.Presets[name]), launchOptions{Preset: name, FromTray: true})
+	}
+	for _, slots := range c.ParallelPresets {
+		if slots < 1 {
+			continue
+		}
+		addChoice(slotsLabel(slots), fmt.Sprintf("Load with -np %d", slots), launchOptions{Parallel: slots, FromTray: true})
+	}
+
+	custom := item.AddSubMenuItem("Custom…", "Edit the arguments for this launch")
+	children = append(children, custom)
+	go func() {
+		for range custom.ClickedCh {
+			loadWithCustomArgs(modelIdx, cfgIdx)
+		}
+	}()
+
+	favoriteTitle := "Add to Favorites"
+	if isFavorite(title) {
+		favoriteTitle = "Remove from Favorites"
+	}
+	favorite := item.AddSubMenuItem(favoriteTitle, "Pin this model to the top of Load Model")
+	children = append(children, favorite)
+	go func() {
+		for range favorite.ClickedCh {
+			toggleFavorite(title)
+		}
+	}()
+
+	autoLoadTitle := "Load on Startup"
+	if isAutoLoaded(title) {
+		autoLoadTitle = "✓ " + autoLoadTitle
+	}
+	autoLoad := item.AddSubMenuItem(autoLoadTitle, "Add this model to autoLoadModels")
+	children = append(children, autoLoad)
+	go func() {
+		for range autoLoad.ClickedCh {
+			toggleAutoLoad(title)
+		}
+	}()
+	menuItems.modelConfigs = append(menuItems.modelConfigs, children)
+	return item
+}
+
+// loadWithCustomArgs asks for the arguments of one launch, starting from the
+// ones the model would be loaded with.
+func loadWithCustomArgs(modelIdx int, cfgIdx int) {
+	entry, ok := modelAt(modelIdx)
+	if !ok {
+		return
+	}
+	initial := joinArgs(applyAutoArgs(entry, cfgIdx, getModelArgs(entry, cfgIdx)))
+	line, ok := promptText("lmgo", "Arguments for "+entry.BaseName+":", initial)
+	if !ok {
+		return
+	}
+	args, err := parseArgs(line)
+	if err == nil {
+		err = validateArgsOverride(args)
+	}
+	if err != nil {
+		notify("Invalid Arguments", err.Error())
+		return
+	}
+	loadModelWithOptions(modelIdx, cfgIdx, launchOptions{Args: args, FromTray: true})
+}
+
+func slotsLabel(slots int) string {
+	if slots == 1 {
+		return "1 slot"
+	}
+	return fmt.Sprintf("%d slots", slots)
+}
+
+func addOpenFolderItems() {
+	menuItems.folders = []*systray.MenuItem{}
+
+	for _, m := range modelSnapshot() {
+		item := menuItems.openFolder.AddSubMenuItem(m.BaseName, fmt.Sprintf("Show %s in Explorer", filepath.Base(m.Path)))
+		menuItems.folders = append(menuItems.folders, item)
+
+		go func(entry modelEntry, menuItem *systray.MenuItem) {
+			for range menuItem.ClickedCh {
+				openModelFolder(entry)
+			}
+		}(m, item)
+	}
+}
+
+func refreshMenuState() {
+	if headless {
+		return
 	}
 
+	refreshInstanceMenu()
+	refreshLogMenu()
+	refreshSortMenu()
+
+	runningModelsMu.RLock()
+	refreshFavoriteAndRecentMenus()
+	runningModelsMu.RUnlock()
+
 	menuItemIndex := 0
-	for _, m := range currentModels {
+	models := modelSnapshot()
+	for _, i := range menuItems.modelOrder {
+		if i >= len(models) {
+			continue
+		}
+		m := models[i]
 		modelConfigs := []ModelConfig{}
-		for _, cfg := range config.ModelSpecificArgs {
+		for _, cfg := range config().ModelSpecificArgs {
 			if cfg.Target == m.BaseName {
 				modelConfigs = append(modelConfigs, cfg)
 			}
@@ -710,18 +2332,9 @@ func refreshMenuState() {
 					item := menuItems.models[menuItemIndex]
 
 					runningModelsMu.RLock()
-					isCurrent := hasRunningModel &&
-						runningModel.entry.Path == m.Path &&
-						runningModel.configIndex == configIdx
+					title := modelMenuTitle(cfg.Name, m.Path, configIdx)
 					runningModelsMu.RUnlock()
 
-					title := cfg.Name
-					if isCurrent {
-						title = "● " + title
-					} else {
-						title = "○ " + title
-					}
-
 					item.SetTitle(title)
 					item.SetTooltip(fmt.Sprintf("Load %s with %s", m.BaseName, cfg.Name))
 					item.Show()
@@ -733,16 +2346,9 @@ func refreshMenuState() {
 				item := menuItems.models[menuItemIndex]
 
 				runningModelsMu.RLock()
-				isCurrent := hasRunningModel && runningModel.entry.Path == m.Path
+				title := modelMenuTitle(m.BaseName, m.Path, -1)
 				runningModelsMu.RUnlock()
 
-				title := m.BaseName
-				if isCurrent {
-					title = "● " + title
-				} else {
-					title = "○ " + title
-				}
-
 				item.SetTitle(title)
 				item.SetTooltip(fmt.Sprintf("Load %s", m.BaseName))
 				item.Show()
@@ -755,52 +2361,270 @@ func refreshMenuState() {
 		menuItems.models[j].Hide()
 	}
 
-	if config.AutoStartEnabled {
+	if hasPreviousSession() {
+		menuItems.restoreSession.Show()
+	} else {
+		menuItems.restoreSession.Hide()
+	}
+
+	if autoStartStale {
+		menuItems.autoStart.SetTitle("⚠ Auto Startup (click to repair)")
+	} else if config().AutoStartEnabled {
 		menuItems.autoStart.SetTitle("✓ Auto Startup")
 	} else {
 		menuItems.autoStart.SetTitle("Auto Startup")
 	}
+
+	if lanAccessAllowed() {
+		menuItems.lanAccess.SetTitle("✓ Allow LAN Access")
+	} else {
+		menuItems.lanAccess.SetTitle("Allow LAN Access")
+	}
+}
+
+// modelMenuTitle prefixes a "Load Model" entry with its state: ○ when not
+// running, ● when running, with the loading progress of a starting instance
+// and the instance count when more than one is running. Aliases, the
+// quantization and size follow the name. Callers must hold runningModelsMu.
+func modelMenuTitle(title string, path string, configIndex int) string {
+	aliases := aliasesOf(title)
+	count := 0
+	loadingLabel := ""
+	for _, instance := range runningModels {
+		if instance.entry.Path != path || instance.configIndex != configIndex {
+			continue
+		}
+		count++
+		if !instance.ready && instance.progress != nil {
+			loadingLabel = instance.progress.label()
+		}
+	}
+
+	switch {
+	case count == 0:
+		title = "○ " + title
+	case loadingLabel != "":
+		title = fmt.Sprintf("● [%s] %s", loadingLabel, title)
+	default:
+		title = "● " + title
+	}
+	if count > 1 {
+		title += fmt.Sprintf(" (%d)", count)
+	}
+	if len(aliases) > 0 {
+		title += " = " + strings.Join(aliases, ", ")
+	}
+	if summary := modelSummary(path); summary != "" {
+		title += "  ·  " + summary
+	}
+	return title
+}
+
+// refreshInstanceMenu lists the running instances under "Unload Model" and
+// "Web Interface", adding slots when more instances run than ever before.
+// instanceSlotPort returns the port of the instance the slot at idx stands
+// for at the time of the click.
+func instanceSlotPort(idx int) int {
+	menuMu.Lock()
+	defer menuMu.Unlock()
+	return menuItems.instances[idx].port
 }
 
-func openCurrentModelWebInterface() {
+func refreshInstanceMenu() {
 	runningModelsMu.RLock()
-	defer runningModelsMu.RUnlock()
+	instances := append([]*modelInstance(nil), runningModels...)
+	titles := make([]string, len(instances))
+	unloadTitles := make([]string, len(instances))
+	for i, instance := range instances {
+		titles[i] = instanceTitle(instance)
+		unloadTitles[i] = titles[i]
+		if details := instanceDetails(instance); details != "" {
+			unloadTitles[i] += "  ·  " + details
+		}
+	}
+	runningModelsMu.RUnlock()
+
+	menuMu.Lock()
+	defer menuMu.Unlock()
+	for len(menuItems.instances) < len(instances) {
+		slot := &instanceMenuSlot{
+			unload: menuItems.unloadModel.AddSubMenuItem("", "Unload this instance"),
+			web:    menuItems.webInterface.AddSubMenuItem("", "Open the web interface of this instance"),
+			test:   menuItems.test.AddSubMenuItem("", "Send a short request and report the first-token latency and tokens/s"),
+		}
+		menuItems.instances = append(menuItems.instances, *slot)
+		idx := len(menuItems.instances) - 1
+		go func() {
+			for range slot.unload.ClickedCh {
+				unloadInstance(instanceSlotPort(idx))
+			}
+		}()
+		go func() {
+			for range slot.web.ClickedCh {
+				openURL(fmt.Sprintf("http://127.0.0.1:%d", instanceSlotPort(idx)))
+			}
+		}()
+		go func() {
+			for range slot.test.ClickedCh {
+				go testFromMenu(instanceSlotPort(idx))
+			}
+		}()
+	}
+
+	for i := range menuItems.instances {
+		slot := &menuItems.instances[i]
+		if i < len(instances) {
+			slot.port = instances[i].port
+			slot.unload.SetTitle(unloadTitles[i])
+			slot.web.SetTitle(titles[i])
+			slot.test.SetTitle(titles[i])
+			slot.unload.Show()
+			slot.web.Show()
+			slot.test.Show()
+		} else {
+			slot.port = 0
+			slot.unload.Hide()
+			slot.web.Hide()
+			slot.test.Hide()
+		}
+	}
+
+	if len(instances) > 0 {
+		menuItems.unloadModel.Enable()
+		menuItems.webInterface.Enable()
+		menuItems.test.Enable()
+	} else {
+		menuItems.unloadModel.Disable()
+		menuItems.webInterface.Disable()
+		menuItems.test.Disable()
+	}
+}
+
+// openURL opens url with browserCommand when configured, or with the system
+// default browser otherwise.
+func openURL(url string) {
+	c := config()
+	if c.BrowserCommand == "" {
+		if err := openBrowser(url); err != nil {
+			log.Printf("Failed to open browser for %s: %v", url, err)
+		}
+		return
+	}
+
+	args := expandBrowserCommand(c.BrowserCommand, url)
+	if len(args) == 0 {
+		log.Printf("Invalid browserCommand %q", c.BrowserCommand)
+		return
+	}
 
-	if runningModel == nil {
+	cmd := exec.Command(args[0], args[1:]...)
+	if err := cmd.Start(); err != nil {
+		log.Printf("Failed to run browser command %q: %v", args, err)
 		return
 	}
+	go cmd.Wait()
+}
+
+// expandBrowserCommand splits a browserCommand template into arguments,
+// honoring double quotes, and substitutes {url}. When the template has no
+// placeholder the URL is appended as the last argument.
+func expandBrowserCommand(template, url string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes, hasArg, substituted := false, false, false
+
+	flush := func() {
+		if hasArg {
+			arg := current.String()
+			if strings.Contains(arg, "{url}") {
+				arg = strings.ReplaceAll(arg, "{url}", url)
+				substituted = true
+			}
+			args = append(args, arg)
+		}
+		current.Reset()
+		hasArg = false
+	}
+
+	for _, r := range template {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			hasArg = true
+		case (r == ' ' || r == '\t') && !inQuotes:
+			flush()
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	flush()
 
-	url := fmt.Sprintf("http://127.0.0.1:%d", runningModel.port)
-	if err := openBrowser(url); err != nil {
-		log.Printf("Failed to open browser: %v", err)
+	if len(args) > 0 && !substituted {
+		args = append(args, url)
 	}
+	return args
 }
 
 func loadModel(idx int, configIndex int) error {
-	if idx < 0 || idx >= len(currentModels) {
+	return loadModelWithOptions(idx, configIndex, launchOptions{})
+}
+
+func loadModelWithOptions(idx int, configIndex int, opts launchOptions) error {
+	entry, ok := modelAt(idx)
+	if !ok {
 		return fmt.Errorf("invalid model index")
 	}
 
 	if err := loadConfig(); err != nil {
 		log.Printf("Warning: Failed to reload config: %v", err)
 	}
+	c := config()
 
-	entry := currentModels[idx]
-
-	runningModelsMu.Lock()
-	if runningModel != nil {
-		stopModelInstance(runningModel)
-		runningModel = nil
+	modelArgs := getModelArgs(entry, configIndex)
+	modelArgs = applyAutoArgs(entry, configIndex, modelArgs)
+	if opts.Args != nil {
+		modelArgs = opts.Args
+	}
+	if opts.Preset != "" {
+		modelArgs = mergeArgs(modelArgs, c.Presets[opts.Preset])
+	}
+	if opts.Parallel > 0 {
+		modelArgs = mergeArgs(modelArgs, parallelArgs(opts.Parallel))
+	}
+	if host := serverHost(entry, configIndex); host != "" {
+		modelArgs = mergeArgs(modelArgs, []string{"--host", host})
+	}
+	// The hook runs first as it may free VRAM, e.g. by stopping a game.
+	if hook := preLoadHook(entry, configIndex); hook != "" {
+		name := entry.BaseName
+		if cfg := modelSpecificConfig(entry, configIndex); cfg != nil {
+			name = cfg.Name
+		}
+		if err := runHook("preLoadHook", hook, name, entry, 0); err != nil {
+			notify("Model Load Failed", fmt.Sprintf("%s: %v", name, err))
+			return err
+		}
+	}
+	// The check may ask the user, so it runs before runningModelsMu is held.
+	if err := checkVRAM(entry, modelArgs, opts); err != nil {
+		return err
 	}
 
+	runningModelsMu.Lock()
 	instance := &modelInstance{
 		entry:       entry,
-		port:        config.LlamaServerPort,
+		instanceNum: nextInstanceNum(entry.Path, configIndex),
 		configIndex: configIndex,
+		parallel:    opts.Parallel,
+		preset:      opts.Preset,
+		customArgs:  opts.Args,
+		startedAt:   time.Now(),
+		restarts:    opts.Restarts,
 	}
 	if configIndex >= 0 {
 		var matchingConfigs []ModelConfig
-		for _, cfg := range config.ModelSpecificArgs {
+		for _, cfg := range c.ModelSpecificArgs {
 			if cfg.Target == entry.BaseName {
 				matchingConfigs = append(matchingConfigs, cfg)
 			}
@@ -809,70 +2633,307 @@ func loadModel(idx int, configIndex int) error {
 			instance.configName = matchingConfigs[configIndex].Name
 		}
 	}
+	port, err := opts.Port, error(nil)
+	if port == 0 || !preferredPortFree(instance, port) {
+		port, err = allocatePort(instance)
+	}
+	if err != nil {
+		runningModelsMu.Unlock()
+		log.Printf("Cannot load %s: %v", displayName(instance), err)
+		notify("Model Load Failed", fmt.Sprintf("%s: %v", displayName(instance), err))
+		return err
+	}
+	instance.port = port
 
 	args := []string{
 		"-m", instance.entry.Path,
 		"--port", strconv.Itoa(instance.port),
 	}
-	modelArgs := getModelArgs(instance.entry, instance.configIndex)
 	args = append(args, modelArgs...)
 
 	log.Printf("Starting model %s on port %d", filepath.Base(instance.entry.Path), instance.port)
 
+	instance.progress = newLoadProgress()
+	logs := newLogBuffer()
+	registerLogSource(instance, logs)
+
+	var file io.Writer = io.Discard
+	logFile, err := openLogFile(instance, args)
+	if err != nil {
+		log.Printf("Warning: Failed to open the log file of port %d: %v", instance.port, err)
+	} else {
+		file = logFile
+	}
+
 	cmd := exec.Command(serverPath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	cmd.Stdout = io.MultiWriter(os.Stdout, instance.progress.writer(), logs.writer(), file)
+	cmd.Stderr = io.MultiWriter(os.Stderr, instance.progress.writer(), logs.writer(), file)
+	cmd.Env = append(serverEnv(), serverAPIKeyEnv()...)
+	hideWindow(cmd)
 
 	if err := cmd.Start(); err != nil {
 		runningModelsMu.Unlock()
+		if logFile != nil {
+			fmt.Fprintf(logFile, "failed to start llama-server: %v\n", err)
+			logFile.Close()
+		}
+		notify("Model Load Failed", fmt.Sprintf("%s: failed to start llama-server: %v", entry.BaseName, err))
 		return fmt.Errorf("failed to start llama-server: %v", err)
 	}
 
+	if err := bindToParent(cmd); err != nil {
+		log.Printf("Warning: llama-server on port %d may outlive lmgo: %v", instance.port, err)
+	}
+
 	instance.cmd = cmd
-	runningModel = instance
+	runningModels = append(runningModels, instance)
 	runningModelsMu.Unlock()
+	refreshMenuState()
+
+	exited := make(chan error, 1)
+	hooksRunning.Add(1)
+	go func() {
+		defer hooksRunning.Done()
+		err := cmd.Wait()
+		if logFile != nil {
+			logFile.Close()
+		}
+		exited <- err
+		runPostUnloadHook(instance)
+	}()
+
+	loadDone := make(chan struct{})
+	go showLoadProgress(instance, loadDone)
+
+	err = waitForModelLoad(instance, exited)
+	close(loadDone)
+	setTooltip(idleTooltip())
 
-	if err := waitForModelLoad(instance); err != nil {
-		runningModelsMu.Lock()
-		if runningModel == instance {
+	if err != nil {
+		if errors.Is(err, errServerExited) {
+			removeInstance(instance)
+			lines, _ := logs.tail(failureLines)
+			if reason := failureReason(lines); reason != "" {
+				err = fmt.Errorf("%v: %s", err, reason)
+			}
+		} else if removeInstance(instance) {
 			stopModelInstance(instance)
-			runningModel = nil
 		}
-		runningModelsMu.Unlock()
+		refreshMenuState()
+		notify("Model Load Failed", fmt.Sprintf("%s: %v", instance.entry.BaseName, err))
+		publishEvent(instanceEvent(eventLoadFailed, instance, err.Error()))
 		return err
 	}
 
+	runningModelsMu.Lock()
+	instance.ready = true
+	instance.readyAt = time.Now()
+	instance.lastUsed = instance.readyAt
+	runningModelsMu.Unlock()
+	saveSession()
+	recordLoad(displayName(instance))
+	recordRecent(displayName(instance))
+
+	_, _, elapsed := instance.progress.snapshot()
+	log.Printf("Model %s loaded in %ds", instance.entry.BaseName, int(elapsed.Seconds()))
+	message := fmt.Sprintf("%s loaded in %ds on port %d", displayName(instance), int(elapsed.Seconds()), instance.port)
+	notify("Model Loaded Successfully", message)
+	fireWebhooks(eventModelLoaded, "Model Loaded", message)
+	publishEvent(instanceEvent(eventModelLoaded, instance, message))
+
 	go func() {
-		err := cmd.Wait()
+		err := <-exited
 		if err != nil {
 			log.Printf("llama-server exited abnormally: %v", err)
 		}
-		runningModelsMu.Lock()
-		if runningModel == instance {
-			runningModel = nil
+		// An instance still registered was not unloaded on purpose.
+		if removeInstance(instance) {
+			var reason string
+			lines, _ := logs.tail(failureLines)
+			if cause := failureReason(lines); cause != "" && err != nil {
+				reason = ": " + cause
+			}
+			if err != nil {
+				message := fmt.Sprintf("%s on port %d crashed%s", displayName(instance), instance.port, reason)
+				fireWebhooks(eventModelCrashed, "Model Crashed", message)
+				publishEvent(instanceEvent(eventModelCrashed, instance, message))
+			} else {
+				publishEvent(instanceEvent(eventModelUnloaded, instance, "exited"))
+			}
+			if err == nil || !restartAfterCrash(instance, reason) {
+				notify("Model Stopped", fmt.Sprintf("%s on port %d exited%s", displayName(instance), instance.port, reason))
+			}
+		} else {
+			publishEvent(instanceEvent(eventModelUnloaded, instance, "unloaded"))
 		}
-		runningModelsMu.Unlock()
 		go refreshMenuState()
 	}()
 
 	refreshMenuState()
+
+	if c.AutoOpenWeb && !opts.NoBrowser {
+		openURL(fmt.Sprintf("http://127.0.0.1:%d", instance.port))
+	}
 	return nil
 }
 
-func unloadModel() {
-	if err := loadConfig(); err != nil {
-		log.Printf("Warning: Failed to reload config: %v", err)
+// showLoadProgress refreshes the menu title and tooltip with the loading
+// progress of instance until done is closed.
+func showLoadProgress(instance *modelInstance, done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			label := instance.progress.label()
+			setTooltip(fmt.Sprintf("lmgo Model Server - %s %s", label, displayName(instance)))
+			refreshMenuState()
+
+			event := instanceEvent(eventProgress, instance, label)
+			event.Progress, _, _ = instance.progress.snapshot()
+			publishEvent(event)
+		}
+	}
+}
+
+// instanceTitle describes a running instance for menu titles, e.g.
+// "Qwen2.5-14B #2 (longContext) ×4 slots, Port:8081".
+func instanceTitle(instance *modelInstance) string {
+	title := displayName(instance)
+	if instance.instanceNum > 1 {
+		title += fmt.Sprintf(" #%d", instance.instanceNum)
+	}
+	if instance.preset != "" {
+		title += " (" + instance.preset + ")"
+	}
+	if instance.parallel > 0 {
+		title += " ×" + slotsLabel(instance.parallel)
 	}
+	title = fmt.Sprintf("%s, Port:%d", title, instance.port)
+	if instance.unhealthy {
+		title += " - Not Responding"
+	}
+	return title
+}
 
-	runningModelsMu.Lock()
+// instanceDetails describes when an instance started and how often it was
+// restarted, e.g. "started 14:05, ready in 42s, 1 restart". The times are
+// absolute so the title stays right between menu refreshes. Callers must
+// hold runningModelsMu.
+func instanceDetails(instance *modelInstance) string {
+	if instance.startedAt.IsZero() {
+		return ""
+	}
+	details := []string{"started " + instance.startedAt.Format("15:04")}
+	if instance.ready {
+		details = append(details, fmt.Sprintf("ready in %ds", int(instance.readyAt.Sub(instance.startedAt).Seconds())))
+	}
+	switch {
+	case instance.restarts == 1:
+		details = append(details, "1 restart")
+	case instance.restarts > 1:
+		details = append(details, fmt.Sprintf("%d restarts", instance.restarts))
+	}
+	return strings.Join(details, ", ")
+}
+
+func displayName(instance *modelInstance) string {
+	if instance.configName != "" {
+		return instance.configName
+	}
+	return instance.entry.BaseName
+}
+
+// nextInstanceNum numbers instances of the same model and configuration from
+// 1, reusing numbers freed by unloaded instances. Callers must hold
+// runningModelsMu.
+func nextInstanceNum(path string, configIndex int) int {
+	num := 1
+	for {
+		inUse := false
+		for _, instance := range runningModels {
+			if instance.entry.Path == path && instance.configIndex == configIndex && instance.instanceNum == num {
+				inUse = true
+				break
+			}
+		}
+		if !inUse {
+			return num
+		}
+		num++
+	}
+}
 
-	if runningModel != nil {
-		stopModelInstance(runningModel)
-		runningModel = nil
+// findInstance returns a running instance of the given model and
+// configuration, or nil. Callers must hold runningModelsMu.
+func findInstance(path string, configIndex int) *modelInstance {
+	for _, instance := range runningModels {
+		if instance.entry.Path == path && instance.configIndex == configIndex {
+			return instance
+		}
 	}
+	return nil
+}
+
+// isRegistered reports whether instance is still in runningModels.
+func isRegistered(instance *modelInstance) bool {
+	runningModelsMu.RLock()
+	defer runningModelsMu.RUnlock()
+	return slices.Contains(runningModels, instance)
+}
 
+// removeInstance drops instance from runningModels and reports whether it was
+// still registered.
+func removeInstance(instance *modelInstance) bool {
+	runningModelsMu.Lock()
+	removed := false
+	for i, running := range runningModels {
+		if running == instance {
+			runningModels = append(runningModels[:i], runningModels[i+1:]...)
+			removed = true
+			break
+		}
+	}
 	runningModelsMu.Unlock()
+
+	if removed {
+		saveSession()
+	}
+	return removed
+}
+
+// unloadInstance stops the instance listening on port and reports whether
+// one was found.
+func unloadInstance(port int) bool {
+	runningModelsMu.RLock()
+	var instance *modelInstance
+	for _, running := range runningModels {
+		if running.port == port {
+			instance = running
+			break
+		}
+	}
+	runningModelsMu.RUnlock()
+
+	if instance == nil || !removeInstance(instance) {
+		return false
+	}
+
+	stopModelInstance(instance)
+	refreshMenuState()
+	return true
+}
+
+func unloadAllModels() {
+	if err := loadConfig(); err != nil {
+		log.Printf("Warning: Failed to reload config: %v", err)
+	}
+
+	stopAllModels()
+	saveSession()
 	refreshMenuState()
 }
 
@@ -880,10 +2941,23 @@ func stopModelInstance(instance *modelInstance) {
 	if instance.cmd != nil && instance.cmd.Process != nil {
 		pid := instance.cmd.Process.Pid
 
-		if err := instance.cmd.Process.Kill(); err != nil {
-			log.Printf("Failed to kill process (port %d): %v", instance.port, err)
+		if err := terminateProcess(instance.cmd.Process); err != nil {
+			log.Printf("Failed to stop process (port %d): %v", instance.port, err)
 		} else {
-			processState, _ := instance.cmd.Process.Wait()
+			exited := make(chan *os.ProcessState, 1)
+			go func() {
+				processState, _ := instance.cmd.Process.Wait()
+				exited <- processState
+			}()
+
+			var processState *os.ProcessState
+			select {
+			case processState = <-exited:
+			case <-time.After(10 * time.Second):
+				log.Printf("Process on port %d did not exit, killing it", instance.port)
+				instance.cmd.Process.Kill()
+				processState = <-exited
+			}
 			log.Printf("Stopped model %s (port %d), PID: %d, Exit Code: %v",
 				filepath.Base(instance.entry.Path), instance.port, pid, processState.ExitCode())
 		}
@@ -894,55 +2968,75 @@ func stopModelInstance(instance *modelInstance) {
 	time.Sleep(500 * time.Millisecond)
 }
 
+// stopAllModels takes every instance off the list and then stops them, so the
+// API and the tray are not blocked while each one shuts down. The instances
+// are no longer registered when they exit, which marks the exits as intended.
 func stopAllModels() {
+	stopAllCount.Add(1)
 	runningModelsMu.Lock()
-	if runningModel != nil {
-		stopModelInstance(runningModel)
-		runningModel = nil
-	}
+	instances := runningModels
+	runningModels = nil
 	runningModelsMu.Unlock()
+
+	for _, instance := range instances {
+		stopModelInstance(instance)
+	}
 }
 
 func onExit() {
+	waitForWebhooks := fireWebhooks(eventShutdown, "lmgo Stopped", "lmgo is shutting down and unloading all models")
 	if apiServer != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		apiServer.Shutdown(ctx)
 	}
 	stopAllModels()
+	saveStats()
+	waitForHooks(30 * time.Second)
+	waitForWebhooks()
 }
 
+// findGGUFFiles lists the models in dir and, with scanSubfoldersEnabled, in
+// its subfolders, skipping hidden ones.
 func findGGUFFiles(dir string) ([]modelEntry, error) {
 	var result []modelEntry
 
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, entry := range entries {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			log.Printf("Warning: Skipping %s: %v", path, err)
+			return nil
+		}
 		if entry.IsDir() {
-			continue
+			if path != dir && (!config().ScanSubfolders || strings.HasPrefix(entry.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 		name := entry.Name()
 		if !strings.HasSuffix(strings.ToLower(name), ".gguf") {
-			continue
+			return nil
 		}
 
-		path := filepath.Join(dir, name)
 		if abs, err := filepath.Abs(path); err == nil {
 			path = abs
 		}
 
 		if isExcluded(name, path) {
 			log.Printf("Excluded model: %s", name)
-			continue
+			return nil
 		}
 
 		result = append(result, modelEntry{
 			Path:     path,
 			BaseName: strings.TrimSuffix(name, ".gguf"),
 		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	for i := 0; i < len(result); i++ {
@@ -961,11 +3055,12 @@ func findGGUFFiles(dir string) ([]modelEntry, error) {
 }
 
 func isExcluded(filename, fullPath string) bool {
-	if len(config.ExcludePatterns) == 0 {
+	c := config()
+	if len(c.ExcludePatterns) == 0 {
 		return false
 	}
 
-	for _, pattern := range config.ExcludePatterns {
+	for _, pattern := range c.ExcludePatterns {
 		matched, err := filepath.Match(pattern, filename)
 		if err == nil && matched {
 			return true
@@ -977,7 +3072,7 @@ func isExcluded(filename, fullPath string) bool {
 		}
 
 		if strings.Contains(pattern, "/") || strings.Contains(pattern, "\\") {
-			relPath, err := filepath.Rel(config.ModelDir, fullPath)
+			relPath, err := filepath.Rel(c.ModelDir, fullPath)
 			if err == nil {
 				matched, err = filepath.Match(pattern, relPath)
 				if err == nil && matched {
@@ -990,82 +3085,60 @@ func isExcluded(filename, fullPath string) bool {
 	return false
 }
 
-func waitForModelLoad(instance *modelInstance) error {
+// errServerExited is returned by waitForModelLoad when llama-server quits
+// before the model is ready, e.g. for lack of memory.
+var errServerExited = errors.New("llama-server exited while loading the model")
+
+// waitForModelLoad waits until the instance reports ready on /health, its
+// process exits, the load is cancelled or five minutes have passed.
+func waitForModelLoad(instance *modelInstance, exited <-chan error) error {
 	client := &http.Client{Timeout: 5 * time.Second}
-	url := fmt.Sprintf("http://127.0.0.1:%d/models", instance.port)
+	url := fmt.Sprintf("http://127.0.0.1:%d/health", instance.port)
 	timeout := time.After(5 * time.Minute)
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case err := <-exited:
+			if err == nil {
+				return errServerExited
+			}
+			return fmt.Errorf("%w (%v)", errServerExited, err)
 		case <-ticker.C:
-			resp, err := client.Get(url)
-			if err != nil {
-				continue
+			// The instance is unregistered when it is unloaded before it
+			// finished loading.
+			if !isRegistered(instance) {
+				return fmt.Errorf("load on port %d was cancelled", instance.port)
 			}
-			defer resp.Body.Close()
-			body, _ := io.ReadAll(resp.Body)
-			var responseMap map[string]interface{}
-			if err := json.Unmarshal(body, &responseMap); err == nil {
-				if errorObj, ok := responseMap["error"].(map[string]interface{}); ok {
-					if msg, msgOk := errorObj["message"].(string); msgOk && msg == "Loading model" {
-						continue
-					}
-				}
+			if serverReady(client, url) {
+				return nil
 			}
-			return nil
 		case <-timeout:
 			return fmt.Errorf("timeout waiting for model to load on port %d", instance.port)
 		}
 	}
 }
 
-func setAutoStart(enabled bool) error {
-	const regPath = "Software\\Microsoft\\Windows\\CurrentVersion\\Run"
-	const regName = "lmgo"
-
-	exePath, err := os.Executable()
+// serverReady reports whether llama-server's /health says the model is
+// loaded. Current versions answer 503 while loading; older ones answer 200
+// with a status other than "ok".
+func serverReady(client *http.Client, url string) bool {
+	resp, err := client.Get(url)
 	if err != nil {
-		return fmt.Errorf("failed to get executable path: %v", err)
+		return false
 	}
-
-	exeDir := filepath.Dir(exePath)
-
-	cmd := fmt.Sprintf("cd /d \"%s\" && \"%s\"", exeDir, exePath)
-
-	key, err := registry.OpenKey(registry.CURRENT_USER, regPath, registry.SET_VALUE)
-	if err != nil {
-		return fmt.Errorf("failed to open registry key: %v", err)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
 	}
-	defer key.Close()
-
-	if enabled {
-		err = key.SetStringValue(regName, cmd)
-		if err != nil {
-			return fmt.Errorf("failed to set registry value: %v", err)
-		}
-	} else {
-		err = key.DeleteValue(regName)
-		if err != nil && err != registry.ErrNotExist {
-			return fmt.Errorf("failed to delete registry value: %v", err)
-		}
+	var health struct {
+		Status string `json:"status"`
 	}
-	return nil
-}
-
-func isAutoStartEnabled() bool {
-	const regPath = "Software\\Microsoft\\Windows\\CurrentVersion\\Run"
-	const regName = "lmgo"
-
-	key, err := registry.OpenKey(registry.CURRENT_USER, regPath, registry.QUERY_VALUE)
-	if err != nil {
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
 		return false
 	}
-	defer key.Close()
-
-	_, _, err = key.GetStringValue(regName)
-	return err == nil
+	return health.Status == "" || health.Status == "ok"
 }
 
 func waitForModelShutdown(instance *modelInstance) {
@@ -1092,60 +3165,40 @@ func waitForModelShutdown(instance *modelInstance) {
 	}
 }
 
-func refreshConfigAndModels() {
+// refreshConfigAndModels reloads the config and rescans the model directory,
+// so models added or removed since startup show up. Running instances are
+// left alone. The tray also rebuilds its menus.
+func refreshConfigAndModels() error {
+	rescanMu.Lock()
+	defer rescanMu.Unlock()
+
 	if err := loadConfig(); err != nil {
 		log.Printf("Failed to reload config: %v", err)
-		return
+		return err
 	}
 
-	models, err := findGGUFFiles(config.ModelDir)
+	models, err := findGGUFFiles(config().ModelDir)
 	if err != nil {
 		log.Printf("Error scanning model files: %v", err)
-		return
+		return err
 	}
 
-	currentModels = models
+	setModels(models)
+	publishEvent(Event{Type: eventScanComplete, Message: fmt.Sprintf("Found %d models", len(models))})
 
-	for i := 0; i < len(menuItems.models); i++ {
-		menuItems.models[i].Hide()
+	if headless {
+		log.Printf("Config reloaded and models rescanned. Found %d models.", len(models))
+		return nil
 	}
 
-	menuItems.models = []*systray.MenuItem{}
-	menuItems.modelConfigs = [][]*systray.MenuItem{}
-
-	for i := 0; i < len(currentModels); i++ {
-		m := currentModels[i]
-
-		modelConfigs := []ModelConfig{}
-		for _, cfg := range config.ModelSpecificArgs {
-			if cfg.Target == m.BaseName {
-				modelConfigs = append(modelConfigs, cfg)
-			}
-		}
-
-		if len(modelConfigs) > 0 {
-			for configIdx, cfg := range modelConfigs {
-				item := menuItems.loadModel.AddSubMenuItem(cfg.Name, "")
-				menuItems.models = append(menuItems.models, item)
-
-				go func(modelIdx int, cfgIdx int, menuItem *systray.MenuItem) {
-					for range menuItem.ClickedCh {
-						loadModel(modelIdx, cfgIdx)
-					}
-				}(i, configIdx, item)
-			}
-		} else {
-			item := menuItems.loadModel.AddSubMenuItem(m.BaseName, "")
-			menuItems.models = append(menuItems.models, item)
+	addModelMenuItems()
 
-			go func(modelIdx int, menuItem *systray.MenuItem) {
-				for range menuItem.ClickedCh {
-					loadModel(modelIdx, -1)
-				}
-			}(i, item)
-		}
+	for i := 0; i < len(menuItems.folders); i++ {
+		menuItems.folders[i].Hide()
 	}
+	addOpenFolderItems()
 
 	refreshMenuState()
-	log.Printf("Config reloaded and models rescanned. Found %d models.", len(currentModels))
+	log.Printf("Config reloaded and models rescanned. Found %d models.", len(models))
+	return nil
 }
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestArbitrateInstance(t *testing.T) {
+	tests := []struct {
+		name        string
+		acquired    bool
+		acquireErr  error
+		handOffErr  error
+		wantRun     bool
+		wantMessage string
+		wantHandOff bool
+	}{
+		{name: "first copy", acquired: true, wantRun: true},
+		{name: "guard unavailable", acquireErr: errors.New("access denied"), wantRun: true},
+		{name: "handed off", wantHandOff: true},
+		{name: "hand-off failed", handOffErr: errors.New("connection refused"), wantHandOff: true, wantMessage: "lmgo is already running."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handedOff := false
+			run, message := arbitrateInstance(
+				func() (bool, error) { return tt.acquired, tt.acquireErr },
+				func() error { handedOff = true; return tt.handOffErr },
+			)
+			if run != tt.wantRun || message != tt.wantMessage || handedOff != tt.wantHandOff {
+				t.Errorf("got run=%v message=%q handOff=%v, want run=%v message=%q handOff=%v",
+					run, message, handedOff, tt.wantRun, tt.wantMessage, tt.wantHandOff)
+			}
+		})
+	}
+}
+
+// serverPort returns the port of a test server listening on 127.0.0.1.
+func serverPort(t *testing.T, server *httptest.Server) int {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func TestHandOffToRunningInstance(t *testing.T) {
+	saved := *config()
+	t.Cleanup(func() { setConfig(saved) })
+	setConfig(Config{APIKeys: []string{"secret"}})
+
+	var got ActivateRequest
+	var method, path, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, auth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding hand-off body: %v", err)
+		}
+		writeJSON(w, http.StatusOK, APIResponse{Success: true})
+	}))
+	defer server.Close()
+
+	if err := handOffToRunningInstance(serverPort(t, server), []string{"qwen", "coder"}); err != nil {
+		t.Fatalf("handOffToRunningInstance: %v", err)
+	}
+	if method != http.MethodPost || path != "/api/activate" {
+		t.Errorf("request = %s %s, want POST /api/activate", method, path)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the first API key", auth)
+	}
+	if !slices.Equal(got.Load, []string{"qwen", "coder"}) {
+		t.Errorf("load = %q, want [qwen coder]", got.Load)
+	}
+}
+
+func TestHandOffRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnauthorized, APIResponse{Success: false})
+	}))
+	defer server.Close()
+
+	if err := handOffToRunningInstance(serverPort(t, server), nil); err == nil {
+		t.Error("handOffToRunningInstance succeeded against a server answering 401")
+	}
+}
+
+func TestHandOffReachesActivate(t *testing.T) {
+	savedHeadless := headless
+	t.Cleanup(func() { headless = savedHeadless })
+	headless = true
+
+	server := httptest.NewServer(http.HandlerFunc(handleActivate))
+	defer server.Close()
+
+	if err := handOffToRunningInstance(serverPort(t, server), nil); err != nil {
+		t.Errorf("hand-off to handleActivate: %v", err)
+	}
+}
+
+func TestExpandBrowserCommand(t *testing.T) {
+	const u = "http://127.0.0.1:8081/"
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+	}{
+		{"placeholder", "firefox --new-window {url}", []string{"firefox", "--new-window", u}},
+		{"no placeholder", "firefox --private-window", []string{"firefox", "--private-window", u}},
+		{"program only", "chromium", []string{"chromium", u}},
+		{"quoted path with spaces", `"C:\Program Files\Mozilla Firefox\firefox.exe" -new-tab {url}`,
+			[]string{`C:\Program Files\Mozilla Firefox\firefox.exe`, "-new-tab", u}},
+		{"quoted path without placeholder", `"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"`,
+			[]string{"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome", u}},
+		{"placeholder inside an argument", "chromium --app={url}", []string{"chromium", "--app=" + u}},
+		{"quoted placeholder", `open -a Safari "{url}"`, []string{"open", "-a", "Safari", u}},
+		{"several placeholders", "sh -c {url}#a {url}#b", []string{"sh", "-c", u + "#a", u + "#b"}},
+		{"placeholder twice in one argument", "echo {url}|{url}", []string{"echo", u + "|" + u}},
+		{"extra whitespace", "  firefox \t  {url}  ", []string{"firefox", u}},
+		{"empty quoted argument", `browser "" {url}`, []string{"browser", "", u}},
+		{"empty template", "", nil},
+		{"blank template", "   ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandBrowserCommand(tt.template, u); !slices.Equal(got, tt.want) {
+				t.Errorf("expandBrowserCommand(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandBrowserCommandKeepsURLWhole(t *testing.T) {
+	// The URL is substituted after splitting, so spaces or quotes in it
+	// never split it into several arguments.
+	u := `http://host/a b"c`
+	got := expandBrowserCommand("browser {url}", u)
+	if !slices.Equal(got, []string{"browser", u}) {
+		t.Errorf("expandBrowserCommand = %q, want the URL as one argument", got)
+	}
+}
+
+// TestModelsDuringRescan looks models up while rescans replace the list with
+// shorter and longer ones. Run with -race to check the synchronization.
+func TestModelsDuringRescan(t *testing.T) {
+	saved := modelSnapshot()
+	t.Cleanup(func() { setModels(saved) })
+
+	long := []modelEntry{{BaseName: "a", Path: "/m/a.gguf"}, {BaseName: "b", Path: "/m/b.gguf"}, {BaseName: "c", Path: "/m/c.gguf"}}
+	short := long[:1]
+	setModels(long)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if i%2 == 0 {
+				setModels(short)
+			} else {
+				setModels(long)
+			}
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if idx, _, ok := findModelByName("c"); ok {
+			if entry, ok := modelAt(idx); ok && entry.Path == "" {
+				t.Fatalf("modelAt(%d) returned an empty entry", idx)
+			}
+		}
+	}
+}
+
+func TestSuspendCancelsPendingRestarts(t *testing.T) {
+	t.Cleanup(func() { suspended, suspendedModels = false, nil })
+
+	generation := stopAllCount.Load()
+	handleSuspend()
+	if stopAllCount.Load() == generation {
+		t.Error("handleSuspend left stopAllCount unchanged, so crash restarts still run")
+	}
+}
+
+// TestConfigReloadDuringReads reloads the config while other goroutines
+// read and change it. Run with -race to check the synchronization.
+func TestConfigReloadDuringReads(t *testing.T) {
+	saved := *config()
+	t.Cleanup(func() { setConfig(saved) })
+	dir := t.TempDir()
+	setConfig(Config{ModelDir: dir, BasePort: 8080, LlamaServerPort: 8081})
+
+	var writers sync.WaitGroup
+	writers.Add(2)
+	go func() {
+		defer writers.Done()
+		for i := 0; i < 200; i++ {
+			if err := applyConfig(Config{ModelDir: dir, BasePort: 8080, LlamaServerPort: 8081 + i%2}); err != nil {
+				t.Errorf("applyConfig: %v", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer writers.Done()
+		for i := 0; i < 200; i++ {
+			updateConfig(func(c *Config) { c.Favorites = append(slices.Clip(c.Favorites), "model") })
+		}
+	}()
+	done := make(chan struct{})
+	go func() {
+		writers.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if c := config(); c.ModelDir != dir || c.BasePort != 8080 {
+			t.Fatalf("read a config with modelDir %q and basePort %d", c.ModelDir, c.BasePort)
+		}
+		portRange()
+	}
+}
+
+func TestHandleLoadRejectsArgsFromReboundPage(t *testing.T) {
+	savedConfig, savedModels := *config(), modelSnapshot()
+	t.Cleanup(func() {
+		setConfig(savedConfig)
+		setModels(savedModels)
+	})
+	setConfig(Config{})
+	setModels([]modelEntry{{BaseName: "a", Path: "/m/a.gguf"}})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/load?index=0", strings.NewReader(`{"args": ["-c", "4096"]}`))
+	r.RemoteAddr, r.Host = "127.0.0.1:50000", "attacker.example:8080"
+	r.Header.Set("Origin", "http://attacker.example:8080")
+	w := httptest.NewRecorder()
+	handleLoad(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
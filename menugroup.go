@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/getlantern/systray"
+)
+
+// menuGroupModes are the ways the "Load Model" menu can put models into
+// submenus. The empty mode lists every model directly.
+var menuGroupModes = []string{"folder", "family"}
+
+func validateMenuGroup(mode string) error {
+	if mode != "" && !slices.Contains(menuGroupModes, mode) {
+		return fmt.Errorf("invalid menuGroup %q, expected one of %s", mode, strings.Join(menuGroupModes, ", "))
+	}
+	return nil
+}
+
+// menuGroupPath returns the submenus a model is listed under, outermost
+// first: the subfolders under modelDir, or the model family. Models directly
+// in modelDir are not grouped by folder.
+func menuGroupPath(entry modelEntry) []string {
+	switch config().MenuGroup {
+	case "folder":
+		dir, err := filepath.Abs(config().ModelDir)
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(entry.Path))
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return nil
+		}
+		return strings.Split(filepath.ToSlash(rel), "/")
+	case "family":
+		return []string{modelFamily(entry)}
+	}
+	return nil
+}
+
+// modelFamily is the architecture from the GGUF header, such as "llama" or
+// "qwen2", which the models of one family share whatever their file names.
+func modelFamily(entry modelEntry) string {
+	meta, err := cachedGGUFMetadata(firstShardPath(entry.Path))
+	if err != nil || meta.Architecture == "" {
+		return "Other"
+	}
+	return meta.Architecture
+}
+
+// addGroupMenus creates the submenus the models in order are listed under,
+// sorted by name, and returns them by their path joined with "/". The
+// submenus of the previous build are hidden since tray items cannot be
+// removed.
+func addGroupMenus(order []int) map[string]*systray.MenuItem {
+	for _, item := range menuItems.groups {
+		item.Hide()
+	}
+	menuItems.groups = nil
+
+	paths := map[string]bool{}
+	models := modelSnapshot()
+	for _, i := range order {
+		path := menuGroupPath(models[i])
+		for n := range path {
+			paths[strings.Join(path[:n+1], "/")] = true
+		}
+	}
+
+	// Sorting puts every parent before its children.
+	groups := map[string]*systray.MenuItem{}
+	for _, key := range slices.Sorted(maps.Keys(paths)) {
+		parent, name := menuItems.loadModel, key
+		if i := strings.LastIndex(key, "/"); i >= 0 {
+			parent, name = groups[key[:i]], key[i+1:]
+		}
+		item := parent.AddSubMenuItem(name, "")
+		groups[key] = item
+		menuItems.groups = append(menuItems.groups, item)
+	}
+	return groups
+}
+
+// menuParent returns the menu a model's entries go in.
+func menuParent(entry modelEntry, groups map[string]*systray.MenuItem) *systray.MenuItem {
+	if path := menuGroupPath(entry); len(path) > 0 {
+		return groups[strings.Join(path, "/")]
+	}
+	return menuItems.loadModel
+}
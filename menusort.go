@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/getlantern/systray"
+)
+
+// menuSortModes are the orders of the "Load Model" menu. The empty mode sorts
+// by name, as the menu always did.
+var menuSortModes = []string{"name", "size", "modified", "lastUsed"}
+
+var menuSortLabels = map[string]string{
+	"name":     "Name",
+	"size":     "File Size",
+	"modified": "Date Modified",
+	"lastUsed": "Last Used",
+}
+
+func validateMenuSort(mode string) error {
+	if mode != "" && !slices.Contains(menuSortModes, mode) {
+		return fmt.Errorf("invalid menuSort %q, expected one of %s", mode, strings.Join(menuSortModes, ", "))
+	}
+	return nil
+}
+
+// modelOrder returns the indices of the scanned models in the order of menuSort.
+// Size, modification date and last use put the largest and latest first;
+// ties and unknown values keep the name order of the scan.
+func modelOrder() []int {
+	models := modelSnapshot()
+	order := make([]int, len(models))
+	keys := make([]int64, len(models))
+	for i, m := range models {
+		order[i] = i
+		switch config().MenuSort {
+		case "size":
+			keys[i] = modelFileSize(m.Path)
+		case "modified":
+			if info, err := os.Stat(firstShardPath(m.Path)); err == nil {
+				keys[i] = info.ModTime().Unix()
+			}
+		case "lastUsed":
+			keys[i] = modelLastUsed(m).Unix()
+		}
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		switch {
+		case keys[a] > keys[b]:
+			return -1
+		case keys[a] < keys[b]:
+			return 1
+		}
+		return 0
+	})
+	return order
+}
+
+// modelLastUsed is the latest load of or request to a model file under any of
+// its configurations, or the zero time.
+func modelLastUsed(m modelEntry) time.Time {
+	names := []string{m.BaseName}
+	for _, cfg := range config().ModelSpecificArgs {
+		if cfg.Target == m.BaseName {
+			names = append(names, cfg.Name)
+		}
+	}
+	var latest time.Time
+	for _, name := range names {
+		if used := lastUsed(name); used.After(latest) {
+			latest = used
+		}
+	}
+	return latest
+}
+
+// addSortMenu adds the "Sort By" submenu at the top of "Load Model".
+func addSortMenu() {
+	sortMenu := menuItems.loadModel.AddSubMenuItem("Sort By", "Choose the order of this menu")
+	for _, mode := range menuSortModes {
+		item := sortMenu.AddSubMenuItem(menuSortLabels[mode], "Sort models by "+strings.ToLower(menuSortLabels[mode]))
+		menuItems.sortModes = append(menuItems.sortModes, item)
+		go func(mode string, item *systray.MenuItem) {
+			for range item.ClickedCh {
+				setMenuSort(mode)
+			}
+		}(mode, item)
+	}
+}
+
+// setMenuSort saves a new order and rebuilds the model entries in it.
+func setMenuSort(mode string) {
+	if mode == "name" {
+		mode = ""
+	}
+	if mode == config().MenuSort {
+		return
+	}
+	updateConfig(func(c *Config) { c.MenuSort = mode })
+	if err := saveConfig(); err != nil {
+		log.Printf("Failed to save config: %v", err)
+	}
+
+	addModelMenuItems()
+	refreshMenuState()
+}
+
+// refreshSortMenu marks the current order with a check mark.
+func refreshSortMenu() {
+	current := config().MenuSort
+	if current == "" {
+		current = "name"
+	}
+	for i, item := range menuItems.sortModes {
+		title := menuSortLabels[menuSortModes[i]]
+		if menuSortModes[i] == current {
+			title = "✓ " + title
+		}
+		item.SetTitle(title)
+	}
+}
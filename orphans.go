@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+// serverMarkerEnv is set on every llama-server lmgo starts, with lmgo's
+// process ID, so that servers left behind by a crashed lmgo can be told
+// apart from ones started by hand.
+const serverMarkerEnv = "LMGO_PARENT_PID"
+
+func serverEnv() []string {
+	return append(os.Environ(), serverMarkerEnv+"="+strconv.Itoa(os.Getpid()))
+}
+
+// stopOrphanedServers looks for llama-server processes a previous lmgo left
+// running, which would keep their ports and VRAM, and stops them. The tray
+// asks first where the platform can show a dialog.
+func stopOrphanedServers() {
+	pids := findOrphanedServers()
+	if len(pids) == 0 {
+		return
+	}
+	log.Printf("Found %d llama-server processes left over from a previous run: %v", len(pids), pids)
+
+	// Where no dialog can be shown they are stopped, since they carry lmgo's
+	// marker.
+	message := fmt.Sprintf("%d llama-server processes from a previous lmgo run are still running and may hold ports and GPU memory. Stop them?", len(pids))
+	if !headless && !confirm("lmgo", message, true) {
+		log.Printf("Leaving the leftover llama-server processes running")
+		return
+	}
+	for _, pid := range pids {
+		process, err := os.FindProcess(pid)
+		if err == nil {
+			err = process.Kill()
+		}
+		if err != nil {
+			log.Printf("Failed to stop leftover llama-server (PID %d): %v", pid, err)
+			continue
+		}
+		log.Printf("Stopped leftover llama-server (PID %d)", pid)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// configPath is the config file, lmgo.json next to the executable unless
+// --config names another one.
+var configPath = "lmgo.json"
+
+// overrides hold the settings given through the environment or the command
+// line. They take precedence over the config file without being written to
+// it, so one executable can be launched with different setups.
+var overrides struct {
+	modelDir string
+	basePort int
+}
+
+// fileSettings are the overridden settings as the config file has them, so
+// that saving the config keeps them.
+var fileSettings struct {
+	modelDir string
+	basePort int
+}
+
+// configName is the file name of the config file, used in messages.
+func configName() string {
+	return filepath.Base(configPath)
+}
+
+// setConfigPath makes path, relative to the current directory, the config
+// file. It must be called before lmgo changes to the executable's directory.
+func setConfigPath(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("--config: %v", err)
+	}
+	configPath = abs
+	return nil
+}
+
+// parseOverrides reads LMGO_MODEL_DIR and LMGO_BASE_PORT. A --model-dir flag
+// wins over LMGO_MODEL_DIR. Relative directories are taken from the current
+// directory, so it must be called before lmgo changes to the executable's
+// directory.
+func parseOverrides(modelDirFlag string) error {
+	modelDir := os.Getenv("LMGO_MODEL_DIR")
+	if modelDirFlag != "" {
+		modelDir = modelDirFlag
+	}
+	if modelDir != "" {
+		abs, err := filepath.Abs(modelDir)
+		if err != nil {
+			return fmt.Errorf("model directory %q: %v", modelDir, err)
+		}
+		overrides.modelDir = abs
+	}
+
+	if value := os.Getenv("LMGO_BASE_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("LMGO_BASE_PORT: invalid port %q", value)
+		}
+		if err := validatePort("LMGO_BASE_PORT", port); err != nil {
+			return err
+		}
+		overrides.basePort = port
+	}
+	return nil
+}
+
+// applyOverrides puts the overridden settings into a freshly loaded config
+// and remembers the values they replace. Callers hold configMu.
+func applyOverrides(c *Config) {
+	fileSettings.modelDir = c.ModelDir
+	fileSettings.basePort = c.BasePort
+	if overrides.modelDir != "" {
+		c.ModelDir = overrides.modelDir
+	}
+	if overrides.basePort != 0 {
+		c.BasePort = overrides.basePort
+	}
+}
+
+// fileConfig returns the config as it is written to the config file, with
+// the file's own values in place of overridden ones.
+func fileConfig() Config {
+	configMu.Lock()
+	defer configMu.Unlock()
+	saved := *config()
+	if overrides.modelDir != "" {
+		saved.ModelDir = fileSettings.modelDir
+	}
+	if overrides.basePort != 0 {
+		saved.BasePort = fileSettings.basePort
+	}
+	return saved
+}
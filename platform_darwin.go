@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+var archiveKeywords = []string{"macos", "darwin"}
+
+const launchAgentLabel = "com.github.zyoung11.lmgo"
+
+// openBrowser opens url with open. A failure names the command line, so the
+// log shows what was run.
+func openBrowser(url string) error {
+	args := []string{"open", url}
+	if err := exec.Command(args[0], args[1:]...).Start(); err != nil {
+		return fmt.Errorf("running %q: %w", args, err)
+	}
+	return nil
+}
+
+func revealInFileManager(path string) error {
+	return exec.Command("open", "-R", path).Start()
+}
+
+func showNotification(title, message string) error {
+	appleQuote := func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	script := fmt.Sprintf("display notification %s with title %s", appleQuote(message), appleQuote(title))
+
+	cmd := exec.Command("osascript", "-e", script)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+func launchAgentFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchAgentLabel+".plist"), nil
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func setAutoStart(enabled bool) error {
+	file, err := launchAgentFile()
+	if err != nil {
+		return fmt.Errorf("failed to locate LaunchAgents directory: %v", err)
+	}
+
+	if !enabled {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove launch agent: %v", err)
+		}
+		return nil
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %v", err)
+	}
+
+	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+		<string>--boot</string>
+	</array>
+	<key>WorkingDirectory</key>
+	<string>%s</string>
+	<key>RunAtLoad</key>
+	<true/>
+</dict>
+</plist>
+`, launchAgentLabel, xmlEscape(exePath), xmlEscape(filepath.Dir(exePath)))
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create LaunchAgents directory: %v", err)
+	}
+	if err := os.WriteFile(file, []byte(plist), 0644); err != nil {
+		return fmt.Errorf("failed to write launch agent: %v", err)
+	}
+	return nil
+}
+
+// readAutoStartTarget returns the first ProgramArguments entry of the launch
+// agent.
+func readAutoStartTarget() (string, bool) {
+	file, err := launchAgentFile()
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", false
+	}
+
+	var plist struct {
+		Strings []string `xml:"dict>array>string"`
+	}
+	if err := xml.Unmarshal(data, &plist); err != nil || len(plist.Strings) == 0 {
+		return "", true
+	}
+	return plist.Strings[0], true
+}
+
+// availableMemory returns the share of unified memory Metal lets a process
+// use by default (about three quarters of physical memory).
+func availableMemory() (uint64, error) {
+	total, err := unix.SysctlUint64("hw.memsize")
+	if err != nil {
+		return 0, err
+	}
+	return total / 4 * 3, nil
+}
+
+// systemMemory is not reported on macOS, where free memory depends on how
+// the unified memory is split between CPU and GPU.
+func systemMemory() (total, available uint64, err error) {
+	return 0, 0, fmt.Errorf("not supported on macOS")
+}
+
+func platformGPUUsage() []GPUUsage {
+	return nil
+}
+
+// detectGPUs reports nothing: macOS builds of llama.cpp always use Metal.
+func detectGPUs() []gpuInfo {
+	return nil
+}
+
+// findOrphanedServers is not implemented on macOS.
+func findOrphanedServers() []int {
+	return nil
+}
+
+// confirm asks a yes/no question in an AppleScript dialog, passing the texts
+// through the environment like promptText. Cancel makes osascript fail with
+// error -128; any other failure, such as no GUI session, answers fallback.
+func confirm(title, message string, fallback bool) bool {
+	const script = `display dialog (system attribute "LMGO_PROMPT_MESSAGE") ` +
+		`with title (system attribute "LMGO_PROMPT_TITLE") buttons {"Cancel", "OK"} default button "OK" cancel button "Cancel"`
+	cmd := exec.Command("osascript", "-e", script)
+	cmd.Env = append(os.Environ(), "LMGO_PROMPT_TITLE="+title, "LMGO_PROMPT_MESSAGE="+message)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return true
+	}
+	if strings.Contains(string(out), "(-128)") {
+		return false
+	}
+	return fallback
+}
+
+// promptText asks for a line of text in an AppleScript dialog. The texts are
+// passed through the environment so they need no quoting.
+func promptText(title, message, initial string) (string, bool) {
+	const script = `text returned of (display dialog (system attribute "LMGO_PROMPT_MESSAGE") ` +
+		`default answer (system attribute "LMGO_PROMPT_INITIAL") with title (system attribute "LMGO_PROMPT_TITLE"))`
+	cmd := exec.Command("osascript", "-e", script)
+	cmd.Env = append(os.Environ(), "LMGO_PROMPT_TITLE="+title, "LMGO_PROMPT_MESSAGE="+message, "LMGO_PROMPT_INITIAL="+initial)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(out)), true
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var archiveKeywords = []string{"ubuntu", "linux"}
+
+// openBrowser opens url with xdg-open. A failure names the command line, so
+// the log shows what was run.
+func openBrowser(url string) error {
+	args := []string{"xdg-open", url}
+	if err := exec.Command(args[0], args[1:]...).Start(); err != nil {
+		return fmt.Errorf("running %q: %w", args, err)
+	}
+	return nil
+}
+
+// revealInFileManager asks the desktop's file manager to select the file over
+// D-Bus, falling back to opening the containing directory.
+func revealInFileManager(path string) error {
+	uri := "file://" + path
+	err := exec.Command("dbus-send", "--session", "--print-reply",
+		"--dest=org.freedesktop.FileManager1", "/org/freedesktop/FileManager1",
+		"org.freedesktop.FileManager1.ShowItems", "array:string:"+uri, "string:").Run()
+	if err == nil {
+		return nil
+	}
+	return exec.Command("xdg-open", filepath.Dir(path)).Start()
+}
+
+func showNotification(title, message string) error {
+	cmd := exec.Command("notify-send", "--app-name=lmgo", title, message)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+func autostartFile() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "autostart", "lmgo.desktop"), nil
+}
+
+// desktopExecQuote quotes an argument for the Exec key of a .desktop file.
+func desktopExecQuote(arg string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`)
+	return `"` + r.Replace(arg) + `"`
+}
+
+func setAutoStart(enabled bool) error {
+	file, err := autostartFile()
+	if err != nil {
+		return fmt.Errorf("failed to locate autostart directory: %v", err)
+	}
+
+	if !enabled {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove autostart entry: %v", err)
+		}
+		return nil
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %v", err)
+	}
+
+	entry := fmt.Sprintf("[Desktop Entry]\nType=Application\nName=lmgo\nComment=lmgo Model Server\nExec=%s --boot\nPath=%s\nTerminal=false\nX-GNOME-Autostart-enabled=true\n",
+		desktopExecQuote(exePath), filepath.Dir(exePath))
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create autostart directory: %v", err)
+	}
+	if err := os.WriteFile(file, []byte(entry), 0644); err != nil {
+		return fmt.Errorf("failed to write autostart entry: %v", err)
+	}
+	return nil
+}
+
+// readAutoStartTarget returns the executable from the Exec line of the
+// autostart entry.
+func readAutoStartTarget() (string, bool) {
+	file, err := autostartFile()
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", false
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		value, ok := strings.CutPrefix(line, "Exec=")
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(value, `"`) {
+			if end := strings.Index(value[1:], `"`); end >= 0 {
+				return value[1 : end+1], true
+			}
+		}
+		if fields := strings.Fields(value); len(fields) > 0 {
+			return fields[0], true
+		}
+	}
+	return "", true
+}
+
+// availableMemory returns MemAvailable from /proc/meminfo.
+func availableMemory() (uint64, error) {
+	_, available, err := systemMemory()
+	return available, err
+}
+
+// systemMemory returns MemTotal and MemAvailable from /proc/meminfo.
+func systemMemory() (total, available uint64, err error) {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	found := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || (fields[0] != "MemTotal:" && fields[0] != "MemAvailable:") {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		if fields[0] == "MemTotal:" {
+			total = kb * 1024
+		} else {
+			available = kb * 1024
+		}
+		found++
+	}
+	if found < 2 {
+		return 0, 0, fmt.Errorf("MemTotal or MemAvailable not found in /proc/meminfo")
+	}
+	return total, available, nil
+}
+
+// platformGPUUsage reports the VRAM and load of amdgpu devices from sysfs,
+// the same counters rocm-smi reads. Other drivers do not expose these files
+// and are left out.
+func platformGPUUsage() []GPUUsage {
+	cards, _ := filepath.Glob("/sys/class/drm/card[0-9]*/device/mem_info_vram_total")
+	var gpus []GPUUsage
+	for _, totalPath := range cards {
+		dir := filepath.Dir(totalPath)
+		total, err := readSysfsUint(totalPath)
+		if err != nil || total == 0 {
+			continue
+		}
+		used, err := readSysfsUint(filepath.Join(dir, "mem_info_vram_used"))
+		if err != nil {
+			continue
+		}
+		gpu := GPUUsage{
+			Name:  filepath.Base(filepath.Dir(dir)),
+			Used:  used,
+			Total: total,
+		}
+		if busy, err := readSysfsUint(filepath.Join(dir, "gpu_busy_percent")); err == nil {
+			percent := int(busy)
+			gpu.Utilization = &percent
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus
+}
+
+// pciVendors maps the PCI vendor IDs of GPU makers to the names backends
+// are chosen by.
+var pciVendors = map[string]string{"0x1002": "amd", "0x10de": "nvidia", "0x8086": "intel"}
+
+// detectGPUs lists the GPUs from sysfs. The gfx targets of AMD GPUs come
+// from the KFD topology, in the same order as the cards.
+func detectGPUs() []gpuInfo {
+	vendors, _ := filepath.Glob("/sys/class/drm/card[0-9]*/device/vendor")
+	targets := kfdTargets()
+	var gpus []gpuInfo
+	for _, path := range vendors {
+		card := filepath.Base(filepath.Dir(filepath.Dir(path)))
+		if strings.Contains(card, "-") {
+			continue // a connector such as card0-DP-1
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		vendor, ok := pciVendors[strings.TrimSpace(string(data))]
+		if !ok {
+			continue
+		}
+		gpu := gpuInfo{Vendor: vendor, Name: card}
+		if vendor == "amd" && len(targets) > 0 {
+			gpu.Target, targets = targets[0], targets[1:]
+		}
+		gpus = append(gpus, gpu)
+	}
+	addComputeCaps(gpus)
+	return gpus
+}
+
+// kfdTargets reads the gfx targets of the GPU nodes of the ROCm kernel
+// driver. gfx_target_version encodes gfx1151 as 110501 and gfx90a as 90010.
+func kfdTargets() []string {
+	nodes, _ := filepath.Glob("/sys/class/kfd/kfd/topology/nodes/*/properties")
+	var targets []string
+	for _, path := range nodes {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			value, ok := strings.CutPrefix(line, "gfx_target_version ")
+			if !ok {
+				continue
+			}
+			if v, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && v > 0 {
+				targets = append(targets, fmt.Sprintf("gfx%d%x%x", v/10000, v/100%100, v%100))
+			}
+		}
+	}
+	return targets
+}
+
+func readSysfsUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// findOrphanedServers returns the processes that carry lmgo's marker but are
+// no longer children of the lmgo that started them.
+func findOrphanedServers() []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		environ, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "environ"))
+		if err != nil {
+			continue
+		}
+		var parent string
+		for _, v := range strings.Split(string(environ), "\x00") {
+			if value, ok := strings.CutPrefix(v, serverMarkerEnv+"="); ok {
+				parent = value
+			}
+		}
+		if parent == "" {
+			continue
+		}
+		// The parent PID is the fourth field of stat, after the command
+		// name in parentheses, which may itself contain spaces.
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		_, rest, _ := strings.Cut(string(stat), ") ")
+		if fields := strings.Fields(rest); len(fields) > 1 && fields[1] != parent {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// confirm asks a yes/no question with zenity when it is installed. Without
+// it the answer is fallback.
+func confirm(title, message string, fallback bool) bool {
+	if _, err := exec.LookPath("zenity"); err != nil {
+		return fallback
+	}
+	return exec.Command("zenity", "--question", "--title="+title, "--text="+message).Run() == nil
+}
+
+// promptText asks for a line of text with zenity, starting from initial. It
+// returns false when cancelled or when zenity is not installed.
+func promptText(title, message, initial string) (string, bool) {
+	if _, err := exec.LookPath("zenity"); err != nil {
+		return "", false
+	}
+	out, err := exec.Command("zenity", "--entry", "--title="+title, "--text="+message, "--entry-text="+initial, "--width=600").Output()
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(out)), true
+}
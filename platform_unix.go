@@ -0,0 +1,89 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+const serverBinaryName = "llama-server"
+
+var instanceLock *os.File
+
+func hideConsole() {}
+
+func attachConsole() {}
+
+func hideWindow(cmd *exec.Cmd) {}
+
+// autoStartOutdated is always false here: the autostart entry holds nothing
+// but the executable, and startupDelaySeconds is read on each boot launch.
+func autoStartOutdated() bool { return false }
+
+// shellCommand runs command through sh.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
+
+// terminateProcess asks llama-server to shut down gracefully. The caller kills
+// the process if it does not exit in time.
+func terminateProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
+
+// bindToParent is implemented on Windows only. Elsewhere servers are
+// stopped when lmgo shuts down.
+func bindToParent(cmd *exec.Cmd) error {
+	return nil
+}
+
+// runtimeDir returns the per-user directory for the lock file and the control
+// socket, creating it if needed.
+func runtimeDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, "lmgo")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// acquireSingleInstance takes an exclusive lock on a per-user lock file. It
+// reports false when another process already holds it.
+func acquireSingleInstance() (bool, error) {
+	dir, err := runtimeDir()
+	if err != nil {
+		return false, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, "lmgo.lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return false, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return false, nil
+		}
+		return false, err
+	}
+
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	instanceLock = f
+	return true, nil
+}
+
+// registerPowerNotifications is not implemented outside Windows; instances are
+// still covered by the regular exit handling.
+func registerPowerNotifications() error {
+	return nil
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+const serverBinaryName = "llama-server.exe"
+
+var archiveKeywords = []string{"win"}
+
+// singleInstanceMutexName is the same for every port and session, so that a
+// copy running as a service and one in the tray exclude each other too.
+const singleInstanceMutexName = `Global\lmgo-single-instance`
+
+var instanceMutex windows.Handle
+
+const (
+	deviceNotifyCallback  = 2
+	pbtAPMSuspend         = 0x4
+	pbtAPMResumeAutomatic = 0x12
+)
+
+type deviceNotifySubscribeParameters struct {
+	callback uintptr
+	context  uintptr
+}
+
+var (
+	powerNotifyParams deviceNotifySubscribeParameters
+	powerNotifyHandle uintptr
+)
+
+func hideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+}
+
+// shellCommand runs command through cmd.exe without a console window. The
+// command line is passed as is, since cmd does not follow the quoting rules
+// exec applies to arguments.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "cmd.exe")
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CmdLine: `/S /C "` + command + `"`}
+	return cmd
+}
+
+// terminateProcess stops a llama-server process. Windows has no SIGTERM
+// equivalent for console-less children, so the process is killed.
+func terminateProcess(p *os.Process) error {
+	return p.Kill()
+}
+
+// openBrowser opens url with the default browser through ShellExecuteW, so
+// characters such as "&" are not interpreted by cmd. A failure names the
+// call, so the log shows what was run.
+func openBrowser(url string) error {
+	if err := shellOpen(url); err != nil {
+		return fmt.Errorf("ShellExecuteW(\"open\", %q): %w", url, err)
+	}
+	return nil
+}
+
+func shellOpen(url string) error {
+	verb, err := windows.UTF16PtrFromString("open")
+	if err != nil {
+		return err
+	}
+	file, err := windows.UTF16PtrFromString(url)
+	if err != nil {
+		return err
+	}
+	return windows.ShellExecute(0, verb, file, nil, nil, windows.SW_SHOWNORMAL)
+}
+
+// revealInFileManager opens Explorer with the file pre-selected. The command line
+// is passed verbatim to CreateProcess so paths containing spaces or "&" survive.
+func revealInFileManager(path string) error {
+	cmd := exec.Command("explorer.exe")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CmdLine: fmt.Sprintf(`explorer.exe /select,"%s"`, path),
+	}
+	return cmd.Start()
+}
+
+// showNotification shows a Windows toast notification through PowerShell.
+func showNotification(title, message string) error {
+	psQuote := func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	script := fmt.Sprintf(`[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode(%s)) > $null
+$text.Item(1).AppendChild($template.CreateTextNode(%s)) > $null
+$appId = '{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe'
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($appId).Show([Windows.UI.Notifications.ToastNotification]::new($template))`,
+		psQuote(title), psQuote(message))
+
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script)
+	hideWindow(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+func getConsoleWindow() syscall.Handle {
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	proc := kernel32.NewProc("GetConsoleWindow")
+	ret, _, _ := proc.Call()
+	return syscall.Handle(ret)
+}
+
+func hideConsole() {
+	hwnd := getConsoleWindow()
+	if hwnd == 0 {
+		return
+	}
+	user32 := syscall.NewLazyDLL("user32.dll")
+	showWindow := user32.NewProc("ShowWindow")
+	showWindow.Call(uintptr(hwnd), uintptr(0))
+}
+
+// attachConsole connects a windowsgui build to the console of the terminal it
+// was started from, so commands can print their results. Output that is
+// already redirected, e.g. to a file or a pipe, is left alone.
+func attachConsole() {
+	if handle, err := windows.GetStdHandle(windows.STD_OUTPUT_HANDLE); err == nil && handle != 0 && handle != windows.InvalidHandle {
+		return
+	}
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	attach := kernel32.NewProc("AttachConsole")
+	const attachParentProcess = ^uintptr(0)
+	if ret, _, _ := attach.Call(attachParentProcess); ret == 0 {
+		return
+	}
+	if console, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout = console
+		os.Stderr = console
+	}
+}
+
+// serverJob is the job object llama-server processes are assigned to. It is
+// never closed explicitly: Windows closes it when lmgo exits, however that
+// happens, and KILL_ON_JOB_CLOSE then ends every server still running so
+// none is left holding VRAM.
+var (
+	serverJob     windows.Handle
+	serverJobErr  error
+	serverJobOnce sync.Once
+)
+
+func createServerJob() (windows.Handle, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		windows.CloseHandle(job)
+		return 0, err
+	}
+	return job, nil
+}
+
+// bindToParent makes a started llama-server end together with lmgo.
+func bindToParent(cmd *exec.Cmd) error {
+	serverJobOnce.Do(func() {
+		serverJob, serverJobErr = createServerJob()
+	})
+	if serverJobErr != nil {
+		return fmt.Errorf("failed to create job object: %v", serverJobErr)
+	}
+
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(process)
+	return windows.AssignProcessToJobObject(serverJob, process)
+}
+
+// findOrphanedServers returns the llama-server processes started from
+// serverPath whose parent is no longer a running lmgo. Other processes'
+// environment cannot be read on Windows, so the marker is not used.
+func findOrphanedServers() []int {
+	server, err := filepath.Abs(serverPath)
+	if err != nil {
+		return nil
+	}
+	self, _ := os.Executable()
+
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil
+	}
+	defer windows.CloseHandle(snapshot)
+
+	names := map[uint32]string{}
+	parents := map[uint32]uint32{}
+	entry := windows.ProcessEntry32{Size: uint32(unsafe.Sizeof(windows.ProcessEntry32{}))}
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		names[entry.ProcessID] = windows.UTF16ToString(entry.ExeFile[:])
+		parents[entry.ProcessID] = entry.ParentProcessID
+	}
+
+	var pids []int
+	for pid, name := range names {
+		if !strings.EqualFold(name, serverBinaryName) {
+			continue
+		}
+		if parent, ok := names[parents[pid]]; ok && strings.EqualFold(parent, filepath.Base(self)) {
+			continue
+		}
+		if path, err := processImage(pid); err == nil && strings.EqualFold(path, server) {
+			pids = append(pids, int(pid))
+		}
+	}
+	return pids
+}
+
+func processImage(pid uint32) (string, error) {
+	process, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(process)
+
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(process, 0, &buf[0], &size); err != nil {
+		return "", err
+	}
+	return windows.UTF16ToString(buf[:size]), nil
+}
+
+// confirm asks a yes/no question in a message box. The fallback is only
+// needed on platforms that may have no way to ask.
+func confirm(title, message string, fallback bool) bool {
+	const idYes = 6
+	text, err := windows.UTF16PtrFromString(message)
+	if err != nil {
+		return false
+	}
+	caption, err := windows.UTF16PtrFromString(title)
+	if err != nil {
+		return false
+	}
+	ret, _ := windows.MessageBox(0, text, caption, windows.MB_YESNO|windows.MB_ICONQUESTION)
+	return ret == idYes
+}
+
+// promptText asks for a line of text with the Visual Basic InputBox through
+// PowerShell, since Win32 has no ready-made input dialog. The texts are
+// passed through the environment so they need no quoting. An empty answer
+// counts as cancelled, as InputBox cannot tell the two apart.
+func promptText(title, message, initial string) (string, bool) {
+	const script = `Add-Type -AssemblyName Microsoft.VisualBasic; ` +
+		`[Console]::OutputEncoding = [Text.Encoding]::UTF8; ` +
+		`[Microsoft.VisualBasic.Interaction]::InputBox($env:LMGO_PROMPT_MESSAGE, $env:LMGO_PROMPT_TITLE, $env:LMGO_PROMPT_INITIAL)`
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	cmd.Env = append(os.Environ(), "LMGO_PROMPT_TITLE="+title, "LMGO_PROMPT_MESSAGE="+message, "LMGO_PROMPT_INITIAL="+initial)
+	hideWindow(cmd)
+	out, err := cmd.Output()
+	text := strings.TrimSpace(string(out))
+	if err != nil || text == "" {
+		return "", false
+	}
+	return text, true
+}
+
+// acquireSingleInstance creates the named mutex guarding against a second
+// copy of lmgo. It reports false when another process already owns it.
+func acquireSingleInstance() (bool, error) {
+	name, err := windows.UTF16PtrFromString(singleInstanceMutexName)
+	if err != nil {
+		return false, err
+	}
+
+	handle, err := windows.CreateMutex(nil, false, name)
+	// A mutex created by a service running under another account exists
+	// but cannot be opened from here.
+	if errors.Is(err, windows.ERROR_ALREADY_EXISTS) || errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		if handle != 0 {
+			windows.CloseHandle(handle)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	instanceMutex = handle
+	return true, nil
+}
+
+// registerPowerNotifications subscribes to suspend/resume events so that
+// instances wedged by a lost GPU context can be recovered after wake.
+func registerPowerNotifications() error {
+	powrprof := windows.NewLazySystemDLL("powrprof.dll")
+	proc := powrprof.NewProc("PowerRegisterSuspendResumeNotification")
+	if err := proc.Find(); err != nil {
+		return err
+	}
+
+	powerNotifyParams.callback = syscall.NewCallback(func(context, eventType, setting uintptr) uintptr {
+		switch eventType {
+		case pbtAPMSuspend:
+			handleSuspend()
+		case pbtAPMResumeAutomatic:
+			go handleResume()
+		}
+		return 0
+	})
+
+	ret, _, _ := proc.Call(
+		deviceNotifyCallback,
+		uintptr(unsafe.Pointer(&powerNotifyParams)),
+		uintptr(unsafe.Pointer(&powerNotifyHandle)),
+	)
+	if ret != 0 {
+		return syscall.Errno(ret)
+	}
+	return nil
+}
+
+const (
+	autoStartRegPath = "Software\\Microsoft\\Windows\\CurrentVersion\\Run"
+	autoStartName    = "lmgo"
+)
+
+var (
+	quotedExePattern   = regexp.MustCompile(`"([^"]+\.exe)"`)
+	taskCommandPattern = regexp.MustCompile(`<Command>([^<]*)</Command>`)
+	taskDelayPattern   = regexp.MustCompile(`<Delay>PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?</Delay>`)
+)
+
+func useTaskScheduler() bool {
+	return strings.EqualFold(config().AutoStartMethod, "taskScheduler")
+}
+
+// setAutoStart creates or removes the auto-start entry for the configured
+// backend. Enabling one backend removes any entry left by the other, and
+// enabling over an existing entry rewrites it in place.
+func setAutoStart(enabled bool) error {
+	if !enabled {
+		if err := deleteRegistryAutoStart(); err != nil {
+			return err
+		}
+		return deleteScheduledTask()
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %v", err)
+	}
+
+	if useTaskScheduler() {
+		if err := deleteRegistryAutoStart(); err != nil {
+			return err
+		}
+		return createScheduledTask(exePath)
+	}
+
+	if err := deleteScheduledTask(); err != nil {
+		return err
+	}
+
+	key, err := registry.OpenKey(registry.CURRENT_USER, autoStartRegPath, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open registry key: %v", err)
+	}
+	defer key.Close()
+
+	if err := key.SetStringValue(autoStartName, fmt.Sprintf("\"%s\" --boot", exePath)); err != nil {
+		return fmt.Errorf("failed to set registry value: %v", err)
+	}
+	return nil
+}
+
+// readAutoStartTarget returns the executable path stored in the auto-start
+// entry. The configured backend is checked first, then the other one, so an
+// entry left behind by a change of autoStartMethod is still found.
+func readAutoStartTarget() (string, bool) {
+	first, second := readRegistryTarget, readTaskTarget
+	if useTaskScheduler() {
+		first, second = second, first
+	}
+	if target, ok := first(); ok {
+		return target, true
+	}
+	return second()
+}
+
+// autoStartOutdated reports whether the auto-start entry must be rewritten
+// although it launches the right executable: it belongs to the other
+// backend, or the task delay differs from startupDelaySeconds.
+func autoStartOutdated() bool {
+	task, hasTask := queryScheduledTask()
+	if !useTaskScheduler() {
+		return hasTask
+	}
+	if _, ok := readRegistryTarget(); ok {
+		return true
+	}
+	return hasTask && taskDelay(task) != config().StartupDelay
+}
+
+func queryScheduledTask() ([]byte, bool) {
+	cmd := exec.Command("schtasks", "/Query", "/TN", autoStartName, "/XML")
+	hideWindow(cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func readTaskTarget() (string, bool) {
+	task, ok := queryScheduledTask()
+	if !ok {
+		return "", false
+	}
+	m := taskCommandPattern.FindSubmatch(task)
+	if m == nil {
+		return "", false
+	}
+	return strings.Trim(html.UnescapeString(string(m[1])), `"`), true
+}
+
+// taskDelay returns the logon delay of a task definition in seconds, 0 when
+// it has none.
+func taskDelay(task []byte) int {
+	m := taskDelayPattern.FindSubmatch(task)
+	if m == nil {
+		return 0
+	}
+	seconds := 0
+	for i, unit := range []int{3600, 60, 1} {
+		n, _ := strconv.Atoi(string(m[i+1]))
+		seconds += n * unit
+	}
+	return seconds
+}
+
+func readRegistryTarget() (string, bool) {
+	key, err := registry.OpenKey(registry.CURRENT_USER, autoStartRegPath, registry.QUERY_VALUE)
+	if err != nil {
+		return "", false
+	}
+	defer key.Close()
+
+	value, _, err := key.GetStringValue(autoStartName)
+	if err != nil {
+		return "", false
+	}
+	return parseAutoStartCommand(value), true
+}
+
+// parseAutoStartCommand extracts the executable from a Run value. Older
+// versions wrote `cd /d "dir" && "exe"`, so the last quoted .exe wins.
+func parseAutoStartCommand(value string) string {
+	matches := quotedExePattern.FindAllStringSubmatch(value, -1)
+	if len(matches) > 0 {
+		return matches[len(matches)-1][1]
+	}
+	if fields := strings.Fields(value); len(fields) > 0 {
+		return fields[0]
+	}
+	return value
+}
+
+func deleteRegistryAutoStart() error {
+	key, err := registry.OpenKey(registry.CURRENT_USER, autoStartRegPath, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open registry key: %v", err)
+	}
+	defer key.Close()
+
+	if err := key.DeleteValue(autoStartName); err != nil && err != registry.ErrNotExist {
+		return fmt.Errorf("failed to delete registry value: %v", err)
+	}
+	return nil
+}
+
+// createScheduledTask registers a logon task. startupDelaySeconds becomes the
+// task's own delay, so the executable is started without --boot.
+func createScheduledTask(exePath string) error {
+	c := config()
+	args := []string{"/Create", "/F", "/TN", autoStartName, "/SC", "ONLOGON", "/RL", "LIMITED",
+		"/TR", fmt.Sprintf("\"%s\"", exePath)}
+	if c.StartupDelay > 0 {
+		args = append(args, "/DELAY", fmt.Sprintf("%04d:%02d", c.StartupDelay/60, c.StartupDelay%60))
+	}
+
+	cmd := exec.Command("schtasks", args...)
+	hideWindow(cmd)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create scheduled task: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func deleteScheduledTask() error {
+	query := exec.Command("schtasks", "/Query", "/TN", autoStartName)
+	hideWindow(query)
+	if query.Run() != nil {
+		return nil
+	}
+
+	cmd := exec.Command("schtasks", "/Delete", "/F", "/TN", autoStartName)
+	hideWindow(cmd)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete scheduled task: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+type memoryStatusEx struct {
+	length               uint32
+	memoryLoad           uint32
+	totalPhys            uint64
+	availPhys            uint64
+	totalPageFile        uint64
+	availPageFile        uint64
+	totalVirtual         uint64
+	availVirtual         uint64
+	availExtendedVirtual uint64
+}
+
+// availableMemory returns the physical memory currently available. On the
+// unified-memory APUs lmgo targets this is also the GPU's budget.
+func availableMemory() (uint64, error) {
+	_, available, err := systemMemory()
+	return available, err
+}
+
+// systemMemory returns the total and available physical memory.
+func systemMemory() (total, available uint64, err error) {
+	status := memoryStatusEx{}
+	status.length = uint32(unsafe.Sizeof(status))
+
+	proc := windows.NewLazySystemDLL("kernel32.dll").NewProc("GlobalMemoryStatusEx")
+	ret, _, err := proc.Call(uintptr(unsafe.Pointer(&status)))
+	if ret == 0 {
+		return 0, 0, err
+	}
+	return status.totalPhys, status.availPhys, nil
+}
+
+// platformGPUUsage reports nothing on Windows: AMD exposes VRAM usage only
+// through ADLX, which needs cgo. NVIDIA cards are read with nvidia-smi.
+func platformGPUUsage() []GPUUsage {
+	return nil
+}
+
+// displayClassKey holds one subkey per display adapter driver.
+const displayClassKey = `SYSTEM\CurrentControlSet\Control\Class\{4d36e968-e325-11ce-bfc1-08002be10318}`
+
+// detectGPUs lists the display adapters from the registry, telling the
+// vendors apart by the PCI vendor ID in their device ID.
+func detectGPUs() []gpuInfo {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, displayClassKey, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return nil
+	}
+	defer key.Close()
+
+	names, _ := key.ReadSubKeyNames(-1)
+	var gpus []gpuInfo
+	for _, name := range names {
+		sub, err := registry.OpenKey(key, name, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		desc, _, _ := sub.GetStringValue("DriverDesc")
+		id, _, _ := sub.GetStringValue("MatchingDeviceId")
+		sub.Close()
+
+		id = strings.ToLower(id)
+		for ven, vendor := range map[string]string{"ven_1002": "amd", "ven_10de": "nvidia", "ven_8086": "intel"} {
+			if strings.Contains(id, ven) {
+				gpus = append(gpus, gpuInfo{Vendor: vendor, Name: desc})
+			}
+		}
+	}
+	addComputeCaps(gpus)
+	return gpus
+}
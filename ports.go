@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultPortRange is how many ports from llamaServerPort are handed out
+// when llamaServerPortMax is not set.
+const defaultPortRange = 100
+
+// portRange returns the first and last port llama-server instances may use.
+func portRange() (int, int) {
+	c := config()
+	last := c.MaxServerPort
+	if last == 0 {
+		last = c.LlamaServerPort + defaultPortRange - 1
+	}
+	return c.LlamaServerPort, min(last, 65535)
+}
+
+func validatePortRange(c *Config) error {
+	if c.MaxServerPort != 0 && c.MaxServerPort < c.LlamaServerPort {
+		return fmt.Errorf("llamaServerPortMax (%d) is below llamaServerPort (%d)", c.MaxServerPort, c.LlamaServerPort)
+	}
+	return nil
+}
+
+// validateModelPorts rejects modelPorts entries that can never be bound:
+// ports out of range, the API port, and ports given to two models.
+func validateModelPorts(c *Config) error {
+	names := make([]string, 0, len(c.ModelPorts))
+	for name := range c.ModelPorts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	owners := make(map[int]string)
+	for _, name := range names {
+		port := c.ModelPorts[name]
+		switch {
+		case port <= 0 || port > 65535:
+			return fmt.Errorf("modelPorts: invalid port %d for %s", port, name)
+		case port == c.BasePort:
+			return fmt.Errorf("modelPorts: port %d of %s is the API port", port, name)
+		case owners[port] != "":
+			return fmt.Errorf("modelPorts: %s and %s both use port %d", owners[port], name, port)
+		}
+		owners[port] = name
+	}
+	return nil
+}
+
+// fixedPort looks up the modelPorts entry of an instance, by configuration
+// name or file name, with or without .gguf.
+func fixedPort(instance *modelInstance) (int, bool) {
+	for name, port := range config().ModelPorts {
+		name = strings.TrimSuffix(name, ".gguf")
+		if strings.EqualFold(name, displayName(instance)) || strings.EqualFold(name, instance.entry.BaseName) {
+			return port, true
+		}
+	}
+	return 0, false
+}
+
+// reservedPort reports whether port is set aside for a model in modelPorts.
+func reservedPort(port int) bool {
+	for _, p := range config().ModelPorts {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// allocatePort picks the port of a new instance: its modelPorts entry if it
+// has one, otherwise the next free port. A fixed port that is taken is an
+// error rather than a silent move, since clients expect the model there.
+// Only further instances of the same model fall back to a free port. Callers
+// must hold runningModelsMu.
+func allocatePort(instance *modelInstance) (int, error) {
+	port, ok := fixedPort(instance)
+	if !ok {
+		return nextFreePort()
+	}
+	for _, other := range runningModels {
+		if other.port != port {
+			continue
+		}
+		if other.entry.Path == instance.entry.Path && other.configIndex == instance.configIndex {
+			free, err := nextFreePort()
+			if err == nil {
+				log.Printf("Port %d of %s is taken by its first instance, using port %d", port, displayName(instance), free)
+			}
+			return free, err
+		}
+		return 0, fmt.Errorf("port %d, set for %s in modelPorts, is in use by %s", port, displayName(instance), displayName(other))
+	}
+	if !portAvailable(port) {
+		return 0, fmt.Errorf("port %d, set for %s in modelPorts, is in use by another program", port, displayName(instance))
+	}
+	return port, nil
+}
+
+// preferredPortFree reports whether port can be given to instance: it is in
+// range, not the API port, not used by another instance, not reserved for
+// another model in modelPorts, and not taken by another program. Callers must
+// hold runningModelsMu.
+func preferredPortFree(instance *modelInstance, port int) bool {
+	if port <= 0 || port > 65535 || port == config().BasePort || portInUse(port) {
+		return false
+	}
+	if fixed, ok := fixedPort(instance); reservedPort(port) && (!ok || fixed != port) {
+		return false
+	}
+	return portAvailable(port)
+}
+
+// nextFreePort returns the lowest port in the llama-server port range that
+// no running instance uses, no other program is listening on and modelPorts
+// does not reserve, so ports of unloaded instances are reused first. Callers
+// must hold runningModelsMu.
+func nextFreePort() (int, error) {
+	first, last := portRange()
+	for port := first; port <= last; port++ {
+		if port == config().BasePort || portInUse(port) || reservedPort(port) {
+			continue
+		}
+		if portAvailable(port) {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free port between %d and %d; unload a model or widen llamaServerPortMax", first, last)
+}
+
+// portInUse reports whether a running instance has port. Callers must hold
+// runningModelsMu.
+func portInUse(port int) bool {
+	for _, instance := range runningModels {
+		if instance.port == port {
+			return true
+		}
+	}
+	return false
+}
+
+// portAvailable probes whether port can be bound, both on all interfaces and
+// on loopback, since llama-server may be started with either as --host.
+func portAvailable(port int) bool {
+	for _, host := range []string{"", "127.0.0.1"} {
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			return false
+		}
+		ln.Close()
+	}
+	return true
+}
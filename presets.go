@@ -0,0 +1,33 @@
+package main
+
+import "fmt"
+
+// validatePresets checks that every preset referenced from modelSpecificArgs
+// is defined.
+func validatePresets(c *Config) error {
+	for _, cfg := range c.ModelSpecificArgs {
+		if cfg.Preset == "" {
+			continue
+		}
+		if _, ok := c.Presets[cfg.Preset]; !ok {
+			return fmt.Errorf("modelSpecificArgs entry %q uses undefined preset %q", cfg.Name, cfg.Preset)
+		}
+	}
+	return nil
+}
+
+// entryArgs returns the arguments of a modelSpecificArgs entry. An entry with
+// a preset starts from defaultArgs, applies the preset and then its own args
+// on top; an entry without one replaces defaultArgs with its args.
+func entryArgs(cfg ModelConfig) []string {
+	if cfg.Preset == "" {
+		return cfg.Args
+	}
+	return mergeArgs(config().DefaultArgs, ownArgs(cfg))
+}
+
+// ownArgs returns the arguments an entry sets itself, through its preset or
+// its args, which take precedence over automatic values.
+func ownArgs(cfg ModelConfig) []string {
+	return mergeArgs(config().Presets[cfg.Preset], cfg.Args)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxProgressLineLength = 4096
+
+// Phases of a load after the tensors are read, which can take a while for
+// large contexts and are otherwise indistinguishable from a hang at 100%.
+const (
+	phaseTensors = "loading tensors"
+	phaseContext = "creating context"
+	phaseWarmup  = "warming up"
+)
+
+// loadProgress tracks how far llama-server has got loading a model, based on
+// its console output. Unknown output leaves the percentage at -1, which is
+// shown as "loading" with the elapsed time.
+type loadProgress struct {
+	mu        sync.Mutex
+	percent   int
+	phase     string
+	loaded    bool
+	startedAt time.Time
+}
+
+func newLoadProgress() *loadProgress {
+	return &loadProgress{percent: -1, startedAt: time.Now()}
+}
+
+// writer returns a progressWriter that parses one of llama-server's output
+// streams into p. Use one per stream: a run of tensor loading dots would be
+// broken up by lines from the other stream.
+func (p *loadProgress) writer() *progressWriter {
+	return &progressWriter{progress: p}
+}
+
+func (p *loadProgress) snapshot() (percent int, loaded bool, elapsed time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.percent, p.loaded, time.Since(p.startedAt)
+}
+
+// currentPhase returns the phase of the load, or "" before the first one
+// is recognized.
+func (p *loadProgress) currentPhase() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.phase
+}
+
+func (p *loadProgress) update(percent int, phase string, loaded bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if percent > p.percent {
+		p.percent = percent
+	}
+	if phase != "" {
+		p.phase = phase
+	}
+	if loaded {
+		p.loaded = true
+	}
+}
+
+// label formats the progress for menu titles and the tooltip, e.g.
+// "Loading 63%", "Warming up… 40s" or "Loading… 12s".
+func (p *loadProgress) label() string {
+	percent, loaded, elapsed := p.snapshot()
+	phase := p.currentPhase()
+	switch {
+	case percent >= 100 && !loaded && phase != "" && phase != phaseTensors:
+		return fmt.Sprintf("%s… %ds", strings.ToUpper(phase[:1])+phase[1:], int(elapsed.Seconds()))
+	case percent >= 0:
+		return fmt.Sprintf("Loading %d%%", percent)
+	}
+	return fmt.Sprintf("Loading… %ds", int(elapsed.Seconds()))
+}
+
+type progressWriter struct {
+	progress *loadProgress
+	line     []byte
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	for _, c := range b {
+		if c == '\n' || c == '\r' {
+			if percent, phase, loaded, ok := parseProgressLine(string(w.line)); ok {
+				w.progress.update(percent, phase, loaded)
+			}
+			w.line = w.line[:0]
+			continue
+		}
+
+		if len(w.line) < maxProgressLineLength {
+			w.line = append(w.line, c)
+		}
+
+		// The tensor loading dots arrive one at a time without a newline.
+		if c == '.' {
+			if percent, ok := parseDotProgress(string(w.line)); ok {
+				w.progress.update(percent, phaseTensors, false)
+			}
+		}
+	}
+	return len(b), nil
+}
+
+// parseDotProgress interprets a line made only of dots. llama.cpp prints one
+// dot per percent of tensor data loaded.
+func parseDotProgress(line string) (int, bool) {
+	if line == "" || strings.Trim(line, ".") != "" {
+		return 0, false
+	}
+	return min(len(line), 100), true
+}
+
+// parseProgressLine recognizes the phase markers llama-server prints while
+// loading. It reports ok=false for lines that carry no progress information.
+func parseProgressLine(line string) (percent int, phase string, loaded bool, ok bool) {
+	line = strings.TrimSpace(line)
+
+	if p, isDots := parseDotProgress(line); isDots {
+		return p, phaseTensors, false, true
+	}
+
+	switch {
+	case strings.Contains(line, "model loaded"),
+		strings.Contains(line, "server is listening"):
+		return 100, "", true, true
+	case strings.Contains(line, "warming up the model"):
+		return 100, phaseWarmup, false, true
+	case strings.HasPrefix(line, "llama_context:"),
+		strings.HasPrefix(line, "llama_init_from_model:"):
+		return 100, phaseContext, false, true
+	case strings.Contains(line, "loading model tensors"):
+		return 0, phaseTensors, false, true
+	}
+	return 0, "", false, false
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProgressLine(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		wantOK      bool
+		wantPercent int
+		wantPhase   string
+		wantLoaded  bool
+	}{
+		// Load progress, as printed by recent and older llama.cpp builds.
+		{name: "tensors start", line: "load_tensors: loading model tensors, this can take a while... (mmap = true)", wantOK: true, wantPhase: phaseTensors},
+		{name: "tensors start, old prefix", line: "llm_load_tensors: loading model tensors, this can take a while... (mmap = false)", wantOK: true, wantPhase: phaseTensors},
+		{name: "context", line: "llama_context: constructing llama_context", wantOK: true, wantPercent: 100, wantPhase: phaseContext},
+		{name: "context, old prefix", line: "llama_init_from_model: n_ctx      = 8192", wantOK: true, wantPercent: 100, wantPhase: phaseContext},
+		{name: "warmup", line: "common_init_from_params: warming up the model with an empty run - please wait ... (--no-warmup to disable)", wantOK: true, wantPercent: 100, wantPhase: phaseWarmup},
+		{name: "model loaded", line: "main: model loaded", wantOK: true, wantPercent: 100, wantLoaded: true},
+		{name: "listening", line: "main: server is listening on http://127.0.0.1:8081 - starting the main loop", wantOK: true, wantPercent: 100, wantLoaded: true},
+
+		// Tensor lines: the dots llama.cpp prints while reading tensor data.
+		{name: "dots", line: "..........", wantOK: true, wantPercent: 10, wantPhase: phaseTensors},
+		{name: "dots with spaces", line: "  .....  ", wantOK: true, wantPercent: 5, wantPhase: phaseTensors},
+		{name: "more than 100 dots", line: strings.Repeat(".", 130), wantOK: true, wantPercent: 100, wantPhase: phaseTensors},
+		{name: "tensor buffer", line: "load_tensors:        CUDA0 model buffer size =  4403.49 MiB"},
+		{name: "offloaded layers", line: "load_tensors: offloaded 33/33 layers to GPU"},
+		{name: "model loader", line: "llama_model_loader: loaded meta data with 29 key-value pairs and 291 tensors from /models/a.gguf (version GGUF V3 (latest))"},
+		{name: "load_model", line: "srv    load_model: loading model '/models/a.gguf'"},
+
+		// Slot lines, printed once the server runs.
+		{name: "slots idle", line: "srv  update_slots: all slots are idle"},
+		{name: "slot task", line: "slot launch_slot_: id  0 | task 0 | processing task"},
+		{name: "slot prompt", line: "slot update_slots: id  0 | task 0 | new prompt, n_ctx_slot = 8192, n_keep = 0, n_prompt_tokens = 11"},
+		{name: "slot release", line: "slot      release: id  0 | task 0 | stop processing: n_past = 42, truncated = 0"},
+
+		// Malformed lines.
+		{name: "empty", line: ""},
+		{name: "blank", line: " \t "},
+		{name: "dots then text", line: "....x"},
+		{name: "text then dots", line: "loading...."},
+		{name: "binary", line: "\x00\xff\xfe"},
+		{name: "prefix without colon", line: "llama_context constructing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			percent, phase, loaded, ok := parseProgressLine(tt.line)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if percent != tt.wantPercent || phase != tt.wantPhase || loaded != tt.wantLoaded {
+				t.Errorf("got %d%% %q loaded=%v, want %d%% %q loaded=%v",
+					percent, phase, loaded, tt.wantPercent, tt.wantPhase, tt.wantLoaded)
+			}
+		})
+	}
+}
+
+func TestParseDotProgress(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   int
+		wantOK bool
+	}{
+		{".", 1, true},
+		{strings.Repeat(".", 63), 63, true},
+		{strings.Repeat(".", 250), 100, true},
+		{"", 0, false},
+		{". .", 0, false},
+		{"..a", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseDotProgress(tt.line)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseDotProgress(%q) = %d, %v; want %d, %v", tt.line, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// capturedLoad is the console output of llama-server loading a model, cut
+// down to the lines the parser looks at and some it must ignore.
+var capturedLoad = []string{
+	"build: 6500 (a1b2c3d4) with cc (GCC) 14.2.1 for x86_64-pc-linux-gnu",
+	"llama_model_loader: loaded meta data with 29 key-value pairs and 291 tensors from /models/a.gguf (version GGUF V3 (latest))",
+	"load_tensors: loading model tensors, this can take a while... (mmap = true)",
+	"load_tensors: offloaded 33/33 layers to GPU",
+	"load_tensors:        CUDA0 model buffer size =  4403.49 MiB",
+	strings.Repeat(".", 40),
+	strings.Repeat(".", 87),
+	"llama_context: constructing llama_context",
+	"common_init_from_params: warming up the model with an empty run - please wait ... (--no-warmup to disable)",
+	"main: model loaded",
+	"main: server is listening on http://127.0.0.1:8081 - starting the main loop",
+	"srv  update_slots: all slots are idle",
+}
+
+func TestProgressWriter(t *testing.T) {
+	tests := []struct {
+		name        string
+		lines       []string
+		newline     string
+		wantPercent int
+		wantPhase   string
+		wantLoaded  bool
+	}{
+		{"complete load", capturedLoad, "\n", 100, phaseWarmup, true},
+		{"complete load, CRLF", capturedLoad, "\r\n", 100, phaseWarmup, true},
+		{"while reading tensors", capturedLoad[:6], "\n", 40, phaseTensors, false},
+		{"while warming up", capturedLoad[:9], "\n", 100, phaseWarmup, false},
+		{"unknown output", []string{"something else entirely", "version 2"}, "\n", -1, "", false},
+		{"overlong line", []string{strings.Repeat("x", 2*maxProgressLineLength) + " model loaded"}, "\n", -1, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress := newLoadProgress()
+			w := progress.writer()
+			// Write a byte at a time: output arrives in arbitrary chunks.
+			for _, c := range []byte(strings.Join(tt.lines, tt.newline) + tt.newline) {
+				w.Write([]byte{c})
+			}
+			percent, loaded, _ := progress.snapshot()
+			if percent != tt.wantPercent || loaded != tt.wantLoaded || progress.currentPhase() != tt.wantPhase {
+				t.Errorf("got %d%% %q loaded=%v, want %d%% %q loaded=%v",
+					percent, progress.currentPhase(), loaded, tt.wantPercent, tt.wantPhase, tt.wantLoaded)
+			}
+		})
+	}
+}
+
+func TestProgressWriterDotsWithoutNewline(t *testing.T) {
+	progress := newLoadProgress()
+	w := progress.writer()
+	w.Write([]byte("load_tensors: loading model tensors\n"))
+	w.Write([]byte(strings.Repeat(".", 25)))
+	if percent, _, _ := progress.snapshot(); percent != 25 {
+		t.Errorf("percent = %d while dots are still arriving, want 25", percent)
+	}
+}
+
+func TestLoadProgressNeverGoesBack(t *testing.T) {
+	progress := newLoadProgress()
+	progress.update(70, phaseTensors, false)
+	progress.update(0, phaseTensors, false)
+	if percent, _, _ := progress.snapshot(); percent != 70 {
+		t.Errorf("percent = %d after a lower update, want 70", percent)
+	}
+}
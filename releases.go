@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// releasesRepo is the GitHub repository llama.cpp builds are downloaded
+// from.
+const releasesRepo = "ggml-org/llama.cpp"
+
+var githubAPI = "https://api.github.com"
+
+// releasesDir holds the downloaded builds, one directory per release tag and
+// one below it per archive, so that switching release or backend back and
+// forth does not download again.
+const releasesDir = "releases"
+
+var releaseTagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+type githubRelease struct {
+	TagName string        `json:"tag_name"`
+	Assets  []githubAsset `json:"assets"`
+}
+
+// githubAsset is a file attached to a release. Digest is "sha256:<hex>" for
+// assets uploaded since GitHub started recording it, and empty before.
+type githubAsset struct {
+	Name   string `json:"name"`
+	URL    string `json:"browser_download_url"`
+	Size   int64  `json:"size"`
+	Digest string `json:"digest"`
+}
+
+// validateServerRelease checks the serverRelease setting, which ends up in a
+// directory name.
+func validateServerRelease(tag string) error {
+	if tag == "" || releaseTagPattern.MatchString(tag) {
+		return nil
+	}
+	return fmt.Errorf("invalid serverRelease %q: expected a release tag such as b6500, or latest", tag)
+}
+
+// releaseServer returns llama-server from the configured llama.cpp release,
+// downloading and extracting the build for this platform and backend unless
+// it is cached already. When "latest" cannot be resolved, e.g. offline, the
+// newest cached release is used.
+func releaseServer(tag string) (string, error) {
+	if tag != "latest" {
+		if path, ok := cachedReleaseServer(tag); ok {
+			log.Printf("Using cached llama-server %s: %s", tag, path)
+			return path, nil
+		}
+	}
+
+	release, err := fetchRelease(tag)
+	if err != nil {
+		if tag != "latest" {
+			return "", err
+		}
+		for _, t := range cachedReleaseTags() {
+			if path, ok := cachedReleaseServer(t); ok {
+				log.Printf("Could not look up the latest release (%v), using cached release %s", err, t)
+				return path, nil
+			}
+		}
+		return "", err
+	}
+
+	asset, ok := pickReleaseAsset(release)
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s build for %s/%s", release.TagName, backendLabel(config().Backend), runtime.GOOS, runtime.GOARCH)
+	}
+
+	dest := filepath.Join(releasesDir, release.TagName, archiveBaseName(asset.Name))
+	if paths := findServerBinaries(dest); len(paths) > 0 {
+		log.Printf("Using llama-server %s from %s", release.TagName, dest)
+		return paths[0], nil
+	}
+
+	assets := []githubAsset{asset}
+	if cudart := cudaRuntimeAsset(release, asset); cudart != nil {
+		assets = append(assets, *cudart)
+	}
+
+	// Archives are extracted next to the final directory and moved into
+	// place once complete, so an interrupted download is never mistaken for
+	// a cached build.
+	part := dest + ".part"
+	os.RemoveAll(part)
+	if err := os.MkdirAll(part, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", part, err)
+	}
+	for _, a := range assets {
+		path, err := downloadAsset(a, filepath.Dir(part))
+		if err != nil {
+			os.RemoveAll(part)
+			return "", fmt.Errorf("failed to download %s: %v", a.Name, err)
+		}
+		err = extractAsset(a, path, part)
+		os.Remove(path)
+		if err != nil {
+			os.RemoveAll(part)
+			return "", fmt.Errorf("failed to extract %s: %v", a.Name, err)
+		}
+	}
+	if len(findServerBinaries(part)) == 0 {
+		os.RemoveAll(part)
+		return "", fmt.Errorf("%s not found in %s", serverBinaryName, asset.Name)
+	}
+	if err := os.Rename(part, dest); err != nil {
+		return "", fmt.Errorf("failed to move the build into place: %v", err)
+	}
+
+	paths := findServerBinaries(dest)
+	log.Printf("Server %s extracted to: %s", release.TagName, paths[0])
+	return paths[0], nil
+}
+
+// fetchRelease looks up a release, or the latest one, on GitHub. A token in
+// GITHUB_TOKEN raises the rate limit for unauthenticated requests.
+func fetchRelease(tag string) (githubRelease, error) {
+	endpoint := fmt.Sprintf("%s/repos/%s/releases/tags/%s", githubAPI, releasesRepo, tag)
+	if tag == "latest" {
+		endpoint = fmt.Sprintf("%s/repos/%s/releases/latest", githubAPI, releasesRepo)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return githubRelease{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return githubRelease{}, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return githubRelease{}, fmt.Errorf("release %s not found in %s", tag, releasesRepo)
+	case resp.StatusCode != http.StatusOK:
+		return githubRelease{}, fmt.Errorf("looking up release %s: %s", tag, resp.Status)
+	}
+
+	var release githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return githubRelease{}, fmt.Errorf("invalid release response: %v", err)
+	}
+	if !releaseTagPattern.MatchString(release.TagName) {
+		return githubRelease{}, fmt.Errorf("unexpected release tag %q", release.TagName)
+	}
+	return release, nil
+}
+
+// pickReleaseAsset chooses the archive for this platform and backend the
+// same way as among embedded archives, see selectServerArchive. The CUDA
+// runtime archives published for Windows contain no server and are skipped.
+func pickReleaseAsset(release githubRelease) (githubAsset, bool) {
+	var names []string
+	for _, a := range release.Assets {
+		if strings.HasPrefix(a.Name, "cudart-") {
+			continue
+		}
+		if strings.HasSuffix(a.Name, ".zip") || strings.HasSuffix(a.Name, ".tar.gz") {
+			names = append(names, a.Name)
+		}
+	}
+	names = namesMentioning(names, archiveKeywords)
+	names = namesMentioning(names, archKeywords[runtime.GOARCH])
+
+	name, ok := pickBuild(names, config().Backend, detectedGPUs)
+	if !ok {
+		return githubAsset{}, false
+	}
+	for _, a := range release.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return githubAsset{}, false
+}
+
+// cudaRuntimeAsset returns the CUDA runtime archive matching a Windows CUDA
+// build, e.g. cudart-llama-bin-win-cuda-12.4-x64.zip for
+// llama-b6500-bin-win-cuda-12.4-x64.zip, or nil when there is none.
+func cudaRuntimeAsset(release githubRelease, build githubAsset) *githubAsset {
+	if buildBackend(build.Name) != "cuda" {
+		return nil
+	}
+	suffix := strings.TrimPrefix(build.Name, "llama-"+release.TagName+"-")
+	for i, a := range release.Assets {
+		if a.Name == "cudart-llama-"+suffix {
+			return &release.Assets[i]
+		}
+	}
+	return nil
+}
+
+// releaseClient downloads release archives. The timeout only ends a stalled
+// download: CUDA builds are several hundred MB.
+var releaseClient = &http.Client{Timeout: 30 * time.Minute}
+
+// downloadAsset streams an archive to a temporary file in dir and checks it
+// against the SHA-256 digest GitHub records for it, returning the file's
+// path. Older releases have no digest; installing one of them unverified
+// needs the user's confirmation in the tray and is refused headless.
+func downloadAsset(asset githubAsset, dir string) (string, error) {
+	want, found := strings.CutPrefix(asset.Digest, "sha256:")
+	if !found {
+		message := fmt.Sprintf("No checksum is published for %s, so it cannot be verified. Install it anyway?", asset.Name)
+		if headless || !confirm("lmgo", message, false) {
+			return "", fmt.Errorf("no checksum published for %s; choose a newer serverRelease", asset.Name)
+		}
+		log.Printf("Warning: installing %s without a checksum, as confirmed", asset.Name)
+	}
+
+	log.Printf("Downloading %s (%.0f MB)", asset.Name, float64(asset.Size)/(1<<20))
+	resp, err := releaseClient.Get(asset.URL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	file, err := os.CreateTemp(dir, "download-*")
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	if found {
+		if got := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(got, want) {
+			os.Remove(file.Name())
+			return "", fmt.Errorf("checksum mismatch: expected sha256 %s, got %s", want, got)
+		}
+		log.Printf("Verified the checksum of %s", asset.Name)
+	}
+	return file.Name(), nil
+}
+
+// extractAsset extracts a downloaded archive into dest.
+func extractAsset(asset githubAsset, path, dest string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if !strings.HasSuffix(asset.Name, ".zip") {
+		return extractTarGz(file, dest)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return extractZip(file, info.Size(), dest)
+}
+
+// cachedReleaseServer returns llama-server from a downloaded build of tag
+// for the current backend, if there is one.
+func cachedReleaseServer(tag string) (string, bool) {
+	entries, err := os.ReadDir(filepath.Join(releasesDir, tag))
+	if err != nil {
+		return "", false
+	}
+	var builds []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasSuffix(entry.Name(), ".part") {
+			builds = append(builds, entry.Name())
+		}
+	}
+	build, ok := pickBuild(builds, config().Backend, detectedGPUs)
+	if !ok {
+		return "", false
+	}
+	paths := findServerBinaries(filepath.Join(releasesDir, tag, build))
+	if len(paths) == 0 {
+		return "", false
+	}
+	return paths[0], true
+}
+
+// cachedReleaseTags lists the downloaded releases, newest first. llama.cpp
+// tags are build numbers such as b6500.
+func cachedReleaseTags() []string {
+	entries, err := os.ReadDir(releasesDir)
+	if err != nil {
+		return nil
+	}
+	var tags []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			tags = append(tags, entry.Name())
+		}
+	}
+	buildNumber := func(tag string) int {
+		n, _ := strconv.Atoi(strings.TrimPrefix(tag, "b"))
+		return n
+	}
+	slices.SortFunc(tags, func(a, b string) int {
+		if na, nb := buildNumber(a), buildNumber(b); na != nb {
+			return nb - na
+		}
+		return strings.Compare(b, a)
+	})
+	return tags
+}
+
+// archiveBaseName strips the archive extension from name.
+func archiveBaseName(name string) string {
+	if base, ok := strings.CutSuffix(name, ".tar.gz"); ok {
+		return base
+	}
+	return strings.TrimSuffix(name, ".zip")
+}
+
+func backendLabel(backend string) string {
+	if backend == "" {
+		return "auto"
+	}
+	return backend
+}
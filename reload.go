@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// configPollInterval is how often lmgo.json is checked for changes.
+const configPollInterval = 2 * time.Second
+
+var (
+	configModTimeMu sync.Mutex
+	configModTime   time.Time
+)
+
+// recordConfigModTime remembers the modification time of lmgo.json after lmgo
+// writes it, so the watcher does not reload its own changes.
+func recordConfigModTime() {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return
+	}
+	configModTimeMu.Lock()
+	configModTime = info.ModTime()
+	configModTimeMu.Unlock()
+}
+
+// configChanged reports whether lmgo.json was modified since it was last
+// seen, and remembers the new modification time.
+func configChanged() bool {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return false
+	}
+	configModTimeMu.Lock()
+	defer configModTimeMu.Unlock()
+
+	if info.ModTime().Equal(configModTime) {
+		return false
+	}
+	configModTime = info.ModTime()
+	return true
+}
+
+// watchConfig applies edits to lmgo.json while lmgo runs. Most settings are
+// read when they are used, so reloading the config and rescanning the model
+// directory is enough; settings only read at startup are reported instead.
+func watchConfig() {
+	started := *config()
+	recordConfigModTime()
+
+	var reported string
+	for range time.Tick(configPollInterval) {
+		if !configChanged() {
+			continue
+		}
+		log.Printf("%s changed, reloading", configName())
+		if err := refreshConfigAndModels(); err != nil {
+			notify("Config Not Applied", fmt.Sprintf("%s: %v", configName(), err))
+			continue
+		}
+		applyAPIHost()
+		syncAutoStart()
+
+		settings := strings.Join(restartSettings(started, *config()), ", ")
+		if settings != "" && settings != reported {
+			notify("Restart Required", fmt.Sprintf("Restart lmgo to apply the new %s.", settings))
+		}
+		reported = settings
+	}
+}
+
+// restartSettings lists the settings that differ between two configs and
+// only take effect when lmgo starts.
+func restartSettings(old, new Config) []string {
+	var changed []string
+	if old.BasePort != new.BasePort {
+		changed = append(changed, "basePort")
+	}
+	if old.ServerPath != new.ServerPath {
+		changed = append(changed, "serverPath")
+	}
+	if old.Backend != new.Backend {
+		changed = append(changed, "backend")
+	}
+	if old.ServerRelease != new.ServerRelease {
+		changed = append(changed, "serverRelease")
+	}
+	if old.TLS != new.TLS || old.TLSCert != new.TLSCert || old.TLSKey != new.TLSKey {
+		changed = append(changed, "TLS settings")
+	}
+	return changed
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxSuggestions is how many similar names are offered for a name that
+// matches no model.
+const maxSuggestions = 3
+
+// ResolvedName is one entry of GET /api/resolve: what a name given to
+// autoLoadModels, the CLI or the proxy refers to.
+type ResolvedName struct {
+	Name        string   `json:"name"`
+	Matched     bool     `json:"matched"`
+	Alias       string   `json:"alias,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Config      string   `json:"config,omitempty"`
+	Path        string   `json:"path,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// resolveName looks a name up the way findModelByName does and, when nothing
+// matches, suggests the names that contain it or are contained in it, which
+// is what a name written for substring matching usually is.
+func resolveName(name string) ResolvedName {
+	resolved := ResolvedName{Name: name}
+	if target := resolveAlias(name); target != name {
+		resolved.Alias = target
+	}
+	modelIdx, configIdx, ok := findModelByName(name)
+	if !ok {
+		resolved.Suggestions = suggestModels(resolveAlias(name))
+		return resolved
+	}
+
+	entry, ok := modelAt(modelIdx)
+	if !ok {
+		resolved.Suggestions = suggestModels(resolveAlias(name))
+		return resolved
+	}
+	resolved.Matched = true
+	resolved.Model = entry.BaseName
+	resolved.Path = entry.Path
+	if cfg := modelSpecificConfig(entry, configIdx); cfg != nil {
+		resolved.Config = cfg.Name
+	}
+	return resolved
+}
+
+func suggestModels(name string) []string {
+	needle := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), ".gguf"))
+	if needle == "" {
+		return nil
+	}
+	var names []string
+	for _, m := range modelSnapshot() {
+		for _, cfg := range config().ModelSpecificArgs {
+			if cfg.Target == m.BaseName {
+				names = append(names, cfg.Name)
+			}
+		}
+		names = append(names, m.BaseName)
+	}
+
+	var suggestions []string
+	for _, candidate := range names {
+		lower := strings.ToLower(candidate)
+		if strings.Contains(lower, needle) || strings.Contains(needle, lower) {
+			suggestions = append(suggestions, candidate)
+			if len(suggestions) == maxSuggestions {
+				break
+			}
+		}
+	}
+	return suggestions
+}
+
+// describeUnmatched explains why a name matches no model, offering the
+// suggestions of resolveName.
+func describeUnmatched(resolved ResolvedName) string {
+	message := fmt.Sprintf("no model named %q", resolved.Name)
+	if resolved.Alias != "" {
+		message = fmt.Sprintf("alias %q points to %q, which is not in the library", resolved.Name, resolved.Alias)
+	}
+	if len(resolved.Suggestions) > 0 {
+		message += fmt.Sprintf(" (did you mean %s?)", strings.Join(quoteAll(resolved.Suggestions), " or "))
+	}
+	return message
+}
+
+func quoteAll(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return quoted
+}
+
+// handleResolve reports what each name parameter refers to. Without one it
+// resolves autoLoadModels, so a client can check the list before a restart.
+func handleResolve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+
+	names := r.URL.Query()["name"]
+	if len(names) == 0 {
+		for _, entry := range config().AutoLoadModels {
+			names = append(names, entry.Model)
+		}
+	}
+	results := make([]ResolvedName, 0, len(names))
+	unmatched := 0
+	for _, name := range names {
+		results = append(results, resolveName(name))
+		if !results[len(results)-1].Matched {
+			unmatched++
+		}
+	}
+
+	message := ""
+	if unmatched > 0 {
+		message = fmt.Sprintf("%d of %d names match no model", unmatched, len(names))
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: unmatched == 0, Message: message, Data: results})
+}
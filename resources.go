@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ResourceUsage is the response of GET /api/resources. Anything the platform
+// or an instance cannot report is left out rather than reported as zero.
+type ResourceUsage struct {
+	Memory    *MemoryUsage    `json:"memory,omitempty"`
+	GPUs      []GPUUsage      `json:"gpus,omitempty"`
+	Instances []InstanceSlots `json:"instances,omitempty"`
+}
+
+// MemoryUsage is used and total memory in bytes.
+type MemoryUsage struct {
+	Name  string `json:"name,omitempty"`
+	Used  uint64 `json:"used"`
+	Total uint64 `json:"total"`
+}
+
+// InstanceSlots is the slot occupancy of one instance, from llama-server's
+// /slots endpoint.
+type InstanceSlots struct {
+	Port  int `json:"port"`
+	Busy  int `json:"busy"`
+	Total int `json:"total"`
+}
+
+func handleResources(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+
+	var usage ResourceUsage
+	if total, available, err := systemMemory(); err == nil && total > 0 {
+		usage.Memory = &MemoryUsage{Used: total - min(available, total), Total: total}
+	}
+	usage.GPUs = gpuUsage()
+	usage.Instances = instanceSlots()
+
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: usage})
+}
+
+// instanceSlots queries the slots of every ready instance in parallel.
+// Instances started with --no-slots, or that do not answer in time, are
+// skipped.
+func instanceSlots() []InstanceSlots {
+	runningModelsMu.RLock()
+	var ports []int
+	for _, instance := range runningModels {
+		if instance.ready {
+			ports = append(ports, instance.port)
+		}
+	}
+	runningModelsMu.RUnlock()
+
+	results := make([]*InstanceSlots, len(ports))
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = querySlots(port)
+		}()
+	}
+	wg.Wait()
+
+	var slots []InstanceSlots
+	for _, result := range results {
+		if result != nil {
+			slots = append(slots, *result)
+		}
+	}
+	return slots
+}
+
+func querySlots(port int) *InstanceSlots {
+	slots, err := slotList(port)
+	if err != nil || len(slots) == 0 {
+		return nil
+	}
+
+	result := &InstanceSlots{Port: port, Total: len(slots)}
+	for _, slot := range slots {
+		if slot.Processing {
+			result.Busy++
+		}
+	}
+	return result
+}
+
+// maxSlotPrompt is how many characters of a slot's prompt GET /api/slots
+// returns, so that huge prompts are not passed on.
+const maxSlotPrompt = 200
+
+// errSlotsDisabled is returned for instances started without the /slots
+// endpoint.
+var errSlotsDisabled = errors.New("the /slots endpoint is disabled")
+
+// SlotInfo is one slot of an instance. Decoded is the number of tokens
+// generated so far for the current or last request.
+type SlotInfo struct {
+	ID         int    `json:"id"`
+	Processing bool   `json:"processing"`
+	Decoded    int    `json:"decoded"`
+	Prompt     string `json:"prompt,omitempty"`
+}
+
+// InstanceSlotList is one entry of GET /api/slots. Available is false when
+// the instance does not expose /slots; Error says why.
+type InstanceSlotList struct {
+	Name      string     `json:"name"`
+	Port      int        `json:"port"`
+	Available bool       `json:"available"`
+	Error     string     `json:"error,omitempty"`
+	Slots     []SlotInfo `json:"slots"`
+}
+
+// handleSlots lists the slots of every ready instance.
+func handleSlots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+
+	runningModelsMu.RLock()
+	var list []InstanceSlotList
+	for _, instance := range runningModels {
+		if instance.ready {
+			list = append(list, InstanceSlotList{Name: displayName(instance), Port: instance.port})
+		}
+	}
+	runningModelsMu.RUnlock()
+
+	var wg sync.WaitGroup
+	for i := range list {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots, err := slotList(list[i].Port)
+			if err != nil {
+				list[i].Error = err.Error()
+				return
+			}
+			list[i].Available = true
+			list[i].Slots = slots
+		}()
+	}
+	wg.Wait()
+
+	if list == nil {
+		list = []InstanceSlotList{}
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: list})
+}
+
+// slotList queries llama-server's /slots endpoint on port. Prompts are cut
+// to maxSlotPrompt characters; prompts given as tokens are left out.
+func slotList(port int) ([]SlotInfo, error) {
+	client := &http.Client{Timeout: 1 * time.Second}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/slots", port), nil)
+	if err != nil {
+		return nil, err
+	}
+	authorizeServerRequest(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotImplemented || resp.StatusCode == http.StatusNotFound:
+		return nil, errSlotsDisabled
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("/slots returned %s", resp.Status)
+	}
+
+	var raw []struct {
+		ID           int             `json:"id"`
+		IsProcessing bool            `json:"is_processing"`
+		Prompt       json.RawMessage `json:"prompt"`
+		NextToken    json.RawMessage `json:"next_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid /slots response: %v", err)
+	}
+
+	slots := make([]SlotInfo, len(raw))
+	for i, r := range raw {
+		slots[i] = SlotInfo{ID: r.ID, Processing: r.IsProcessing, Decoded: decodedTokens(r.NextToken)}
+		var prompt string
+		if json.Unmarshal(r.Prompt, &prompt) == nil {
+			if runes := []rune(prompt); len(runes) > maxSlotPrompt {
+				prompt = string(runes[:maxSlotPrompt])
+			}
+			slots[i].Prompt = prompt
+		}
+	}
+	return slots, nil
+}
+
+// decodedTokens reads n_decoded from a slot's next_token, which is an object
+// in older llama-server versions and an array of them in newer ones.
+func decodedTokens(raw json.RawMessage) int {
+	type nextToken struct {
+		NDecoded int `json:"n_decoded"`
+	}
+	var one nextToken
+	if json.Unmarshal(raw, &one) == nil {
+		return one.NDecoded
+	}
+	var many []nextToken
+	if json.Unmarshal(raw, &many) == nil && len(many) > 0 {
+		return many[0].NDecoded
+	}
+	return 0
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRoutedBody bounds the request bodies the router reads to find the
+// model, which also covers long prompts with images.
+const maxRoutedBody = 64 << 20
+
+var (
+	routeCounter   int
+	routeCounterMu sync.Mutex
+
+	// demandLoads are the loads started by requests, by model and
+	// configuration index, so that concurrent requests share one load.
+	demandLoads   = map[[2]int]*demandLoad{}
+	demandLoadsMu sync.Mutex
+)
+
+type demandLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// OpenAIModel is one entry of GET /v1/models. Meta holds the /api/models
+// fields of the model and whether and where it runs, which OpenAI clients
+// ignore.
+type OpenAIModel struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	OwnedBy string                 `json:"owned_by"`
+	Meta    map[string]interface{} `json:"meta,omitempty"`
+}
+
+// handleOpenAI serves the OpenAI-compatible API on the management port.
+// GET /v1/models lists every model of the library, and POST requests such as
+// /v1/chat/completions or /v1/completions are forwarded to the instance
+// named by their model field, so clients need a single endpoint.
+func handleOpenAI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/models" && r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"object": "list", "data": openAIModels()})
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRoutedBody+1))
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	if len(body) > maxRoutedBody {
+		writeOpenAIError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	var req struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	started := time.Now()
+	port, status, message := routeModel(req.Model)
+	if port == 0 && config().LoadOnDemand && req.Model != "" {
+		port, status, message = awaitModel(r.Context(), req.Model, status)
+	}
+	if port == 0 && !config().LoadOnDemand && status == http.StatusNotFound && req.Model != "" {
+		if _, _, ok := findModelByName(strings.TrimSuffix(req.Model, ".gguf")); ok {
+			message += "; load it first or enable loadOnDemandEnabled"
+		}
+	}
+	if port == 0 {
+		writeOpenAIError(w, status, message)
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	done := beginRequest(port)
+	defer done()
+
+	name := instanceName(port)
+	tap := &usageTap{}
+	instanceProxy(port, tap).ServeHTTP(w, r)
+	if name != "" {
+		prompt, completion := tap.tokens()
+		recordUsage(name, time.Since(started), tap.status == 0 || tap.status >= 400, prompt, completion)
+	}
+}
+
+// instanceName is the name of the instance on port as shown in the menu, or
+// "" if none runs there.
+func instanceName(port int) string {
+	runningModelsMu.RLock()
+	defer runningModelsMu.RUnlock()
+	for _, instance := range runningModels {
+		if instance.port == port {
+			return displayName(instance)
+		}
+	}
+	return ""
+}
+
+// routeModel finds the port of a ready instance for a model name, matched
+// against configuration and model names as shown in the menu, with or
+// without the .gguf extension. Requests without a model go to the only
+// running instance. Several instances of a model take turns. On failure it
+// returns 0 with the status and message to answer with.
+func routeModel(name string) (int, int, string) {
+	name = resolveAlias(strings.TrimSuffix(name, ".gguf"))
+
+	runningModelsMu.RLock()
+	defer runningModelsMu.RUnlock()
+
+	var matches []*modelInstance
+	for _, instance := range runningModels {
+		if name == "" || strings.EqualFold(displayName(instance), name) || strings.EqualFold(instance.entry.BaseName, name) {
+			matches = append(matches, instance)
+		}
+	}
+	switch {
+	case name == "" && len(matches) > 1:
+		return 0, http.StatusBadRequest, "Several models are running, the model field is required"
+	case len(matches) == 0 && name == "":
+		return 0, http.StatusNotFound, "No model is running"
+	case len(matches) == 0:
+		return 0, http.StatusNotFound, fmt.Sprintf("Model %s is not running", name)
+	}
+
+	var ready []*modelInstance
+	for _, instance := range matches {
+		if instance.ready {
+			ready = append(ready, instance)
+		}
+	}
+	if len(ready) == 0 {
+		return 0, http.StatusServiceUnavailable, fmt.Sprintf("Model %s is still loading", displayName(matches[0]))
+	}
+
+	routeCounterMu.Lock()
+	instance := ready[routeCounter%len(ready)]
+	routeCounter++
+	routeCounterMu.Unlock()
+	return instance.port, 0, ""
+}
+
+// awaitModel serves a request for a model that is not ready, with
+// loadOnDemandEnabled: a model that is not running is loaded, and the
+// request waits until it or an instance that is already loading is ready.
+// status is what routeModel answered.
+func awaitModel(ctx context.Context, name string, status int) (int, int, string) {
+	if status == http.StatusNotFound {
+		idx, configIdx, ok := findModelByName(strings.TrimSuffix(name, ".gguf"))
+		if !ok {
+			return 0, http.StatusNotFound, fmt.Sprintf("Model %s not found", name)
+		}
+		if err := loadOnDemand(ctx, name, idx, configIdx); err != nil {
+			return 0, http.StatusServiceUnavailable, fmt.Sprintf("Failed to load %s: %v", name, err)
+		}
+	}
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		port, status, message := routeModel(name)
+		if status != http.StatusServiceUnavailable {
+			return port, status, message
+		}
+		select {
+		case <-ctx.Done():
+			return 0, status, message
+		case <-ticker.C:
+		}
+	}
+}
+
+// loadOnDemand loads a model for a request, or joins a load another request
+// started, and waits for it unless the client gives up first.
+func loadOnDemand(ctx context.Context, name string, idx, configIdx int) error {
+	key := [2]int{idx, configIdx}
+
+	demandLoadsMu.Lock()
+	load, ok := demandLoads[key]
+	if !ok {
+		load = &demandLoad{done: make(chan struct{})}
+		demandLoads[key] = load
+		log.Printf("Loading %s on request", name)
+		go func() {
+			load.err = loadModelWithOptions(idx, configIdx, launchOptions{NoBrowser: true})
+			demandLoadsMu.Lock()
+			delete(demandLoads, key)
+			demandLoadsMu.Unlock()
+			close(load.done)
+		}()
+	}
+	demandLoadsMu.Unlock()
+
+	select {
+	case <-load.done:
+		return load.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// instanceProxy forwards a request to the llama-server on port. Responses
+// are flushed as they arrive so that streamed completions are not held
+// back, and the server's CORS headers are dropped in favour of ours. The
+// response is recorded in tap for the usage statistics.
+func instanceProxy(port int, tap *usageTap) *httputil.ReverseProxy {
+	target := &url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", port)}
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+		},
+		FlushInterval: -1,
+		ModifyResponse: func(resp *http.Response) error {
+			for key := range resp.Header {
+				if strings.HasPrefix(key, "Access-Control-") {
+					resp.Header.Del(key)
+				}
+			}
+			tap.tapBody(resp)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			writeOpenAIError(w, http.StatusBadGateway, fmt.Sprintf("llama-server on port %d did not respond: %v", port, err))
+		},
+	}
+}
+
+// openAIModels lists every model of the library, as in /api/models, with
+// the ports it runs on, followed by running models no longer in the library.
+func openAIModels() []OpenAIModel {
+	ports := map[string][]int{}
+	var running []string
+	runningModelsMu.RLock()
+	for _, instance := range runningModels {
+		name := displayName(instance)
+		if ports[name] == nil {
+			running = append(running, name)
+		}
+		ports[name] = append(ports[name], instance.port)
+	}
+	runningModelsMu.RUnlock()
+
+	models := []OpenAIModel{}
+	seen := map[string]bool{}
+	for _, item := range modelList() {
+		name, _ := item["name"].(string)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		item["loaded"] = len(ports[name]) > 0
+		item["ports"] = append([]int{}, ports[name]...)
+		modified, _ := item["modified"].(int64)
+		models = append(models, OpenAIModel{ID: name, Object: "model", Created: modified, OwnedBy: "lmgo", Meta: item})
+	}
+	for _, name := range running {
+		if !seen[name] {
+			meta := map[string]interface{}{"loaded": true, "ports": ports[name]}
+			models = append(models, OpenAIModel{ID: name, Object: "model", OwnedBy: "lmgo", Meta: meta})
+		}
+	}
+	return models
+}
+
+// writeOpenAIError answers in the error format OpenAI clients expect.
+func writeOpenAIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    "invalid_request_error",
+			"code":    status,
+		},
+	})
+}
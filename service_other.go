@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+func runningAsService() bool {
+	return false
+}
+
+func runService(boot bool, loadNames []string) error {
+	return fmt.Errorf("service mode is only supported on Windows")
+}
+
+func installService() error {
+	return fmt.Errorf("service installation is only supported on Windows; use --headless with systemd or launchd instead")
+}
+
+func uninstallService() error {
+	return fmt.Errorf("service installation is only supported on Windows")
+}
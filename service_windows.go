@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const serviceName = "lmgo"
+
+type lmgoService struct {
+	boot      bool
+	loadNames []string
+}
+
+func runningAsService() bool {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		log.Printf("Warning: Failed to detect service mode: %v", err)
+		return false
+	}
+	return isService
+}
+
+func runService(boot bool, loadNames []string) error {
+	return svc.Run(serviceName, &lmgoService{boot: boot, loadNames: loadNames})
+}
+
+func (s *lmgoService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+
+	status <- svc.Status{State: svc.StartPending}
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		if err := startBackend(); err != nil {
+			done <- err
+			return
+		}
+		runHeadless(stop, s.boot, s.loadNames)
+		done <- nil
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: accepted}
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				log.Printf("Service stopped: %v", err)
+				return false, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				close(stop)
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
+
+func installService() error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %v", err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %v", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", serviceName)
+	}
+
+	s, err := m.CreateService(serviceName, exePath, mgr.Config{
+		DisplayName: "lmgo Model Server",
+		Description: "Manages llama-server model instances for lmgo",
+		StartType:   mgr.StartAutomatic,
+	}, "--boot")
+	if err != nil {
+		return fmt.Errorf("failed to create service: %v", err)
+	}
+	defer s.Close()
+
+	log.Printf("Service %s installed for %s", serviceName, exePath)
+	return nil
+}
+
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %v", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %v", err)
+	}
+
+	log.Printf("Service %s removed", serviceName)
+	return nil
+}
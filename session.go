@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// sessionInstance is a running instance as remembered in the session file:
+// enough to launch it again the same way.
+type sessionInstance struct {
+	Path     string   `json:"path"`
+	Config   string   `json:"config,omitempty"`
+	Port     int      `json:"port"`
+	Preset   string   `json:"preset,omitempty"`
+	Parallel int      `json:"parallel,omitempty"`
+	Args     []string `json:"args,omitempty"`
+}
+
+var (
+	sessionMu sync.Mutex
+
+	// previousSession is what was running when lmgo last exited, read at
+	// startup before any instance overwrites the file.
+	previousSession []sessionInstance
+)
+
+// sessionPath is the session file next to the config file, e.g.
+// lmgo-session.json for lmgo.json.
+func sessionPath() string {
+	name := strings.TrimSuffix(configName(), filepath.Ext(configName()))
+	return filepath.Join(filepath.Dir(configPath), name+"-session.json")
+}
+
+// saveSession records the ready instances. It runs whenever one becomes
+// ready or goes away, so the file is current even if lmgo does not exit
+// cleanly. Shutting down does not clear it.
+func saveSession() {
+	runningModelsMu.RLock()
+	instances := []sessionInstance{}
+	for _, instance := range runningModels {
+		if !instance.ready {
+			continue
+		}
+		instances = append(instances, sessionInstance{
+			Path:     instance.entry.Path,
+			Config:   instance.configName,
+			Port:     instance.port,
+			Preset:   instance.preset,
+			Parallel: instance.parallel,
+			Args:     instance.customArgs,
+		})
+	}
+	runningModelsMu.RUnlock()
+
+	data, err := json.MarshalIndent(instances, "", "  ")
+	if err != nil {
+		return
+	}
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if err := os.WriteFile(sessionPath(), data, 0644); err != nil {
+		log.Printf("Warning: Failed to save session: %v", err)
+	}
+}
+
+// readPreviousSession loads the session file left by the last run.
+func readPreviousSession() {
+	data, err := os.ReadFile(sessionPath())
+	if err != nil {
+		return
+	}
+	var instances []sessionInstance
+	if err := json.Unmarshal(data, &instances); err != nil {
+		log.Printf("Warning: Ignoring unreadable session file %s: %v", sessionPath(), err)
+		return
+	}
+
+	sessionMu.Lock()
+	previousSession = instances
+	sessionMu.Unlock()
+	if len(instances) > 0 {
+		log.Printf("Previous session had %d instance(s)", len(instances))
+	}
+}
+
+func hasPreviousSession() bool {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	return len(previousSession) > 0
+}
+
+// restoreSession launches the instances of the previous session again, on
+// their old ports where those are free, and returns a summary line for each.
+// The session can only be restored once.
+func restoreSession() []string {
+	sessionMu.Lock()
+	instances := previousSession
+	previousSession = nil
+	sessionMu.Unlock()
+	refreshMenuState()
+
+	var summaries []string
+	for _, saved := range instances {
+		idx := findModelIndexByPath(saved.Path)
+		entry, ok := modelAt(idx)
+		if !ok {
+			summaries = append(summaries, fmt.Sprintf("%s is no longer available", filepath.Base(saved.Path)))
+			continue
+		}
+		configIdx, ok := configIndexByName(entry, saved.Config)
+		name := entry.BaseName
+		if saved.Config != "" {
+			name = saved.Config
+		}
+		if !ok {
+			summaries = append(summaries, fmt.Sprintf("%s is no longer configured", name))
+			continue
+		}
+
+		opts := launchOptions{Parallel: saved.Parallel, Preset: saved.Preset, Args: saved.Args, Port: saved.Port, NoBrowser: true}
+		if _, defined := config().Presets[opts.Preset]; opts.Preset != "" && !defined {
+			opts.Preset = ""
+		}
+		if err := loadModelWithOptions(idx, configIdx, opts); err != nil {
+			summaries = append(summaries, fmt.Sprintf("Failed to restore %s: %v", name, err))
+			continue
+		}
+		summaries = append(summaries, fmt.Sprintf("Restored %s", name))
+	}
+	for _, summary := range summaries {
+		log.Print(summary)
+	}
+	return summaries
+}
+
+// configIndexByName finds the modelSpecificArgs entry of entry called name.
+// An empty name stands for the default configuration.
+func configIndexByName(entry modelEntry, name string) (int, bool) {
+	if name == "" {
+		return -1, true
+	}
+	configIdx := 0
+	for _, cfg := range config().ModelSpecificArgs {
+		if cfg.Target != entry.BaseName {
+			continue
+		}
+		if cfg.Name == name {
+			return configIdx, true
+		}
+		configIdx++
+	}
+	return 0, false
+}
+
+// handleSession returns the previous session on GET and restores it on POST
+// to /api/session/restore.
+func handleSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+	sessionMu.Lock()
+	instances := append([]sessionInstance{}, previousSession...)
+	sessionMu.Unlock()
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: instances})
+}
+
+func handleRestoreSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+	if !hasPreviousSession() {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: "No previous session to restore"})
+		return
+	}
+	summaries := restoreSession()
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Message: strings.Join(summaries, "\n")})
+}
+
+// restoreSessionFromMenu restores the previous session for the tray item.
+func restoreSessionFromMenu() {
+	if summaries := restoreSession(); len(summaries) > 0 {
+		notify("Session Restored", strings.Join(summaries, "\n"))
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+//go:embed settings.html
+var settingsPage []byte
+
+// Settings are the options the settings page edits, as served and accepted
+// by /api/config.
+type Settings struct {
+	ModelDir             string          `json:"modelDir"`
+	BasePort             int             `json:"basePort"`
+	DefaultArgs          []string        `json:"defaultArgs"`
+	AutoLoadModels       []AutoLoadEntry `json:"autoLoadModels"`
+	NotificationsEnabled bool            `json:"notificationsEnabled"`
+}
+
+// notificationsEnabled reports whether desktop notifications are shown. They
+// are on unless notificationsEnabled is set to false.
+func notificationsEnabled() bool {
+	return config().Notifications == nil || *config().Notifications
+}
+
+func currentSettings() Settings {
+	c := config()
+	return Settings{
+		ModelDir:             c.ModelDir,
+		BasePort:             c.BasePort,
+		DefaultArgs:          append([]string{}, c.DefaultArgs...),
+		AutoLoadModels:       append([]AutoLoadEntry{}, c.AutoLoadModels...),
+		NotificationsEnabled: notificationsEnabled(),
+	}
+}
+
+func openSettings() {
+	openURL(apiBaseURL() + "/settings")
+}
+
+func handleSettingsPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(settingsPage)
+}
+
+// handleConfig serves the settings on GET and saves them on POST. Changes
+// take effect right away where possible: a new modelDir is rescanned, and
+// the other settings are read on the next load or notification. basePort
+// needs a restart.
+func handleConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: currentSettings()})
+		return
+	case http.MethodPost:
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+
+	// The API is reachable from the network and allows any origin, so only
+	// the settings page itself, opened on this machine, may write lmgo.json.
+	if !sameMachineRequest(r) {
+		writeJSON(w, http.StatusForbidden, APIResponse{Success: false, Message: "Settings can only be changed from this machine"})
+		return
+	}
+
+	var req Settings
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid request body"})
+		return
+	}
+	if err := validateSettings(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	// Start from the file rather than loadConfig, so a modelDir that no
+	// longer exists can be fixed here.
+	rescanMu.Lock()
+	loaded, err := readConfigFile(configPath)
+	if err != nil {
+		rescanMu.Unlock()
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	// modelDir and basePort may come from LMGO_MODEL_DIR, --model-dir or
+	// LMGO_BASE_PORT; the file keeps its own values unless they are edited.
+	previous := currentSettings()
+	if req.ModelDir != previous.ModelDir {
+		loaded.ModelDir = req.ModelDir
+	}
+	if req.BasePort != previous.BasePort {
+		loaded.BasePort = req.BasePort
+	}
+	loaded.DefaultArgs = append([]string{}, req.DefaultArgs...)
+	loaded.AutoLoadModels = req.AutoLoadModels
+	loaded.Notifications = &req.NotificationsEnabled
+	if err := applyConfig(loaded); err != nil {
+		rescanMu.Unlock()
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	err = saveConfig()
+	rescanMu.Unlock()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	log.Printf("Settings changed from the settings page")
+
+	message := "Settings saved"
+	if req.ModelDir != previous.ModelDir {
+		if err := refreshConfigAndModels(); err != nil {
+			message += fmt.Sprintf("; rescanning %s failed: %v", req.ModelDir, err)
+		} else {
+			message += fmt.Sprintf("; found %d models", len(modelSnapshot()))
+		}
+	}
+	if req.BasePort != previous.BasePort {
+		message += fmt.Sprintf("; restart lmgo to move the API to port %d", req.BasePort)
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Message: message, Data: currentSettings()})
+}
+
+func validateSettings(s Settings) error {
+	if err := validateArgsOverride(s.DefaultArgs); err != nil {
+		return fmt.Errorf("defaultArgs: %v", err)
+	}
+	for _, entry := range s.AutoLoadModels {
+		if strings.TrimSpace(entry.Model) == "" {
+			return fmt.Errorf("autoLoadModels contains an empty name")
+		}
+		// A new modelDir is only scanned once saved, so its names cannot be
+		// checked yet.
+		if s.ModelDir != config().ModelDir {
+			continue
+		}
+		if resolved := resolveName(entry.Model); !resolved.Matched {
+			return fmt.Errorf("autoLoadModels: %s", describeUnmatched(resolved))
+		}
+	}
+	return nil
+}
+
+// sameMachineRequest reports whether r comes from the loopback interface and,
+// when sent by a browser, from a page served by lmgo itself. The Host header
+// must name this machine too: a page on another domain that is rebound to
+// 127.0.0.1 passes the other checks with its own name as Host and Origin.
+func sameMachineRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return false
+	}
+	hostname, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		hostname = strings.Trim(r.Host, "[]")
+	}
+	if !isLoopbackHost(strings.ToLower(hostname)) {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	return origin == "" || origin == apiScheme()+"://"+r.Host
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSameMachineRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote string
+		host   string
+		origin string
+		want   bool
+	}{
+		{"local tool", "127.0.0.1:50000", "127.0.0.1:8080", "", true},
+		{"localhost page", "127.0.0.1:50000", "localhost:8080", "http://localhost:8080", true},
+		{"loopback page", "127.0.0.1:50000", "127.0.0.1:8080", "http://127.0.0.1:8080", true},
+		{"ipv6 page", "[::1]:50000", "[::1]:8080", "http://[::1]:8080", true},
+		{"host without port", "127.0.0.1:50000", "localhost", "", true},
+		{"uppercase localhost", "127.0.0.1:50000", "LOCALHOST:8080", "", true},
+		{"another machine", "192.168.1.20:50000", "192.168.1.10:8080", "", false},
+		{"page on another site", "127.0.0.1:50000", "127.0.0.1:8080", "http://evil.example", false},
+		{"rebound domain", "127.0.0.1:50000", "attacker.example:8080", "http://attacker.example:8080", false},
+		{"rebound domain without origin", "127.0.0.1:50000", "attacker.example:8080", "", false},
+		{"lan address as host", "127.0.0.1:50000", "192.168.1.10:8080", "http://192.168.1.10:8080", false},
+		{"localhost subdomain", "127.0.0.1:50000", "localhost.attacker.example:8080", "http://localhost.attacker.example:8080", false},
+		{"bad remote address", "garbage", "localhost:8080", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/config", nil)
+			r.RemoteAddr, r.Host = tt.remote, tt.host
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := sameMachineRequest(r); got != tt.want {
+				t.Errorf("sameMachineRequest = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// smokeTestTokens is how many tokens a test generates at most.
+	smokeTestTokens = 32
+	// smokeTestTimeout covers a slow first token on a model running on the
+	// CPU.
+	smokeTestTimeout = 2 * time.Minute
+)
+
+// smokeTestPrompt asks for a short reply that any working model can give.
+const smokeTestPrompt = "Count from 1 to 20, separated by spaces."
+
+// offloadPattern matches the line where llama-server reports how many layers
+// it put on the GPU.
+var offloadPattern = regexp.MustCompile(`offloaded (\d+)/(\d+) layers to GPU`)
+
+// TestResult is the outcome of a smoke test, returned by POST /api/test.
+// GPULayers is llama-server's "offloaded" count such as "33/33", or empty
+// when its output did not say.
+type TestResult struct {
+	Name            string  `json:"name"`
+	Port            int     `json:"port"`
+	FirstTokenMs    int64   `json:"firstTokenMs"`
+	TokensPerSecond float64 `json:"tokensPerSecond"`
+	Tokens          int     `json:"tokens"`
+	GPULayers       string  `json:"gpuLayers,omitempty"`
+	Reply           string  `json:"reply"`
+}
+
+// summary formats the result for notifications and messages.
+func (r TestResult) summary() string {
+	text := fmt.Sprintf("%s: first token in %d ms, %.1f tokens/s", r.Name, r.FirstTokenMs, r.TokensPerSecond)
+	switch {
+	case r.GPULayers == "":
+	case strings.HasPrefix(r.GPULayers, "0/"):
+		text += ", running on the CPU"
+	default:
+		text += fmt.Sprintf(", %s layers on the GPU", r.GPULayers)
+	}
+	return text
+}
+
+var errInstanceNotReady = errors.New("instance is still loading")
+
+// testInstance sends a tiny streamed completion to the instance on port and
+// measures the time to the first token and the generation speed, to confirm
+// the model actually answers.
+func testInstance(port int) (TestResult, error) {
+	runningModelsMu.RLock()
+	var instance *modelInstance
+	for _, candidate := range runningModels {
+		if candidate.port == port {
+			instance = candidate
+		}
+	}
+	ready := instance != nil && instance.ready
+	runningModelsMu.RUnlock()
+	if instance == nil {
+		return TestResult{}, fmt.Errorf("no instance running on port %d", port)
+	}
+	if !ready {
+		return TestResult{}, errInstanceNotReady
+	}
+	result := TestResult{Name: displayName(instance), Port: port, GPULayers: offloadedLayers(port)}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"messages":     []map[string]string{{"role": "user", "content": smokeTestPrompt}},
+		"max_tokens":   smokeTestTokens,
+		"temperature":  0,
+		"stream":       true,
+		"cache_prompt": false,
+	})
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://127.0.0.1:%d/v1/chat/completions", port), bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	authorizeServerRequest(req)
+
+	client := &http.Client{Timeout: smokeTestTimeout}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("completion failed: %s", resp.Status)
+	}
+
+	var reply strings.Builder
+	var first, last time.Time
+	var serverRate float64
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		data = strings.TrimSpace(data)
+		if !ok || data == "" || data == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Timings *struct {
+				PredictedPerSecond float64 `json:"predicted_per_second"`
+			} `json:"timings"`
+		}
+		if json.Unmarshal([]byte(data), &chunk) != nil {
+			continue
+		}
+		if chunk.Timings != nil {
+			serverRate = chunk.Timings.PredictedPerSecond
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		last = time.Now()
+		if first.IsZero() {
+			first = last
+		}
+		result.Tokens++
+		reply.WriteString(chunk.Choices[0].Delta.Content)
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("reading the reply failed: %v", err)
+	}
+	if result.Tokens == 0 {
+		return result, errors.New("the model replied with no tokens")
+	}
+
+	result.Reply = strings.TrimSpace(reply.String())
+	result.FirstTokenMs = first.Sub(start).Milliseconds()
+	result.TokensPerSecond = serverRate
+	// Without llama-server's timings, every streamed chunk counts as a token.
+	if serverRate == 0 && result.Tokens > 1 {
+		result.TokensPerSecond = float64(result.Tokens-1) / last.Sub(first).Seconds()
+	}
+	return result, nil
+}
+
+// offloadedLayers finds how many layers the instance on port put on the GPU
+// in its output, e.g. "33/33".
+func offloadedLayers(port int) string {
+	logSourcesMu.Lock()
+	source := logSources[port]
+	logSourcesMu.Unlock()
+	if source == nil {
+		return ""
+	}
+	lines, _ := source.logs.tail(maxLogLines)
+	for _, line := range lines {
+		if match := offloadPattern.FindStringSubmatch(line); match != nil {
+			return match[1] + "/" + match[2]
+		}
+	}
+	return ""
+}
+
+// testFromMenu runs a smoke test for the tray and shows the result as a
+// notification.
+func testFromMenu(port int) {
+	result, err := testInstance(port)
+	if err != nil {
+		notify("Test Failed", fmt.Sprintf("Port %d: %v", port, err))
+		return
+	}
+	notify("Test Passed", result.summary())
+}
+
+// handleTest runs a smoke test on the instance given by port and answers
+// with a TestResult.
+func handleTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+	port, err := strconv.Atoi(r.URL.Query().Get("port"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Message: "Invalid port"})
+		return
+	}
+
+	result, err := testInstance(port)
+	switch {
+	case errors.Is(err, errInstanceNotReady):
+		writeJSON(w, http.StatusConflict, APIResponse{Success: false, Message: fmt.Sprintf("Instance on port %d is still loading", port)})
+	case err != nil && result.Name == "":
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Message: fmt.Sprintf("No instance running on port %d", port)})
+	case err != nil:
+		writeJSON(w, http.StatusBadGateway, APIResponse{Success: false, Message: fmt.Sprintf("Test of %s failed: %v", result.Name, err)})
+	default:
+		writeJSON(w, http.StatusOK, APIResponse{Success: true, Message: result.summary(), Data: result})
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// statsSaveInterval is how often changed usage statistics are written
+	// to disk; they are also written when lmgo exits.
+	statsSaveInterval = time.Minute
+
+	// maxUsageTail is how much of the end of a response is kept to find the
+	// token counts in. They come last, in the final chunk of a stream.
+	maxUsageTail = 256 << 10
+)
+
+// ModelStats is the usage of one model through the OpenAI-compatible
+// endpoint, as returned by GET /api/stats. LastLoaded is when an instance of
+// it last finished loading.
+type ModelStats struct {
+	Name             string    `json:"name"`
+	Requests         int64     `json:"requests"`
+	Errors           int64     `json:"errors"`
+	PromptTokens     int64     `json:"promptTokens"`
+	CompletionTokens int64     `json:"completionTokens"`
+	TotalLatencyMs   int64     `json:"totalLatencyMs"`
+	AvgLatencyMs     int64     `json:"avgLatencyMs"`
+	LastUsed         time.Time `json:"lastUsed,omitzero"`
+	LastLoaded       time.Time `json:"lastLoaded,omitzero"`
+}
+
+var (
+	usageStats   = map[string]*ModelStats{}
+	usageStatsMu sync.Mutex
+	statsDirty   bool
+)
+
+// statsPath is the statistics file next to the config file, e.g.
+// lmgo-stats.json for lmgo.json.
+func statsPath() string {
+	name := strings.TrimSuffix(configName(), filepath.Ext(configName()))
+	return filepath.Join(filepath.Dir(configPath), name+"-stats.json")
+}
+
+// loadStats reads the statistics of previous runs.
+func loadStats() {
+	data, err := os.ReadFile(statsPath())
+	if err != nil {
+		return
+	}
+	var stats []ModelStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		log.Printf("Warning: Ignoring unreadable statistics file %s: %v", statsPath(), err)
+		return
+	}
+
+	usageStatsMu.Lock()
+	defer usageStatsMu.Unlock()
+	for _, s := range stats {
+		usageStats[s.Name] = &s
+	}
+}
+
+// saveStats writes the statistics if they changed since the last save.
+func saveStats() {
+	usageStatsMu.Lock()
+	if !statsDirty {
+		usageStatsMu.Unlock()
+		return
+	}
+	stats := statsList()
+	statsDirty = false
+	usageStatsMu.Unlock()
+
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(statsPath(), data, 0644); err != nil {
+		log.Printf("Warning: Failed to save statistics: %v", err)
+	}
+}
+
+// persistStats saves the statistics periodically until lmgo exits.
+func persistStats() {
+	for range time.Tick(statsSaveInterval) {
+		saveStats()
+	}
+}
+
+// statsList returns the statistics sorted by model name. Callers must hold
+// usageStatsMu.
+func statsList() []ModelStats {
+	stats := []ModelStats{}
+	for _, s := range usageStats {
+		entry := *s
+		if entry.Requests > 0 {
+			entry.AvgLatencyMs = entry.TotalLatencyMs / entry.Requests
+		}
+		stats = append(stats, entry)
+	}
+	slices.SortFunc(stats, func(a, b ModelStats) int { return strings.Compare(a.Name, b.Name) })
+	return stats
+}
+
+// recordUsage adds a request that took latency to the statistics of a model.
+func recordUsage(name string, latency time.Duration, failed bool, promptTokens, completionTokens int64) {
+	usageStatsMu.Lock()
+	defer usageStatsMu.Unlock()
+
+	s := usageStats[name]
+	if s == nil {
+		s = &ModelStats{Name: name}
+		usageStats[name] = s
+	}
+	s.Requests++
+	if failed {
+		s.Errors++
+	}
+	s.PromptTokens += promptTokens
+	s.CompletionTokens += completionTokens
+	s.TotalLatencyMs += latency.Milliseconds()
+	s.LastUsed = time.Now()
+	statsDirty = true
+}
+
+// recordLoad notes that an instance of a model finished loading.
+func recordLoad(name string) {
+	usageStatsMu.Lock()
+	defer usageStatsMu.Unlock()
+
+	s := usageStats[name]
+	if s == nil {
+		s = &ModelStats{Name: name}
+		usageStats[name] = s
+	}
+	s.LastLoaded = time.Now()
+	statsDirty = true
+}
+
+// lastUsed is the latest load of or request to a model, or the zero time.
+func lastUsed(name string) time.Time {
+	usageStatsMu.Lock()
+	defer usageStatsMu.Unlock()
+
+	s := usageStats[name]
+	if s == nil {
+		return time.Time{}
+	}
+	if s.LastLoaded.After(s.LastUsed) {
+		return s.LastLoaded
+	}
+	return s.LastUsed
+}
+
+// usageTap keeps the status and the end of a proxied response, to read the
+// token counts from once it has been forwarded.
+type usageTap struct {
+	status int
+	tail   []byte
+}
+
+// tapBody records resp in the tap while the proxy forwards it.
+func (t *usageTap) tapBody(resp *http.Response) {
+	t.status = resp.StatusCode
+	resp.Body = &tapReader{ReadCloser: resp.Body, tap: t}
+}
+
+type tapReader struct {
+	io.ReadCloser
+	tap *usageTap
+}
+
+func (r *tapReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.tap.tail = append(r.tap.tail, p[:n]...)
+	if over := len(r.tap.tail) - maxUsageTail; over > 0 {
+		r.tap.tail = r.tap.tail[over:]
+	}
+	return n, err
+}
+
+// tokens finds the token counts of the response: the OpenAI usage object of
+// a JSON answer or of the last chunk of a stream that has one, or
+// llama-server's timings, which streams carry without include_usage.
+func (t *usageTap) tokens() (prompt, completion int64) {
+	var answer struct {
+		Usage *struct {
+			PromptTokens     int64 `json:"prompt_tokens"`
+			CompletionTokens int64 `json:"completion_tokens"`
+		} `json:"usage"`
+		Timings *struct {
+			PromptN    int64 `json:"prompt_n"`
+			PredictedN int64 `json:"predicted_n"`
+		} `json:"timings"`
+	}
+	parse := func(data []byte) bool {
+		answer.Usage, answer.Timings = nil, nil
+		if json.Unmarshal(data, &answer) != nil {
+			return false
+		}
+		switch {
+		case answer.Usage != nil:
+			prompt, completion = answer.Usage.PromptTokens, answer.Usage.CompletionTokens
+		case answer.Timings != nil:
+			prompt, completion = answer.Timings.PromptN, answer.Timings.PredictedN
+		default:
+			return false
+		}
+		return true
+	}
+
+	if parse(t.tail) {
+		return prompt, completion
+	}
+	lines := bytes.Split(t.tail, []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		line, ok := bytes.CutPrefix(bytes.TrimSpace(lines[i]), []byte("data:"))
+		if ok && parse(bytes.TrimSpace(line)) {
+			return prompt, completion
+		}
+	}
+	return 0, 0
+}
+
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Message: "Method not allowed"})
+		return
+	}
+	usageStatsMu.Lock()
+	stats := statsList()
+	usageStatsMu.Unlock()
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: stats})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// selfSignedValidity is how long a generated certificate is valid.
+	selfSignedValidity = 2 * 365 * 24 * time.Hour
+	// selfSignedRenewal is how long before it expires a generated
+	// certificate is replaced.
+	selfSignedRenewal = 30 * 24 * time.Hour
+)
+
+// certificatePaths returns the certificate and key the management API is
+// served with: tlsCert and tlsKey, or the self-signed pair lmgo keeps next to
+// its config file.
+func certificatePaths() (certFile, keyFile string) {
+	c := config()
+	if c.TLSCert != "" {
+		return c.TLSCert, c.TLSKey
+	}
+	name := strings.TrimSuffix(configName(), filepath.Ext(configName()))
+	dir := filepath.Dir(configPath)
+	return filepath.Join(dir, name+"-cert.pem"), filepath.Join(dir, name+"-key.pem")
+}
+
+// apiTLSConfig loads the certificate for HTTPS, generating a self-signed one
+// first when no tlsCert is configured.
+func apiTLSConfig() (*tls.Config, error) {
+	certFile, keyFile := certificatePaths()
+	if config().TLSCert == "" {
+		if err := ensureSelfSigned(certFile, keyFile); err != nil {
+			return nil, fmt.Errorf("cannot create a self-signed certificate: %v", err)
+		}
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf != nil {
+		sum := sha256.Sum256(cert.Leaf.Raw)
+		log.Printf("HTTPS certificate %s, SHA-256 fingerprint %s", certFile, hex.EncodeToString(sum[:]))
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// ensureSelfSigned keeps a usable self-signed pair and replaces one that is
+// missing, unreadable or about to expire.
+func ensureSelfSigned(certFile, keyFile string) error {
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil && cert.Leaf != nil &&
+		time.Until(cert.Leaf.NotAfter) > selfSignedRenewal {
+		return nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "lmgo", Organization: []string{"lmgo"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           localAddresses(),
+	}
+	if hostname != "" && hostname != "localhost" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	log.Printf("Created self-signed certificate %s", certFile)
+	return nil
+}
+
+// localAddresses lists the loopback and interface addresses of this machine,
+// so that the certificate names it however it is reached on the LAN.
+func localAddresses() []net.IP {
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ips
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return ips
+}
+
+// apiScheme is "https" when the management API is served over TLS.
+func apiScheme() string {
+	if apiListen.tls {
+		return "https"
+	}
+	return "http"
+}
+
+// validateTLS checks that tlsCert and tlsKey come as a pair of readable
+// files.
+func validateTLS(c *Config) error {
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("tlsCert and tlsKey: set both, or neither for a self-signed certificate")
+	}
+	if !c.TLS || c.TLSCert == "" {
+		return nil
+	}
+	for field, path := range map[string]string{"tlsCert": c.TLSCert, "tlsKey": c.TLSKey} {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("%s: %v", field, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// readConfigFile parses the config file at path. Syntax errors, unknown keys and values of
+// the wrong type are reported with the line and the field they concern,
+// rather than as a bare decoding error.
+func readConfigFile(path string) (Config, error) {
+	var loaded Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return loaded, fmt.Errorf("failed to read config file: %v", err)
+	}
+	if err := checkConfigKeys(data); err != nil {
+		return loaded, err
+	}
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return loaded, describeJSONError(data, err)
+	}
+	return loaded, nil
+}
+
+// checkConfigKeys rejects keys lmgo does not know at the top level and in
+// modelSpecificArgs, webhooks and accessRules entries, which are most often typos that would otherwise
+// be ignored silently.
+func checkConfigKeys(data []byte) error {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return describeJSONError(data, err)
+	}
+	if err := checkKeys(data, "", top, reflect.TypeFor[Config]()); err != nil {
+		return err
+	}
+
+	lists := map[string]reflect.Type{
+		"modelSpecificArgs": reflect.TypeFor[ModelConfig](),
+		"webhooks":          reflect.TypeFor[Webhook](),
+		"accessRules":       reflect.TypeFor[AccessRule](),
+		"autoLoadModels":    reflect.TypeFor[AutoLoadEntry](),
+	}
+	for key, raw := range top {
+		for name, t := range lists {
+			if !strings.EqualFold(key, name) {
+				continue
+			}
+			var entries []json.RawMessage
+			if json.Unmarshal(raw, &entries) != nil {
+				// Reported with its type by the full decode.
+				continue
+			}
+			for i, rawEntry := range entries {
+				// autoLoadModels also takes plain names.
+				var entry map[string]json.RawMessage
+				if json.Unmarshal(rawEntry, &entry) != nil {
+					continue
+				}
+				if err := checkKeys(data, fmt.Sprintf("%s[%d].", name, i), entry, t); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkKeys reports the first key of object that is not a field of t,
+// matching case-insensitively as encoding/json does.
+func checkKeys(data []byte, prefix string, object map[string]json.RawMessage, t reflect.Type) error {
+	known := jsonKeys(t)
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		if slices.ContainsFunc(known, func(k string) bool { return strings.EqualFold(k, key) }) {
+			continue
+		}
+		message := fmt.Sprintf("unknown key %q", prefix+key)
+		if suggestion := closestKey(key, known); suggestion != "" {
+			message += fmt.Sprintf(", did you mean %q?", prefix+suggestion)
+		}
+		return fmt.Errorf("%s%s: %s", configName(), keyLine(data, key), message)
+	}
+	return nil
+}
+
+// jsonKeys returns the JSON names of the fields of struct type t.
+func jsonKeys(t reflect.Type) []string {
+	var keys []string
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// closestKey returns the known key nearest to key by edit distance, if it is
+// close enough to be a likely typo.
+func closestKey(key string, known []string) string {
+	best, bestDistance := "", 4
+	for _, candidate := range known {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// keyLine returns " line N" for the first occurrence of key in data, or an
+// empty string if it cannot be found.
+func keyLine(data []byte, key string) string {
+	quoted, _ := json.Marshal(key)
+	offset := bytes.Index(data, quoted)
+	if offset < 0 {
+		return ""
+	}
+	line, _ := position(data, int64(offset))
+	return fmt.Sprintf(" line %d", line)
+}
+
+// position converts a byte offset in data to a line and column, both from 1.
+func position(data []byte, offset int64) (int, int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return line, column
+}
+
+var indexPattern = regexp.MustCompile(`\.(\d+)`)
+
+// describeJSONError turns a decoding error into one that names the position
+// and, for values of the wrong type, the field and the expected type.
+func describeJSONError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		line, column := position(data, syntaxErr.Offset)
+		return fmt.Errorf("%s line %d, column %d: %v", configName(), line, column, syntaxErr)
+	case errors.As(err, &typeErr):
+		line, _ := position(data, typeErr.Offset)
+		if typeErr.Field == "" {
+			return fmt.Errorf("%s must contain a JSON object, not %s", configName(), article(typeErr.Value))
+		}
+		field := indexPattern.ReplaceAllString(typeErr.Field, "[$1]")
+		return fmt.Errorf("%s line %d: %s must be %s, not %s", configName(), line, field, article(jsonTypeName(typeErr.Type)), article(typeErr.Value))
+	}
+	return fmt.Errorf("failed to parse config file: %v", err)
+}
+
+// jsonTypeName names the JSON type that decodes into t.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "whole number"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	}
+	return "object"
+}
+
+func article(name string) string {
+	switch name {
+	case "bool":
+		return "true or false"
+	case "array", "object":
+		return "an " + name
+	}
+	return "a " + name
+}
+
+// validateModelSpecificArgs checks the fields of each modelSpecificArgs entry.
+func validateModelSpecificArgs(c *Config) error {
+	for i, cfg := range c.ModelSpecificArgs {
+		field := fmt.Sprintf("modelSpecificArgs[%d]", i)
+		switch {
+		case strings.TrimSpace(cfg.Name) == "":
+			return fmt.Errorf("%s.name: missing the name shown in the menu", field)
+		case strings.TrimSpace(cfg.Target) == "":
+			return fmt.Errorf("%s.target: missing the model file name for %q", field, cfg.Name)
+		case strings.HasSuffix(strings.ToLower(cfg.Target), ".gguf"):
+			return fmt.Errorf("%s.target: %q must be the file name without .gguf", field, cfg.Target)
+		case cfg.IdleTimeout < -1:
+			return fmt.Errorf("%s.idleTimeoutMinutes: invalid value %d", field, cfg.IdleTimeout)
+		case cfg.CrashRestarts < -1:
+			return fmt.Errorf("%s.crashRestarts: invalid value %d", field, cfg.CrashRestarts)
+		}
+		if err := validateAutoSetting(field+".ctxSize", cfg.CtxSize); err != nil {
+			return err
+		}
+		if err := validateAutoSetting(field+".gpuLayers", cfg.GPULayers); err != nil {
+			return err
+		}
+		for _, arg := range cfg.Args {
+			if strings.ContainsAny(arg, "\x00\r\n") {
+				return fmt.Errorf("%s.args: %q contains a control character", field, arg)
+			}
+		}
+	}
+	return nil
+}
+
+// validateAutoSetting checks a ctxSize or gpuLayers value, which is "auto",
+// a non-negative number or empty.
+func validateAutoSetting(field, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "auto") {
+		return nil
+	}
+	if n, err := strconv.Atoi(value); err != nil || n < 0 {
+		return fmt.Errorf("%s: invalid value %q, expected \"auto\" or a number", field, value)
+	}
+	return nil
+}
+
+// validateCounts rejects negative values for settings that count seconds,
+// minutes, megabytes, files or restarts.
+func validateCounts(c *Config) error {
+	counts := []struct {
+		field string
+		value int
+	}{
+		{"startupDelaySeconds", c.StartupDelay},
+		{"waitForGPUSeconds", c.WaitForGPU},
+		{"idleTimeoutMinutes", c.IdleTimeout},
+		{"crashRestarts", c.CrashRestarts},
+		{"logMaxSizeMB", c.LogMaxSizeMB},
+		{"logMaxFiles", c.LogMaxFiles},
+	}
+	for _, count := range counts {
+		if count.value < 0 {
+			return fmt.Errorf("%s: invalid value %d, expected 0 or more", count.field, count.value)
+		}
+	}
+	return nil
+}
+
+// validateWebhooks checks that each webhook has a known type, what that type
+// needs to be sent, and known events.
+func validateWebhooks(c *Config) error {
+	for i, hook := range c.Webhooks {
+		field := fmt.Sprintf("webhooks[%d]", i)
+		switch webhookType(hook) {
+		case "telegram":
+			if hook.BotToken == "" || hook.ChatID == "" {
+				return fmt.Errorf("%s: telegram needs botToken and chatId", field)
+			}
+		case "json", "ntfy", "discord":
+			if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%s.url: invalid URL %q, expected http:// or https://", field, hook.URL)
+			}
+		default:
+			return fmt.Errorf("%s.type: unknown type %q, expected json, ntfy, discord or telegram", field, hook.Type)
+		}
+		for _, event := range hook.Events {
+			if !slices.Contains(webhookEvents, event) {
+				return fmt.Errorf("%s.events: unknown event %q, expected one of %s", field, event, strings.Join(webhookEvents, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// validatePort checks that a port setting is in the valid range.
+func validatePort(field string, port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%s: invalid port %d, expected 1-65535", field, port)
+	}
+	return nil
+}
+
+// validateModelDir checks that modelDir names an existing directory.
+func validateModelDir(c *Config) error {
+	info, err := os.Stat(c.ModelDir)
+	switch {
+	case strings.TrimSpace(c.ModelDir) == "":
+		return fmt.Errorf("modelDir: missing the directory to scan for models")
+	case os.IsNotExist(err):
+		return fmt.Errorf("modelDir: %q does not exist", c.ModelDir)
+	case err != nil:
+		return fmt.Errorf("modelDir: %v", err)
+	case !info.IsDir():
+		return fmt.Errorf("modelDir: %q is not a directory", c.ModelDir)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultServerContext is the --ctx-size llama-server uses when none is
+// given.
+const defaultServerContext = 4096
+
+var vramCheckModes = []string{"block", "warn", "off"}
+
+// errNotEnoughVRAM marks a load refused because the model cannot fit.
+var errNotEnoughVRAM = errors.New("not enough free VRAM")
+
+// vramEstimate is the GPU memory a launch needs at least. Compute buffers
+// are left out, so a load that exceeds the free VRAM is sure to fail while
+// one that stays below may still run short.
+type vramEstimate struct {
+	Weights int64
+	KVCache int64
+	Context int
+}
+
+func (e vramEstimate) total() int64 {
+	return e.Weights + e.KVCache
+}
+
+func validateVRAMCheck(mode string) error {
+	if mode != "" && !slices.Contains(vramCheckModes, mode) {
+		return fmt.Errorf("invalid vramCheck %q, expected one of %s", mode, strings.Join(vramCheckModes, ", "))
+	}
+	return nil
+}
+
+// estimateVRAM works out the VRAM a launch with args needs from the model's
+// size and header: the share of the weights given by -ngl, the KV cache for
+// the context unless it is kept in system memory, and the --mmproj file. It
+// returns false when nothing is offloaded or the header cannot be read.
+func estimateVRAM(entry modelEntry, args []string) (vramEstimate, bool) {
+	meta, err := cachedGGUFMetadata(firstShardPath(entry.Path))
+	if err != nil || meta.BlockCount == 0 {
+		return vramEstimate{}, false
+	}
+
+	share := offloadShare(meta, args)
+	if share == 0 {
+		return vramEstimate{}, false
+	}
+
+	est := vramEstimate{
+		Weights: int64(float64(modelFileSize(entry.Path))*share) + mmprojSize(args),
+		Context: argsContext(meta, args),
+	}
+	if kvOffloaded(args) {
+		keyBytes, valueBytes := cacheTypeSizes(args)
+		est.KVCache = int64(kvBytesPerToken(meta, keyBytes, valueBytes) * float64(est.Context) * share)
+	}
+	return est, true
+}
+
+// offloadShare is the fraction of the layers -ngl puts on the GPU. Without
+// -ngl llama-server offloads everything it can.
+func offloadShare(meta *ggufMetadata, args []string) float64 {
+	if meta.BlockCount == 0 {
+		return 0
+	}
+	layers := meta.BlockCount
+	if value, ok := argValue(args, "-ngl", "--n-gpu-layers", "--gpu-layers"); ok {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			layers = min(n, meta.BlockCount)
+		}
+	}
+	return float64(layers) / float64(meta.BlockCount)
+}
+
+// argsContext is the context a launch with args gets: --ctx-size, the
+// trained length for 0, or llama-server's default.
+func argsContext(meta *ggufMetadata, args []string) int {
+	value, ok := argValue(args, "-c", "--ctx-size")
+	if !ok {
+		return defaultServerContext
+	}
+	n, err := strconv.Atoi(value)
+	switch {
+	case err != nil:
+		return defaultServerContext
+	case n == 0 && meta.ContextLength > 0:
+		return meta.ContextLength
+	}
+	return max(n, 1)
+}
+
+func kvOffloaded(args []string) bool {
+	return !slices.Contains(args, "--no-kv-offload") && !slices.Contains(args, "-nkvo")
+}
+
+func mmprojSize(args []string) int64 {
+	if path, ok := argValue(args, "-mm", "--mmproj"); ok {
+		if info, err := os.Stat(path); err == nil {
+			return info.Size()
+		}
+	}
+	return 0
+}
+
+// freeVRAM returns the unused memory of all GPUs together, since layers are
+// split across them. It returns false where VRAM cannot be read.
+func freeVRAM() (int64, bool) {
+	gpus := gpuUsage()
+	if len(gpus) == 0 {
+		return 0, false
+	}
+	var free int64
+	for _, gpu := range gpus {
+		free += int64(gpu.Total - min(gpu.Used, gpu.Total))
+	}
+	return free, true
+}
+
+// checkVRAM compares the estimate for a launch with the free VRAM. When it
+// cannot fit, vramCheck decides: "block" refuses the load unless forced, or,
+// for a load chosen in the tray, unless the user confirms; "warn" only
+// notifies; "off" skips the check.
+func checkVRAM(entry modelEntry, args []string, opts launchOptions) error {
+	if config().VRAMCheck == "off" {
+		return nil
+	}
+	est, ok := estimateVRAM(entry, args)
+	if !ok {
+		return nil
+	}
+	free, ok := freeVRAM()
+	if !ok || est.total() <= free {
+		return nil
+	}
+
+	message := fmt.Sprintf("%s needs at least %s of VRAM (%s of weights, %s of KV cache for %d tokens) but only %s is free",
+		entry.BaseName, formatGB(est.total()), formatGB(est.Weights), formatGB(est.KVCache), est.Context, formatGB(free))
+	log.Print(message)
+
+	switch {
+	case config().VRAMCheck == "warn":
+		notify("Model May Not Fit", message)
+		return nil
+	case opts.Force:
+		return nil
+	case opts.FromTray && !headless && confirm("lmgo", message+". Load anyway?", false):
+		return nil
+	}
+	notify("Model Load Refused", message)
+	return fmt.Errorf("%w: %s", errNotEnoughVRAM, message)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// healthCheckInterval is how often ready instances are checked on
+	// /health.
+	healthCheckInterval = 30 * time.Second
+
+	// unhealthyAfter is how many checks in a row an instance has to fail
+	// before it is reported as not responding.
+	unhealthyAfter = 3
+)
+
+// watchInstanceHealth checks the running instances on /health so that one
+// that hangs, e.g. on a stuck GPU, does not go unnoticed. Instances that stop
+// answering are marked in the menu and the API, and restarted when
+// restartUnhealthyEnabled is set.
+func watchInstanceHealth() {
+	client := &http.Client{Timeout: 10 * time.Second}
+	for range time.Tick(healthCheckInterval) {
+		for _, instance := range readyInstances() {
+			url := fmt.Sprintf("http://127.0.0.1:%d/health", instance.port)
+			recordHealth(instance, serverReady(client, url))
+		}
+	}
+}
+
+func readyInstances() []*modelInstance {
+	runningModelsMu.RLock()
+	defer runningModelsMu.RUnlock()
+
+	var ready []*modelInstance
+	for _, instance := range runningModels {
+		if instance.ready {
+			ready = append(ready, instance)
+		}
+	}
+	return ready
+}
+
+// recordHealth updates the health of instance with the result of a check and
+// reports a change to the user.
+func recordHealth(instance *modelInstance, ok bool) {
+	runningModelsMu.Lock()
+	wasUnhealthy := instance.unhealthy
+	instance.lastHealthCheck = time.Now()
+	if ok {
+		instance.healthFailures = 0
+	} else {
+		instance.healthFailures++
+	}
+	unhealthy := instance.healthFailures >= unhealthyAfter
+	instance.unhealthy = unhealthy
+	runningModelsMu.Unlock()
+
+	if unhealthy == wasUnhealthy || !isRegistered(instance) {
+		return
+	}
+	refreshMenuState()
+
+	name := displayName(instance)
+	if !unhealthy {
+		log.Printf("%s on port %d is responding again", name, instance.port)
+		notify("Model Responding Again", fmt.Sprintf("%s on port %d is responding again", name, instance.port))
+		return
+	}
+
+	log.Printf("%s on port %d failed %d health checks in a row", name, instance.port, unhealthyAfter)
+	if !config().RestartUnhealthy {
+		notify("Model Not Responding", fmt.Sprintf("%s on port %d is not responding. Unload it or enable restartUnhealthyEnabled.", name, instance.port))
+		return
+	}
+	notify("Model Not Responding", fmt.Sprintf("%s on port %d is not responding and is being restarted", name, instance.port))
+	go restartUnhealthy(instance)
+}
+
+// restartUnhealthy replaces an instance that stopped responding with a new
+// one launched the same way on the same port.
+func restartUnhealthy(instance *modelInstance) {
+	if !unloadInstance(instance.port) {
+		return
+	}
+	name := displayName(instance)
+	idx := findModelIndexByPath(instance.entry.Path)
+	if idx < 0 {
+		notify("Model Stopped", fmt.Sprintf("%s is no longer available and was not restarted", name))
+		return
+	}
+
+	opts := launchOptions{
+		Parallel:  instance.parallel,
+		Preset:    instance.preset,
+		Args:      instance.customArgs,
+		Port:      instance.port,
+		NoBrowser: true,
+		Restarts:  instance.restarts + 1,
+	}
+	if err := loadModelWithOptions(idx, instance.configIndex, opts); err != nil {
+		log.Printf("Failed to restart %s: %v", name, err)
+	}
+}
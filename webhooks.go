@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Webhook is an endpoint told about lifecycle events, so that a machine
+// without a desktop can alert a phone. Type is "json" (the default), "ntfy",
+// "discord" or "telegram"; Telegram takes a bot token and chat ID instead of
+// a URL. Without events, every event is sent.
+type Webhook struct {
+	Type     string   `json:"type,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	BotToken string   `json:"botToken,omitempty"`
+	ChatID   string   `json:"chatId,omitempty"`
+	Events   []string `json:"events,omitempty"`
+}
+
+// The events webhooks can subscribe to.
+const (
+	eventModelLoaded    = "modelLoaded"
+	eventModelCrashed   = "modelCrashed"
+	eventAutoLoadFailed = "autoLoadFailed"
+	eventShutdown       = "shutdown"
+)
+
+var webhookEvents = []string{eventModelLoaded, eventModelCrashed, eventAutoLoadFailed, eventShutdown}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookPayload is the body of a "json" webhook.
+type webhookPayload struct {
+	Event   string    `json:"event"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Host    string    `json:"host,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// fireWebhooks sends an event to the webhooks subscribed to it, in the
+// background. It returns a function that waits until they are sent, for
+// events such as shutdown that must not be cut off.
+func fireWebhooks(event, title, message string) (wait func()) {
+	host, _ := os.Hostname()
+	payload := webhookPayload{Event: event, Title: title, Message: message, Host: host, Time: time.Now()}
+
+	var wg sync.WaitGroup
+	for _, hook := range config().Webhooks {
+		if len(hook.Events) > 0 && !slices.Contains(hook.Events, event) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sendWebhook(hook, payload); err != nil {
+				log.Printf("Warning: %s webhook for %s failed: %v", webhookType(hook), event, err)
+			}
+		}()
+	}
+	return wg.Wait
+}
+
+func webhookType(hook Webhook) string {
+	if hook.Type == "" {
+		return "json"
+	}
+	return strings.ToLower(hook.Type)
+}
+
+// sendWebhook posts an event in the format the webhook's service expects.
+func sendWebhook(hook Webhook, payload webhookPayload) error {
+	text := payload.Title + ": " + payload.Message
+	if payload.Host != "" {
+		text = fmt.Sprintf("[%s] %s", payload.Host, text)
+	}
+
+	var req *http.Request
+	var err error
+	switch webhookType(hook) {
+	case "ntfy":
+		req, err = http.NewRequest(http.MethodPost, hook.URL, strings.NewReader(payload.Message))
+		if err == nil {
+			req.Header.Set("Title", payload.Title)
+			req.Header.Set("Tags", "lmgo,"+payload.Event)
+		}
+	case "discord":
+		req, err = jsonRequest(hook.URL, map[string]string{"content": text})
+	case "telegram":
+		endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", url.PathEscape(hook.BotToken))
+		req, err = jsonRequest(endpoint, map[string]string{"chat_id": hook.ChatID, "text": text})
+	default:
+		req, err = jsonRequest(hook.URL, payload)
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		// The error quotes the URL, which holds the bot token for Telegram.
+		if webhookType(hook) == "telegram" {
+			return fmt.Errorf("request to api.telegram.org failed")
+		}
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("answered %s", resp.Status)
+	}
+	return nil
+}
+
+func jsonRequest(endpoint string, body any) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}